@@ -50,7 +50,11 @@ func SessionFields() []*service.ConfigField {
 				Optional().Advanced(),
 			service.NewStringField("role_external_id").
 				Description("An external ID to provide when assuming a role.").
-				Optional().Advanced()).
+				Optional().Advanced(),
+			service.NewStringField("web_identity_token_file").
+				Description("The path of a file containing an OpenID Connect token that is exchanged for the credentials of `role` via `AssumeRoleWithWebIdentity`, such as the service account token mounted into pods by https://docs.aws.amazon.com/eks/latest/userguide/iam-roles-for-service-accounts.html[IAM roles for service accounts^] on EKS. The file is read again whenever the credentials are refreshed. This takes precedence over any web identity found by the default credentials chain, which removes ambiguity when a pod has access to multiple identities, and requires `role` to be set. An external ID cannot be given with `role_external_id` when exchanging a web identity token, and so it cannot be combined with this field, nor can `from_ec2_role`.").
+				Example("/var/run/secrets/eks.amazonaws.com/serviceaccount/token").
+				Optional().Advanced().Version("4.64.0")).
 			Advanced().
 			Optional().
 			Description("Optional manual configuration of AWS credentials to use. More information can be found in xref:guides:cloud/aws.adoc[]."),
@@ -17,20 +17,28 @@ package aws
 import (
 	"container/list"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/gofrs/uuid/v5"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/Jeffail/shutdown"
 
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/aws/config"
@@ -38,23 +46,164 @@ import (
 
 const (
 	// SQS Input Fields
-	sqsiFieldURL                 = "url"
-	sqsiFieldWaitTimeSeconds     = "wait_time_seconds"
-	sqsiFieldDeleteMessage       = "delete_message"
-	sqsiFieldResetVisibility     = "reset_visibility"
-	sqsiFieldMaxNumberOfMessages = "max_number_of_messages"
-	sqsiFieldMaxOutstanding      = "max_outstanding_messages"
-	sqsiFieldMessageTimeout      = "message_timeout"
+	sqsiFieldURL                    = "url"
+	sqsiFieldWaitTimeSeconds        = "wait_time_seconds"
+	sqsiFieldDeleteMessage          = "delete_message"
+	sqsiFieldResetVisibility        = "reset_visibility"
+	sqsiFieldMaxNumberOfMessages    = "max_number_of_messages"
+	sqsiFieldMaxOutstanding         = "max_outstanding_messages"
+	sqsiFieldMessageTimeout         = "message_timeout"
+	sqsiFieldMessageTimeoutOverride = "message_timeout_override"
+	sqsiFieldFilter                 = "filter"
+	sqsiFieldDropFiltered           = "drop_filtered"
+	sqsiFieldFilteredVisibility     = "filtered_visibility"
+	sqsiFieldMaxMessageAge          = "max_message_age"
+	sqsiFieldRefreshConcurrency     = "refresh_concurrency"
+	sqsiFieldDedupeTTL              = "dedupe_ttl"
+	sqsiFieldDedupeKey              = "dedupe_key"
+	sqsiFieldDedupeMaxEntries       = "dedupe_max_entries"
+	sqsiFieldCoerceAttributeTypes   = "coerce_attribute_types"
+	sqsiFieldBodyMetadataKey        = "body_metadata_key"
+	sqsiFieldAckCheckpoint          = "ack_checkpoint"
+	sqsiFieldDeliveryBatchHint      = "delivery_batch_hint"
+	sqsiFieldPreservePollOrder      = "preserve_poll_order"
+	sqsiFieldQuarantineThreshold    = "quarantine_threshold"
+	sqsiFieldQuarantineAction       = "quarantine_action"
+	sqsiFieldQuarantineVisibility   = "quarantine_visibility"
+	sqsiFieldQuarantineMaxEntries   = "quarantine_max_entries"
+	sqsiFieldReceiveCountWindow     = "receive_count_window"
+	sqsiFieldUserAgentSuffix        = "user_agent_suffix"
+	sqsiFieldClientRebuildThreshold = "client_rebuild_threshold"
+	sqsiFieldS3EventMode            = "s3_event_mode"
+	sqsiFieldMaxInFlightDeliveries  = "max_in_flight_deliveries"
+	sqsiFieldVerifyMD5              = "verify_md5"
+	sqsiFieldPrefetchCount          = "prefetch_count"
+	sqsiFieldBacklogPollInterval    = "backlog_poll_interval"
+	sqsiFieldDetectSequenceGaps     = "detect_sequence_gaps"
+	sqsiFieldSNSUnwrap              = "sns_unwrap"
+	sqsiFieldAckFlushIdlePeriod     = "ack_flush_idle_period"
+	sqsiFieldReceiveBatchMultiplier = "receive_batch_multiplier"
+	sqsiFieldCommitGroup            = "commit_group"
+	sqsiFieldCommitCache            = "commit_cache"
+	sqsiFieldCommitTimeout          = "commit_timeout"
+	sqsiFieldAttributePrefix        = "attribute_prefix"
+	sqsiFieldHeartbeatInterval      = "heartbeat_interval"
+	sqsiFieldFastDrain              = "fast_drain"
+	sqsiFieldFetchRedrivePolicy     = "fetch_redrive_policy"
+	sqsiFieldMaxBodyBytes           = "max_body_bytes"
+	sqsiFieldMaxBodyAction          = "max_body_action"
+	sqsiFieldStartupDelay           = "startup_delay"
+	sqsiFieldReceiveAttemptID       = "receive_request_attempt_id"
+	sqsiFieldLoadQueueTags          = "load_queue_tags"
+	sqsiFieldMaxMessages            = "max_messages"
+	sqsiFieldMaxRuntime             = "max_runtime"
+	sqsiFieldStallTimeout           = "stall_timeout"
+	sqsiFieldStallMessageTimeout    = "stall_message_timeout"
+	sqsiFieldIncludeAttributes      = "include_attributes"
+	sqsiFieldExcludeAttributes      = "exclude_attributes"
+	sqsiFieldEmitDrainMarker        = "emit_drain_marker"
+	sqsiFieldDrainMarkerReceives    = "drain_marker_empty_receives"
+	sqsiFieldJSONSchema             = "json_schema"
+	sqsiFieldJSONSchemaAction       = "json_schema_action"
+	sqsiFieldRateLimit              = "rate_limit"
+	sqsiFieldMapping                = "mapping"
+	sqsiFieldStrictMaxOutstanding   = "strict_max_outstanding"
+
+	// SQS Input Metrics
+	sqsiMetricDroppedStale     = "sqs_dropped_stale"
+	sqsiMetricDroppedDuplicate = "sqs_dropped_duplicate"
+	sqsiMetricDroppedAcked     = "sqs_dropped_acked"
+	sqsiMetricDwellTime        = "sqs_dwell_time_ns"
+	sqsiMetricQuarantined      = "sqs_quarantined"
+	sqsiMetricMaxReceiveCount  = "sqs_max_receive_count"
+	sqsiMetricErrors           = "sqs_errors"
+	sqsiMetricClientRebuilds   = "sqs_client_rebuilds"
+	sqsiMetricMD5Mismatch      = "sqs_md5_mismatch"
+	sqsiMetricBacklogVisible   = "sqs_approximate_messages"
+	sqsiMetricBacklogInFlight  = "sqs_approximate_messages_not_visible"
+	sqsiMetricThrottled        = "sqs_throttled"
+	sqsiMetricLastReceive      = "sqs_last_receive_timestamp"
+	sqsiMetricOversized        = "sqs_oversized"
+	sqsiMetricAckLatency       = "sqs_ack_latency_ns"
+	sqsiMetricSchemaInvalid    = "sqs_schema_invalid"
+	sqsiMetricMappingFailed    = "sqs_mapping_failed"
+
+	// The minimum interval between logs of the same category of error
+	sqsiErrorLogInterval = 10 * time.Second
+
+	// The initial and maximum intervals to back off for after SQS throttles a
+	// receive, which are longer than those of other errors as retrying
+	// quickly only prolongs the throttling.
+	sqsiThrottleInitialBackOff = time.Second
+	sqsiThrottleMaxBackOff     = 5 * time.Minute
+
+	// The maximum number of messages that SQS returns from a single receive
+	sqsiMaxNumberOfMessagesLimit = 10
 )
 
 type sqsiConfig struct {
-	URL                 string
-	WaitTimeSeconds     int
-	DeleteMessage       bool
-	ResetVisibility     bool
-	MaxNumberOfMessages int
-	MaxOutstanding      int
-	MessageTimeout      time.Duration
+	URL                    string
+	WaitTimeSeconds        int
+	DeleteMessage          bool
+	ResetVisibility        bool
+	MaxNumberOfMessages    int
+	MaxOutstanding         int
+	MessageTimeout         time.Duration
+	MessageTimeoutOverride *service.InterpolatedString
+	Filter                 *bloblang.Executor
+	DropFiltered           bool
+	FilteredVisibility     time.Duration
+	MaxMessageAge          time.Duration
+	RefreshConcurrency     int
+	DedupeTTL              time.Duration
+	DedupeKey              *service.InterpolatedString
+	DedupeMaxEntries       int
+	CoerceAttributeTypes   bool
+	BodyMetadataKey        string
+	AckCheckpoint          string
+	DeliveryBatchHint      bool
+	PreservePollOrder      bool
+	QuarantineThreshold    int
+	QuarantineAction       string
+	QuarantineVisibility   time.Duration
+	QuarantineMaxEntries   int
+	ReceiveCountWindow     time.Duration
+	UserAgentSuffix        string
+	ClientRebuildThreshold int
+	S3EventMode            bool
+	MaxInFlightDeliveries  int
+	VerifyMD5              bool
+	PrefetchCount          int
+	BacklogPollInterval    time.Duration
+	DetectSequenceGaps     bool
+	SNSUnwrap              bool
+	AckFlushIdlePeriod     time.Duration
+	ReceiveBatchMultiplier int
+	CommitGroup            *service.InterpolatedString
+	CommitCache            string
+	AttributePrefix        string
+	CommitTimeout          time.Duration
+	HeartbeatInterval      time.Duration
+	FastDrain              bool
+	FetchRedrivePolicy     bool
+	MaxBodyBytes           int
+	MaxBodyAction          string
+	StartupDelay           time.Duration
+	ReceiveAttemptID       bool
+	LoadQueueTags          bool
+	MaxMessages            int
+	MaxRuntime             time.Duration
+	StallTimeout           time.Duration
+	StallMessageTimeout    time.Duration
+	IncludeAttributes      []string
+	ExcludeAttributes      []string
+	EmitDrainMarker        bool
+	DrainMarkerReceives    int
+	JSONSchema             string
+	JSONSchemaAction       string
+	RateLimit              string
+	Mapping                *bloblang.Executor
+	StrictMaxOutstanding   bool
 }
 
 func sqsiConfigFromParsed(pConf *service.ParsedConfig) (conf sqsiConfig, err error) {
@@ -79,6 +228,327 @@ func sqsiConfigFromParsed(pConf *service.ParsedConfig) (conf sqsiConfig, err err
 	if conf.MessageTimeout, err = pConf.FieldDuration(sqsiFieldMessageTimeout); err != nil {
 		return
 	}
+	if pConf.Contains(sqsiFieldMessageTimeoutOverride) {
+		if conf.MessageTimeoutOverride, err = pConf.FieldInterpolatedString(sqsiFieldMessageTimeoutOverride); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldFilter) {
+		if conf.Filter, err = pConf.FieldBloblang(sqsiFieldFilter); err != nil {
+			return
+		}
+	}
+	if conf.DropFiltered, err = pConf.FieldBool(sqsiFieldDropFiltered); err != nil {
+		return
+	}
+	if pConf.Contains(sqsiFieldFilteredVisibility) {
+		if conf.FilteredVisibility, err = pConf.FieldDuration(sqsiFieldFilteredVisibility); err != nil {
+			return
+		}
+		if conf.FilteredVisibility < time.Second || conf.FilteredVisibility > 12*time.Hour {
+			err = errors.New("field " + sqsiFieldFilteredVisibility + " must be between 1s and 12h")
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldMaxMessageAge) {
+		if conf.MaxMessageAge, err = pConf.FieldDuration(sqsiFieldMaxMessageAge); err != nil {
+			return
+		}
+	}
+	if conf.RefreshConcurrency, err = pConf.FieldInt(sqsiFieldRefreshConcurrency); err != nil {
+		return
+	}
+	if conf.RefreshConcurrency < 1 {
+		err = errors.New("field " + sqsiFieldRefreshConcurrency + " must be at least 1")
+		return
+	}
+	if pConf.Contains(sqsiFieldDedupeTTL) {
+		if conf.DedupeTTL, err = pConf.FieldDuration(sqsiFieldDedupeTTL); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldDedupeKey) {
+		if conf.DedupeKey, err = pConf.FieldInterpolatedString(sqsiFieldDedupeKey); err != nil {
+			return
+		}
+	}
+	if conf.DedupeMaxEntries, err = pConf.FieldInt(sqsiFieldDedupeMaxEntries); err != nil {
+		return
+	}
+	if conf.DedupeMaxEntries < 1 {
+		err = errors.New("field " + sqsiFieldDedupeMaxEntries + " must be at least 1")
+		return
+	}
+	if conf.CoerceAttributeTypes, err = pConf.FieldBool(sqsiFieldCoerceAttributeTypes); err != nil {
+		return
+	}
+	if conf.BodyMetadataKey, err = pConf.FieldString(sqsiFieldBodyMetadataKey); err != nil {
+		return
+	}
+	if pConf.Contains(sqsiFieldAckCheckpoint) {
+		if conf.AckCheckpoint, err = pConf.FieldString(sqsiFieldAckCheckpoint); err != nil {
+			return
+		}
+	}
+	if conf.DeliveryBatchHint, err = pConf.FieldBool(sqsiFieldDeliveryBatchHint); err != nil {
+		return
+	}
+	if conf.PreservePollOrder, err = pConf.FieldBool(sqsiFieldPreservePollOrder); err != nil {
+		return
+	}
+	if conf.QuarantineThreshold, err = pConf.FieldInt(sqsiFieldQuarantineThreshold); err != nil {
+		return
+	}
+	if conf.QuarantineThreshold < 0 {
+		err = errors.New("field " + sqsiFieldQuarantineThreshold + " must not be negative")
+		return
+	}
+	if conf.QuarantineAction, err = pConf.FieldString(sqsiFieldQuarantineAction); err != nil {
+		return
+	}
+	if conf.QuarantineVisibility, err = pConf.FieldDuration(sqsiFieldQuarantineVisibility); err != nil {
+		return
+	}
+	if conf.QuarantineVisibility < time.Second || conf.QuarantineVisibility > 12*time.Hour {
+		err = errors.New("field " + sqsiFieldQuarantineVisibility + " must be between 1s and 12h")
+		return
+	}
+	if conf.QuarantineMaxEntries, err = pConf.FieldInt(sqsiFieldQuarantineMaxEntries); err != nil {
+		return
+	}
+	if conf.QuarantineMaxEntries < 1 {
+		err = errors.New("field " + sqsiFieldQuarantineMaxEntries + " must be at least 1")
+		return
+	}
+	if conf.ReceiveCountWindow, err = pConf.FieldDuration(sqsiFieldReceiveCountWindow); err != nil {
+		return
+	}
+	if conf.ReceiveCountWindow <= 0 {
+		err = errors.New("field " + sqsiFieldReceiveCountWindow + " must be greater than zero")
+		return
+	}
+	if conf.UserAgentSuffix, err = pConf.FieldString(sqsiFieldUserAgentSuffix); err != nil {
+		return
+	}
+	if conf.ClientRebuildThreshold, err = pConf.FieldInt(sqsiFieldClientRebuildThreshold); err != nil {
+		return
+	}
+	if conf.ClientRebuildThreshold < 0 {
+		err = errors.New("field " + sqsiFieldClientRebuildThreshold + " must not be negative")
+		return
+	}
+	if conf.S3EventMode, err = pConf.FieldBool(sqsiFieldS3EventMode); err != nil {
+		return
+	}
+	if conf.MaxInFlightDeliveries, err = pConf.FieldInt(sqsiFieldMaxInFlightDeliveries); err != nil {
+		return
+	}
+	if conf.MaxInFlightDeliveries < 0 {
+		err = errors.New("field " + sqsiFieldMaxInFlightDeliveries + " must not be negative")
+		return
+	}
+	if conf.VerifyMD5, err = pConf.FieldBool(sqsiFieldVerifyMD5); err != nil {
+		return
+	}
+	if conf.PrefetchCount, err = pConf.FieldInt(sqsiFieldPrefetchCount); err != nil {
+		return
+	}
+	if conf.PrefetchCount < 0 {
+		err = errors.New("field " + sqsiFieldPrefetchCount + " must not be negative")
+		return
+	}
+	if conf.BacklogPollInterval, err = pConf.FieldDuration(sqsiFieldBacklogPollInterval); err != nil {
+		return
+	}
+	if conf.BacklogPollInterval < 0 {
+		err = errors.New("field " + sqsiFieldBacklogPollInterval + " must not be negative")
+		return
+	}
+	if conf.DetectSequenceGaps, err = pConf.FieldBool(sqsiFieldDetectSequenceGaps); err != nil {
+		return
+	}
+	if conf.SNSUnwrap, err = pConf.FieldBool(sqsiFieldSNSUnwrap); err != nil {
+		return
+	}
+	if conf.AckFlushIdlePeriod, err = pConf.FieldDuration(sqsiFieldAckFlushIdlePeriod); err != nil {
+		return
+	}
+	if conf.AckFlushIdlePeriod < 0 {
+		err = errors.New("field " + sqsiFieldAckFlushIdlePeriod + " must not be negative")
+		return
+	}
+	if conf.ReceiveBatchMultiplier, err = pConf.FieldInt(sqsiFieldReceiveBatchMultiplier); err != nil {
+		return
+	}
+	if conf.ReceiveBatchMultiplier < 1 {
+		err = errors.New("field " + sqsiFieldReceiveBatchMultiplier + " must be at least 1")
+		return
+	}
+	if conf.ReceiveBatchMultiplier > 1 && conf.PreservePollOrder {
+		// Each message of a poll would wait on the previous message of the
+		// same batch to be acked.
+		err = errors.New("field " + sqsiFieldPreservePollOrder + " cannot be enabled when " + sqsiFieldReceiveBatchMultiplier + " is greater than 1")
+		return
+	}
+	if pConf.Contains(sqsiFieldCommitGroup) {
+		if conf.CommitGroup, err = pConf.FieldInterpolatedString(sqsiFieldCommitGroup); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldCommitCache) {
+		if conf.CommitCache, err = pConf.FieldString(sqsiFieldCommitCache); err != nil {
+			return
+		}
+	}
+	if conf.CommitGroup != nil && conf.CommitCache == "" {
+		err = errors.New("field " + sqsiFieldCommitCache + " must be set when " + sqsiFieldCommitGroup + " is set")
+		return
+	}
+	if conf.CommitTimeout, err = pConf.FieldDuration(sqsiFieldCommitTimeout); err != nil {
+		return
+	}
+	if conf.CommitTimeout <= 0 {
+		err = errors.New("field " + sqsiFieldCommitTimeout + " must be greater than zero")
+		return
+	}
+	if conf.AttributePrefix, err = pConf.FieldString(sqsiFieldAttributePrefix); err != nil {
+		return
+	}
+	if conf.HeartbeatInterval, err = pConf.FieldDuration(sqsiFieldHeartbeatInterval); err != nil {
+		return
+	}
+	if conf.HeartbeatInterval < 0 {
+		err = errors.New("field " + sqsiFieldHeartbeatInterval + " must not be negative")
+		return
+	}
+	if conf.FastDrain, err = pConf.FieldBool(sqsiFieldFastDrain); err != nil {
+		return
+	}
+	if conf.FastDrain {
+		// Messages are deleted before they are delivered, and so features that
+		// act on acknowledged or rejected messages cannot be honored.
+		for _, conflict := range []struct {
+			field string
+			set   bool
+		}{
+			{sqsiFieldAckCheckpoint, conf.AckCheckpoint != ""},
+			{sqsiFieldCommitGroup, conf.CommitGroup != nil},
+			{sqsiFieldQuarantineThreshold, conf.QuarantineThreshold > 0},
+			{sqsiFieldPreservePollOrder, conf.PreservePollOrder},
+		} {
+			if conflict.set {
+				err = errors.New("field " + conflict.field + " cannot be set when " + sqsiFieldFastDrain + " is enabled")
+				return
+			}
+		}
+		if !conf.DeleteMessage {
+			err = errors.New("field " + sqsiFieldDeleteMessage + " must be enabled when " + sqsiFieldFastDrain + " is enabled")
+			return
+		}
+		conf.MaxNumberOfMessages = sqsiMaxNumberOfMessagesLimit
+	}
+	if conf.FetchRedrivePolicy, err = pConf.FieldBool(sqsiFieldFetchRedrivePolicy); err != nil {
+		return
+	}
+	if conf.MaxBodyBytes, err = pConf.FieldInt(sqsiFieldMaxBodyBytes); err != nil {
+		return
+	}
+	if conf.MaxBodyBytes < 0 {
+		err = errors.New("field " + sqsiFieldMaxBodyBytes + " must not be negative")
+		return
+	}
+	if conf.MaxBodyAction, err = pConf.FieldString(sqsiFieldMaxBodyAction); err != nil {
+		return
+	}
+	if conf.StartupDelay, err = pConf.FieldDuration(sqsiFieldStartupDelay); err != nil {
+		return
+	}
+	if conf.StartupDelay < 0 {
+		err = errors.New("field " + sqsiFieldStartupDelay + " must not be negative")
+		return
+	}
+	if conf.ReceiveAttemptID, err = pConf.FieldBool(sqsiFieldReceiveAttemptID); err != nil {
+		return
+	}
+	if conf.LoadQueueTags, err = pConf.FieldBool(sqsiFieldLoadQueueTags); err != nil {
+		return
+	}
+	if conf.MaxMessages, err = pConf.FieldInt(sqsiFieldMaxMessages); err != nil {
+		return
+	}
+	if conf.MaxMessages < 0 {
+		err = errors.New("field " + sqsiFieldMaxMessages + " must not be negative")
+		return
+	}
+	if conf.MaxRuntime, err = pConf.FieldDuration(sqsiFieldMaxRuntime); err != nil {
+		return
+	}
+	if conf.MaxRuntime < 0 {
+		err = errors.New("field " + sqsiFieldMaxRuntime + " must not be negative")
+		return
+	}
+	if conf.StallTimeout, err = pConf.FieldDuration(sqsiFieldStallTimeout); err != nil {
+		return
+	}
+	if conf.StallTimeout < 0 {
+		err = errors.New("field " + sqsiFieldStallTimeout + " must not be negative")
+		return
+	}
+	if conf.StallMessageTimeout, err = pConf.FieldDuration(sqsiFieldStallMessageTimeout); err != nil {
+		return
+	}
+	if conf.StallMessageTimeout > 12*time.Hour {
+		err = errors.New("field " + sqsiFieldStallMessageTimeout + " must not exceed 12h")
+		return
+	}
+	if pConf.Contains(sqsiFieldIncludeAttributes) {
+		if conf.IncludeAttributes, err = pConf.FieldStringList(sqsiFieldIncludeAttributes); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldExcludeAttributes) {
+		if conf.ExcludeAttributes, err = pConf.FieldStringList(sqsiFieldExcludeAttributes); err != nil {
+			return
+		}
+	}
+	if _, err = newSQSAttributeFilter(conf.IncludeAttributes, conf.ExcludeAttributes); err != nil {
+		return
+	}
+	if conf.EmitDrainMarker, err = pConf.FieldBool(sqsiFieldEmitDrainMarker); err != nil {
+		return
+	}
+	if conf.DrainMarkerReceives, err = pConf.FieldInt(sqsiFieldDrainMarkerReceives); err != nil {
+		return
+	}
+	if conf.DrainMarkerReceives < 1 {
+		err = errors.New("field " + sqsiFieldDrainMarkerReceives + " must be at least 1")
+		return
+	}
+	if pConf.Contains(sqsiFieldJSONSchema) {
+		if conf.JSONSchema, err = pConf.FieldString(sqsiFieldJSONSchema); err != nil {
+			return
+		}
+	}
+	if conf.JSONSchemaAction, err = pConf.FieldString(sqsiFieldJSONSchemaAction); err != nil {
+		return
+	}
+	if pConf.Contains(sqsiFieldRateLimit) {
+		if conf.RateLimit, err = pConf.FieldString(sqsiFieldRateLimit); err != nil {
+			return
+		}
+	}
+	if pConf.Contains(sqsiFieldMapping) {
+		if conf.Mapping, err = pConf.FieldBloblang(sqsiFieldMapping); err != nil {
+			return
+		}
+	}
+	if conf.StrictMaxOutstanding, err = pConf.FieldBool(sqsiFieldStrictMaxOutstanding); err != nil {
+		return
+	}
+	if conf.StrictMaxOutstanding && conf.FastDrain {
+		err = errors.New("field " + sqsiFieldStrictMaxOutstanding + " cannot be set when " + sqsiFieldFastDrain + " is enabled")
+		return
+	}
 	return
 }
 
@@ -102,10 +572,196 @@ This input adds the following metadata fields to each message:
 - sqs_message_id
 - sqs_receipt_handle
 - sqs_approximate_receive_count
-- All message attributes
+- sqs_dwell_ms: The number of milliseconds between the message being sent and first received, including any delay
+- sqs_body_md5: The MD5 digest of the message body calculated by SQS
+- sqs_sequence_number: The sequence number of the message, only set for FIFO queues
+- sqs_commit_group: The commit group of the message, only set when `+"`"+sqsiFieldCommitGroup+"`"+` is configured
+- sqs_truncated: Set to `+"`true`"+` when the body of the message was truncated, only set when `+"`"+sqsiFieldMaxBodyAction+"`"+` is `+"`"+sqsMaxBodyActionTruncate+"`"+`
+- sqs_schema_invalid: Set to `+"`true`"+` when the body of the message does not conform to `+"`"+sqsiFieldJSONSchema+"`"+`, only set when `+"`"+sqsiFieldJSONSchemaAction+"`"+` is `+"`"+sqsSchemaActionFlag+"`"+`
+- sqs_schema_error: The reasons that the body of the message does not conform to `+"`"+sqsiFieldJSONSchema+"`"+`, only set along with `+"`sqs_schema_invalid`"+`
+- All message attributes, with their keys prefixed by `+"`"+sqsiFieldAttributePrefix+"`"+` when set, which can be limited with `+"`"+sqsiFieldIncludeAttributes+"`"+` and `+"`"+sqsiFieldExcludeAttributes+"`"+`
+
+When `+"`"+sqsiFieldFetchRedrivePolicy+"`"+` is enabled and the queue has a redrive policy the following metadata fields are also added:
+
+- sqs_redrive_dead_letter_target_arn: The ARN of the dead letter queue that messages are moved to
+- sqs_redrive_max_receive_count: The number of times a message can be received before it is moved to the dead letter queue, which can be compared against `+"`sqs_approximate_receive_count`"+` to detect the last delivery of a message
+
+When `+"`"+sqsiFieldLoadQueueTags+"`"+` is enabled each tag of the queue is also added as a metadata field with its key prefixed by `+"`sqs_tag_`"+`, such that a tag `+"`cost-center`"+` is added as `+"`sqs_tag_cost-center`"+`. The tags are listed once each time the input connects.
+
+When `+"`"+sqsiFieldDeliveryBatchHint+"`"+` is enabled the following metadata fields are also added:
+
+- sqs_receive_batch_id: A unique ID of the `+"`ReceiveMessage`"+` call that the message was received by
+- sqs_receive_batch_index: The position of the message within the response, starting at zero
+- sqs_receive_batch_size: The number of messages in the response
+
+Messages that are filtered, stale or duplicates are not delivered, and therefore a batch may be delivered with gaps in its positions.
 
 You can access these metadata fields using
-xref:configuration:interpolation.adoc#bloblang-queries[function interpolation].`).
+xref:configuration:interpolation.adoc#bloblang-queries[function interpolation].
+
+== Filtering
+
+When a `+"`"+sqsiFieldFilter+"`"+` query is configured each message is checked against it before being delivered downstream. Messages that do not match are deleted from the queue when `+"`"+sqsiFieldDropFiltered+"`"+` is enabled, which is the default. Otherwise they are left on the queue for other consumers, and how soon they become visible again depends on the following fields:
+
+- When `+"`"+sqsiFieldFilteredVisibility+"`"+` is set the visibility timeout of filtered messages is set to that duration, which holds them back from this consumer and any others for that long.
+- Otherwise, when `+"`"+sqsiFieldResetVisibility+"`"+` is enabled the visibility of filtered messages is reset to zero so that they can be received again straight away.
+- Otherwise filtered messages are left until their visibility timeout expires.
+
+Since this consumer can receive filtered messages again just as any other consumer can, each time a message is filtered its `+"`ApproximateReceiveCount`"+` increases, and a queue with a redrive policy can therefore move messages that are filtered often enough to its dead letter queue. Queues shared by consumers with different filters should use a `+"`"+sqsiFieldFilteredVisibility+"`"+` long enough for the other consumers to receive the messages, and a `+"`maxReceiveCount`"+` high enough to accommodate it.
+
+== Deduplication
+
+Standard SQS queues provide at-least-once delivery, which means that a message can occasionally be received more than once. When `+"`"+sqsiFieldDedupeTTL+"`"+` is set this input remembers the key of each acknowledged message for that duration, and messages received again within the window are deleted from the queue without being delivered downstream. If a message is nacked its key is forgotten so that it can be redelivered.
+
+A message can also be received again whilst an earlier delivery with the same key is still in flight, for example when its visibility timeout lapses because it is processed slowly or refreshing its visibility fails. Since the earlier delivery can still be nacked the duplicate is not deleted, instead it is held on the queue until its visibility timeout expires without being delivered downstream, and is deleted if it is received again once the earlier delivery has been acknowledged.
+
+Deduplication is best-effort: keys are only held in memory within a single instance of this input, up to a maximum of `+"`"+sqsiFieldDedupeMaxEntries+"`"+` acknowledged keys, and are lost on restart.
+
+== Ack checkpoints
+
+When `+"`"+sqsiFieldAckCheckpoint+"`"+` is set the ID of each message is written to the named cache once the message has been acknowledged, and the message is only deleted from the queue after that write succeeds. If the write fails then the visibility of the message is reset so that it can be delivered again. Received messages are checked against the cache before being delivered, and those already recorded are deleted from the queue without being delivered again, which narrows the window in which a crash between a message being acknowledged and deleted results in a duplicate. Messages dropped this way are counted by the `+"`"+sqsiMetricDroppedAcked+"`"+` metric.
+
+This does not provide exactly-once delivery, a crash after a message is written by an output but before its ID is recorded still results in the message being delivered again. The cache should be shared by all consumers of the queue and should retain keys for at least as long as the retention period of the queue, for example by using a cache with a TTL.
+
+== Commit groups
+
+Outputs that write several messages within a single transaction, such as a database transaction spanning a batch, only make those messages durable once the transaction commits. When `+"`"+sqsiFieldCommitGroup+"`"+` is set it is evaluated against each received message, and acknowledged messages are not deleted from the queue until their group has been committed downstream. The group of each message is added as the `+"`sqs_commit_group`"+` metadata field, and a group is committed by writing its name as a key to the `+"`"+sqsiFieldCommitCache+"`"+` cache resource, for example with a `+"`cache`"+` output that runs once the transaction has been written. This input checks the cache every second, and once the key of a group is found all of its acknowledged messages are deleted and the key is removed from the cache so that the name can be reused. Messages that evaluate to an empty group are deleted once acknowledged as usual.
+
+While a group awaits its commit its acknowledged messages remain in flight, with their visibility timeout extended as usual, and count towards `+"`"+sqsiFieldMaxOutstanding+"`"+`. A group that is not committed within `+"`"+sqsiFieldCommitTimeout+"`"+` of its first message being acknowledged, or that has not been committed when this input shuts down, has the visibility of its messages reset so that they are delivered again. Rejected messages are returned to the queue individually, and do not affect the other messages of their group.
+
+This preserves at-least-once delivery: a message is never deleted before its group is committed, but a group that is committed after it times out, or just before this input shuts down or crashes, results in its messages being delivered again. SQS cannot delete messages atomically, and so the messages of a committed group are deleted in batches of up to `+"`"+sqsiFieldMaxNumberOfMessages+"`"+` like any other acknowledged messages. Groups are only tracked within a single instance of this input, and so the messages of a group should be consumed and committed by the same instance.
+
+== Quarantine
+
+A message that repeatedly fails with the same error, also known as a poison pill, can be quarantined rather than retried indefinitely. When `+"`"+sqsiFieldQuarantineThreshold+"`"+` is set this input records the error of each nacked message, and once a message has been nacked that many times in a row with the same error the configured `+"`"+sqsiFieldQuarantineAction+"`"+` is taken:
+
+- `+"`"+sqsQuarantineActionDelete+"`"+`: The message is deleted from the queue.
+- `+"`"+sqsQuarantineActionExtendVisibility+"`"+`: The visibility timeout of the message is set to `+"`"+sqsiFieldQuarantineVisibility+"`"+`, which holds it back from being received again for that duration.
+- `+"`"+sqsQuarantineActionTag+"`"+`: The visibility of the message is reset as usual, and each time it is delivered again the metadata fields `+"`sqs_quarantined`"+` (set to `+"`true`"+`) and `+"`sqs_quarantine_reason`"+` (the error it was last nacked with) are added, which allows the pipeline to route it elsewhere, such as to a dead letter queue.
+
+Quarantined messages are counted by the `+"`"+sqsiMetricQuarantined+"`"+` metric. Nack counts are only held in memory within a single instance of this input, and so are lost on restart and are not shared by other consumers of the queue. The counts of up to `+"`"+sqsiFieldQuarantineMaxEntries+"`"+` messages are held at a time, beyond which the messages least recently nacked are forgotten, and a message is forgotten once it is acked.
+
+== SNS notifications
+
+When a queue is subscribed to an SNS topic without raw message delivery the body of each message is an SNS envelope, with the published message held within its `+"`Message`"+` field. When `+"`"+sqsiFieldSNSUnwrap+"`"+` is enabled the body of each message that is an SNS notification is replaced with the published message, and the following metadata fields are added:
+
+- sns_topic_arn
+- sns_message_id
+- sns_subject: Only set when the notification has a subject
+- sns_attribute_<name>: The value of each message attribute of the notification
+
+Messages that are not SNS notifications are delivered unchanged. When combined with `+"`"+sqsiFieldS3EventMode+"`"+` the published message is parsed as an S3 event notification, which allows S3 events that are fanned out by SNS to be consumed.
+
+== S3 event notifications
+
+When `+"`"+sqsiFieldS3EventMode+"`"+` is enabled the body of each message is parsed as an https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html[S3 event notification^], and one message is emitted for each entry of its `+"`Records`"+` array. The contents of each emitted message is the record itself, and the following metadata fields are added:
+
+- s3_bucket_name
+- s3_object_key
+- s3_event_name
+
+The object key is URL decoded. The SQS message is only deleted once every message emitted from it has been acknowledged, and if any of them are rejected then the SQS message is retried as a whole. Filtering and deduplication apply to the SQS message before it is split. Messages that are not S3 event notifications, such as the test event sent by S3 when notifications are first configured, are delivered unchanged.
+
+== Body integrity
+
+When `+"`"+sqsiFieldVerifyMD5+"`"+` is enabled the MD5 digest of the body of each received message is calculated and compared against the digest calculated by SQS when the message was sent. Messages that do not match, which indicates that the body was corrupted or truncated, are logged, counted by the `+"`"+sqsiMetricMD5Mismatch+"`"+` metric and rejected. Since a corrupted message is unlikely to be fixed by receiving it again straight away these messages are held on the queue for their message timeout before they are received again rather than having their visibility reset, which also allows a redrive policy to move them to a dead letter queue.
+
+When `+"`"+sqsiFieldMaxBodyBytes+"`"+` is set the body of each received message is checked against that limit before the message is created, which protects downstream components that cannot handle large payloads. Messages with larger bodies are counted by the `+"`"+sqsiMetricOversized+"`"+` metric, and the configured `+"`"+sqsiFieldMaxBodyAction+"`"+` is taken:
+
+- `+"`"+sqsMaxBodyActionTruncate+"`"+`: The body is cut to at most `+"`"+sqsiFieldMaxBodyBytes+"`"+` bytes without splitting a UTF-8 character and the message is delivered with the metadata field `+"`sqs_truncated`"+` set to `+"`true`"+`. The truncated body is also used for `+"`"+sqsiFieldBodyMetadataKey+"`"+`, SNS unwrapping and S3 event notifications.
+- `+"`"+sqsMaxBodyActionReject+"`"+`: The message is logged and rejected, and is held on the queue for its message timeout before it is received again rather than having its visibility reset. This allows a redrive policy to move it to a dead letter queue without it being received over and over in the meantime.
+- `+"`"+sqsMaxBodyActionDrop+"`"+`: The message is logged and deleted from the queue without being delivered.
+
+The MD5 digest is verified against the original body before it is truncated.
+
+== Schema validation
+
+When `+"`"+sqsiFieldJSONSchema+"`"+` is set the body of each received message is validated against that JSON schema before it is delivered, which keeps messages that do not conform, including those that are not valid JSON, away from downstream components without a separate validation stage. The body is validated after SNS notifications are unwrapped and before `+"`"+sqsiFieldFilter+"`"+` is applied. Messages that do not conform are counted by the `+"`"+sqsiMetricSchemaInvalid+"`"+` metric, and the configured `+"`"+sqsiFieldJSONSchemaAction+"`"+` is taken:
+
+- `+"`"+sqsSchemaActionReject+"`"+`: The message is logged and rejected, and is held on the queue for its message timeout before it is received again rather than having its visibility reset. This allows a redrive policy to move it to a dead letter queue without it being received over and over in the meantime.
+- `+"`"+sqsSchemaActionDrop+"`"+`: The message is logged and deleted from the queue without being delivered.
+- `+"`"+sqsSchemaActionFlag+"`"+`: The message is delivered with the metadata field `+"`sqs_schema_invalid`"+` set to `+"`true`"+` and the reasons that it does not conform in the metadata field `+"`sqs_schema_error`"+`, so that it can be routed elsewhere, for example with a xref:components:outputs/switch.adoc[`+"`switch`"+` output].
+
+== Mapping messages
+
+When `+"`"+sqsiFieldMapping+"`"+` is set each received message is replaced with the result of executing that mapping against it within the input, rather than in a processor, which allows bodies to be normalized or redacted at the point they are received, before they are held by any other component. The metadata of the message can be read and modified with `+"`@`"+` and `+"`meta`"+` assignments. The mapping is executed once SNS notifications are unwrapped and bodies are validated against `+"`"+sqsiFieldJSONSchema+"`"+`, and before `+"`"+sqsiFieldFilter+"`"+`, deduplication and S3 event notifications are applied, which therefore see the mapped message.
+
+Messages that the mapping fails on are logged, counted by the `+"`"+sqsiMetricMappingFailed+"`"+` metric and rejected, and are held on the queue for their message timeout before they are received again rather than having their visibility reset. This allows a redrive policy to move them to a dead letter queue without them being received over and over in the meantime. Messages that the mapping deletes with `+"`root = deleted()`"+` are deleted from the queue without being delivered. The original body is still added as `+"`"+sqsiFieldBodyMetadataKey+"`"+` when that field is set, and so it should not be combined with a mapping that redacts the body.
+
+== FIFO ordering
+
+FIFO queues assign each message a sequence number that increases within its message group, which is added as the `+"`sqs_sequence_number`"+` metadata field and can be used to restore the order of messages that are replayed. When `+"`"+sqsiFieldDetectSequenceGaps+"`"+` is enabled the sequence number of the last message delivered from each group is remembered, and a warning is logged whenever a message is delivered with a lower sequence number than a previous message of the same group, which indicates that messages of the group are being delivered out of order. Messages delivered again with the same sequence number, such as after being rejected, are not reported. Only the latest 10000 groups are remembered, and each instance of this input only observes the messages that it delivers.
+
+When a `+"`ReceiveMessage`"+` call to a FIFO queue fails after SQS has received it, such as when the connection is lost before the response arrives, the messages it returned are hidden until their visibility timeout expires and any later messages of the same message groups are held back with them. When `+"`"+sqsiFieldReceiveAttemptID+"`"+` is enabled each call is made with a generated `+"`ReceiveRequestAttemptId`"+`, which is also used when the AWS SDK retries the call. If the call still fails the same ID is used for the next call, and SQS returns the messages of the failed attempt again rather than waiting for them to become visible. A new ID is generated once a call succeeds, or after four minutes as SQS only honors an ID for five minutes. Each of the parallel calls made when `+"`"+sqsiFieldReceiveBatchMultiplier+"`"+` is greater than `+"`1`"+` uses an ID of its own. Standard queues ignore the ID.
+
+== Metrics
+
+Errors returned by SQS when receiving, deleting or resetting the visibility of messages, or when getting the attributes of the queue, are counted by the `+"`"+sqsiMetricErrors+"`"+` metric. This metric is labelled by the `+"`operation`"+` that failed and by a `+"`category`"+` derived from the AWS error code, which is one of `+"`throttling`, `auth`, `not_found`, `network`, `timeout`, `client`, `server` or `unknown`"+`. Repeated errors of the same operation and category are logged at most once every ten seconds.
+
+The time between each message being sent and first received, which includes any delay configured on the queue or message, is recorded by the `+"`"+sqsiMetricDwellTime+"`"+` timer metric.
+
+The time between each message being delivered and it being acknowledged or rejected, which covers processing by the pipeline and delivery by the output, is recorded by the `+"`"+sqsiMetricAckLatency+"`"+` timer metric. This metric is labelled by the `+"`outcome`"+` of the message, which is either `+"`ack`"+` or `+"`nack`"+`. When messages are delivered as a batch the time is recorded for each message of the batch.
+
+The highest approximate receive count of the messages received within each `+"`"+sqsiFieldReceiveCountWindow+"`"+` is reported by the `+"`"+sqsiMetricMaxReceiveCount+"`"+` gauge metric. A climbing value indicates that messages are being retried repeatedly, such as when a poison pill message is stuck, and can be alarmed on before messages reach a dead letter queue. The gauge is updated as messages are received, and therefore holds its last value while the queue is idle.
+
+When `+"`"+sqsiFieldBacklogPollInterval+"`"+` is set the attributes of the queue are polled at that interval, and the approximate number of messages available to be received and the approximate number of messages in flight are reported by the `+"`"+sqsiMetricBacklogVisible+"`"+` and `+"`"+sqsiMetricBacklogInFlight+"`"+` gauge metrics respectively. These cover the whole queue rather than this input alone, and can be used to drive autoscaling without scraping CloudWatch.
+
+When SQS throttles a receive, such as with an `+"`OverLimit`"+` error once a queue has too many messages in flight, this input backs off for longer than it does after other errors, starting at one second and doubling up to five minutes until a receive is no longer throttled. Each throttled receive is counted by the `+"`"+sqsiMetricThrottled+"`"+` metric, which distinguishes being rate limited by AWS from network problems.
+
+When `+"`"+sqsiFieldHeartbeatInterval+"`"+` is set a heartbeat is logged at that interval, independently of receiving messages. While receive requests are completing an info level log reports how many completed and how many messages they returned since the previous heartbeat, and when none have completed a warning is logged instead. Each heartbeat also sets the `+"`"+sqsiMetricLastReceive+"`"+` gauge metric to the unix timestamp in seconds of the last receive request that completed, which can be compared against the current time by liveness probes.
+
+== Outstanding messages
+
+Messages are in flight from the moment they are received until they are acknowledged or rejected, and `+"`"+sqsiFieldMaxOutstanding+"`"+` limits how many messages can be in flight at once. By default this is a soft limit: no further messages are received while the limit is reached, but each poll requests up to `+"`"+sqsiFieldMaxNumberOfMessages+"`"+` messages from each of its `+"`"+sqsiFieldReceiveBatchMultiplier+"`"+` calls regardless of how close to the limit it is, and so the number of messages in flight can exceed the limit by up to a poll. When `+"`"+sqsiFieldMaxOutstanding+"`"+` is lower than the number of messages a single poll can receive a warning is logged when the input is created.
+
+When `+"`"+sqsiFieldStrictMaxOutstanding+"`"+` is enabled the limit is enforced as a hard cap instead. Each poll waits until fewer messages than the limit are in flight, and requests no more messages than remain within it, which results in smaller receives while the limit is nearly reached and therefore more `+"`ReceiveMessage`"+` calls for the same number of messages. Prefetched messages count towards the limit either way. Enabling `+"`"+sqsiFieldStrictMaxOutstanding+"`"+` cannot be combined with `+"`"+sqsiFieldFastDrain+"`"+`, as drained messages are never in flight.
+
+== Batching
+
+By default each message is delivered individually. When `+"`"+sqsiFieldReceiveBatchMultiplier+"`"+` is greater than `+"`1`"+` that many `+"`ReceiveMessage`"+` calls are made in parallel, and the messages that they receive are delivered together as a single batch of up to `+"`"+sqsiFieldReceiveBatchMultiplier+"`"+` multiplied by `+"`"+sqsiFieldMaxNumberOfMessages+"`"+` messages, less any that are filtered or dropped. Acknowledging the batch acknowledges each of its messages, and a batch counts as a single delivery towards `+"`"+sqsiFieldMaxInFlightDeliveries+"`"+`. Messages received in parallel count towards `+"`"+sqsiFieldMaxOutstanding+"`"+` as usual, although a single poll can exceed the limit unless `+"`"+sqsiFieldStrictMaxOutstanding+"`"+` is enabled. Batching cannot be combined with `+"`"+sqsiFieldPreservePollOrder+"`"+`, as the messages of a batch are already delivered together in the order they were received.
+
+== Fast draining
+
+When `+"`"+sqsiFieldFastDrain+"`"+` is enabled this input is intended for draining a queue during maintenance, such as after an incident, where messages are logged or transformed and discarded rather than reliably processed. Each poll requests the maximum of 10 messages regardless of `+"`"+sqsiFieldMaxNumberOfMessages+"`"+`, and the messages received are deleted from the queue immediately, before they are delivered. Messages are not tracked as in flight, and so their visibility is never refreshed, `+"`"+sqsiFieldMaxOutstanding+"`"+` does not apply and acknowledging or rejecting a message has no effect.
+
+This mode is lossy: a message that fails to be processed, or that has not been processed when the pipeline stops or crashes, has already been deleted and is not delivered again. Messages that could not be deleted are still delivered, and can be delivered again once their visibility timeout expires. Fast draining cannot be combined with `+"`"+sqsiFieldAckCheckpoint+"`"+`, `+"`"+sqsiFieldCommitGroup+"`"+`, `+"`"+sqsiFieldQuarantineThreshold+"`"+` or `+"`"+sqsiFieldPreservePollOrder+"`"+`, and requires `+"`"+sqsiFieldDeleteMessage+"`"+` to be enabled.
+
+== Bounded jobs
+
+By default this input receives messages until the pipeline is stopped. When `+"`"+sqsiFieldMaxMessages+"`"+` or `+"`"+sqsiFieldMaxRuntime+"`"+` is set the input instead stops receiving messages once it has received that many messages, or once that duration has passed since it first connected, whichever happens first. The messages already received are still delivered and acknowledged, after which the input ends and the pipeline shuts down cleanly, which allows a queue to be consumed by a one-shot batch job.
+
+Each message received from SQS counts towards `+"`"+sqsiFieldMaxMessages+"`"+`, including messages that are filtered, stale or duplicates and are therefore not delivered, and an SNS or S3 event notification counts as a single message regardless of the number of messages it is delivered as. Requests never ask for more messages than remain within the limit. A `+"`ReceiveMessage`"+` call that is in progress when `+"`"+sqsiFieldMaxRuntime+"`"+` passes is allowed to complete, and so the input may receive for up to `+"`"+sqsiFieldWaitTimeSeconds+"`"+` longer. Messages that are rejected after the limit was reached are returned to the queue as usual, and are received by the next run.
+
+== Drain markers
+
+When `+"`"+sqsiFieldEmitDrainMarker+"`"+` is enabled the input emits a marker message once the queue has been drained, which gives downstream components such as a compaction step a signal that every message has been consumed without a separate coordinator. The queue is considered drained once `+"`"+sqsiFieldDrainMarkerReceives+"`"+` consecutive `+"`ReceiveMessage`"+` calls returned no messages and every message received earlier has been acknowledged or rejected. The marker has an empty body and the metadata field `+"`sqs_drain_complete`"+` set to `+"`true`"+`, no other metadata, and acknowledging it has no effect on the queue.
+
+A single marker is emitted for each drain, after which the input keeps receiving messages. Once further messages are received a marker is emitted again when the queue is next drained, and a queue that is empty when the input connects is drained as soon as the receives return no messages. Failed receives reset the count of consecutive empty receives. With `+"`"+sqsiFieldWaitTimeSeconds+"`"+` set each empty receive takes that long, and so the marker is emitted roughly `+"`"+sqsiFieldDrainMarkerReceives+"`"+` times `+"`"+sqsiFieldWaitTimeSeconds+"`"+` after the last message was received.
+
+No marker is emitted once `+"`"+sqsiFieldMaxMessages+"`"+` or `+"`"+sqsiFieldMaxRuntime+"`"+` has been reached, as the input no longer receives messages. The marker can be routed separately from the messages of the queue with a check such as `+"`@sqs_drain_complete == \"true\"`"+`.
+
+== Stalled pipelines
+
+While messages are in flight their visibility timeout is refreshed so that they are not delivered again, even when the pipeline cannot make progress on them, such as when an output is down. When `+"`"+sqsiFieldStallTimeout+"`"+` is set and no message has been acknowledged or rejected for that duration while messages are in flight, the pipeline is considered stalled. While stalled:
+
+- No further messages are received, as they could not be processed either. Messages that were already received are still delivered.
+- Messages that are in flight are still refreshed, but with a visibility timeout of `+"`"+sqsiFieldStallMessageTimeout+"`"+` rather than their usual timeout. As messages are refreshed once half of their visibility timeout has passed, a longer timeout reduces the number of `+"`ChangeMessageVisibility`"+` calls made during an outage.
+
+The stall ends as soon as any message is acknowledged or rejected, after which messages are received again and are refreshed with their usual timeout. Messages that were refreshed during the stall remain hidden for up to `+"`"+sqsiFieldStallMessageTimeout+"`"+` should the input stop without acknowledging them. Each time receiving is paused or resumed a log is emitted, and periods where no messages are in flight never count towards `+"`"+sqsiFieldStallTimeout+"`"+`.
+
+== Rate limiting
+
+When `+"`"+sqsiFieldRateLimit+"`"+` is set every call this input makes to SQS, including `+"`ReceiveMessage`"+`, `+"`DeleteMessageBatch`"+` and `+"`ChangeMessageVisibilityBatch`"+`, first waits for access from the referenced xref:components:rate_limits/about.adoc[rate limit resource]. A single rate limit can be shared by several inputs and other components in order to keep their combined requests within the quotas of an account, which avoids throttling errors that would otherwise occur in bursts. Each call, rather than each message, counts towards the limit, and so batching acknowledgements and receiving several messages per call both reduce the number of accesses.
+
+A rate limit that is too low delays acknowledgements and visibility timeout refreshes as well as receives, and messages that are not refreshed in time become visible again and are delivered a second time, so the limit should leave headroom for the refreshes of all messages that can be in flight. Waiting for access is abandoned as soon as the input is closed.
+
+== Reconnecting
+
+When `+"`"+sqsiFieldClientRebuildThreshold+"`"+` is set and receiving messages fails that many times in a row with a `+"`network`"+` or `+"`auth`"+` error, this input resolves its AWS configuration and credentials again and replaces its SQS client, which recovers from problems such as expired credentials or stale connections without restarting the pipeline. Messages that are in flight when the client is replaced are unaffected and can still be acknowledged. Each replacement is counted by the `+"`"+sqsiMetricClientRebuilds+"`"+` metric.
+
+== Tracing
+
+When a tracer is configured the span of each message is annotated with attributes describing its queue and message, including the queue name (`+"`messaging.destination.name`"+`), the message ID (`+"`messaging.message.id`"+`), the number of messages received in the same batch (`+"`messaging.batch.message_count`"+`), the number of messages in flight when the batch was received (`+"`aws.sqs.in_flight_count`"+`) and the approximate number of times the message has been received (`+"`aws.sqs.approximate_receive_count`"+`).`).
 		Fields(
 			service.NewURLField(sqsiFieldURL).
 				Description("The SQS URL to consume from."),
@@ -123,8 +779,13 @@ xref:configuration:interpolation.adoc#bloblang-queries[function interpolation].`
 				Default(10).
 				Advanced(),
 			service.NewIntField(sqsiFieldMaxOutstanding).
-				Description("The maximum number of outstanding pending messages to be consumed at a given time.").
+				Description("The maximum number of outstanding pending messages to be consumed at a given time. This is a soft limit unless `"+sqsiFieldStrictMaxOutstanding+"` is enabled, refer to the <<outstanding-messages, outstanding messages section>> for details.").
 				Default(1000),
+			service.NewBoolField(sqsiFieldStrictMaxOutstanding).
+				Description("Whether to enforce `"+sqsiFieldMaxOutstanding+"` as a hard limit by requesting no more messages from each poll than remain within the limit, rather than allowing a poll to exceed it.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
 			service.NewIntField(sqsiFieldWaitTimeSeconds).
 				Description("Whether to set the wait time. Enabling this activates long-polling. Valid values: 0 to 20.").
 				Default(0).
@@ -133,13 +794,331 @@ xref:configuration:interpolation.adoc#bloblang-queries[function interpolation].`
 				Description("The time to process messages before needing to refresh the receipt handle. Messages will be eligible for refresh when half of the timeout has elapsed. This sets MessageVisibility for each received message.").
 				Default("30s").
 				Advanced(),
+			service.NewInterpolatedStringField(sqsiFieldMessageTimeoutOverride).
+				Description("An optional duration that overrides `"+sqsiFieldMessageTimeout+"` for individual messages, evaluated against the metadata of each received message. This allows a single queue to carry messages that need different processing windows. When the result is empty or not a valid duration the value of `"+sqsiFieldMessageTimeout+"` is used instead.").
+				Version("4.64.0").
+				Example(`${! @processing_hint.or("") }`).
+				Optional().
+				Advanced(),
+			service.NewBloblangField(sqsiFieldMapping).
+				Description("An optional xref:guides:bloblang/about.adoc[Bloblang mapping] that is executed against each consumed message and its metadata before it is delivered, such as to redact sensitive fields as soon as a message is received. Refer to the <<mapping-messages, mapping messages section>> for details.").
+				Version("4.64.0").
+				Example(`root = this
+root.customer.email = deleted()`).
+				Optional().
+				Advanced(),
+			service.NewBloblangField(sqsiFieldFilter).
+				Description("An optional xref:guides:bloblang/about.adoc[Bloblang query] that is executed against each consumed message and its metadata, and must return a boolean. Messages for which the query returns `false` are not delivered downstream, which avoids paying the cost of processing unwanted messages from a shared queue.").
+				Version("4.64.0").
+				Example(`root = @event_type == "order_created"`).
+				Optional().
+				Advanced(),
+			service.NewBoolField(sqsiFieldDropFiltered).
+				Description("Whether messages rejected by the `"+sqsiFieldFilter+"` query should be deleted from the queue as if they were acked. Disabling this instead leaves filtered messages on the queue for other consumers, with their visibility set to `"+sqsiFieldFilteredVisibility+"` when it is set, reset to zero when `"+sqsiFieldResetVisibility+"` is enabled, or otherwise left to expire. Filtered messages can be received by this consumer again, which counts towards the redrive policy of the queue. Refer to the <<filtering, filtering section>> for more information.").
+				Version("4.64.0").
+				Default(true).
+				Advanced(),
+			service.NewDurationField(sqsiFieldFilteredVisibility).
+				Description("An optional visibility timeout applied to messages rejected by the `"+sqsiFieldFilter+"` query when `"+sqsiFieldDropFiltered+"` is disabled, which holds them back from being received again for that duration regardless of `"+sqsiFieldResetVisibility+"`. Valid values: 1s to 12h.").
+				Version("4.64.0").
+				Example("5m").
+				Optional().
+				Advanced(),
+			service.NewDurationField(sqsiFieldMaxMessageAge).
+				Description("An optional maximum age of messages, calculated from the time they were sent to the queue. Messages older than this are deleted from the queue without being delivered downstream, and are counted by the `"+sqsiMetricDroppedStale+"` metric.").
+				Version("4.64.0").
+				Example("1h").
+				Optional().
+				Advanced(),
+			service.NewIntField(sqsiFieldRefreshConcurrency).
+				Description("The maximum number of parallel requests used to refresh the visibility timeout of in-flight messages. Increasing this reduces the time taken to refresh large numbers of outstanding messages, which helps to avoid them becoming visible again before they are processed.").
+				Version("4.64.0").
+				Default(1).
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Advanced(),
+			service.NewDurationField(sqsiFieldDedupeTTL).
+				Description("An optional window within which messages received with the same key as a previously acknowledged message are treated as duplicates. Duplicates are deleted from the queue without being delivered downstream, and are counted by the `"+sqsiMetricDroppedDuplicate+"` metric.").
+				Version("4.64.0").
+				Example("5m").
+				Optional().
+				Advanced(),
+			service.NewInterpolatedStringField(sqsiFieldDedupeKey).
+				Description("An optional key used to identify duplicate messages, evaluated against each received message. By default the SQS message ID is used.").
+				Version("4.64.0").
+				Example(`${! @event_id }`).
+				Example(`${! content().hash("xxhash64").encode("hex") }`).
+				Optional().
+				Advanced(),
+			service.NewIntField(sqsiFieldDedupeMaxEntries).
+				Description("The maximum number of acknowledged keys held for deduplication. When this is exceeded the oldest keys are evicted.").
+				Version("4.64.0").
+				Default(10000).
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Advanced(),
+			service.NewBoolField(sqsiFieldCoerceAttributeTypes).
+				Description("Whether to convert message attributes to metadata values that match their SQS data type. When enabled `Number` attributes are added as integer or floating point values and `Binary` attributes are added as raw bytes, otherwise all attributes are added as strings and `Binary` attributes are omitted.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewStringField(sqsiFieldBodyMetadataKey).
+				Description("An optional metadata key under which the raw body of each message is stored, which preserves the original body after processors have replaced the payload. When empty the body is not added to metadata.").
+				Example("sqs_body").
+				Version("4.64.0").
+				Default("").
+				Advanced(),
+			service.NewStringField(sqsiFieldAckCheckpoint).
+				Description("An optional xref:components:caches/about.adoc[cache resource] used to record the IDs of messages that have been acknowledged before they are deleted from the queue. Messages that are received again after being recorded are deleted without being delivered downstream. Refer to the <<ack-checkpoints, ack checkpoints section>> for more information.").
+				Version("4.64.0").
+				Optional().
+				Advanced(),
+			service.NewBoolField(sqsiFieldDeliveryBatchHint).
+				Description("Whether to add metadata to each message identifying the `ReceiveMessage` call that it was received by and its position within the response, which allows downstream processors and batching policies to group messages by the batch they were received in. Refer to the <<metadata, metadata section>> for the keys that are added.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewBoolField(sqsiFieldPreservePollOrder).
+				Description("Whether to deliver the messages received by each `ReceiveMessage` call strictly in the order they were received, where each message is only delivered once the previous message has been acked. If a message is nacked then the remaining messages received by the same call have their visibility reset rather than being delivered, so that they do not overtake it. This limits throughput to one message in flight at a time. Standard queues do not guarantee the order of messages and so for those queues ordering is best-effort.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldQuarantineThreshold).
+				Description("The number of consecutive times a message can be nacked with the same error before it is quarantined. Set to zero in order to disable quarantining. Refer to the <<quarantine, quarantine section>> for more information.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Advanced(),
+			service.NewStringAnnotatedEnumField(sqsiFieldQuarantineAction, map[string]string{
+				sqsQuarantineActionDelete:           "Delete the message from the queue.",
+				sqsQuarantineActionExtendVisibility: "Set the visibility timeout of the message to `" + sqsiFieldQuarantineVisibility + "`.",
+				sqsQuarantineActionTag:              "Add metadata to the message each time it is delivered again.",
+			}).
+				Description("The action to take when a message is quarantined.").
+				Version("4.64.0").
+				Default(sqsQuarantineActionTag).
+				Advanced(),
+			service.NewDurationField(sqsiFieldQuarantineVisibility).
+				Description("The visibility timeout applied to quarantined messages when `"+sqsiFieldQuarantineAction+"` is `"+sqsQuarantineActionExtendVisibility+"`. Valid values: 1s to 12h.").
+				Version("4.64.0").
+				Default("12h").
+				Advanced(),
+			service.NewIntField(sqsiFieldQuarantineMaxEntries).
+				Description("The maximum number of messages for which nack counts are held. When this is exceeded the messages least recently nacked are forgotten.").
+				Version("4.64.0").
+				Default(10000).
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Advanced(),
+			service.NewDurationField(sqsiFieldReceiveCountWindow).
+				Description("The window over which the highest approximate receive count of received messages is tracked by the `"+sqsiMetricMaxReceiveCount+"` metric, after which the tracked value is reset.").
+				Version("4.64.0").
+				Default("1m").
+				Advanced(),
+			service.NewStringField(sqsiFieldUserAgentSuffix).
+				Description("An optional value appended to the User-Agent header of requests made to SQS, which allows them to be identified in AWS CloudTrail logs, for example in order to attribute costs to an application. The value should be of the form `name` or `name/version`, and characters that are not permitted within a User-Agent are replaced with `-`. When empty the standard User-Agent is used.").
+				Example("app/order-service").
+				Version("4.64.0").
+				Default("").
+				Advanced(),
+			service.NewIntField(sqsiFieldClientRebuildThreshold).
+				Description("The number of consecutive `network` or `auth` errors returned when receiving messages after which the SQS client is rebuilt from a freshly resolved AWS configuration. By default the client is never rebuilt.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Advanced(),
+			service.NewBoolField(sqsiFieldS3EventMode).
+				Description("Whether to parse the body of each message as an S3 event notification, emitting one message for each record along with its bucket name, object key and event name as metadata.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldMaxInFlightDeliveries).
+				Description("The maximum number of messages that can be delivered downstream and awaiting acknowledgement at any given time, which can be used to protect a fragile downstream regardless of the number of pipeline threads. Unlike `"+sqsiFieldMaxOutstanding+"` this does not limit the number of messages received from the queue ahead of delivery. Set to `0` for no limit.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Advanced(),
+			service.NewBoolField(sqsiFieldVerifyMD5).
+				Description("Whether to verify that the MD5 digest of the body of each message matches the digest calculated by SQS, rejecting messages that do not. This is disabled by default in order to avoid the cost of hashing every message.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldPrefetchCount).
+				Description("The number of received messages to buffer ahead of being read, which allows the next messages to be delivered without waiting on a request to SQS when downstream consumption is bursty, at the cost of memory and of holding more messages in flight. Prefetched messages that have not been delivered when the input shuts down have their visibility reset. Prefetched messages count towards `"+sqsiFieldMaxOutstanding+"`.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Advanced(),
+			service.NewDurationField(sqsiFieldBacklogPollInterval).
+				Description("The interval at which the approximate number of visible and in-flight messages of the queue are polled and reported by the `"+sqsiMetricBacklogVisible+"` and `"+sqsiMetricBacklogInFlight+"` metrics, which requires the `sqs:GetQueueAttributes` permission. Set to `0s` to disable polling.").
+				Version("4.64.0").
+				Default("0s").
+				Example("30s").
+				Advanced(),
+			service.NewBoolField(sqsiFieldDetectSequenceGaps).
+				Description("Whether to log a warning when a message of a FIFO queue is delivered with a lower sequence number than a previous message of the same message group.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewBoolField(sqsiFieldSNSUnwrap).
+				Description("Whether to replace the body of each message that is an SNS notification envelope with the published message, adding the topic ARN, subject and message attributes of the notification as metadata.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewDurationField(sqsiFieldAckFlushIdlePeriod).
+				Description("Acknowledged and rejected messages are deleted or have their visibility reset in batches, which are sent once `"+sqsiFieldMaxNumberOfMessages+"` messages are pending or every second. When no further messages are acknowledged or rejected for this period the pending messages are sent early, which reduces the latency of deletes when messages are consumed in small bursts. By default only full batches are sent early.").
+				Version("4.64.0").
+				Default("0s").
+				Advanced(),
+			service.NewIntField(sqsiFieldReceiveBatchMultiplier).
+				Description("The number of `ReceiveMessage` calls made in parallel each time messages are received. When greater than `1` the messages received together, up to this number multiplied by `"+sqsiFieldMaxNumberOfMessages+"`, are delivered as a single batch, which allows batches larger than the SQS limit of 10 messages to be assembled for bulk outputs.").
+				Version("4.64.0").
+				Default(1).
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Advanced(),
+			service.NewInterpolatedStringField(sqsiFieldCommitGroup).
+				Description("An optional commit group evaluated against each received message. Acknowledged messages of a group are only deleted from the queue once the group has been committed by writing its name to `"+sqsiFieldCommitCache+"`. Refer to the <<commit-groups, commit groups section>> for more information.").
+				Version("4.64.0").
+				Example(`${! @transaction_id }`).
+				Example(`${! json("batch.id") }`).
+				Optional().
+				Advanced(),
+			service.NewStringField(sqsiFieldCommitCache).
+				Description("The xref:components:caches/about.adoc[cache resource] that groups are committed to, which must be set when `"+sqsiFieldCommitGroup+"` is set.").
+				Version("4.64.0").
+				Optional().
+				Advanced(),
+			service.NewDurationField(sqsiFieldCommitTimeout).
+				Description("The maximum period to wait for a group to be committed after its first message is acknowledged, after which the messages of the group are returned to the queue to be delivered again.").
+				Version("4.64.0").
+				Default("5m").
+				Advanced(),
+			service.NewStringField(sqsiFieldAttributePrefix).
+				Description("An optional prefix added to the metadata key of each message attribute, which prevents attributes from colliding with the `sqs_` metadata fields added by this input or with other metadata. When empty attributes are added under their original names.").
+				Example("attr_").
+				Version("4.64.0").
+				Default("").
+				Advanced(),
+			service.NewBoolField(sqsiFieldFastDrain).
+				Description("Whether to delete messages from the queue as soon as they are received, before they are processed, in order to drain a queue as fast as possible during maintenance. This is lossy, refer to the <<fast-draining, fast draining section>> before enabling it.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewBoolField(sqsiFieldFetchRedrivePolicy).
+				Description("Whether to fetch the redrive policy of the queue when connecting, which requires the `sqs:GetQueueAttributes` permission. The dead letter queue and maximum receive count of the policy are logged and added to the metadata of each message, which allows the pipeline to align its behavior with the queue. The input continues without the policy when it cannot be fetched.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldMaxBodyBytes).
+				Description("The maximum size in bytes of the body of a message, beyond which `"+sqsiFieldMaxBodyAction+"` is taken. Refer to the <<body-integrity, body integrity section>> for details. Set to `0` for no limit.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Example(65536).
+				Advanced(),
+			service.NewStringAnnotatedEnumField(sqsiFieldMaxBodyAction, map[string]string{
+				sqsMaxBodyActionTruncate: "Truncate the body to `" + sqsiFieldMaxBodyBytes + "` and add the `sqs_truncated` metadata field.",
+				sqsMaxBodyActionReject:   "Reject the message so that it is received again once its message timeout has elapsed.",
+				sqsMaxBodyActionDrop:     "Delete the message from the queue without delivering it.",
+			}).
+				Description("The action to take on messages with a body larger than `"+sqsiFieldMaxBodyBytes+"`.").
+				Version("4.64.0").
+				Default(sqsMaxBodyActionReject).
+				Advanced(),
+			service.NewStringField(sqsiFieldJSONSchema).
+				Description("An optional JSON schema that the body of each message must conform to, or the path of a file containing the schema prefixed with `file://`. Refer to the <<schema-validation, schema validation section>> for details.").
+				Version("4.64.0").
+				Example(`{"type":"object","required":["id"]}`).
+				Example("file://./schemas/order.json").
+				Optional().
+				Advanced(),
+			service.NewStringAnnotatedEnumField(sqsiFieldJSONSchemaAction, map[string]string{
+				sqsSchemaActionReject: "Reject the message so that it is received again once its message timeout has elapsed.",
+				sqsSchemaActionDrop:   "Delete the message from the queue without delivering it.",
+				sqsSchemaActionFlag:   "Deliver the message with the `sqs_schema_invalid` and `sqs_schema_error` metadata fields.",
+			}).
+				Description("The action to take on messages with a body that does not conform to `"+sqsiFieldJSONSchema+"`.").
+				Version("4.64.0").
+				Default(sqsSchemaActionReject).
+				Advanced(),
+			service.NewDurationField(sqsiFieldStartupDelay).
+				Description("The duration to wait after connecting before the first messages are received, which gives dependent resources such as caches and connections time to warm up so that the first messages received do not fail downstream and needlessly increase their receive counts. Set to `0s` to receive immediately.").
+				Version("4.64.0").
+				Default("0s").
+				Example("30s").
+				Advanced(),
+			service.NewBoolField(sqsiFieldReceiveAttemptID).
+				Description("Whether to set a `ReceiveRequestAttemptId` on each `ReceiveMessage` call to a FIFO queue, which makes retries of a failed call idempotent. Refer to the <<fifo-ordering, FIFO ordering section>> for details.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldMaxMessages).
+				Description("The maximum number of messages to receive before the input ends, which allows a queue to be consumed as a bounded batch job. Refer to the <<bounded-jobs, bounded jobs section>> for details. Set to `0` for no limit.").
+				Version("4.64.0").
+				Default(0).
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Example(10000).
+				Advanced(),
+			service.NewDurationField(sqsiFieldMaxRuntime).
+				Description("The maximum duration to receive messages for after first connecting, after which the input ends. Refer to the <<bounded-jobs, bounded jobs section>> for details. Set to `0s` for no limit.").
+				Version("4.64.0").
+				Default("0s").
+				Example("1h").
+				Advanced(),
+			service.NewBoolField(sqsiFieldLoadQueueTags).
+				Description("Whether to list the tags of the queue when connecting, which requires the `sqs:ListQueueTags` permission. Each tag is added to the metadata of every message with its key prefixed by `sqs_tag_`, which makes tags such as cost centers available without a separate lookup. The input continues without the tags when they cannot be listed.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewDurationField(sqsiFieldStallTimeout).
+				Description("The duration without any message being acknowledged or rejected, while messages are in flight, after which the pipeline is considered stalled and receiving is paused. Refer to the <<stalled-pipelines, stalled pipelines section>> for details. Set to `0s` to never pause receiving.").
+				Version("4.64.0").
+				Default("0s").
+				Example("5m").
+				Advanced(),
+			service.NewDurationField(sqsiFieldStallMessageTimeout).
+				Description("The visibility timeout applied when refreshing messages that are in flight while the pipeline is stalled, which reduces the rate of refreshes when longer than `"+sqsiFieldMessageTimeout+"`. Cannot exceed `12h`.").
+				Version("4.64.0").
+				Default("10m").
+				Advanced(),
+			service.NewStringListField(sqsiFieldIncludeAttributes).
+				Description("A list of message attribute names to add as metadata, where all other attributes are dropped. Names may contain glob patterns such as `x-trace-*`. When omitted all attributes are added.").
+				Version("4.64.0").
+				Example([]string{"trace_id", "x-tenant-*"}).
+				Optional().
+				Advanced(),
+			service.NewStringListField(sqsiFieldExcludeAttributes).
+				Description("A list of message attribute names to never add as metadata, which may contain glob patterns such as `x-internal-*`. Exclusions take precedence over `"+sqsiFieldIncludeAttributes+"`, such that an attribute matching both lists is dropped.").
+				Version("4.64.0").
+				Example([]string{"password", "x-internal-*"}).
+				Optional().
+				Advanced(),
+			service.NewBoolField(sqsiFieldEmitDrainMarker).
+				Description("Whether to emit a marker message with an empty body and the metadata field `sqs_drain_complete` set to `true` once the queue has been drained. Refer to the <<drain-markers, drain markers section>> for details.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(sqsiFieldDrainMarkerReceives).
+				Description("The number of consecutive receives that return no messages, while no messages are in flight, after which the queue is considered drained when `"+sqsiFieldEmitDrainMarker+"` is enabled.").
+				Version("4.64.0").
+				Default(3).
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Advanced(),
+			service.NewStringField(sqsiFieldRateLimit).
+				Description("An optional xref:components:rate_limits/about.adoc[rate limit resource] that every call to SQS waits for access from, including receives, deletes and visibility changes. Sharing the resource with other components caps the rate of requests across all of them, such as when several inputs consume queues of the same account. Refer to the <<rate-limiting, rate limiting section>> for details.").
+				Version("4.64.0").
+				Optional().
+				Advanced(),
+			service.NewDurationField(sqsiFieldHeartbeatInterval).
+				Description("The interval at which to log the number of receive requests that completed and messages that were received since the last interval, and to update the `"+sqsiMetricLastReceive+"` metric. This distinguishes an input that is healthy but idle from one that is stuck, and should be longer than `"+sqsiFieldWaitTimeSeconds+"`. Set to `0s` to disable the heartbeat.").
+				Version("4.64.0").
+				Default("0s").
+				Example("1m").
+				Advanced(),
 		).
 		Fields(config.SessionFields()...)
 }
 
 func init() {
-	service.MustRegisterInput("aws_sqs", sqsInputSpec(),
-		func(pConf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+	service.MustRegisterBatchInput("aws_sqs", sqsInputSpec(),
+		func(pConf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
 			sess, err := GetSession(context.TODO(), pConf)
 			if err != nil {
 				return nil, err
@@ -150,7 +1129,14 @@ func init() {
 				return nil, err
 			}
 
-			return newAWSSQSReader(conf, sess, mgr.Logger())
+			r, err := newAWSSQSReader(conf, sess, mgr)
+			if err != nil {
+				return nil, err
+			}
+			r.loadConfig = func(ctx context.Context) (aws.Config, error) {
+				return GetSession(ctx, pConf)
+			}
+			return r, nil
 		})
 }
 
@@ -160,55 +1146,220 @@ type sqsAPI interface {
 	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
 	DeleteMessageBatch(context.Context, *sqs.DeleteMessageBatchInput, ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
 	ChangeMessageVisibilityBatch(context.Context, *sqs.ChangeMessageVisibilityBatchInput, ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error)
+	GetQueueAttributes(context.Context, *sqs.GetQueueAttributesInput, ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	ListQueueTags(context.Context, *sqs.ListQueueTagsInput, ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error)
 	SendMessageBatch(context.Context, *sqs.SendMessageBatchInput, ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
 }
 
+type sqsTimer interface {
+	Timing(delta int64, labelValues ...string)
+}
+
 type awsSQSReader struct {
-	conf sqsiConfig
+	conf      sqsiConfig
+	mgr       *service.Resources
+	queueName string
 
-	aconf aws.Config
-	sqs   sqsAPI
+	sqsMut     sync.RWMutex
+	aconf      aws.Config
+	sqs        sqsAPI
+	loadConfig func(context.Context) (aws.Config, error)
+	newClient  func(aws.Config) sqsAPI
 
 	messagesChan     chan sqsMessage
 	ackMessagesChan  chan *sqsMessageHandle
 	nackMessagesChan chan *sqsMessageHandle
 	closeSignal      *shutdown.Signaller
 
-	log *service.Logger
+	dedupe    *sqsDedupeCache
+	nacks     *sqsNackTracker
+	sequences *sqsSequenceTracker
+	pollOrder sqsPollOrder
+	commits   *sqsCommitGroups
+	pending   sqsPendingMessages
+	batchMut  sync.Mutex
+
+	// Holds a slot for each delivered message awaiting acknowledgement when
+	// max_in_flight_deliveries is set.
+	deliveries chan struct{}
+	heartbeat  sqsHeartbeat
+	// The tracker of the messages in flight since the last connect
+	inFlight *sqsInFlightTracker
+	// The attempt IDs of receive calls when receive_request_attempt_id is
+	// enabled
+	receiveAttempts *sqsReceiveAttempts
+	// The redrive policy of the queue when fetch_redrive_policy is enabled
+	redrive atomic.Pointer[sqsRedrivePolicy]
+	// The limits of the input when max_messages or max_runtime are set, which
+	// is otherwise nil
+	limits *sqsReceiveLimits
+	// Detects a stalled pipeline when stall_timeout is set, which is otherwise
+	// nil
+	stall *sqsStallDetector
+
+	// Selects the message attributes that are added as metadata.
+	attributes *sqsAttributeFilter
+	// Validates the bodies of messages when a JSON schema is configured.
+	schema *sqsSchemaValidator
+	// The tags of the queue when load_queue_tags is enabled
+	queueTags atomic.Pointer[map[string]string]
+
+	droppedStaleMetric     *service.MetricCounter
+	droppedDuplicateMetric *service.MetricCounter
+	droppedAckedMetric     *service.MetricCounter
+	dwellTimeMetric        *service.MetricTimer
+	maxReceiveCount        *sqsMaxReceiveCount
+	quarantinedMetric      *service.MetricCounter
+	clientRebuildsMetric   *service.MetricCounter
+	md5MismatchMetric      *service.MetricCounter
+	oversizedMetric        *service.MetricCounter
+	schemaInvalidMetric    *service.MetricCounter
+	mappingFailedMetric    *service.MetricCounter
+	ackLatencyMetric       sqsTimer
+	throttledMetric        sqsErrorCounter
+	backlogVisibleGauge    sqsGauge
+	lastReceiveGauge       sqsGauge
+	backlogNotVisibleGauge sqsGauge
+	errReporter            *sqsErrorReporter
+
+	log    *service.Logger
+	tracer trace.TracerProvider
 }
 
-func newAWSSQSReader(conf sqsiConfig, aconf aws.Config, log *service.Logger) (*awsSQSReader, error) {
-	return &awsSQSReader{
-		conf:             conf,
-		aconf:            aconf,
-		log:              log,
-		messagesChan:     make(chan sqsMessage),
-		ackMessagesChan:  make(chan *sqsMessageHandle),
-		nackMessagesChan: make(chan *sqsMessageHandle),
-		closeSignal:      shutdown.NewSignaller(),
-	}, nil
+func newAWSSQSReader(conf sqsiConfig, aconf aws.Config, mgr *service.Resources) (*awsSQSReader, error) {
+	var dedupe *sqsDedupeCache
+	if conf.DedupeTTL > 0 {
+		dedupe = newSQSDedupeCache(conf.DedupeTTL, conf.DedupeMaxEntries)
+	}
+	var nacks *sqsNackTracker
+	if conf.QuarantineThreshold > 0 {
+		nacks = newSQSNackTracker(conf.QuarantineMaxEntries)
+	}
+	var sequences *sqsSequenceTracker
+	if conf.DetectSequenceGaps {
+		sequences = newSQSSequenceTracker(sqsSequenceMaxGroups)
+	}
+	var deliveries chan struct{}
+	if conf.MaxInFlightDeliveries > 0 {
+		deliveries = make(chan struct{}, conf.MaxInFlightDeliveries)
+	}
+	if conf.AckCheckpoint != "" && !mgr.HasCache(conf.AckCheckpoint) {
+		return nil, fmt.Errorf("unknown cache resource: %s", conf.AckCheckpoint)
+	}
+	if conf.RateLimit != "" && !mgr.HasRateLimit(conf.RateLimit) {
+		return nil, fmt.Errorf("unknown rate limit resource: %s", conf.RateLimit)
+	}
+	var commits *sqsCommitGroups
+	if conf.CommitGroup != nil {
+		if !mgr.HasCache(conf.CommitCache) {
+			return nil, fmt.Errorf("unknown cache resource: %s", conf.CommitCache)
+		}
+		commits = newSQSCommitGroups()
+	}
+	r := &awsSQSReader{
+		conf:                   conf,
+		mgr:                    mgr,
+		queueName:              sqsQueueName(conf.URL),
+		tracer:                 mgr.OtelTracer(),
+		aconf:                  aconf,
+		log:                    mgr.Logger(),
+		messagesChan:           make(chan sqsMessage, conf.PrefetchCount),
+		ackMessagesChan:        make(chan *sqsMessageHandle),
+		nackMessagesChan:       make(chan *sqsMessageHandle),
+		closeSignal:            shutdown.NewSignaller(),
+		dedupe:                 dedupe,
+		nacks:                  nacks,
+		sequences:              sequences,
+		commits:                commits,
+		deliveries:             deliveries,
+		droppedStaleMetric:     mgr.Metrics().NewCounter(sqsiMetricDroppedStale),
+		droppedDuplicateMetric: mgr.Metrics().NewCounter(sqsiMetricDroppedDuplicate),
+		droppedAckedMetric:     mgr.Metrics().NewCounter(sqsiMetricDroppedAcked),
+		dwellTimeMetric:        mgr.Metrics().NewTimer(sqsiMetricDwellTime),
+		maxReceiveCount:        newSQSMaxReceiveCount(mgr.Metrics().NewGauge(sqsiMetricMaxReceiveCount), conf.ReceiveCountWindow),
+		quarantinedMetric:      mgr.Metrics().NewCounter(sqsiMetricQuarantined),
+		clientRebuildsMetric:   mgr.Metrics().NewCounter(sqsiMetricClientRebuilds),
+		md5MismatchMetric:      mgr.Metrics().NewCounter(sqsiMetricMD5Mismatch),
+		oversizedMetric:        mgr.Metrics().NewCounter(sqsiMetricOversized),
+		schemaInvalidMetric:    mgr.Metrics().NewCounter(sqsiMetricSchemaInvalid),
+		mappingFailedMetric:    mgr.Metrics().NewCounter(sqsiMetricMappingFailed),
+		ackLatencyMetric:       mgr.Metrics().NewTimer(sqsiMetricAckLatency, "outcome"),
+		throttledMetric:        mgr.Metrics().NewCounter(sqsiMetricThrottled),
+		backlogVisibleGauge:    mgr.Metrics().NewGauge(sqsiMetricBacklogVisible),
+		backlogNotVisibleGauge: mgr.Metrics().NewGauge(sqsiMetricBacklogInFlight),
+		lastReceiveGauge:       mgr.Metrics().NewGauge(sqsiMetricLastReceive),
+		errReporter: newSQSErrorReporter(
+			mgr.Metrics().NewCounter(sqsiMetricErrors, "operation", "category"),
+			sqsiErrorLogInterval,
+		),
+	}
+	if conf.ReceiveAttemptID {
+		r.receiveAttempts = newSQSReceiveAttempts(conf.ReceiveBatchMultiplier)
+		if !strings.HasSuffix(conf.URL, ".fifo") {
+			r.log.Warnf("Field %v is enabled for queue %v, which does not appear to be a FIFO queue and ignores the receive request attempt ID", sqsiFieldReceiveAttemptID, r.queueName)
+		}
+	}
+	r.limits = newSQSReceiveLimits(conf.MaxMessages, conf.MaxRuntime)
+	r.stall = newSQSStallDetector(conf.StallTimeout, time.Now())
+	var err error
+	if r.attributes, err = newSQSAttributeFilter(conf.IncludeAttributes, conf.ExcludeAttributes); err != nil {
+		return nil, err
+	}
+	if r.schema, err = newSQSSchemaValidator(conf.JSONSchema, mgr.FS()); err != nil {
+		return nil, err
+	}
+	if perPoll := conf.MaxNumberOfMessages * max(conf.ReceiveBatchMultiplier, 1); !conf.FastDrain && !conf.StrictMaxOutstanding && conf.MaxOutstanding < perPoll {
+		r.log.Warnf("Field %v (%v) is lower than the %v messages that a single poll can receive, and so more messages than the limit can be in flight. Enable %v to enforce it as a hard limit", sqsiFieldMaxOutstanding, conf.MaxOutstanding, perPoll, sqsiFieldStrictMaxOutstanding)
+	}
+	if conf.FastDrain {
+		r.log.Warn("Fast drain is enabled, messages are deleted from the queue as soon as they are received and are lost if they fail to be processed")
+	}
+	r.newClient = r.newSQSClient
+	return r, nil
 }
 
 // Connect attempts to establish a connection to the target SQS
 // queue.
-func (a *awsSQSReader) Connect(context.Context) error {
+func (a *awsSQSReader) Connect(ctx context.Context) error {
+	a.sqsMut.Lock()
 	if a.sqs == nil {
-		a.sqs = sqs.NewFromConfig(a.aconf)
+		a.sqs = a.newClient(a.aconf)
+	}
+	a.sqsMut.Unlock()
+
+	if a.conf.FetchRedrivePolicy {
+		a.loadRedrivePolicy(ctx)
 	}
+	if a.conf.LoadQueueTags {
+		a.loadQueueTags(ctx)
+	}
+	a.limits.Start(time.Now())
 
 	ift := &sqsInFlightTracker{
 		handles: map[string]*list.Element{},
 		fifo:    list.New(),
 		limit:   a.conf.MaxOutstanding,
-		timeout: a.conf.MessageTimeout,
 	}
 	ift.l = sync.NewCond(&ift.m)
+	a.inFlight = ift
 
 	var wg sync.WaitGroup
 	wg.Add(3)
 	go a.readLoop(&wg, ift)
 	go a.ackLoop(&wg, ift)
 	go a.refreshLoop(&wg, ift)
+	if a.conf.BacklogPollInterval > 0 {
+		wg.Add(1)
+		go a.backlogLoop(&wg, a.conf.BacklogPollInterval)
+	}
+	if a.conf.HeartbeatInterval > 0 {
+		wg.Add(1)
+		go a.heartbeatLoop(&wg, a.conf.HeartbeatInterval)
+	}
+	if a.commits != nil {
+		wg.Add(1)
+		go a.commitLoop(&wg)
+	}
 	go func() {
 		wg.Wait()
 		a.closeSignal.TriggerHasStopped()
@@ -216,36 +1367,78 @@ func (a *awsSQSReader) Connect(context.Context) error {
 	return nil
 }
 
+// sqsClientOptions returns the options applied to the SQS client created when
+// connecting.
+func (a *awsSQSReader) sqsClientOptions() []func(*sqs.Options) {
+	if a.conf.UserAgentSuffix == "" {
+		return nil
+	}
+	addUserAgent := awsmiddleware.AddUserAgentKey(a.conf.UserAgentSuffix)
+	if key, value, ok := strings.Cut(a.conf.UserAgentSuffix, "/"); ok {
+		addUserAgent = awsmiddleware.AddUserAgentKeyValue(key, value)
+	}
+	return []func(*sqs.Options){
+		func(o *sqs.Options) {
+			o.APIOptions = append(o.APIOptions, addUserAgent)
+		},
+	}
+}
+
 type sqsInFlightTracker struct {
 	handles map[string]*list.Element
 	fifo    *list.List // contains *sqsMessageHandle
 	limit   int
-	timeout time.Duration
 	m       sync.Mutex
 	l       *sync.Cond
 }
 
-func (t *sqsInFlightTracker) PullToRefresh(limit int) []*sqsMessageHandle {
+// PullToRefresh returns up to limit handles that are due to be refreshed, and
+// sets their deadline as though they were refreshed with their timeout or
+// minTimeout, whichever is longer.
+func (t *sqsInFlightTracker) PullToRefresh(limit int, minTimeout time.Duration) []*sqsMessageHandle {
 	t.m.Lock()
 	defer t.m.Unlock()
 
 	handles := make([]*sqsMessageHandle, 0, limit)
 	now := time.Now()
-	// Pull the front of our fifo until we reach our limit or we reach elements that do not
-	// need to be refreshed
-	for e := t.fifo.Front(); e != nil && len(handles) < limit; e = t.fifo.Front() {
+	// Walk our fifo until we reach our limit, skipping elements that do not need
+	// to be refreshed yet. Handles may have different timeouts so we cannot stop
+	// at the first element that isn't due.
+	for e := t.fifo.Front(); e != nil && len(handles) < limit; {
+		next := e.Next()
 		v := e.Value.(*sqsMessageHandle)
-		if v.deadline.Sub(now) > (t.timeout / 2) {
-			break
+		timeout := max(v.timeout, minTimeout)
+		if v.deadline.Sub(now) <= (timeout / 2) {
+			handles = append(handles, v)
+			v.deadline = now.Add(timeout)
+			// Keep recently refreshed elements at the back of our fifo
+			t.fifo.MoveToBack(e)
 		}
-		handles = append(handles, v)
-		v.deadline = now.Add(t.timeout)
-		// Keep our fifo in deadline sorted order
-		t.fifo.MoveToBack(e)
+		e = next
 	}
 	return handles
 }
 
+// Extend sets the visibility timeout applied when refreshing a message that
+// is in flight, and schedules the message to be refreshed with it on the next
+// pass of the refresh loop. Returns false if the message is no longer in
+// flight.
+func (t *sqsInFlightTracker) Extend(id string, timeout time.Duration) bool {
+	t.m.Lock()
+	defer t.m.Unlock()
+	e, ok := t.handles[id]
+	if !ok {
+		return false
+	}
+	// The handle may be in use by a refresh that is in progress, and so it is
+	// replaced rather than modified.
+	h := *e.Value.(*sqsMessageHandle)
+	h.timeout = timeout
+	h.deadline = time.Now()
+	e.Value = &h
+	return true
+}
+
 func (t *sqsInFlightTracker) Size() int {
 	t.m.Lock()
 	defer t.m.Unlock()
@@ -278,6 +1471,21 @@ func (t *sqsInFlightTracker) Clear() {
 	t.l.Signal()
 }
 
+// Capacity waits until fewer messages than the limit are in flight, and
+// returns the number of messages that can be added without exceeding it.
+func (t *sqsInFlightTracker) Capacity(ctx context.Context) int {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	for len(t.handles) >= t.limit {
+		if ctx.Err() != nil {
+			return 0
+		}
+		t.l.Wait()
+	}
+	return t.limit - len(t.handles)
+}
+
 func (t *sqsInFlightTracker) AddNew(ctx context.Context, messages ...sqsMessage) {
 	t.m.Lock()
 	defer t.m.Unlock()
@@ -328,7 +1536,9 @@ func (a *awsSQSReader) ackLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlightT
 		})
 		if erase {
 			if err := a.deleteMessages(closeNowCtx, handles...); err != nil {
-				a.log.Errorf("Failed to delete messages: %v", err)
+				if l := a.reportError(sqsiOpDelete, err); l != nil {
+					l.Errorf("Failed to delete messages: %v", err)
+				}
 			}
 		} else {
 			if err := a.resetMessages(closeNowCtx, handles...); err != nil {
@@ -337,7 +1547,9 @@ func (a *awsSQSReader) ackLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlightT
 				// if this succeeds anyways as it might be racing with the refresh loop. Fixing that
 				// would mean moving nacks to the refresh loop, but I don't think this will be a big deal in
 				// practice.
-				a.log.Infof("Failed to reset the visibility timeout of messages: %v", err)
+				if l := a.reportError(sqsiOpReset, err); l != nil {
+					l.Infof("Failed to reset the visibility timeout of messages: %v", err)
+				}
 			}
 		}
 	}
@@ -345,6 +1557,22 @@ func (a *awsSQSReader) ackLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlightT
 	flushTimer := time.NewTicker(time.Second)
 	defer flushTimer.Stop()
 
+	// Flushes pending handles early once acks and nacks stop arriving, which
+	// happens at most once per idle period and so bounds the number of calls.
+	var idleTimer *time.Timer
+	var idleChan <-chan time.Time
+	if a.conf.AckFlushIdlePeriod > 0 {
+		idleTimer = time.NewTimer(a.conf.AckFlushIdlePeriod)
+		idleTimer.Stop()
+		defer idleTimer.Stop()
+		idleChan = idleTimer.C
+	}
+	resetIdleTimer := func() {
+		if idleTimer != nil {
+			idleTimer.Reset(a.conf.AckFlushIdlePeriod)
+		}
+	}
+
 	pendingAcks := []*sqsMessageHandle{}
 	pendingNacks := []*sqsMessageHandle{}
 
@@ -358,6 +1586,7 @@ ackLoop:
 				flushFinishedHandles(pendingAcks, true)
 				pendingAcks = pendingAcks[:0]
 			}
+			resetIdleTimer()
 		case h := <-a.nackMessagesChan:
 			pendingNacks = append(pendingNacks, h)
 			inFlightTracker.Remove(h.id)
@@ -365,6 +1594,12 @@ ackLoop:
 				flushFinishedHandles(pendingNacks, false)
 				pendingNacks = pendingNacks[:0]
 			}
+			resetIdleTimer()
+		case <-idleChan:
+			flushFinishedHandles(pendingAcks, true)
+			pendingAcks = pendingAcks[:0]
+			flushFinishedHandles(pendingNacks, false)
+			pendingNacks = pendingNacks[:0]
 		case <-flushTimer.C:
 			flushFinishedHandles(pendingAcks, true)
 			pendingAcks = pendingAcks[:0]
@@ -383,17 +1618,21 @@ func (a *awsSQSReader) refreshLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFli
 	defer wg.Done()
 	closeNowCtx, done := a.closeSignal.HardStopCtx(context.Background())
 	defer done()
-	refreshCurrentHandles := func() {
+	refreshWorker := func() {
 		for !a.closeSignal.IsSoftStopSignalled() {
 			// updateVisibilityMessages can only make an API request with 10 messages at most, so grab 10 then refresh to prevent
 			// an issue where we grab a ton of messages and they are acked before we actual make the API call. Note that this scenario
 			// can still happen because we refresh async with acking, but this makes it a lot less likely.
-			currentHandles := inFlightTracker.PullToRefresh(10)
+			var minTimeout time.Duration
+			if a.stall.Stalled(time.Now()) {
+				minTimeout = a.conf.StallMessageTimeout
+			}
+			currentHandles := inFlightTracker.PullToRefresh(10, minTimeout)
 			if len(currentHandles) == 0 {
 				// There is nothing to refresh, return and sleep for a second
 				return
 			}
-			err := a.updateVisibilityMessages(closeNowCtx, int(a.conf.MessageTimeout.Seconds()), currentHandles...)
+			err := a.refreshMessages(closeNowCtx, minTimeout, currentHandles...)
 			if err == nil {
 				continue
 			}
@@ -416,6 +1655,24 @@ func (a *awsSQSReader) refreshLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFli
 		}
 	}
 
+	refreshCurrentHandles := func() {
+		if a.conf.RefreshConcurrency <= 1 {
+			refreshWorker()
+			return
+		}
+		// Each worker pulls its own batches from the tracker until there is
+		// nothing left to refresh.
+		var workersWG sync.WaitGroup
+		workersWG.Add(a.conf.RefreshConcurrency)
+		for range a.conf.RefreshConcurrency {
+			go func() {
+				defer workersWG.Done()
+				refreshWorker()
+			}()
+		}
+		workersWG.Wait()
+	}
+
 	for {
 		select {
 		case <-time.After(time.Second):
@@ -430,7 +1687,18 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlight
 	defer wg.Done()
 
 	var pendingMsgs []sqsMessage
+	var poll uint64
 	defer func() {
+		// Return prefetched messages that have not been read to the queue
+		// along with any that are still pending.
+		for drained := false; !drained; {
+			select {
+			case m := <-a.messagesChan:
+				pendingMsgs = append(pendingMsgs, m)
+			default:
+				drained = true
+			}
+		}
 		if len(pendingMsgs) > 0 {
 			tmpNacks := make([]*sqsMessageHandle, 0, len(pendingMsgs))
 			for _, m := range pendingMsgs {
@@ -442,7 +1710,9 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlight
 			ctx, done := a.closeSignal.HardStopCtx(context.Background())
 			defer done()
 			if err := a.resetMessages(ctx, tmpNacks...); err != nil {
-				a.log.Errorf("Failed to reset visibility timeout for pending messages: %v", err)
+				if l := a.reportError(sqsiOpReset, err); l != nil {
+					l.Errorf("Failed to reset visibility timeout for pending messages: %v", err)
+				}
 			}
 		}
 	}()
@@ -450,44 +1720,137 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlight
 	closeAtLeisureCtx, done := a.closeSignal.SoftStopCtx(context.Background())
 	defer done()
 
+	// Wake up any wait for the in flight messages to drop below the limit so
+	// that it observes the close.
+	stopWake := context.AfterFunc(closeAtLeisureCtx, func() {
+		inFlightTracker.m.Lock()
+		inFlightTracker.l.Broadcast()
+		inFlightTracker.m.Unlock()
+	})
+	defer stopWake()
+
+	// The options are applied before the initial reset, otherwise the first
+	// interval would be based on the default initial interval.
+	throttleBackoff := backoff.NewExponentialBackOff(
+		backoff.WithInitialInterval(sqsiThrottleInitialBackOff),
+		backoff.WithMaxInterval(sqsiThrottleMaxBackOff),
+		backoff.WithMaxElapsedTime(0),
+	)
+
 	backoff := backoff.NewExponentialBackOff()
 	backoff.InitialInterval = 10 * time.Millisecond
 	backoff.MaxInterval = time.Minute
 	backoff.MaxElapsedTime = 0
 
+	if a.conf.StartupDelay > 0 {
+		a.log.Infof("Waiting %v before receiving messages", a.conf.StartupDelay)
+		select {
+		case <-time.After(a.conf.StartupDelay):
+		case <-a.closeSignal.SoftStopChan():
+			return
+		}
+	}
+
+	var receiveFailures, emptyReceives int
+	var throttled, paused bool
+	// Whether a drain marker is due once the queue is next drained
+	drainPending := true
 	getMsgs := func() {
-		res, err := a.sqs.ReceiveMessage(closeAtLeisureCtx, &sqs.ReceiveMessageInput{
-			QueueUrl:              aws.String(a.conf.URL),
-			MaxNumberOfMessages:   int32(a.conf.MaxNumberOfMessages),
-			WaitTimeSeconds:       int32(a.conf.WaitTimeSeconds),
-			AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
-			VisibilityTimeout:     int32(a.conf.MessageTimeout.Seconds()),
-			MessageAttributeNames: []string{"All"},
-		})
-		if err != nil {
-			if !awsErrIsTimeout(err) {
-				a.log.Errorf("Failed to pull new SQS messages: %v", err)
+		limit, _ := a.limits.Remaining(time.Now())
+		if a.conf.StrictMaxOutstanding {
+			if capacity := inFlightTracker.Capacity(closeAtLeisureCtx); limit < 0 || capacity < limit {
+				limit = capacity
+			}
+		}
+		messages, err := a.receiveMessages(closeAtLeisureCtx, limit)
+		a.limits.Add(len(messages))
+		if err != nil && !awsErrIsTimeout(err) {
+			if l := a.reportError(sqsiOpReceive, err); l != nil {
+				l.Errorf("Failed to pull new SQS messages: %v", err)
+			}
+		}
+		if throttled = err != nil && sqsErrorCategory(err) == sqsiErrCategoryThrottling; throttled {
+			a.throttledMetric.Incr(1)
+		} else {
+			throttleBackoff.Reset()
+		}
+		if err != nil && len(messages) == 0 {
+			emptyReceives = 0
+			if a.conf.ClientRebuildThreshold <= 0 || !sqsIsConnectivityError(err) {
+				receiveFailures = 0
+				return
+			}
+			if receiveFailures++; receiveFailures >= a.conf.ClientRebuildThreshold {
+				receiveFailures = 0
+				a.log.Warnf("Rebuilding SQS client after %v consecutive connectivity errors", a.conf.ClientRebuildThreshold)
+				if err := a.rebuildClient(closeAtLeisureCtx); err != nil {
+					a.log.Errorf("Failed to rebuild SQS client: %v", err)
+				}
 			}
 			return
 		}
-		if len(res.Messages) > 0 {
-			for _, msg := range res.Messages {
+		receiveFailures = 0
+		a.heartbeat.Observe(len(messages), time.Now())
+		if a.conf.EmitDrainMarker {
+			if len(messages) > 0 {
+				emptyReceives, drainPending = 0, true
+			} else if emptyReceives++; drainPending && emptyReceives >= a.conf.DrainMarkerReceives && inFlightTracker.Size() == 0 {
+				drainPending = false
+				a.log.Infof("Queue %v is drained, emitting a drain marker", a.queueName)
+				pendingMsgs = append(pendingMsgs, sqsMessage{drainMarker: true})
+			}
+		}
+		if len(messages) > 0 {
+			poll++
+			inFlight := inFlightTracker.Size()
+			var batchID string
+			if a.conf.DeliveryBatchHint {
+				if u4, err := uuid.NewV4(); err == nil {
+					batchID = u4.String()
+				} else {
+					a.log.Errorf("Failed to generate receive batch ID: %v", err)
+				}
+			}
+			var drained []*sqsMessageHandle
+			for i, msg := range messages {
 				var handle *sqsMessageHandle
 				if msg.MessageId != nil && msg.ReceiptHandle != nil {
 					handle = &sqsMessageHandle{
 						id:            *msg.MessageId,
 						receiptHandle: *msg.ReceiptHandle,
-						deadline:      time.Now().Add(a.conf.MessageTimeout),
+						timeout:       a.messageTimeout(msg),
+						// The visibility set by ReceiveMessage is always based on
+						// the default timeout.
+						deadline: time.Now().Add(a.conf.MessageTimeout),
 					}
 				}
+				if a.conf.FastDrain && handle != nil {
+					// Drained messages are deleted up front rather than being
+					// tracked until they are acknowledged.
+					drained = append(drained, handle)
+					handle = nil
+				}
 				pendingMsgs = append(pendingMsgs, sqsMessage{
-					Message: msg,
-					handle:  handle,
+					Message:    msg,
+					handle:     handle,
+					poll:       poll,
+					batchID:    batchID,
+					batchIndex: i,
+					batchSize:  len(messages),
+					inFlight:   inFlight,
 				})
 			}
-			inFlightTracker.AddNew(closeAtLeisureCtx, pendingMsgs[len(pendingMsgs)-len(res.Messages):]...)
+			if a.conf.FastDrain {
+				if err := a.deleteMessages(closeAtLeisureCtx, drained...); err != nil {
+					if l := a.reportError(sqsiOpDelete, err); l != nil {
+						l.Errorf("Failed to delete drained SQS messages, they may be delivered again: %v", err)
+					}
+				}
+			} else {
+				inFlightTracker.AddNew(closeAtLeisureCtx, pendingMsgs[len(pendingMsgs)-len(messages):]...)
+			}
 		}
-		if len(res.Messages) > 0 || a.conf.WaitTimeSeconds > 0 {
+		if len(messages) > 0 || a.conf.WaitTimeSeconds > 0 {
 			// When long polling we want to reset our back off even if we didn't
 			// receive messages. However, with long polling disabled we back off
 			// each time we get an empty response.
@@ -497,10 +1860,47 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlight
 
 	for {
 		if len(pendingMsgs) == 0 {
+			if _, ok := a.limits.Remaining(time.Now()); !ok {
+				// Every message received has been handed over, the input ends
+				// once they have been read but keeps acknowledging them until
+				// it is closed.
+				a.log.Infof("Reached the limits of %v or %v, no further messages are received from queue %v", sqsiFieldMaxMessages, sqsiFieldMaxRuntime, a.queueName)
+				a.limits.Finish()
+				<-a.closeSignal.SoftStopChan()
+				return
+			}
+			if a.stall != nil {
+				now := time.Now()
+				if inFlightTracker.Size() == 0 {
+					a.stall.Progress(now)
+				}
+				if stalled := a.stall.Stalled(now); stalled != paused {
+					if paused = stalled; paused {
+						a.log.Warnf("No messages have been acknowledged or rejected for %v, pausing receiving from queue %v until the pipeline makes progress", a.conf.StallTimeout, a.queueName)
+					} else {
+						a.log.Infof("Resuming receiving from queue %v", a.queueName)
+					}
+				}
+				if paused {
+					select {
+					case <-time.After(time.Second):
+					case <-a.closeSignal.SoftStopChan():
+						return
+					}
+					continue
+				}
+			}
 			getMsgs()
 			if len(pendingMsgs) == 0 {
+				wait := backoff.NextBackOff()
+				if throttled {
+					wait = throttleBackoff.NextBackOff()
+				}
+				if until, ok := a.limits.Until(time.Now()); ok {
+					wait = max(min(wait, until), 0)
+				}
 				select {
-				case <-time.After(backoff.NextBackOff()):
+				case <-time.After(wait):
 				case <-a.closeSignal.SoftStopChan():
 					return
 				}
@@ -519,12 +1919,30 @@ func (a *awsSQSReader) readLoop(wg *sync.WaitGroup, inFlightTracker *sqsInFlight
 type sqsMessage struct {
 	types.Message
 	handle *sqsMessageHandle
+	// The sequence number of the ReceiveMessage call
+	poll uint64
+	// The ID of the receive batch when delivery batch hints are enabled
+	batchID string
+	// The position of the message within its receive batch
+	batchIndex int
+	// The number of messages received in the same batch
+	batchSize int
+	// The number of messages in flight when the batch was received
+	inFlight int
+	// Whether this is a drain marker rather than a message of the queue
+	drainMarker bool
 }
 
 type sqsMessageHandle struct {
 	id, receiptHandle string
+	// The visibility timeout to apply when refreshing the message
+	timeout time.Duration
 	// The timestamp of when the message expires
 	deadline time.Time
+	// The visibility timeout to apply when the message is nacked
+	nackTimeout time.Duration
+	// The dedupe key of a delivered message that is awaiting a commit
+	dedupeKey string
 }
 
 func (a *awsSQSReader) deleteMessages(ctx context.Context, msgs ...*sqsMessageHandle) error {
@@ -550,7 +1968,7 @@ func (a *awsSQSReader) deleteMessages(ctx context.Context, msgs ...*sqsMessageHa
 		}
 
 		msgs = msgs[len(input.Entries):]
-		response, err := a.sqs.DeleteMessageBatch(ctx, &input)
+		response, err := a.client().DeleteMessageBatch(ctx, &input)
 		if err != nil {
 			return err
 		}
@@ -567,9 +1985,21 @@ func (a *awsSQSReader) deleteMessages(ctx context.Context, msgs ...*sqsMessageHa
 
 func (a *awsSQSReader) resetMessages(ctx context.Context, msgs ...*sqsMessageHandle) error {
 	if !a.conf.ResetVisibility {
-		return nil
+		// Messages that are held back, such as those that are quarantined,
+		// are updated regardless.
+		msgs = slices.DeleteFunc(slices.Clone(msgs), func(h *sqsMessageHandle) bool {
+			return h.nackTimeout == 0
+		})
 	}
-	return a.updateVisibilityMessages(ctx, 0, msgs...)
+	return a.updateVisibilityMessages(ctx, func(h *sqsMessageHandle) int32 {
+		return int32(h.nackTimeout.Seconds())
+	}, msgs...)
+}
+
+func (a *awsSQSReader) refreshMessages(ctx context.Context, minTimeout time.Duration, msgs ...*sqsMessageHandle) error {
+	return a.updateVisibilityMessages(ctx, func(h *sqsMessageHandle) int32 {
+		return int32(max(h.timeout, minTimeout).Seconds())
+	}, msgs...)
 }
 
 type batchUpdateVisibilityError struct {
@@ -592,7 +2022,7 @@ func (err *batchUpdateVisibilityError) Error() string {
 	return msg.String()
 }
 
-func (a *awsSQSReader) updateVisibilityMessages(ctx context.Context, timeout int, msgs ...*sqsMessageHandle) error {
+func (a *awsSQSReader) updateVisibilityMessages(ctx context.Context, timeoutFn func(*sqsMessageHandle) int32, msgs ...*sqsMessageHandle) error {
 	const maxBatchSize = 10
 	batchError := &batchUpdateVisibilityError{}
 	for len(msgs) > 0 {
@@ -606,7 +2036,7 @@ func (a *awsSQSReader) updateVisibilityMessages(ctx context.Context, timeout int
 			input.Entries = append(input.Entries, types.ChangeMessageVisibilityBatchRequestEntry{
 				Id:                &msg.id,
 				ReceiptHandle:     &msg.receiptHandle,
-				VisibilityTimeout: int32(timeout),
+				VisibilityTimeout: timeoutFn(msg),
 			})
 			if len(input.Entries) == maxBatchSize {
 				break
@@ -617,7 +2047,7 @@ func (a *awsSQSReader) updateVisibilityMessages(ctx context.Context, timeout int
 		if len(input.Entries) == 0 {
 			continue
 		}
-		response, err := a.sqs.ChangeMessageVisibilityBatch(ctx, &input)
+		response, err := a.client().ChangeMessageVisibilityBatch(ctx, &input)
 		if err != nil {
 			return err
 		}
@@ -631,69 +2061,625 @@ func (a *awsSQSReader) updateVisibilityMessages(ctx context.Context, timeout int
 	return nil
 }
 
-func addSQSMetadata(p *service.Message, sqsMsg types.Message) {
+func addSQSMetadata(p *service.Message, sqsMsg types.Message, coerceTypes bool, attributePrefix string, attributes *sqsAttributeFilter) {
 	p.MetaSetMut("sqs_message_id", *sqsMsg.MessageId)
 	p.MetaSetMut("sqs_receipt_handle", *sqsMsg.ReceiptHandle)
 	if rCountStr, exists := sqsMsg.Attributes["ApproximateReceiveCount"]; exists {
 		p.MetaSetMut("sqs_approximate_receive_count", rCountStr)
 	}
+	if dwell, ok := sqsDwellTime(sqsMsg); ok {
+		p.MetaSetMut("sqs_dwell_ms", strconv.FormatInt(dwell.Milliseconds(), 10))
+	}
+	if sqsMsg.MD5OfBody != nil {
+		p.MetaSetMut("sqs_body_md5", *sqsMsg.MD5OfBody)
+	}
+	if seq, exists := sqsMsg.Attributes["SequenceNumber"]; exists {
+		p.MetaSetMut("sqs_sequence_number", seq)
+	}
 	for k, v := range sqsMsg.MessageAttributes {
+		if !attributes.Allowed(k) {
+			continue
+		}
+		if coerceTypes {
+			if mv, ok := sqsAttributeValue(v); ok {
+				p.MetaSetMut(attributePrefix+k, mv)
+			}
+			continue
+		}
 		if v.StringValue != nil {
-			p.MetaSetMut(k, *v.StringValue)
+			p.MetaSetMut(attributePrefix+k, *v.StringValue)
+		}
+	}
+}
+
+// sqsAttributeValue converts a message attribute into a metadata value that
+// matches its data type. Data types may have a custom suffix such as
+// `Number.float`, which is ignored.
+func sqsAttributeValue(v types.MessageAttributeValue) (any, bool) {
+	dataType := ""
+	if v.DataType != nil {
+		dataType, _, _ = strings.Cut(*v.DataType, ".")
+	}
+	switch dataType {
+	case "Binary":
+		if v.BinaryValue == nil {
+			return nil, false
+		}
+		return v.BinaryValue, true
+	case "Number":
+		if v.StringValue == nil {
+			return nil, false
+		}
+		if i, err := strconv.ParseInt(*v.StringValue, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(*v.StringValue, 64); err == nil {
+			return f, true
+		}
+	}
+	if v.StringValue == nil {
+		return nil, false
+	}
+	return *v.StringValue, true
+}
+
+// messageTimeout returns the visibility timeout to use for a received message,
+// taking into account the configured per-message override.
+func (a *awsSQSReader) messageTimeout(sqsMsg types.Message) time.Duration {
+	if a.conf.MessageTimeoutOverride == nil {
+		return a.conf.MessageTimeout
+	}
+	msg := service.NewMessage(nil)
+	addSQSMetadata(msg, sqsMsg, a.conf.CoerceAttributeTypes, a.conf.AttributePrefix, a.attributes)
+	timeoutStr, err := a.conf.MessageTimeoutOverride.TryString(msg)
+	if err != nil {
+		a.log.Warnf("Failed to evaluate %v, using default: %v", sqsiFieldMessageTimeoutOverride, err)
+		return a.conf.MessageTimeout
+	}
+	if timeoutStr == "" {
+		return a.conf.MessageTimeout
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil || timeout <= 0 {
+		a.log.Warnf("Invalid %v value %q, using default", sqsiFieldMessageTimeoutOverride, timeoutStr)
+		return a.conf.MessageTimeout
+	}
+	return timeout
+}
+
+// sqsMessageAge returns the time elapsed since the message was sent to the
+// queue, based on its SentTimestamp system attribute.
+func sqsMessageAge(sqsMsg types.Message, now time.Time) (time.Duration, bool) {
+	sent, ok := sqsTimestampAttribute(sqsMsg, "SentTimestamp")
+	if !ok {
+		return 0, false
+	}
+	return now.Sub(sent), true
+}
+
+// sqsDwellTime returns the duration between a message being sent and first
+// received, which includes any delay of the queue or message.
+func sqsDwellTime(sqsMsg types.Message) (time.Duration, bool) {
+	sent, ok := sqsTimestampAttribute(sqsMsg, "SentTimestamp")
+	if !ok {
+		return 0, false
+	}
+	received, ok := sqsTimestampAttribute(sqsMsg, "ApproximateFirstReceiveTimestamp")
+	if !ok {
+		return 0, false
+	}
+	return received.Sub(sent), true
+}
+
+// sqsBodyMD5Matches returns whether the MD5 digest of the body of a message
+// matches the digest calculated by SQS. Messages without a digest are assumed
+// to match.
+func sqsBodyMD5Matches(sqsMsg types.Message) bool {
+	if sqsMsg.MD5OfBody == nil || sqsMsg.Body == nil {
+		return true
+	}
+	sum := md5.Sum([]byte(*sqsMsg.Body))
+	return strings.EqualFold(hex.EncodeToString(sum[:]), *sqsMsg.MD5OfBody)
+}
+
+// sqsTimestampAttribute parses a system attribute of a message holding an
+// epoch timestamp in milliseconds.
+func sqsTimestampAttribute(sqsMsg types.Message, name string) (time.Time, bool) {
+	str, exists := sqsMsg.Attributes[name]
+	if !exists {
+		return time.Time{}, false
+	}
+	millis, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(millis), true
+}
+
+// isStale returns whether a message has exceeded the configured max age.
+func (a *awsSQSReader) isStale(sqsMsg types.Message) bool {
+	if a.conf.MaxMessageAge <= 0 {
+		return false
+	}
+	age, ok := sqsMessageAge(sqsMsg, time.Now())
+	return ok && age > a.conf.MaxMessageAge
+}
+
+// filterMessage returns whether a message should be delivered downstream
+// according to the configured filter query.
+func (a *awsSQSReader) filterMessage(msg *service.Message) (bool, error) {
+	if a.conf.Filter == nil {
+		return true, nil
+	}
+	// The query is executed against a copy of the message so that metadata
+	// can be referenced with @, which is not possible when querying a value.
+	res, err := msg.BloblangQuery(a.conf.Filter)
+	if err != nil {
+		return false, err
+	}
+	if res == nil {
+		return false, errors.New("filter query deleted the message")
+	}
+	v, err := res.AsStructured()
+	if err != nil {
+		return false, err
+	}
+	keep, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected filter query to return a boolean, got %T", v)
+	}
+	return keep, nil
+}
+
+// reportError records an SQS error in the errors metric and returns a logger
+// labelled with the error category, or nil if logging the error should be
+// suppressed.
+func (a *awsSQSReader) reportError(op string, err error) *service.Logger {
+	category, shouldLog := a.errReporter.Report(op, err)
+	if !shouldLog {
+		return nil
+	}
+	return a.log.With("operation", op, "category", category)
+}
+
+// dedupeKey returns the key used to detect duplicate deliveries of a message,
+// or an empty string if deduplication is disabled.
+func (a *awsSQSReader) dedupeKey(msg *service.Message, sqsMsg types.Message) (string, error) {
+	if a.dedupe == nil {
+		return "", nil
+	}
+	if a.conf.DedupeKey == nil {
+		return *sqsMsg.MessageId, nil
+	}
+	return a.conf.DedupeKey.TryString(msg)
+}
+
+// isCheckpointed returns whether the ack of a message has already been recorded
+// in the ack checkpoint cache.
+func (a *awsSQSReader) isCheckpointed(ctx context.Context, mHandle *sqsMessageHandle) (found bool, err error) {
+	if a.conf.AckCheckpoint == "" || mHandle == nil {
+		return false, nil
+	}
+	if aerr := a.mgr.AccessCache(ctx, a.conf.AckCheckpoint, func(c service.Cache) {
+		if _, err = c.Get(ctx, mHandle.id); err == nil {
+			found = true
+		} else if errors.Is(err, service.ErrKeyNotFound) {
+			err = nil
+		}
+	}); aerr != nil {
+		return false, aerr
+	}
+	return
+}
+
+// writeCheckpoint records the ack of a message in the ack checkpoint cache.
+func (a *awsSQSReader) writeCheckpoint(ctx context.Context, mHandle *sqsMessageHandle) (err error) {
+	if a.conf.AckCheckpoint == "" || mHandle == nil {
+		return nil
+	}
+	if aerr := a.mgr.AccessCache(ctx, a.conf.AckCheckpoint, func(c service.Cache) {
+		err = c.Set(ctx, mHandle.id, []byte(mHandle.receiptHandle), nil)
+	}); aerr != nil {
+		return aerr
+	}
+	return
+}
+
+// quarantineNack records the nack of a message and returns whether it should be
+// deleted from the queue because it has been quarantined.
+func (a *awsSQSReader) quarantineNack(mHandle *sqsMessageHandle, res error) bool {
+	if a.nacks == nil || mHandle == nil {
+		return false
+	}
+	if a.nacks.Nacked(mHandle.id, res.Error()) != a.conf.QuarantineThreshold {
+		return false
+	}
+	a.quarantinedMetric.Incr(1)
+	a.log.Warnf("Quarantining SQS message '%v' after %v consecutive nacks: %v", mHandle.id, a.conf.QuarantineThreshold, res)
+	switch a.conf.QuarantineAction {
+	case sqsQuarantineActionDelete:
+		a.nacks.Forget(mHandle.id)
+		return true
+	case sqsQuarantineActionExtendVisibility:
+		a.nacks.Forget(mHandle.id)
+		mHandle.nackTimeout = a.conf.QuarantineVisibility
+	}
+	return false
+}
+
+// finishHandle either deletes or resets the visibility of a message handle
+// depending on whether res is nil.
+func (a *awsSQSReader) finishHandle(ctx context.Context, mHandle *sqsMessageHandle, res error) error {
+	if mHandle == nil {
+		return nil
+	}
+	if res == nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closeSignal.SoftStopChan():
+			return a.deleteMessages(ctx, mHandle)
+		case a.ackMessagesChan <- mHandle:
 		}
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.closeSignal.SoftStopChan():
+		return a.resetMessages(ctx, mHandle)
+	case a.nackMessagesChan <- mHandle:
+	}
+	return nil
+}
+
+// settleDedupe resolves the in flight dedupe key of a delivered message. The
+// key is committed once the message is acked, so that deliveries within the
+// TTL are deleted as duplicates, and is otherwise forgotten so that the
+// message can be delivered again.
+func (a *awsSQSReader) settleDedupe(key string, res error) {
+	if key == "" {
+		return
+	}
+	if res == nil {
+		a.dedupe.Commit(key, time.Now())
+		return
 	}
+	a.dedupe.Forget(key)
 }
 
-// ReadBatch attempts to read a new message from the target SQS.
+// holdHandle nacks a message handle without resetting its visibility, so
+// that the message is received again once its visibility timeout expires
+// rather than straight away.
+func (a *awsSQSReader) holdHandle(ctx context.Context, mHandle *sqsMessageHandle, res error) error {
+	if mHandle != nil {
+		mHandle.nackTimeout = mHandle.timeout
+	}
+	return a.finishHandle(ctx, mHandle, res)
+}
+
+var (
+	errSQSMessageFiltered   = errors.New("message rejected by filter")
+	errSQSBodyMD5Mismatch   = errors.New("message body does not match its MD5 digest")
+	errSQSPollOrderNacked   = errors.New("an earlier message of the same poll was nacked")
+	errSQSDuplicateInFlight = errors.New("a delivery with the same dedupe key is in flight")
+)
+
+// Read attempts to read a new message from the target SQS.
 func (a *awsSQSReader) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
-	if a.sqs == nil {
+	if a.client() == nil {
 		return nil, nil, service.ErrNotConnected
 	}
+	if a.deliveries == nil {
+		return a.read(ctx)
+	}
+
+	release, err := a.acquireDelivery(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, ackFn, err := a.read(ctx)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return msg, func(rctx context.Context, res error) error {
+		release()
+		return ackFn(rctx, res)
+	}, nil
+}
+
+// acquireDelivery waits for a free slot of max_in_flight_deliveries, returning
+// a func that releases the slot and is safe to call more than once.
+func (a *awsSQSReader) acquireDelivery(ctx context.Context) (func(), error) {
+	if a.deliveries == nil {
+		return func() {}, nil
+	}
+	select {
+	case a.deliveries <- struct{}{}:
+	case <-a.closeSignal.SoftStopChan():
+		return nil, service.ErrEndOfInput
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var releaseOnce sync.Once
+	return func() {
+		releaseOnce.Do(func() { <-a.deliveries })
+	}, nil
+}
+
+func (a *awsSQSReader) read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+
+	if p, ok := a.pending.pop(); ok {
+		return p.msg, p.ack, nil
+	}
+
+	for {
+		next, err := a.nextMessage(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs, ackFn, err := a.processMessage(ctx, next)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		a.pending.push(msgs[1:], ackFn)
+		return msgs[0], ackFn, nil
+	}
+}
 
+// nextMessage waits for the next message received by the read loop.
+func (a *awsSQSReader) nextMessage(ctx context.Context) (sqsMessage, error) {
 	var next sqsMessage
 	var open bool
 	select {
 	case next, open = <-a.messagesChan:
 		if !open {
-			return nil, nil, service.ErrEndOfInput
+			return sqsMessage{}, service.ErrEndOfInput
+		}
+	case <-a.limits.Reached():
+		// Messages handed over before the limits were reached are read
+		// before the input ends.
+		select {
+		case next = <-a.messagesChan:
+		default:
+			return sqsMessage{}, service.ErrEndOfInput
 		}
 	case <-a.closeSignal.SoftStopChan():
-		return nil, nil, service.ErrEndOfInput
+		return sqsMessage{}, service.ErrEndOfInput
 	case <-ctx.Done():
-		return nil, nil, ctx.Err()
+		return sqsMessage{}, ctx.Err()
 	}
 
-	if next.Body == nil {
-		return nil, nil, context.Canceled
+	if next.Body == nil && !next.drainMarker {
+		return sqsMessage{}, context.Canceled
 	}
+	return next, nil
+}
 
-	msg := service.NewMessage([]byte(*next.Body))
-	addSQSMetadata(msg, next.Message)
-	mHandle := next.handle
-	return msg, func(rctx context.Context, res error) error {
-		if mHandle == nil {
+// processMessage converts a received message into the messages to deliver,
+// which is empty if the message is dropped. The returned ack func must be
+// called once for each message delivered.
+func (a *awsSQSReader) processMessage(ctx context.Context, next sqsMessage) ([]*service.Message, service.AckFunc, error) {
+	if next.drainMarker {
+		msg := service.NewMessage(nil)
+		msg.MetaSetMut("sqs_drain_complete", "true")
+		return []*service.Message{msg}, func(context.Context, error) error {
 			return nil
+		}, nil
+	}
+
+	mHandle := next.handle
+	if a.conf.PreservePollOrder {
+		nacked, err := a.pollOrder.wait(ctx, next.poll)
+		if err != nil {
+			return nil, nil, err
+		}
+		if nacked {
+			// Reject the remainder of the poll so that it is redelivered
+			// after the nacked message.
+			return nil, nil, a.finishHandle(ctx, mHandle, errSQSPollOrderNacked)
+		}
+	}
+
+	if a.isStale(next.Message) {
+		a.droppedStaleMetric.Incr(1)
+		return nil, nil, a.finishHandle(ctx, mHandle, nil)
+	}
+
+	if a.conf.VerifyMD5 && !sqsBodyMD5Matches(next.Message) {
+		a.md5MismatchMetric.Incr(1)
+		a.log.Errorf("Rejecting message %v: %v", aws.ToString(next.MessageId), errSQSBodyMD5Mismatch)
+		return nil, nil, a.holdHandle(ctx, mHandle, errSQSBodyMD5Mismatch)
+	}
+
+	body := *next.Body
+	truncated := false
+	if a.conf.MaxBodyBytes > 0 && len(body) > a.conf.MaxBodyBytes {
+		a.oversizedMetric.Incr(1)
+		switch a.conf.MaxBodyAction {
+		case sqsMaxBodyActionTruncate:
+			body, truncated = truncateSQSBody(body, a.conf.MaxBodyBytes), true
+		case sqsMaxBodyActionDrop:
+			a.log.Warnf("Dropping message %v: %v with %v bytes", aws.ToString(next.MessageId), errSQSBodyTooLarge, len(body))
+			return nil, nil, a.finishHandle(ctx, mHandle, nil)
+		default:
+			a.log.Errorf("Rejecting message %v: %v with %v bytes", aws.ToString(next.MessageId), errSQSBodyTooLarge, len(body))
+			return nil, nil, a.holdHandle(ctx, mHandle, errSQSBodyTooLarge)
+		}
+	}
+
+	acked, err := a.isCheckpointed(ctx, mHandle)
+	if err != nil {
+		a.log.Errorf("Failed to read ack checkpoint, delivering message: %v", err)
+	}
+	if acked {
+		// The message was acked previously but not deleted from the queue.
+		a.droppedAckedMetric.Incr(1)
+		return nil, nil, a.finishHandle(ctx, mHandle, nil)
+	}
+
+	if dwell, ok := sqsDwellTime(next.Message); ok {
+		a.dwellTimeMetric.Timing(dwell.Nanoseconds())
+	}
+	if count, ok := sqsReceiveCount(next.Message); ok {
+		a.maxReceiveCount.Observe(count, time.Now())
+	}
+
+	msg := service.NewMessage([]byte(body))
+	addSQSMetadata(msg, next.Message, a.conf.CoerceAttributeTypes, a.conf.AttributePrefix, a.attributes)
+	a.addRedriveMetadata(msg)
+	a.addQueueTagsMetadata(msg)
+	if truncated {
+		msg.MetaSetMut("sqs_truncated", "true")
+	}
+	if a.conf.BodyMetadataKey != "" {
+		msg.MetaSetMut(a.conf.BodyMetadataKey, body)
+	}
+	if a.conf.SNSUnwrap {
+		if inner, ok := sqsUnwrapSNS(msg, body); ok {
+			body = inner
+			msg.SetBytes([]byte(body))
+		}
+	}
+	if err := a.schema.Validate(body); err != nil {
+		a.schemaInvalidMetric.Incr(1)
+		switch a.conf.JSONSchemaAction {
+		case sqsSchemaActionFlag:
+			msg.MetaSetMut("sqs_schema_invalid", "true")
+			msg.MetaSetMut("sqs_schema_error", err.Error())
+		case sqsSchemaActionDrop:
+			a.log.Warnf("Dropping message %v: %v", aws.ToString(next.MessageId), err)
+			return nil, nil, a.finishHandle(ctx, mHandle, nil)
+		default:
+			a.log.Errorf("Rejecting message %v: %v", aws.ToString(next.MessageId), err)
+			return nil, nil, a.holdHandle(ctx, mHandle, errSQSSchemaInvalid)
+		}
+	}
+	if a.conf.Mapping != nil {
+		if msg, body, err = a.mapMessage(msg); err != nil {
+			a.mappingFailedMetric.Incr(1)
+			a.log.Errorf("Rejecting message %v: %v", aws.ToString(next.MessageId), err)
+			return nil, nil, a.holdHandle(ctx, mHandle, err)
+		}
+		if msg == nil {
+			a.log.Debugf("Dropping message %v deleted by the mapping", aws.ToString(next.MessageId))
+			return nil, nil, a.finishHandle(ctx, mHandle, nil)
+		}
+	}
+	if next.batchID != "" {
+		msg.MetaSetMut("sqs_receive_batch_id", next.batchID)
+		msg.MetaSetMut("sqs_receive_batch_index", strconv.Itoa(next.batchIndex))
+		msg.MetaSetMut("sqs_receive_batch_size", strconv.Itoa(next.batchSize))
+	}
+	if a.nacks != nil && mHandle != nil && a.conf.QuarantineAction == sqsQuarantineActionTag {
+		if reason, count := a.nacks.Get(mHandle.id); count >= a.conf.QuarantineThreshold {
+			msg.MetaSetMut("sqs_quarantined", "true")
+			msg.MetaSetMut("sqs_quarantine_reason", reason)
+		}
+	}
+
+	keep, err := a.filterMessage(msg)
+	if err != nil {
+		// Deliver the message rather than risk dropping data because of a
+		// broken filter.
+		a.log.Errorf("Failed to execute filter query, delivering message: %v", err)
+		keep = true
+	}
+	if !keep {
+		if a.conf.DropFiltered {
+			return nil, nil, a.finishHandle(ctx, mHandle, nil)
+		}
+		if mHandle != nil {
+			mHandle.nackTimeout = a.conf.FilteredVisibility
+		}
+		return nil, nil, a.finishHandle(ctx, mHandle, errSQSMessageFiltered)
+	}
+
+	dedupeKey, err := a.dedupeKey(msg, next.Message)
+	if err != nil {
+		a.log.Errorf("Failed to evaluate dedupe key, delivering message: %v", err)
+		dedupeKey = ""
+	}
+	if dedupeKey != "" {
+		switch a.dedupe.Begin(dedupeKey, time.Now()) {
+		case sqsDedupeAcked:
+			a.droppedDuplicateMetric.Incr(1)
+			return nil, nil, a.finishHandle(ctx, mHandle, nil)
+		case sqsDedupeInFlight:
+			// The earlier delivery can still be nacked, in which case the
+			// message must remain on the queue, so the duplicate is held back
+			// rather than deleted.
+			a.log.Debugf("Holding message %v as a delivery with the same key is in flight", aws.ToString(next.MessageId))
+			return nil, nil, a.holdHandle(ctx, mHandle, errSQSDuplicateInFlight)
+		}
+	}
+
+	commitGroup, err := a.commitGroup(msg)
+	if err != nil {
+		a.log.Errorf("Failed to evaluate commit group, the message is deleted once acknowledged: %v", err)
+		commitGroup = ""
+	}
+	if commitGroup != "" {
+		msg.MetaSetMut("sqs_commit_group", commitGroup)
+	}
+
+	a.checkSequence(next.Message)
+	msg = a.startSpan(msg, next)
+	if mHandle != nil {
+		msg = withSQSVisibilityExtender(msg, a.inFlight, mHandle.id)
+	}
+	release := func(error) {}
+	if a.conf.PreservePollOrder {
+		release = a.pollOrder.deliver(next.poll)
+	}
+	delivered := time.Now()
+	ackFn := func(rctx context.Context, res error) error {
+		outcome := "ack"
+		if res != nil {
+			outcome = "nack"
 		}
+		a.ackLatencyMetric.Timing(time.Since(delivered).Nanoseconds(), outcome)
+		a.stall.Progress(time.Now())
 		if res == nil {
-			select {
-			case <-rctx.Done():
-				return rctx.Err()
-			case <-a.closeSignal.SoftStopChan():
-				return a.deleteMessages(rctx, mHandle)
-			case a.ackMessagesChan <- mHandle:
+			if err := a.writeCheckpoint(rctx, mHandle); err != nil {
+				// Leave the message on the queue to be delivered again
+				// rather than delete it without a checkpoint.
+				res = fmt.Errorf("failed to write ack checkpoint: %w", err)
+				a.log.Errorf("%v", res)
 			}
-			return nil
 		}
-
-		select {
-		case <-rctx.Done():
-			return rctx.Err()
-		case <-a.closeSignal.SoftStopChan():
-			return a.resetMessages(rctx, mHandle)
-		case a.nackMessagesChan <- mHandle:
+		if res == nil && a.nacks != nil && mHandle != nil {
+			a.nacks.Forget(mHandle.id)
 		}
-		return nil
-	}, nil
+		if res != nil && a.quarantineNack(mHandle, res) {
+			res = nil
+		}
+		if res == nil && commitGroup != "" && mHandle != nil {
+			// The message remains in flight until its group is committed, and
+			// so does its dedupe key.
+			mHandle.dedupeKey = dedupeKey
+			if a.commits.Add(commitGroup, mHandle, time.Now()) {
+				release(nil)
+				return nil
+			}
+			res = errSQSCommitAborted
+		}
+		a.settleDedupe(dedupeKey, res)
+		err := a.finishHandle(rctx, mHandle, res)
+		release(res)
+		return err
+	}
+	if a.conf.S3EventMode {
+		if msgs, ok := sqsS3EventMessages(msg, body); ok {
+			return msgs, sqsSharedAck(len(msgs), ackFn), nil
+		}
+	}
+	return []*service.Message{msg}, ackFn, nil
 }
 
 func (a *awsSQSReader) Close(ctx context.Context) error {
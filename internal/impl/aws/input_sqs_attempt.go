@@ -0,0 +1,70 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// SQS honors a ReceiveRequestAttemptId for five minutes, attempts are renewed
+// ahead of that so that a retry never uses an expired attempt.
+const sqsiReceiveAttemptTTL = 4 * time.Minute
+
+type sqsReceiveAttempt struct {
+	id      string
+	created time.Time
+}
+
+// sqsReceiveAttempts tracks the ReceiveRequestAttemptId of each of the
+// parallel receive calls of a poll. The ID of a call that fails is used again
+// by the next call in the same slot, which causes SQS to return the messages
+// of the failed call rather than hide further messages of the FIFO queue.
+type sqsReceiveAttempts struct {
+	mut      sync.Mutex
+	attempts []sqsReceiveAttempt
+}
+
+func newSQSReceiveAttempts(slots int) *sqsReceiveAttempts {
+	return &sqsReceiveAttempts{attempts: make([]sqsReceiveAttempt, max(slots, 1))}
+}
+
+// Next returns the attempt ID to use for a receive call in a slot, which is
+// the ID of the previous call in the slot if it failed recently.
+func (r *sqsReceiveAttempts) Next(slot int, now time.Time) (string, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	a := &r.attempts[slot]
+	if a.id != "" && now.Sub(a.created) < sqsiReceiveAttemptTTL {
+		return a.id, nil
+	}
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	*a = sqsReceiveAttempt{id: u4.String(), created: now}
+	return a.id, nil
+}
+
+// Done marks the receive call of a slot as having succeeded, so that the next
+// call in the slot uses a new attempt ID.
+func (r *sqsReceiveAttempts) Done(slot int) {
+	r.mut.Lock()
+	r.attempts[slot] = sqsReceiveAttempt{}
+	r.mut.Unlock()
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"fmt"
+	"path"
+)
+
+// sqsAttributeFilter selects the message attributes that are added as
+// metadata by name. Names are matched against glob patterns, and an attribute
+// that matches an exclude pattern is never added, even when it also matches
+// an include pattern.
+type sqsAttributeFilter struct {
+	include []string
+	exclude []string
+}
+
+// newSQSAttributeFilter returns nil when no patterns are given, which allows
+// all attributes.
+func newSQSAttributeFilter(include, exclude []string) (*sqsAttributeFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	for _, patterns := range [][]string{include, exclude} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid attribute pattern %q: %w", p, err)
+			}
+		}
+	}
+	return &sqsAttributeFilter{
+		include: include,
+		exclude: exclude,
+	}, nil
+}
+
+// Allowed returns whether an attribute of a given name is added as metadata.
+func (f *sqsAttributeFilter) Allowed(name string) bool {
+	if f == nil {
+		return true
+	}
+	if sqsAttributeMatchAny(f.exclude, name) {
+		return false
+	}
+	return len(f.include) == 0 || sqsAttributeMatchAny(f.include, name)
+}
+
+func sqsAttributeMatchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
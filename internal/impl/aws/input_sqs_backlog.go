@@ -0,0 +1,74 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// backlogLoop polls the approximate number of visible and in-flight messages
+// of the queue every interval until the input is stopped.
+func (a *awsSQSReader) backlogLoop(wg *sync.WaitGroup, interval time.Duration) {
+	defer wg.Done()
+
+	ctx, done := a.closeSignal.SoftStopCtx(context.Background())
+	defer done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.pollBacklog(ctx)
+		select {
+		case <-ticker.C:
+		case <-a.closeSignal.SoftStopChan():
+			return
+		}
+	}
+}
+
+// pollBacklog updates the backlog gauges from the attributes of the queue.
+func (a *awsSQSReader) pollBacklog(ctx context.Context) {
+	res, err := a.client().GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(a.conf.URL),
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameApproximateNumberOfMessages,
+			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		},
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			if l := a.reportError(sqsiOpGetAttributes, err); l != nil {
+				l.Errorf("Failed to get SQS queue attributes: %v", err)
+			}
+		}
+		return
+	}
+	for name, gauge := range map[types.QueueAttributeName]sqsGauge{
+		types.QueueAttributeNameApproximateNumberOfMessages:           a.backlogVisibleGauge,
+		types.QueueAttributeNameApproximateNumberOfMessagesNotVisible: a.backlogNotVisibleGauge,
+	} {
+		if v, err := strconv.ParseInt(res.Attributes[string(name)], 10, 64); err == nil {
+			gauge.Set(v)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// receiveMessages receives the messages of a single poll, which consists of
+// receive_batch_multiplier parallel ReceiveMessage calls. If some calls fail
+// the messages received by the others are returned along with the first
+// error. When limit is not negative no more than limit messages are received.
+func (a *awsSQSReader) receiveMessages(ctx context.Context, limit int) ([]types.Message, error) {
+	receive := func(slot int) ([]types.Message, error) {
+		maxMessages := a.conf.MaxNumberOfMessages
+		if limit >= 0 {
+			if maxMessages = min(maxMessages, limit-slot*maxMessages); maxMessages <= 0 {
+				return nil, nil
+			}
+		}
+		input := &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(a.conf.URL),
+			MaxNumberOfMessages:   int32(maxMessages),
+			WaitTimeSeconds:       int32(a.conf.WaitTimeSeconds),
+			AttributeNames:        []types.QueueAttributeName{types.QueueAttributeNameAll},
+			VisibilityTimeout:     int32(a.conf.MessageTimeout.Seconds()),
+			MessageAttributeNames: []string{"All"},
+		}
+		if a.receiveAttempts != nil {
+			id, err := a.receiveAttempts.Next(slot, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			input.ReceiveRequestAttemptId = aws.String(id)
+		}
+		res, err := a.client().ReceiveMessage(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if a.receiveAttempts != nil {
+			a.receiveAttempts.Done(slot)
+		}
+		return res.Messages, nil
+	}
+	if a.conf.ReceiveBatchMultiplier <= 1 {
+		return receive(0)
+	}
+
+	results := make([][]types.Message, a.conf.ReceiveBatchMultiplier)
+	errs := make([]error, a.conf.ReceiveBatchMultiplier)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = receive(i)
+		}()
+	}
+	wg.Wait()
+
+	var messages []types.Message
+	for _, res := range results {
+		messages = append(messages, res...)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return messages, err
+		}
+	}
+	return messages, nil
+}
+
+// ReadBatch attempts to read the messages of the next poll as a single batch
+// when receive_batch_multiplier is greater than one, otherwise it reads a
+// single message.
+func (a *awsSQSReader) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	if a.conf.ReceiveBatchMultiplier <= 1 {
+		msg, ackFn, err := a.Read(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return service.MessageBatch{msg}, ackFn, nil
+	}
+	if a.client() == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	release, err := a.acquireDelivery(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	batch, acks, err := a.readPoll(ctx)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return batch, func(rctx context.Context, res error) error {
+		release()
+		var ackErr error
+		for _, ackFn := range acks {
+			if err := ackFn(rctx, res); err != nil && ackErr == nil {
+				ackErr = err
+			}
+		}
+		return ackErr
+	}, nil
+}
+
+// readPoll reads the messages that remain of the next poll, returning them
+// along with the ack func of each. Messages of a poll that are all dropped
+// result in the messages of the following poll being read instead.
+func (a *awsSQSReader) readPoll(ctx context.Context) (service.MessageBatch, []service.AckFunc, error) {
+	// Concurrent readers would otherwise split the messages of a poll between
+	// them, and wait on the following poll in order to complete their batch.
+	a.batchMut.Lock()
+	defer a.batchMut.Unlock()
+
+	var batch service.MessageBatch
+	var acks []service.AckFunc
+	for {
+		next, err := a.nextMessage(ctx)
+		if err == nil {
+			var msgs []*service.Message
+			var ackFn service.AckFunc
+			if msgs, ackFn, err = a.processMessage(ctx, next); err == nil {
+				for _, msg := range msgs {
+					batch = append(batch, msg)
+					acks = append(acks, ackFn)
+				}
+			}
+		}
+		if err != nil {
+			if len(batch) > 0 {
+				// Deliver the messages already read, those that remain of the
+				// poll are returned to the queue when the input shuts down.
+				return batch, acks, nil
+			}
+			return nil, nil, err
+		}
+		if len(batch) > 0 && next.batchIndex >= next.batchSize-1 {
+			return batch, acks, nil
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+const (
+	sqsMaxBodyActionTruncate = "truncate"
+	sqsMaxBodyActionReject   = "reject"
+	sqsMaxBodyActionDrop     = "drop"
+)
+
+var errSQSBodyTooLarge = errors.New("message body exceeds the maximum size")
+
+// truncateSQSBody returns the longest prefix of body that is at most maxBytes
+// long without splitting a UTF-8 character.
+func truncateSQSBody(body string, maxBytes int) string {
+	if len(body) <= maxBytes {
+		return body
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut]
+}
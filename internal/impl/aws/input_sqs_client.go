@@ -0,0 +1,76 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// sqsIsConnectivityError returns whether an error suggests that the SQS client
+// is unable to reach or authenticate with SQS, which rebuilding the client
+// from a freshly resolved config may resolve.
+func sqsIsConnectivityError(err error) bool {
+	switch sqsErrorCategory(err) {
+	case sqsiErrCategoryNetwork, sqsiErrCategoryAuth:
+		return true
+	}
+	return false
+}
+
+func (a *awsSQSReader) newSQSClient(aconf aws.Config) sqsAPI {
+	return sqs.NewFromConfig(aconf, a.sqsClientOptions()...)
+}
+
+// client returns the current SQS client, which may be replaced at any time by
+// rebuildClient. When a rate limit is configured each call made through the
+// returned client waits for access first.
+func (a *awsSQSReader) client() sqsAPI {
+	a.sqsMut.RLock()
+	defer a.sqsMut.RUnlock()
+	if a.conf.RateLimit != "" {
+		return &sqsRateLimitedAPI{api: a.sqs, wait: a.waitForRateLimit}
+	}
+	return a.sqs
+}
+
+// rebuildClient replaces the SQS client with one created from a freshly
+// resolved AWS config, which picks up rotated credentials and discards any
+// broken connections held by the previous client. Receipt handles are not tied
+// to a client and so messages already in flight can still be acked, nacked and
+// refreshed once the client has been replaced.
+func (a *awsSQSReader) rebuildClient(ctx context.Context) error {
+	a.sqsMut.RLock()
+	aconf := a.aconf
+	a.sqsMut.RUnlock()
+
+	if a.loadConfig != nil {
+		var err error
+		if aconf, err = a.loadConfig(ctx); err != nil {
+			return err
+		}
+	}
+	client := a.newClient(aconf)
+
+	a.sqsMut.Lock()
+	a.aconf = aconf
+	a.sqs = client
+	a.sqsMut.Unlock()
+
+	a.clientRebuildsMetric.Incr(1)
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// The interval at which the commit cache is checked for committed groups.
+const sqsCommitPollInterval = time.Second
+
+var (
+	errSQSCommitTimeout = errors.New("commit group was not committed in time")
+	errSQSCommitAborted = errors.New("input is stopping before the commit group was committed")
+)
+
+type sqsCommitGroup struct {
+	handles []*sqsMessageHandle
+	// The time that the first message of the group was acknowledged
+	started time.Time
+}
+
+// sqsCommitGroups holds the handles of acknowledged messages that belong to a
+// commit group until the group is committed downstream, during which the
+// messages remain in flight.
+type sqsCommitGroups struct {
+	mut    sync.Mutex
+	groups map[string]*sqsCommitGroup
+	closed bool
+}
+
+func newSQSCommitGroups() *sqsCommitGroups {
+	return &sqsCommitGroups{groups: map[string]*sqsCommitGroup{}}
+}
+
+// Add adds the handle of an acknowledged message to a group, returning false
+// if the groups are closed because the input is stopping.
+func (c *sqsCommitGroups) Add(group string, h *sqsMessageHandle, now time.Time) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.closed {
+		return false
+	}
+	g, exists := c.groups[group]
+	if !exists {
+		g = &sqsCommitGroup{started: now}
+		c.groups[group] = g
+	}
+	g.handles = append(g.handles, h)
+	return true
+}
+
+// Names returns the names of the groups awaiting a commit.
+func (c *sqsCommitGroups) Names() []string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	names := make([]string, 0, len(c.groups))
+	for name := range c.groups {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Take removes a group and returns the handles of its messages.
+func (c *sqsCommitGroups) Take(group string) []*sqsMessageHandle {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	g, exists := c.groups[group]
+	if !exists {
+		return nil
+	}
+	delete(c.groups, group)
+	return g.handles
+}
+
+// TakeExpired removes the groups whose first message was acknowledged before
+// the given time and returns the handles of their messages by group.
+func (c *sqsCommitGroups) TakeExpired(before time.Time) map[string][]*sqsMessageHandle {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	var expired map[string][]*sqsMessageHandle
+	for name, g := range c.groups {
+		if !g.started.Before(before) {
+			continue
+		}
+		if expired == nil {
+			expired = map[string][]*sqsMessageHandle{}
+		}
+		expired[name] = g.handles
+		delete(c.groups, name)
+	}
+	return expired
+}
+
+// Close removes all groups and returns the handles of their messages, after
+// which no further messages can be added.
+func (c *sqsCommitGroups) Close() []*sqsMessageHandle {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.closed = true
+	var handles []*sqsMessageHandle
+	for _, g := range c.groups {
+		handles = append(handles, g.handles...)
+	}
+	c.groups = map[string]*sqsCommitGroup{}
+	return handles
+}
+
+// commitGroup returns the commit group of a message, or an empty string if
+// commit groups are disabled.
+func (a *awsSQSReader) commitGroup(msg *service.Message) (string, error) {
+	if a.conf.CommitGroup == nil {
+		return "", nil
+	}
+	return a.conf.CommitGroup.TryString(msg)
+}
+
+// commitLoop deletes the messages of each group once it has been committed,
+// and returns the messages of groups that are not committed in time to the
+// queue, until the input is stopped.
+func (a *awsSQSReader) commitLoop(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// Messages of committed groups must still be deleted when the input
+	// starts to stop, and so only a hard stop interrupts the loop.
+	closeNowCtx, done := a.closeSignal.HardStopCtx(context.Background())
+	defer done()
+
+	ticker := time.NewTicker(sqsCommitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.pollCommits(closeNowCtx)
+		case <-a.closeSignal.SoftStopChan():
+			// Messages of groups that have not been committed are returned to
+			// the queue to be delivered again.
+			if handles := a.commits.Close(); len(handles) > 0 {
+				for _, h := range handles {
+					a.settleDedupe(h.dedupeKey, errSQSCommitAborted)
+				}
+				if err := a.resetMessages(closeNowCtx, handles...); err != nil {
+					if l := a.reportError(sqsiOpReset, err); l != nil {
+						l.Errorf("Failed to reset the visibility timeout of uncommitted messages: %v", err)
+					}
+				}
+			}
+			return
+		}
+	}
+}
+
+// pollCommits checks the commit cache for each group awaiting a commit.
+func (a *awsSQSReader) pollCommits(ctx context.Context) {
+	for _, group := range a.commits.Names() {
+		committed, err := a.isCommitted(ctx, group)
+		if err != nil {
+			a.log.Errorf("Failed to check the commit of group %v: %v", group, err)
+		}
+		if !committed {
+			continue
+		}
+		for _, h := range a.commits.Take(group) {
+			a.settleDedupe(h.dedupeKey, nil)
+			if err := a.finishHandle(ctx, h, nil); err != nil {
+				a.log.Errorf("Failed to delete message %v of committed group %v: %v", h.id, group, err)
+			}
+		}
+	}
+
+	for group, handles := range a.commits.TakeExpired(time.Now().Add(-a.conf.CommitTimeout)) {
+		a.log.Warnf("Group %v was not committed within %v, returning %v messages to the queue", group, a.conf.CommitTimeout, len(handles))
+		for _, h := range handles {
+			a.settleDedupe(h.dedupeKey, errSQSCommitTimeout)
+			if err := a.finishHandle(ctx, h, errSQSCommitTimeout); err != nil {
+				a.log.Errorf("Failed to reset message %v of uncommitted group %v: %v", h.id, group, err)
+			}
+		}
+	}
+}
+
+// isCommitted returns whether a group has been committed downstream, in which
+// case the commit is removed from the cache so that the group name can be
+// reused.
+func (a *awsSQSReader) isCommitted(ctx context.Context, group string) (committed bool, err error) {
+	if aerr := a.mgr.AccessCache(ctx, a.conf.CommitCache, func(c service.Cache) {
+		if _, err = c.Get(ctx, group); err != nil {
+			if errors.Is(err, service.ErrKeyNotFound) {
+				err = nil
+			}
+			return
+		}
+		committed = true
+		err = c.Delete(ctx, group)
+	}); aerr != nil {
+		return false, aerr
+	}
+	return
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type sqsDedupeEntry struct {
+	key    string
+	expiry time.Time
+}
+
+// sqsDedupeStatus is the status of a key within a sqsDedupeCache.
+type sqsDedupeStatus int
+
+const (
+	// The key has not been seen, or was forgotten.
+	sqsDedupeNew sqsDedupeStatus = iota
+	// A message with the key has been delivered and is yet to be acked.
+	sqsDedupeInFlight
+	// A message with the key was acked within the TTL.
+	sqsDedupeAcked
+)
+
+// sqsDedupeCache is a bounded set of recently acked message keys, each of
+// which expires after a fixed TTL. Since every key has the same TTL the
+// insertion order is also the expiry order, which allows us to evict both
+// expired and excess keys from the front of a single list. Keys of messages
+// that have been delivered but not yet acked are held separately, as they
+// must not be treated as acked until the delivery is resolved.
+type sqsDedupeCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mut      sync.Mutex
+	entries  map[string]*list.Element
+	fifo     *list.List // contains *sqsDedupeEntry
+	inFlight map[string]struct{}
+}
+
+func newSQSDedupeCache(ttl time.Duration, maxEntries int) *sqsDedupeCache {
+	return &sqsDedupeCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		fifo:       list.New(),
+		inFlight:   map[string]struct{}{},
+	}
+}
+
+// Begin returns the status of a key, and marks the key as in flight if it is
+// new.
+func (c *sqsDedupeCache) Begin(key string, now time.Time) sqsDedupeStatus {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.evictExpired(now)
+	if _, exists := c.entries[key]; exists {
+		return sqsDedupeAcked
+	}
+	if _, exists := c.inFlight[key]; exists {
+		return sqsDedupeInFlight
+	}
+	c.inFlight[key] = struct{}{}
+	return sqsDedupeNew
+}
+
+// Commit marks the in flight key as acked, after which it is considered a
+// duplicate for the TTL.
+func (c *sqsDedupeCache) Commit(key string, now time.Time) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	delete(c.inFlight, key)
+	if e, exists := c.entries[key]; exists {
+		c.removeElement(e)
+	}
+	c.entries[key] = c.fifo.PushBack(&sqsDedupeEntry{
+		key:    key,
+		expiry: now.Add(c.ttl),
+	})
+	for len(c.entries) > c.maxEntries {
+		c.removeElement(c.fifo.Front())
+	}
+}
+
+// Forget removes a key so that it is no longer considered a duplicate.
+func (c *sqsDedupeCache) Forget(key string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	delete(c.inFlight, key)
+	if e, exists := c.entries[key]; exists {
+		c.removeElement(e)
+	}
+}
+
+// Len returns the number of acked keys currently held.
+func (c *sqsDedupeCache) Len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.entries)
+}
+
+func (c *sqsDedupeCache) evictExpired(now time.Time) {
+	for e := c.fifo.Front(); e != nil; e = c.fifo.Front() {
+		if e.Value.(*sqsDedupeEntry).expiry.After(now) {
+			return
+		}
+		c.removeElement(e)
+	}
+}
+
+func (c *sqsDedupeCache) removeElement(e *list.Element) {
+	c.fifo.Remove(e)
+	delete(c.entries, e.Value.(*sqsDedupeEntry).key)
+}
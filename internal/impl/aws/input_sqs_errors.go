@@ -0,0 +1,122 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// SQS operations that errors are reported for.
+const (
+	sqsiOpReceive       = "receive"
+	sqsiOpDelete        = "delete"
+	sqsiOpReset         = "reset"
+	sqsiOpGetAttributes = "get_attributes"
+	sqsiOpListTags      = "list_tags"
+)
+
+// Categories of SQS errors, used as metric labels.
+const (
+	sqsiErrCategoryThrottling = "throttling"
+	sqsiErrCategoryAuth       = "auth"
+	sqsiErrCategoryNotFound   = "not_found"
+	sqsiErrCategoryNetwork    = "network"
+	sqsiErrCategoryTimeout    = "timeout"
+	sqsiErrCategoryClient     = "client"
+	sqsiErrCategoryServer     = "server"
+	sqsiErrCategoryUnknown    = "unknown"
+)
+
+// sqsErrorCategory inspects an error returned by the SQS API and returns a
+// coarse category that alerts can be built on.
+func sqsErrorCategory(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return sqsiErrCategoryTimeout
+	}
+	if apiErr := smithy.APIError(nil); errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "Throttling", "RequestThrottled", "TooManyRequestsException",
+			"KmsThrottled", "AWS.SimpleQueueService.RequestThrottled", "OverLimit":
+			return sqsiErrCategoryThrottling
+		case "AccessDenied", "AccessDeniedException", "InvalidClientTokenId", "UnrecognizedClientException",
+			"SignatureDoesNotMatch", "ExpiredToken", "ExpiredTokenException", "MissingAuthenticationToken",
+			"InvalidSecurity", "KmsAccessDenied":
+			return sqsiErrCategoryAuth
+		case "AWS.SimpleQueueService.NonExistentQueue", "QueueDoesNotExist":
+			return sqsiErrCategoryNotFound
+		}
+		switch apiErr.ErrorFault() {
+		case smithy.FaultClient:
+			return sqsiErrCategoryClient
+		case smithy.FaultServer:
+			return sqsiErrCategoryServer
+		}
+		return sqsiErrCategoryUnknown
+	}
+	if netErr := net.Error(nil); errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return sqsiErrCategoryTimeout
+		}
+		return sqsiErrCategoryNetwork
+	}
+	return sqsiErrCategoryUnknown
+}
+
+// sqsErrorCounter is satisfied by *service.MetricCounter.
+type sqsErrorCounter interface {
+	Incr(count int64, labelValues ...string)
+}
+
+// sqsErrorReporter counts SQS errors by operation and category, and rate
+// limits how often each combination is logged.
+type sqsErrorReporter struct {
+	counter     sqsErrorCounter
+	logInterval time.Duration
+
+	mu         sync.Mutex
+	lastLogged map[string]time.Time
+}
+
+func newSQSErrorReporter(counter sqsErrorCounter, logInterval time.Duration) *sqsErrorReporter {
+	return &sqsErrorReporter{
+		counter:     counter,
+		logInterval: logInterval,
+		lastLogged:  map[string]time.Time{},
+	}
+}
+
+// Report records an error for the given operation and returns its category,
+// along with whether the error should be logged.
+func (r *sqsErrorReporter) Report(op string, err error) (category string, shouldLog bool) {
+	category = sqsErrorCategory(err)
+	r.counter.Incr(1, op, category)
+
+	key := op + ":" + category
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, exists := r.lastLogged[key]; exists && now.Sub(last) < r.logInterval {
+		return category, false
+	}
+	r.lastLogged[key] = now
+	return category, true
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sqsHeartbeat counts the receive requests that completed and the messages
+// they returned since the last heartbeat.
+type sqsHeartbeat struct {
+	polls    atomic.Int64
+	messages atomic.Int64
+	// The unix timestamp in seconds of the last completed receive request
+	lastPoll atomic.Int64
+}
+
+// Observe records a completed receive request that returned n messages.
+func (h *sqsHeartbeat) Observe(n int, now time.Time) {
+	h.polls.Add(1)
+	h.messages.Add(int64(n))
+	h.lastPoll.Store(now.Unix())
+}
+
+// Reset returns the number of receive requests and messages observed since
+// the last reset, along with the time of the last receive request.
+func (h *sqsHeartbeat) Reset() (polls, messages, lastPoll int64) {
+	return h.polls.Swap(0), h.messages.Swap(0), h.lastPoll.Load()
+}
+
+// heartbeatLoop logs the activity of the receive loop and updates the liveness
+// gauge every interval until the input is stopped. This runs separately from
+// the receive loop so that a stuck receive is reported rather than silent.
+func (a *awsSQSReader) heartbeatLoop(wg *sync.WaitGroup, interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.closeSignal.SoftStopChan():
+			return
+		}
+		polls, messages, lastPoll := a.heartbeat.Reset()
+		if lastPoll > 0 {
+			a.lastReceiveGauge.Set(lastPoll)
+		}
+		switch {
+		case polls == 0:
+			a.log.Warnf("No receive requests to SQS completed in the last %v", interval)
+		case messages == 0:
+			a.log.Infof("Connected, idle, %v polls, 0 messages in the last %v", polls, interval)
+		default:
+			a.log.Infof("Connected, %v polls, %v messages in the last %v", polls, messages, interval)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"sync"
+	"time"
+)
+
+// sqsReceiveLimits bounds the number of messages that an input receives and
+// the duration that it receives messages for, after which the input ends.
+type sqsReceiveLimits struct {
+	maxMessages int
+	maxRuntime  time.Duration
+
+	mut      sync.Mutex
+	received int
+	deadline time.Time
+
+	reachedOnce sync.Once
+	reached     chan struct{}
+}
+
+// newSQSReceiveLimits returns nil when neither limit is set, which never
+// limits an input.
+func newSQSReceiveLimits(maxMessages int, maxRuntime time.Duration) *sqsReceiveLimits {
+	if maxMessages <= 0 && maxRuntime <= 0 {
+		return nil
+	}
+	return &sqsReceiveLimits{
+		maxMessages: maxMessages,
+		maxRuntime:  maxRuntime,
+		reached:     make(chan struct{}),
+	}
+}
+
+// Start begins the runtime of the input unless it has already begun, such
+// that reconnecting does not extend it.
+func (l *sqsReceiveLimits) Start(now time.Time) {
+	if l == nil || l.maxRuntime <= 0 {
+		return
+	}
+	l.mut.Lock()
+	if l.deadline.IsZero() {
+		l.deadline = now.Add(l.maxRuntime)
+	}
+	l.mut.Unlock()
+}
+
+// Remaining returns the number of messages that can still be received, which
+// is -1 when unbounded, and false once either limit has been reached.
+func (l *sqsReceiveLimits) Remaining(now time.Time) (int, bool) {
+	if l == nil {
+		return -1, true
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if !l.deadline.IsZero() && !now.Before(l.deadline) {
+		return 0, false
+	}
+	if l.maxMessages <= 0 {
+		return -1, true
+	}
+	if l.received >= l.maxMessages {
+		return 0, false
+	}
+	return l.maxMessages - l.received, true
+}
+
+// Add counts messages that were received.
+func (l *sqsReceiveLimits) Add(n int) {
+	if l == nil {
+		return
+	}
+	l.mut.Lock()
+	l.received += n
+	l.mut.Unlock()
+}
+
+// Until returns the duration until the runtime ends, and false when the
+// runtime is unbounded.
+func (l *sqsReceiveLimits) Until(now time.Time) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	if l.deadline.IsZero() {
+		return 0, false
+	}
+	return l.deadline.Sub(now), true
+}
+
+// Finish signals that all messages received within the limits have been
+// handed over for delivery.
+func (l *sqsReceiveLimits) Finish() {
+	l.reachedOnce.Do(func() { close(l.reached) })
+}
+
+// Reached returns a channel that is closed once Finish is called, or nil
+// when there are no limits.
+func (l *sqsReceiveLimits) Reached() <-chan struct{} {
+	if l == nil {
+		return nil
+	}
+	return l.reached
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+var errSQSMappingFailed = errors.New("failed to execute mapping")
+
+// mapMessage executes the configured mapping against a received message,
+// returning the mapped message along with its body, or a nil message when the
+// mapping deleted it.
+func (a *awsSQSReader) mapMessage(msg *service.Message) (*service.Message, string, error) {
+	res, err := msg.BloblangQuery(a.conf.Mapping)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", errSQSMappingFailed, err)
+	}
+	if res == nil {
+		return nil, "", nil
+	}
+	b, err := res.AsBytes()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", errSQSMappingFailed, err)
+	}
+	return res, string(b), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"sync"
+)
+
+// sqsPollOrder serialises the delivery of messages received by the same
+// ReceiveMessage call, such that each message is only delivered once the
+// previous message of the same poll has been acked. Once a message of a poll
+// is nacked all remaining messages of that poll are rejected, so that they
+// are redelivered after it rather than leapfrogging it.
+type sqsPollOrder struct {
+	mut    sync.Mutex
+	poll   uint64
+	done   chan struct{}
+	nacked bool
+}
+
+// wait blocks until the previously delivered message of the same poll has been
+// acked or nacked, and returns true if any message of the poll was nacked.
+func (o *sqsPollOrder) wait(ctx context.Context, poll uint64) (nacked bool, err error) {
+	o.mut.Lock()
+	done := o.done
+	samePoll := o.poll == poll
+	o.mut.Unlock()
+	if !samePoll || done == nil {
+		return false, nil
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	return o.poll == poll && o.nacked, nil
+}
+
+// deliver marks a message of a poll as delivered and returns a function to
+// be called with the result of the message once it has been acked or nacked.
+func (o *sqsPollOrder) deliver(poll uint64) func(res error) {
+	done := make(chan struct{})
+
+	o.mut.Lock()
+	if o.poll != poll {
+		o.poll = poll
+		o.nacked = false
+	}
+	o.done = done
+	o.mut.Unlock()
+
+	var once sync.Once
+	return func(res error) {
+		once.Do(func() {
+			o.mut.Lock()
+			if res != nil && o.poll == poll {
+				o.nacked = true
+			}
+			o.mut.Unlock()
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	sqsQuarantineActionDelete           = "delete"
+	sqsQuarantineActionExtendVisibility = "extend_visibility"
+	sqsQuarantineActionTag              = "tag"
+)
+
+type sqsNackEntry struct {
+	id     string
+	reason string
+	count  int
+}
+
+// sqsNackTracker counts the consecutive nacks of each message that share the
+// same reason. It holds at most maxEntries messages, evicting those that were
+// least recently nacked first.
+type sqsNackTracker struct {
+	maxEntries int
+
+	mut     sync.Mutex
+	entries map[string]*list.Element
+	fifo    *list.List // contains *sqsNackEntry
+}
+
+func newSQSNackTracker(maxEntries int) *sqsNackTracker {
+	return &sqsNackTracker{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		fifo:       list.New(),
+	}
+}
+
+// Nacked records a nack of a message and returns the number of consecutive
+// nacks of that message with the same reason, including this one.
+func (t *sqsNackTracker) Nacked(id, reason string) int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if e, exists := t.entries[id]; exists {
+		entry := e.Value.(*sqsNackEntry)
+		if entry.reason == reason {
+			entry.count++
+		} else {
+			entry.reason = reason
+			entry.count = 1
+		}
+		t.fifo.MoveToBack(e)
+		return entry.count
+	}
+	t.entries[id] = t.fifo.PushBack(&sqsNackEntry{
+		id:     id,
+		reason: reason,
+		count:  1,
+	})
+	for len(t.entries) > t.maxEntries {
+		t.removeElement(t.fifo.Front())
+	}
+	return 1
+}
+
+// Get returns the latest nack reason of a message and the number of
+// consecutive nacks with that reason.
+func (t *sqsNackTracker) Get(id string) (reason string, count int) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if e, exists := t.entries[id]; exists {
+		entry := e.Value.(*sqsNackEntry)
+		return entry.reason, entry.count
+	}
+	return "", 0
+}
+
+// Forget removes the nack history of a message.
+func (t *sqsNackTracker) Forget(id string) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if e, exists := t.entries[id]; exists {
+		t.removeElement(e)
+	}
+}
+
+// Len returns the number of messages currently tracked.
+func (t *sqsNackTracker) Len() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return len(t.entries)
+}
+
+func (t *sqsNackTracker) removeElement(e *list.Element) {
+	t.fifo.Remove(e)
+	delete(t.entries, e.Value.(*sqsNackEntry).id)
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// waitForRateLimit blocks until the rate limit resource grants access for a
+// single call to SQS, or until the context is cancelled. Errors from the rate
+// limit are logged and access is attempted again after a second.
+func (a *awsSQSReader) waitForRateLimit(ctx context.Context) error {
+	for {
+		var period time.Duration
+		var err error
+		if rerr := a.mgr.AccessRateLimit(ctx, a.conf.RateLimit, func(rl service.RateLimit) {
+			period, err = rl.Access(ctx)
+		}); rerr != nil {
+			err = rerr
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			a.log.Errorf("Rate limit error: %v", err)
+			period = time.Second
+		}
+		if period <= 0 {
+			return nil
+		}
+		select {
+		case <-time.After(period):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// sqsRateLimitedAPI wraps an SQS client such that every call first waits for
+// access from a rate limit resource, which is shared with any other component
+// that references it.
+type sqsRateLimitedAPI struct {
+	api  sqsAPI
+	wait func(context.Context) error
+}
+
+func (r *sqsRateLimitedAPI) ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.ReceiveMessage(ctx, in, opts...)
+}
+
+func (r *sqsRateLimitedAPI) DeleteMessageBatch(ctx context.Context, in *sqs.DeleteMessageBatchInput, opts ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.DeleteMessageBatch(ctx, in, opts...)
+}
+
+func (r *sqsRateLimitedAPI) ChangeMessageVisibilityBatch(ctx context.Context, in *sqs.ChangeMessageVisibilityBatchInput, opts ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.ChangeMessageVisibilityBatch(ctx, in, opts...)
+}
+
+func (r *sqsRateLimitedAPI) GetQueueAttributes(ctx context.Context, in *sqs.GetQueueAttributesInput, opts ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.GetQueueAttributes(ctx, in, opts...)
+}
+
+func (r *sqsRateLimitedAPI) ListQueueTags(ctx context.Context, in *sqs.ListQueueTagsInput, opts ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.ListQueueTags(ctx, in, opts...)
+}
+
+func (r *sqsRateLimitedAPI) SendMessageBatch(ctx context.Context, in *sqs.SendMessageBatchInput, opts ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.api.SendMessageBatch(ctx, in, opts...)
+}
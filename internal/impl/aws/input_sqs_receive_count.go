@@ -0,0 +1,78 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sqsGauge is satisfied by *service.MetricGauge.
+type sqsGauge interface {
+	Set(value int64, labelValues ...string)
+}
+
+// sqsMaxReceiveCount tracks the highest approximate receive count of the
+// messages received within a rolling window, and reports it to a gauge.
+type sqsMaxReceiveCount struct {
+	gauge  sqsGauge
+	window time.Duration
+
+	mu          sync.Mutex
+	max         int64
+	windowStart time.Time
+}
+
+func newSQSMaxReceiveCount(gauge sqsGauge, window time.Duration) *sqsMaxReceiveCount {
+	return &sqsMaxReceiveCount{
+		gauge:  gauge,
+		window: window,
+	}
+}
+
+// Observe records the receive count of a message, starting a new window if the
+// current one has elapsed.
+func (m *sqsMaxReceiveCount) Observe(count int64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.windowStart) >= m.window {
+		m.windowStart = now
+		m.max = count
+		m.gauge.Set(count)
+		return
+	}
+	if count > m.max {
+		m.max = count
+		m.gauge.Set(count)
+	}
+}
+
+// sqsReceiveCount returns the approximate number of times a message has been
+// received, based on its ApproximateReceiveCount system attribute.
+func sqsReceiveCount(sqsMsg types.Message) (int64, bool) {
+	str, exists := sqsMsg.Attributes["ApproximateReceiveCount"]
+	if !exists {
+		return 0, false
+	}
+	count, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return count, true
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// sqsRedrivePolicy is the redrive policy of a queue, which moves messages to
+// a dead letter queue once they have been received too many times.
+type sqsRedrivePolicy struct {
+	DeadLetterTargetARN string
+	MaxReceiveCount     int
+}
+
+// parseSQSRedrivePolicy parses the RedrivePolicy attribute of a queue. SQS
+// has returned maxReceiveCount as both a number and a string.
+func parseSQSRedrivePolicy(s string) (p sqsRedrivePolicy, err error) {
+	var raw struct {
+		DeadLetterTargetARN string          `json:"deadLetterTargetArn"`
+		MaxReceiveCount     json.RawMessage `json:"maxReceiveCount"`
+	}
+	if err = json.Unmarshal([]byte(s), &raw); err != nil {
+		return
+	}
+	if raw.DeadLetterTargetARN == "" {
+		err = errors.New("missing deadLetterTargetArn")
+		return
+	}
+	p.DeadLetterTargetARN = raw.DeadLetterTargetARN
+
+	count := string(raw.MaxReceiveCount)
+	if unquoted, uerr := strconv.Unquote(count); uerr == nil {
+		count = unquoted
+	}
+	if p.MaxReceiveCount, err = strconv.Atoi(count); err != nil {
+		err = fmt.Errorf("invalid maxReceiveCount %s", raw.MaxReceiveCount)
+		return
+	}
+	if p.MaxReceiveCount < 1 {
+		err = fmt.Errorf("invalid maxReceiveCount %d", p.MaxReceiveCount)
+	}
+	return
+}
+
+// loadRedrivePolicy fetches the redrive policy of the queue and logs it. The
+// input continues without a policy when it cannot be fetched.
+func (a *awsSQSReader) loadRedrivePolicy(ctx context.Context) {
+	res, err := a.client().GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(a.conf.URL),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameRedrivePolicy},
+	})
+	if err != nil {
+		if l := a.reportError(sqsiOpGetAttributes, err); l != nil {
+			l.Errorf("Failed to get SQS queue redrive policy: %v", err)
+		}
+		return
+	}
+	v, exists := res.Attributes[string(types.QueueAttributeNameRedrivePolicy)]
+	if !exists || v == "" {
+		a.redrive.Store(nil)
+		a.log.Infof("Queue %v has no redrive policy, messages are never moved to a dead letter queue", a.queueName)
+		return
+	}
+	policy, err := parseSQSRedrivePolicy(v)
+	if err != nil {
+		a.log.Errorf("Failed to parse SQS queue redrive policy %q: %v", v, err)
+		return
+	}
+	a.redrive.Store(&policy)
+	a.log.Infof("Queue %v moves messages to dead letter queue %v after %v receives", a.queueName, policy.DeadLetterTargetARN, policy.MaxReceiveCount)
+}
+
+// addRedriveMetadata adds the redrive policy of the queue to a message when
+// it is known.
+func (a *awsSQSReader) addRedriveMetadata(msg *service.Message) {
+	policy := a.redrive.Load()
+	if policy == nil {
+		return
+	}
+	msg.MetaSetMut("sqs_redrive_dead_letter_target_arn", policy.DeadLetterTargetARN)
+	msg.MetaSetMut("sqs_redrive_max_receive_count", strconv.Itoa(policy.MaxReceiveCount))
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+type sqsS3EventNotification struct {
+	Records []json.RawMessage `json:"Records"`
+}
+
+type sqsS3EventRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// sqsS3EventMessages splits a message containing an S3 event notification into
+// one message per record, each with the record as its contents and its bucket,
+// key and event name as metadata. Returns false if the body is not an S3 event
+// notification with at least one record.
+func sqsS3EventMessages(msg *service.Message, body string) ([]*service.Message, bool) {
+	var notification sqsS3EventNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil || len(notification.Records) == 0 {
+		return nil, false
+	}
+	msgs := make([]*service.Message, 0, len(notification.Records))
+	for _, raw := range notification.Records {
+		var record sqsS3EventRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, false
+		}
+		// Object keys within event notifications are URL encoded.
+		key := record.S3.Object.Key
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		recordMsg := msg.Copy()
+		recordMsg.SetBytes(raw)
+		recordMsg.MetaSetMut("s3_bucket_name", record.S3.Bucket.Name)
+		recordMsg.MetaSetMut("s3_object_key", key)
+		recordMsg.MetaSetMut("s3_event_name", record.EventName)
+		msgs = append(msgs, recordMsg)
+	}
+	return msgs, true
+}
+
+// sqsSharedAck returns an ack func that must be called n times before ack is
+// called with the first error it received, if any.
+func sqsSharedAck(n int, ack service.AckFunc) service.AckFunc {
+	var mut sync.Mutex
+	var res error
+	return func(ctx context.Context, err error) error {
+		mut.Lock()
+		if res == nil {
+			res = err
+		}
+		n--
+		remaining, finalRes := n, res
+		mut.Unlock()
+
+		if remaining > 0 {
+			return nil
+		}
+		return ack(ctx, finalRes)
+	}
+}
+
+type sqsPendingMessage struct {
+	msg *service.Message
+	ack service.AckFunc
+}
+
+// sqsPendingMessages holds messages that have been split from a single SQS
+// message and are waiting to be read.
+type sqsPendingMessages struct {
+	mut  sync.Mutex
+	msgs []sqsPendingMessage
+}
+
+func (p *sqsPendingMessages) push(msgs []*service.Message, ack service.AckFunc) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for _, msg := range msgs {
+		p.msgs = append(p.msgs, sqsPendingMessage{msg: msg, ack: ack})
+	}
+}
+
+func (p *sqsPendingMessages) pop() (sqsPendingMessage, bool) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if len(p.msgs) == 0 {
+		return sqsPendingMessage{}, false
+	}
+	next := p.msgs[0]
+	p.msgs = p.msgs[1:]
+	return next, true
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	sqsSchemaActionReject = "reject"
+	sqsSchemaActionDrop   = "drop"
+	sqsSchemaActionFlag   = "flag"
+)
+
+var errSQSSchemaInvalid = errors.New("message body does not conform to the JSON schema")
+
+// sqsSchemaValidator checks the bodies of messages against a JSON schema.
+type sqsSchemaValidator struct {
+	schema *gojsonschema.Schema
+}
+
+// newSQSSchemaValidator compiles a JSON schema, which is read from a file when
+// prefixed with file://, and returns nil when no schema is given.
+func newSQSSchemaValidator(schema string, fs *service.FS) (*sqsSchemaValidator, error) {
+	if schema == "" {
+		return nil, nil
+	}
+	if path, ok := strings.CutPrefix(schema, "file://"); ok {
+		b, err := service.ReadFile(fs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JSON schema: %w", err)
+		}
+		schema = string(b)
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JSON schema: %w", err)
+	}
+	return &sqsSchemaValidator{schema: s}, nil
+}
+
+// Validate returns an error describing why a body does not conform to the
+// schema, including when it is not valid JSON.
+func (v *sqsSchemaValidator) Validate(body string) error {
+	if v == nil {
+		return nil
+	}
+	res, err := v.schema.Validate(gojsonschema.NewStringLoader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errSQSSchemaInvalid, err)
+	}
+	if res.Valid() {
+		return nil
+	}
+	reasons := make([]string, 0, len(res.Errors()))
+	for _, e := range res.Errors() {
+		reasons = append(reasons, e.String())
+	}
+	return fmt.Errorf("%w: %v", errSQSSchemaInvalid, strings.Join(reasons, ", "))
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"cmp"
+	"container/list"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// The maximum number of message groups tracked at a time when detecting
+// sequence gaps.
+const sqsSequenceMaxGroups = 10000
+
+type sqsSequenceEntry struct {
+	group    string
+	sequence string
+}
+
+// sqsSequenceTracker records the sequence number of the last message delivered
+// from each message group of a FIFO queue. It holds at most maxEntries groups,
+// evicting those that were least recently delivered from first.
+type sqsSequenceTracker struct {
+	maxEntries int
+
+	mut     sync.Mutex
+	entries map[string]*list.Element
+	fifo    *list.List // contains *sqsSequenceEntry
+}
+
+func newSQSSequenceTracker(maxEntries int) *sqsSequenceTracker {
+	return &sqsSequenceTracker{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		fifo:       list.New(),
+	}
+}
+
+// Delivered records the delivery of a message with a sequence number from a
+// group. If the sequence number is lower than that of the previous message
+// delivered from the group then the previous sequence number is returned along
+// with false. A message delivered again with the same sequence number, such as
+// after being rejected, is not considered out of order.
+func (t *sqsSequenceTracker) Delivered(group, sequence string) (previous string, ordered bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if e, exists := t.entries[group]; exists {
+		entry := e.Value.(*sqsSequenceEntry)
+		previous = entry.sequence
+		ordered = sqsCompareSequenceNumbers(sequence, previous) >= 0
+		if ordered {
+			entry.sequence = sequence
+		}
+		t.fifo.MoveToBack(e)
+		return previous, ordered
+	}
+	t.entries[group] = t.fifo.PushBack(&sqsSequenceEntry{
+		group:    group,
+		sequence: sequence,
+	})
+	for len(t.entries) > t.maxEntries {
+		e := t.fifo.Front()
+		t.fifo.Remove(e)
+		delete(t.entries, e.Value.(*sqsSequenceEntry).group)
+	}
+	return "", true
+}
+
+// sqsCompareSequenceNumbers compares two SQS sequence numbers, which are
+// decimal strings of up to 128 bits without leading zeros and are too large to
+// be parsed into a uint64.
+func sqsCompareSequenceNumbers(a, b string) int {
+	if c := cmp.Compare(len(a), len(b)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a, b)
+}
+
+// checkSequence logs a warning when a message is delivered out of order
+// relative to the previous message delivered from its group.
+func (a *awsSQSReader) checkSequence(sqsMsg types.Message) {
+	if a.sequences == nil {
+		return
+	}
+	group, exists := sqsMsg.Attributes["MessageGroupId"]
+	if !exists {
+		return
+	}
+	seq, exists := sqsMsg.Attributes["SequenceNumber"]
+	if !exists {
+		return
+	}
+	if previous, ordered := a.sequences.Delivered(group, seq); !ordered {
+		a.log.Warnf("Message %v of group %v was delivered out of order: sequence number %v is lower than the previously delivered %v", aws.ToString(sqsMsg.MessageId), group, seq, previous)
+	}
+}
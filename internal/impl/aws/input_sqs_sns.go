@@ -0,0 +1,56 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"encoding/json"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+type sqsSNSEnvelope struct {
+	Type              string  `json:"Type"`
+	MessageID         string  `json:"MessageId"`
+	TopicArn          string  `json:"TopicArn"`
+	Subject           string  `json:"Subject"`
+	Message           *string `json:"Message"`
+	MessageAttributes map[string]struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	} `json:"MessageAttributes"`
+}
+
+// sqsUnwrapSNS parses a body containing an SNS notification envelope, adds its
+// topic ARN, subject, message ID and message attributes to a message as
+// metadata and returns the inner message. Returns false if the body is not an
+// SNS notification, in which case the message is left unchanged.
+func sqsUnwrapSNS(msg *service.Message, body string) (string, bool) {
+	var envelope sqsSNSEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return "", false
+	}
+	if envelope.Type != "Notification" || envelope.TopicArn == "" || envelope.Message == nil {
+		return "", false
+	}
+	msg.MetaSetMut("sns_topic_arn", envelope.TopicArn)
+	msg.MetaSetMut("sns_message_id", envelope.MessageID)
+	if envelope.Subject != "" {
+		msg.MetaSetMut("sns_subject", envelope.Subject)
+	}
+	for k, v := range envelope.MessageAttributes {
+		msg.MetaSetMut("sns_attribute_"+k, v.Value)
+	}
+	return *envelope.Message, true
+}
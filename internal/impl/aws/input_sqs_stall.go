@@ -0,0 +1,61 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"sync"
+	"time"
+)
+
+// sqsStallDetector detects when the pipeline stops acknowledging or rejecting
+// messages while messages are in flight, such as when an output is down.
+type sqsStallDetector struct {
+	timeout time.Duration
+
+	mut          sync.Mutex
+	lastProgress time.Time
+}
+
+// newSQSStallDetector returns nil when timeout is not positive, which never
+// detects a stall.
+func newSQSStallDetector(timeout time.Duration, now time.Time) *sqsStallDetector {
+	if timeout <= 0 {
+		return nil
+	}
+	return &sqsStallDetector{timeout: timeout, lastProgress: now}
+}
+
+// Progress records that a message was acknowledged or rejected, or that no
+// messages are in flight and so there is nothing to make progress on.
+func (d *sqsStallDetector) Progress(now time.Time) {
+	if d == nil {
+		return
+	}
+	d.mut.Lock()
+	if now.After(d.lastProgress) {
+		d.lastProgress = now
+	}
+	d.mut.Unlock()
+}
+
+// Stalled returns whether no progress has been made for the timeout.
+func (d *sqsStallDetector) Stalled(now time.Time) bool {
+	if d == nil {
+		return false
+	}
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return now.Sub(d.lastProgress) >= d.timeout
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// The prefix of the metadata keys that queue tags are added with.
+const sqsiQueueTagMetaPrefix = "sqs_tag_"
+
+// loadQueueTags lists the tags of the queue. The input continues with the
+// tags of the last successful listing, if any, when they cannot be listed.
+func (a *awsSQSReader) loadQueueTags(ctx context.Context) {
+	res, err := a.client().ListQueueTags(ctx, &sqs.ListQueueTagsInput{
+		QueueUrl: aws.String(a.conf.URL),
+	})
+	if err != nil {
+		if l := a.reportError(sqsiOpListTags, err); l != nil {
+			l.Errorf("Failed to list SQS queue tags: %v", err)
+		}
+		return
+	}
+	tags := make(map[string]string, len(res.Tags))
+	for k, v := range res.Tags {
+		tags[sqsiQueueTagMetaPrefix+k] = v
+	}
+	a.queueTags.Store(&tags)
+	a.log.Debugf("Loaded %v tags of queue %v", len(tags), a.queueName)
+}
+
+// addQueueTagsMetadata adds the tags of the queue to a message when they are
+// known.
+func (a *awsSQSReader) addQueueTagsMetadata(msg *service.Message) {
+	tags := a.queueTags.Load()
+	if tags == nil {
+		return
+	}
+	for k, v := range *tags {
+		msg.MetaSetMut(k, v)
+	}
+}
@@ -15,10 +15,21 @@
 package aws
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,9 +38,15 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 )
 
@@ -40,6 +57,7 @@ type mockSqsInput struct {
 	queueTimeout int32
 	messages     []types.Message
 	mesTimeouts  map[string]int32
+	receives     map[string]int
 }
 
 func (m *mockSqsInput) do(fn func()) {
@@ -79,6 +97,10 @@ func (m *mockSqsInput) ReceiveMessage(context.Context, *sqs.ReceiveMessageInput,
 		if timeout, found := m.mesTimeouts[*message.MessageId]; !found || timeout == 0 {
 			messages = append(messages, message)
 			m.mesTimeouts[*message.MessageId] = m.queueTimeout
+			if m.receives == nil {
+				m.receives = map[string]int{}
+			}
+			m.receives[*message.MessageId]++
 		}
 	}
 
@@ -153,7 +175,7 @@ func TestSQSInput(t *testing.T) {
 			MessageTimeout:      10 * time.Second,
 		},
 		conf,
-		nil,
+		service.MockResources(),
 	)
 	require.NoError(t, err)
 
@@ -244,7 +266,7 @@ func TestSQSInputBatchAck(t *testing.T) {
 			MessageTimeout:      10 * time.Second,
 		},
 		conf,
-		nil,
+		service.MockResources(),
 	)
 	require.NoError(t, err)
 
@@ -292,3 +314,3566 @@ func TestSQSInputBatchAck(t *testing.T) {
 		return msgsLen == 0
 	}, 5*time.Second, time.Second)
 }
+
+func testSQSReaderConfig() sqsiConfig {
+	return sqsiConfig{
+		URL:                 "http://foo.example.com",
+		WaitTimeSeconds:     0,
+		DeleteMessage:       true,
+		ResetVisibility:     true,
+		MaxNumberOfMessages: 10,
+		MaxOutstanding:      100,
+		MessageTimeout:      10 * time.Second,
+	}
+}
+
+func newTestSQSReader(t *testing.T, conf sqsiConfig) *awsSQSReader {
+	t.Helper()
+	return newTestSQSReaderWithResources(t, conf, service.MockResources())
+}
+
+func newTestSQSReaderWithResources(t *testing.T, conf sqsiConfig, mgr *service.Resources) *awsSQSReader {
+	t.Helper()
+
+	aconf, err := config.LoadDefaultConfig(t.Context(),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("xxxxx", "xxxxx", "xxxxx")),
+	)
+	require.NoError(t, err)
+
+	r, err := newAWSSQSReader(conf, aconf, mgr)
+	require.NoError(t, err)
+
+	t.Cleanup(r.closeSignal.TriggerHardStop)
+	return r
+}
+
+func newTestMockSQS(t *testing.T, messages []types.Message) *mockSqsInput {
+	t.Helper()
+
+	mockInput := &mockSqsInput{
+		queueTimeout: 10,
+		messages:     messages,
+		mesTimeouts:  make(map[string]int32, len(messages)),
+	}
+	go mockInput.TimeoutLoop(t.Context())
+	return mockInput
+}
+
+func startTestSQSReader(t *testing.T, conf sqsiConfig, messages []types.Message) (*awsSQSReader, *mockSqsInput) {
+	t.Helper()
+
+	r := newTestSQSReader(t, conf)
+	mockInput := newTestMockSQS(t, messages)
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(t.Context()))
+	return r, mockInput
+}
+
+// assertSQSMessageHeld asserts that a rejected message is not received again
+// straight away.
+func assertSQSMessageHeld(t *testing.T, m *mockSqsInput, id string) {
+	t.Helper()
+	assert.Never(t, func() (received bool) {
+		m.do(func() {
+			received = m.receives[id] > 1
+		})
+		return
+	}, 2*time.Second, 100*time.Millisecond)
+}
+
+func remainingSQSMessageIDs(m *mockSqsInput) (ids []string) {
+	m.do(func() {
+		for _, msg := range m.messages {
+			ids = append(ids, *msg.MessageId)
+		}
+	})
+	return
+}
+
+func TestSQSInputFilter(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{}
+	for i := range 5 {
+		kind := "drop"
+		if i%2 == 0 {
+			kind = "keep"
+		}
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"kind": {DataType: aws.String("String"), StringValue: aws.String(kind)},
+			},
+		})
+	}
+
+	filter, err := bloblang.Parse(`root = @kind == "keep"`)
+	require.NoError(t, err)
+
+	conf := testSQSReaderConfig()
+	conf.Filter = filter
+	conf.DropFiltered = true
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	var acks []service.AckFunc
+	for _, exp := range []string{"message-0", "message-2", "message-4"} {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(mBytes))
+
+		kind, _ := m.MetaGet("kind")
+		assert.Equal(t, "keep", kind)
+		acks = append(acks, aFn)
+	}
+
+	// Filtered messages are deleted without being delivered
+	require.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-0", "id-2", "id-4"})
+	}, 5*time.Second, 100*time.Millisecond)
+
+	for _, aFn := range acks {
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputFilteredVisibility(t *testing.T) {
+	tCtx := t.Context()
+
+	filter, err := bloblang.Parse(`root = content() != "drop"`)
+	require.NoError(t, err)
+
+	newMessages := func() []types.Message {
+		return []types.Message{
+			{Body: aws.String("drop"), MessageId: aws.String("id-1"), ReceiptHandle: aws.String("h-1")},
+			{Body: aws.String("keep"), MessageId: aws.String("id-2"), ReceiptHandle: aws.String("h-2")},
+		}
+	}
+
+	readKept := func(t *testing.T, r *awsSQSReader) {
+		t.Helper()
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "keep", string(b))
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.Filter = filter
+		conf.DropFiltered = false
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		readKept(t, r)
+
+		// The filtered message is made visible again straight away.
+		assert.Eventually(t, func() (received bool) {
+			mockInput.do(func() {
+				received = mockInput.receives["id-1"] > 1
+			})
+			return
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("held", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.Filter = filter
+		conf.DropFiltered = false
+		conf.FilteredVisibility = 30 * time.Second
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		readKept(t, r)
+
+		assert.Eventually(t, func() (held bool) {
+			mockInput.do(func() {
+				held = mockInput.mesTimeouts["id-1"] > 10
+			})
+			return
+		}, 5*time.Second, 100*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-1"})
+		}, 5*time.Second, 100*time.Millisecond)
+		assertSQSMessageHeld(t, mockInput, "id-1")
+	})
+}
+
+func TestSQSInputBodyMetadataKey(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body:          aws.String(`{"signed":"payload"}`),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.BodyMetadataKey = "sqs_body"
+	r, _ := startTestSQSReader(t, conf, messages)
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	// The metadata keeps the original body after the payload is replaced.
+	m.SetBytes([]byte("replaced"))
+	body, exists := m.MetaGetMut("sqs_body")
+	require.True(t, exists)
+	assert.Equal(t, `{"signed":"payload"}`, body)
+
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSInputAckFlushIdlePeriod(t *testing.T) {
+	tCtx := t.Context()
+
+	readAndAck := func(t *testing.T, conf sqsiConfig) *mockSqsInput {
+		t.Helper()
+		r, mockInput := startTestSQSReader(t, conf, []types.Message{
+			{
+				Body:          aws.String("foo"),
+				MessageId:     aws.String("id-1"),
+				ReceiptHandle: aws.String("h-1"),
+			},
+		})
+		_, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		require.NoError(t, aFn(tCtx, nil))
+		return mockInput
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.AckFlushIdlePeriod = 20 * time.Millisecond
+		mockInput := readAndAck(t, conf)
+
+		// The batch is far from full, but is flushed well before the one
+		// second flush interval.
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 500*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		pConf, err := sqsInputSpec().ParseYAML(`url: https://sqs.us-east-1.amazonaws.com/123456789012/orders`, nil)
+		require.NoError(t, err)
+		parsed, err := sqsiConfigFromParsed(pConf)
+		require.NoError(t, err)
+		require.Zero(t, parsed.AckFlushIdlePeriod)
+
+		// Without an idle period pending deletes are only sent once the batch
+		// is full or the one second flush interval elapses.
+		conf := testSQSReaderConfig()
+		conf.AckFlushIdlePeriod = parsed.AckFlushIdlePeriod
+		mockInput := readAndAck(t, conf)
+
+		assert.Never(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 500*time.Millisecond, 10*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
+
+func TestSQSInputS3EventMode(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body: aws.String(`{"Records":[
+				{"eventVersion":"2.1","eventSource":"aws:s3","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"foo-bucket"},"object":{"key":"reports/my+report%3D1.json","size":1024}}},
+				{"eventVersion":"2.1","eventSource":"aws:s3","eventName":"ObjectRemoved:Delete","s3":{"bucket":{"name":"bar-bucket"},"object":{"key":"old.json"}}}
+			]}`),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+		{
+			Body:          aws.String(`{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"foo-bucket"}`),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.S3EventMode = true
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	type record struct {
+		bucket, key, event string
+		aFn                service.AckFunc
+	}
+	readRecord := func() record {
+		t.Helper()
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		body, err := m.AsStructured()
+		require.NoError(t, err)
+		assert.Equal(t, "aws:s3", body.(map[string]any)["eventSource"])
+		id, _ := m.MetaGetMut("sqs_message_id")
+		assert.Equal(t, "id-1", id)
+		var rec record
+		rec.aFn = aFn
+		rec.bucket, _ = m.MetaGet("s3_bucket_name")
+		rec.key, _ = m.MetaGet("s3_object_key")
+		rec.event, _ = m.MetaGet("s3_event_name")
+		return rec
+	}
+
+	first := readRecord()
+	assert.Equal(t, "foo-bucket", first.bucket)
+	assert.Equal(t, "reports/my report=1.json", first.key)
+	assert.Equal(t, "ObjectCreated:Put", first.event)
+
+	second := readRecord()
+	assert.Equal(t, "bar-bucket", second.bucket)
+	assert.Equal(t, "old.json", second.key)
+	assert.Equal(t, "ObjectRemoved:Delete", second.event)
+
+	// Messages that are not S3 event notifications are delivered unchanged.
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, *messages[1].Body, string(b))
+	_, exists := m.MetaGet("s3_bucket_name")
+	assert.False(t, exists)
+	require.NoError(t, aFn(tCtx, nil))
+
+	// The SQS message is only deleted once every record has been acked.
+	require.NoError(t, first.aFn(tCtx, nil))
+	assert.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-1"})
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Never(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 500*time.Millisecond, 100*time.Millisecond)
+
+	require.NoError(t, second.aFn(tCtx, nil))
+	assert.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputSNSUnwrap(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body: aws.String(`{
+				"Type": "Notification",
+				"MessageId": "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+				"TopicArn": "arn:aws:sns:us-west-2:123456789012:MyTopic",
+				"Subject": "My First Message",
+				"Message": "{\"hello\":\"world\"}",
+				"Timestamp": "2012-05-02T00:54:06.655Z",
+				"SignatureVersion": "1",
+				"Signature": "EXAMPLEw6JRN...",
+				"SigningCertURL": "https://sns.us-west-2.amazonaws.com/SimpleNotificationService-f3ecfb7224c7233fe7bb5f59f96de52f.pem",
+				"UnsubscribeURL": "https://sns.us-west-2.amazonaws.com/?Action=Unsubscribe&SubscriptionArn=arn:aws:sns:us-west-2:123456789012:MyTopic:c9135db0-26c4-47ec-8998-413945fb5a96",
+				"MessageAttributes": {
+					"tenant": {"Type": "String", "Value": "acme"},
+					"priority": {"Type": "Number", "Value": "3"}
+				}
+			}`),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+		{
+			Body:          aws.String(`{"Type":"something else","Message":"nope"}`),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		},
+		{
+			Body:          aws.String("not json"),
+			MessageId:     aws.String("id-3"),
+			ReceiptHandle: aws.String("h-3"),
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.SNSUnwrap = true
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(b))
+	for k, v := range map[string]string{
+		"sqs_message_id":         "id-1",
+		"sns_topic_arn":          "arn:aws:sns:us-west-2:123456789012:MyTopic",
+		"sns_message_id":         "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		"sns_subject":            "My First Message",
+		"sns_attribute_tenant":   "acme",
+		"sns_attribute_priority": "3",
+	} {
+		actual, _ := m.MetaGet(k)
+		assert.Equal(t, v, actual, k)
+	}
+	require.NoError(t, aFn(tCtx, nil))
+
+	// Bodies that are not SNS notifications are delivered unchanged.
+	for _, sqsMsg := range messages[1:] {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, *sqsMsg.Body, string(b))
+		_, exists := m.MetaGet("sns_topic_arn")
+		assert.False(t, exists)
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputSNSUnwrapS3Event(t *testing.T) {
+	tCtx := t.Context()
+
+	envelope, err := json.Marshal(map[string]any{
+		"Type":      "Notification",
+		"MessageId": "sns-1",
+		"TopicArn":  "arn:aws:sns:us-west-2:123456789012:Uploads",
+		"Message":   `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"foo-bucket"},"object":{"key":"a.json"}}}]}`,
+	})
+	require.NoError(t, err)
+
+	conf := testSQSReaderConfig()
+	conf.SNSUnwrap = true
+	conf.S3EventMode = true
+	r, _ := startTestSQSReader(t, conf, []types.Message{
+		{
+			Body:          aws.String(string(envelope)),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	})
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	for k, v := range map[string]string{
+		"sns_topic_arn":  "arn:aws:sns:us-west-2:123456789012:Uploads",
+		"s3_bucket_name": "foo-bucket",
+		"s3_object_key":  "a.json",
+	} {
+		actual, _ := m.MetaGet(k)
+		assert.Equal(t, v, actual, k)
+	}
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSSharedAck(t *testing.T) {
+	var calls int
+	var res error
+	ack := sqsSharedAck(3, func(_ context.Context, err error) error {
+		calls++
+		res = err
+		return nil
+	})
+
+	require.NoError(t, ack(t.Context(), nil))
+	require.NoError(t, ack(t.Context(), errors.New("first")))
+	require.NoError(t, ack(t.Context(), errors.New("second")))
+	assert.Equal(t, 1, calls)
+	assert.EqualError(t, res, "first")
+}
+
+func TestSQSBodyMD5Matches(t *testing.T) {
+	msg := func(body, digest *string) types.Message {
+		return types.Message{Body: body, MD5OfBody: digest}
+	}
+	assert.True(t, sqsBodyMD5Matches(msg(aws.String("message-1"), aws.String("3d6b824fd8c1520e9a047d21fee6fb1f"))))
+	assert.True(t, sqsBodyMD5Matches(msg(aws.String("message-1"), aws.String("3D6B824FD8C1520E9A047D21FEE6FB1F"))))
+	assert.True(t, sqsBodyMD5Matches(msg(aws.String("message-1"), nil)))
+	assert.False(t, sqsBodyMD5Matches(msg(aws.String("message-1 "), aws.String("3d6b824fd8c1520e9a047d21fee6fb1f"))))
+	assert.False(t, sqsBodyMD5Matches(msg(aws.String("message-"), aws.String("3d6b824fd8c1520e9a047d21fee6fb1f"))))
+}
+
+func TestSQSInputVerifyMD5(t *testing.T) {
+	tCtx := t.Context()
+
+	newMessages := func() []types.Message {
+		return []types.Message{
+			{
+				Body:          aws.String("message-1"),
+				MD5OfBody:     aws.String("3d6b824fd8c1520e9a047d21fee6fb1f"),
+				MessageId:     aws.String("id-1"),
+				ReceiptHandle: aws.String("h-1"),
+			},
+			{
+				// Tampered with after the digest was calculated.
+				Body:          aws.String("message-2 tampered"),
+				MD5OfBody:     aws.String("95ef155b66299d14edf7ed57c468c13b"),
+				MessageId:     aws.String("id-2"),
+				ReceiptHandle: aws.String("h-2"),
+			},
+			{
+				Body:          aws.String("message-3"),
+				MD5OfBody:     aws.String("a06498de7fb4bd539c8895748f03175d"),
+				MessageId:     aws.String("id-3"),
+				ReceiptHandle: aws.String("h-3"),
+			},
+		}
+	}
+
+	readBodies := func(t *testing.T, r *awsSQSReader, n int) (bodies []string) {
+		t.Helper()
+		for range n {
+			m, aFn, err := r.Read(tCtx)
+			require.NoError(t, err)
+			b, err := m.AsBytes()
+			require.NoError(t, err)
+			bodies = append(bodies, string(b))
+			digest, _ := m.MetaGet("sqs_body_md5")
+			assert.Len(t, digest, 32)
+			require.NoError(t, aFn(tCtx, nil))
+		}
+		return
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.VerifyMD5 = true
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		assert.Equal(t, []string{"message-1", "message-3"}, readBodies(t, r, 2))
+		assert.Eventually(t, func() bool {
+			return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-2"})
+		}, 5*time.Second, 100*time.Millisecond)
+		assertSQSMessageHeld(t, mockInput, "id-2")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		r, mockInput := startTestSQSReader(t, testSQSReaderConfig(), newMessages())
+
+		assert.Equal(t, []string{"message-1", "message-2 tampered", "message-3"}, readBodies(t, r, 3))
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
+
+func TestSQSInputMaxBodyBytes(t *testing.T) {
+	tCtx := t.Context()
+
+	newMessages := func() []types.Message {
+		return []types.Message{
+			{Body: aws.String("small"), MessageId: aws.String("id-1"), ReceiptHandle: aws.String("h-1")},
+			{Body: aws.String("large héllo"), MessageId: aws.String("id-2"), ReceiptHandle: aws.String("h-2")},
+			{Body: aws.String("tiny"), MessageId: aws.String("id-3"), ReceiptHandle: aws.String("h-3")},
+		}
+	}
+
+	readBodies := func(t *testing.T, r *awsSQSReader, n int) (bodies []string, truncated []string) {
+		t.Helper()
+		for range n {
+			m, aFn, err := r.Read(tCtx)
+			require.NoError(t, err)
+			b, err := m.AsBytes()
+			require.NoError(t, err)
+			bodies = append(bodies, string(b))
+			if v, ok := m.MetaGetMut("sqs_truncated"); ok {
+				assert.Equal(t, "true", v)
+				truncated = append(truncated, string(b))
+			}
+			require.NoError(t, aFn(tCtx, nil))
+		}
+		return
+	}
+
+	t.Run("truncate", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.MaxBodyBytes = 8
+		conf.MaxBodyAction = sqsMaxBodyActionTruncate
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		// The cut falls within the two bytes of é, which is kept whole.
+		bodies, truncated := readBodies(t, r, 3)
+		assert.Equal(t, []string{"small", "large h", "tiny"}, bodies)
+		assert.Equal(t, []string{"large h"}, truncated)
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.MaxBodyBytes = 8
+		conf.MaxBodyAction = sqsMaxBodyActionReject
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		bodies, truncated := readBodies(t, r, 2)
+		assert.Equal(t, []string{"small", "tiny"}, bodies)
+		assert.Empty(t, truncated)
+		assert.Eventually(t, func() bool {
+			return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-2"})
+		}, 5*time.Second, 100*time.Millisecond)
+		assertSQSMessageHeld(t, mockInput, "id-2")
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.MaxBodyBytes = 8
+		conf.MaxBodyAction = sqsMaxBodyActionDrop
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		bodies, truncated := readBodies(t, r, 2)
+		assert.Equal(t, []string{"small", "tiny"}, bodies)
+		assert.Empty(t, truncated)
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		r, _ := startTestSQSReader(t, testSQSReaderConfig(), newMessages())
+
+		bodies, truncated := readBodies(t, r, 3)
+		assert.Equal(t, []string{"small", "large héllo", "tiny"}, bodies)
+		assert.Empty(t, truncated)
+	})
+}
+
+func TestSQSInputMapping(t *testing.T) {
+	tCtx := t.Context()
+
+	mapping, err := bloblang.Parse(`
+root = this
+root.email = deleted()
+meta mapped = "true"
+root = if this.type == "skip" { deleted() }
+`)
+	require.NoError(t, err)
+	// The filter sees the mapped message.
+	filter, err := bloblang.Parse(`root = @mapped == "true"`)
+	require.NoError(t, err)
+
+	conf := testSQSReaderConfig()
+	conf.Mapping = mapping
+	conf.Filter = filter
+	conf.DropFiltered = false
+	r, mockInput := startTestSQSReader(t, conf, []types.Message{
+		{Body: aws.String(`{"id":1,"email":"foo@example.com"}`), MessageId: aws.String("id-1"), ReceiptHandle: aws.String("h-1")},
+		{Body: aws.String(`{"id":2,"type":"skip"}`), MessageId: aws.String("id-2"), ReceiptHandle: aws.String("h-2")},
+		{Body: aws.String(`not json`), MessageId: aws.String("id-3"), ReceiptHandle: aws.String("h-3")},
+		{Body: aws.String(`{"id":4,"email":"bar@example.com"}`), MessageId: aws.String("id-4"), ReceiptHandle: aws.String("h-4")},
+	})
+
+	for _, expected := range []string{`{"id":1}`, `{"id":4}`} {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+		v, _ := m.MetaGet("mapped")
+		assert.Equal(t, "true", v)
+		id, _ := m.MetaGet("sqs_message_id")
+		assert.NotEmpty(t, id)
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	// Deleted messages are removed from the queue, whereas those the mapping
+	// fails on are held to be received again later.
+	assert.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-3"})
+	}, 5*time.Second, 100*time.Millisecond)
+	assertSQSMessageHeld(t, mockInput, "id-3")
+}
+
+func TestSQSInputJSONSchema(t *testing.T) {
+	tCtx := t.Context()
+
+	const schema = `{
+  "type": "object",
+  "properties": {
+    "id": { "type": "integer" },
+    "name": { "type": "string" }
+  },
+  "required": ["id"]
+}`
+
+	newMessages := func() []types.Message {
+		return []types.Message{
+			{Body: aws.String(`{"id":1,"name":"foo"}`), MessageId: aws.String("id-1"), ReceiptHandle: aws.String("h-1")},
+			{Body: aws.String(`{"name":"bar"}`), MessageId: aws.String("id-2"), ReceiptHandle: aws.String("h-2")},
+			{Body: aws.String(`{"id":"three"}`), MessageId: aws.String("id-3"), ReceiptHandle: aws.String("h-3")},
+			{Body: aws.String(`not json`), MessageId: aws.String("id-4"), ReceiptHandle: aws.String("h-4")},
+			{Body: aws.String(`{"id":5}`), MessageId: aws.String("id-5"), ReceiptHandle: aws.String("h-5")},
+		}
+	}
+
+	readBodies := func(t *testing.T, r *awsSQSReader, n int) (bodies, invalid []string) {
+		t.Helper()
+		for range n {
+			m, aFn, err := r.Read(tCtx)
+			require.NoError(t, err)
+			b, err := m.AsBytes()
+			require.NoError(t, err)
+			bodies = append(bodies, string(b))
+			if v, ok := m.MetaGet("sqs_schema_invalid"); ok {
+				assert.Equal(t, "true", v)
+				reason, _ := m.MetaGet("sqs_schema_error")
+				assert.NotEmpty(t, reason)
+				invalid = append(invalid, string(b))
+			}
+			require.NoError(t, aFn(tCtx, nil))
+		}
+		return
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.JSONSchema = schema
+		conf.JSONSchemaAction = sqsSchemaActionReject
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		bodies, invalid := readBodies(t, r, 2)
+		assert.Equal(t, []string{`{"id":1,"name":"foo"}`, `{"id":5}`}, bodies)
+		assert.Empty(t, invalid)
+		assert.Eventually(t, func() bool {
+			return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-2", "id-3", "id-4"})
+		}, 5*time.Second, 100*time.Millisecond)
+		for _, id := range []string{"id-2", "id-3", "id-4"} {
+			assertSQSMessageHeld(t, mockInput, id)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.JSONSchema = schema
+		conf.JSONSchemaAction = sqsSchemaActionDrop
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		bodies, invalid := readBodies(t, r, 2)
+		assert.Equal(t, []string{`{"id":1,"name":"foo"}`, `{"id":5}`}, bodies)
+		assert.Empty(t, invalid)
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.JSONSchema = schema
+		conf.JSONSchemaAction = sqsSchemaActionFlag
+		r, mockInput := startTestSQSReader(t, conf, newMessages())
+
+		bodies, invalid := readBodies(t, r, 5)
+		assert.Len(t, bodies, 5)
+		assert.Equal(t, []string{`{"name":"bar"}`, `{"id":"three"}`, `not json`}, invalid)
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(mockInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "schema.json")
+		require.NoError(t, os.WriteFile(path, []byte(schema), 0o644))
+
+		conf := testSQSReaderConfig()
+		conf.JSONSchema = "file://" + path
+		conf.JSONSchemaAction = sqsSchemaActionFlag
+		r, _ := startTestSQSReader(t, conf, newMessages())
+
+		_, invalid := readBodies(t, r, 5)
+		assert.Len(t, invalid, 3)
+	})
+
+	t.Run("invalid schema", func(t *testing.T) {
+		_, err := newSQSSchemaValidator(`{"type": 5}`, service.MockResources().FS())
+		require.Error(t, err)
+		_, err = newSQSSchemaValidator("file://"+filepath.Join(t.TempDir(), "missing.json"), service.MockResources().FS())
+		require.Error(t, err)
+	})
+}
+
+func TestSQSInputCommitGroups(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i, txn := range []string{"a", "a", "b", ""} {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"txn": {DataType: aws.String("String"), StringValue: aws.String(txn)},
+			},
+		})
+	}
+
+	mgr := service.MockResources(service.MockResourcesOptAddCache("commits"))
+
+	group, err := service.NewInterpolatedString(`${! @txn }`)
+	require.NoError(t, err)
+	conf := testSQSReaderConfig()
+	conf.CommitGroup = group
+	conf.CommitCache = "commits"
+	conf.CommitTimeout = time.Minute
+
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	mockInput := newTestMockSQS(t, messages)
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	for i, exp := range []string{"a", "a", "b", ""} {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("message-%v", i), string(mBytes))
+
+		commitGroup, exists := m.MetaGet("sqs_commit_group")
+		assert.Equal(t, exp != "", exists)
+		assert.Equal(t, exp, commitGroup)
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	// Only the message without a group is deleted before a commit
+	require.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-0", "id-1", "id-2"})
+	}, 5*time.Second, 100*time.Millisecond)
+	time.Sleep(sqsCommitPollInterval + 500*time.Millisecond)
+	require.Equal(t, []string{"id-0", "id-1", "id-2"}, remainingSQSMessageIDs(mockInput))
+
+	commit := func(group string) {
+		require.NoError(t, mgr.AccessCache(tCtx, "commits", func(c service.Cache) {
+			require.NoError(t, c.Set(tCtx, group, nil, nil))
+		}))
+	}
+
+	commit("a")
+	require.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-2"})
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// The commit is removed once the group has been deleted
+	require.NoError(t, mgr.AccessCache(tCtx, "commits", func(c service.Cache) {
+		_, err := c.Get(tCtx, "a")
+		require.ErrorIs(t, err, service.ErrKeyNotFound)
+	}))
+
+	commit("b")
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputCommitGroupTimeout(t *testing.T) {
+	tCtx := t.Context()
+
+	mgr := service.MockResources(service.MockResourcesOptAddCache("commits"))
+
+	group, err := service.NewInterpolatedString(`txn`)
+	require.NoError(t, err)
+	conf := testSQSReaderConfig()
+	conf.CommitGroup = group
+	conf.CommitCache = "commits"
+	conf.CommitTimeout = time.Millisecond
+
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	mockInput := newTestMockSQS(t, []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	})
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	_, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	// The group is not committed in time and so the message is delivered
+	// again rather than being deleted.
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	mBytes, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-1", string(mBytes))
+	require.Equal(t, []string{"id-1"}, remainingSQSMessageIDs(mockInput))
+
+	require.NoError(t, mgr.AccessCache(tCtx, "commits", func(c service.Cache) {
+		require.NoError(t, c.Set(tCtx, "txn", nil, nil))
+	}))
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	_, err = newAWSSQSReader(sqsiConfig{CommitGroup: group, CommitCache: "missing"}, aws.Config{}, mgr)
+	require.Error(t, err)
+}
+
+func TestSQSInputAckCheckpoint(t *testing.T) {
+	tCtx := t.Context()
+
+	newMessages := func() []types.Message {
+		return []types.Message{
+			{
+				Body:          aws.String("message-1"),
+				MessageId:     aws.String("id-1"),
+				ReceiptHandle: aws.String("h-1"),
+			},
+			{
+				Body:          aws.String("message-2"),
+				MessageId:     aws.String("id-2"),
+				ReceiptHandle: aws.String("h-2"),
+			},
+		}
+	}
+
+	mgr := service.MockResources(service.MockResourcesOptAddCache("checkpoints"))
+
+	conf := testSQSReaderConfig()
+	conf.AckCheckpoint = "checkpoints"
+
+	// Simulate a crash after the checkpoint is written by never deleting the
+	// acked message from the queue.
+	crashConf := conf
+	crashConf.DeleteMessage = false
+
+	r := newTestSQSReaderWithResources(t, crashConf, mgr)
+	r.sqs = newTestMockSQS(t, newMessages())
+	require.NoError(t, r.Connect(tCtx))
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	mBytes, err := m.AsBytes()
+	require.NoError(t, err)
+	require.Equal(t, "message-1", string(mBytes))
+	require.NoError(t, aFn(tCtx, nil))
+	r.closeSignal.TriggerHardStop()
+
+	// After a restart the acked message is deleted without being delivered.
+	r = newTestSQSReaderWithResources(t, conf, mgr)
+	mockInput := newTestMockSQS(t, newMessages())
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	m, aFn, err = r.Read(tCtx)
+	require.NoError(t, err)
+	mBytes, err = m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-2", string(mBytes))
+
+	require.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-2"})
+	}, 5*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	err = mgr.AccessCache(tCtx, "checkpoints", func(c service.Cache) {
+		_, err := c.Get(tCtx, "id-2")
+		require.NoError(t, err)
+	})
+	require.NoError(t, err)
+
+	_, err = newAWSSQSReader(sqsiConfig{AckCheckpoint: "missing"}, aws.Config{}, mgr)
+	require.Error(t, err)
+}
+
+func TestSQSInputDeliveryBatchHint(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 3 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.DeliveryBatchHint = true
+	r, _ := startTestSQSReader(t, conf, messages)
+
+	var batchID string
+	for i := range messages {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+
+		id, exists := m.MetaGet("sqs_receive_batch_id")
+		require.True(t, exists)
+		require.NotEmpty(t, id)
+		if i == 0 {
+			batchID = id
+		}
+		assert.Equal(t, batchID, id)
+
+		index, _ := m.MetaGet("sqs_receive_batch_index")
+		assert.Equal(t, strconv.Itoa(i), index)
+		size, _ := m.MetaGet("sqs_receive_batch_size")
+		assert.Equal(t, "3", size)
+
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	// Metadata is not added unless enabled
+	r, _ = startTestSQSReader(t, testSQSReaderConfig(), slices.Clone(messages[:1]))
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	_, exists := m.MetaGet("sqs_receive_batch_id")
+	assert.False(t, exists)
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSInputPreservePollOrder(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 5 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.PreservePollOrder = true
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	type readResult struct {
+		body string
+		aFn  service.AckFunc
+	}
+	read := func() <-chan readResult {
+		resChan := make(chan readResult, 1)
+		go func() {
+			m, aFn, err := r.Read(tCtx)
+			if !assert.NoError(t, err) {
+				return
+			}
+			mBytes, err := m.AsBytes()
+			assert.NoError(t, err)
+			resChan <- readResult{body: string(mBytes), aFn: aFn}
+		}()
+		return resChan
+	}
+
+	first := <-read()
+	assert.Equal(t, "message-0", first.body)
+
+	// The next message is not delivered until the first is acked
+	nextChan := read()
+	select {
+	case res := <-nextChan:
+		t.Fatalf("unexpected delivery of %v", res.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(t, first.aFn(tCtx, nil))
+
+	second := <-nextChan
+	assert.Equal(t, "message-1", second.body)
+
+	// Nacking a message causes the rest of its poll to be redelivered after it
+	require.NoError(t, second.aFn(tCtx, errors.New("nope")))
+
+	var delivered []string
+	for range 4 {
+		res := <-read()
+		delivered = append(delivered, res.body)
+		require.NoError(t, res.aFn(tCtx, nil))
+	}
+	assert.Equal(t, []string{"message-1", "message-2", "message-3", "message-4"}, delivered)
+
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputMaxMessageAge(t *testing.T) {
+	tCtx := t.Context()
+
+	sentAt := func(d time.Duration) map[string]string {
+		return map[string]string{
+			"SentTimestamp": strconv.FormatInt(time.Now().Add(-d).UnixMilli(), 10),
+		}
+	}
+	messages := []types.Message{
+		{
+			Body:          aws.String("stale"),
+			MessageId:     aws.String("id-stale"),
+			ReceiptHandle: aws.String("h-stale"),
+			Attributes:    sentAt(2 * time.Hour),
+		},
+		{
+			Body:          aws.String("fresh"),
+			MessageId:     aws.String("id-fresh"),
+			ReceiptHandle: aws.String("h-fresh"),
+			Attributes:    sentAt(time.Second),
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.MaxMessageAge = time.Hour
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	mBytes, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", string(mBytes))
+
+	require.Eventually(t, func() bool {
+		return slices.Equal(remainingSQSMessageIDs(mockInput), []string{"id-fresh"})
+	}, 5*time.Second, 100*time.Millisecond)
+
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSMessageAge(t *testing.T) {
+	now := time.UnixMilli(1700000060000)
+
+	age, ok := sqsMessageAge(types.Message{
+		Attributes: map[string]string{"SentTimestamp": "1700000000000"},
+	}, now)
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, age)
+
+	_, ok = sqsMessageAge(types.Message{}, now)
+	assert.False(t, ok)
+
+	_, ok = sqsMessageAge(types.Message{
+		Attributes: map[string]string{"SentTimestamp": "nope"},
+	}, now)
+	assert.False(t, ok)
+}
+
+func TestSQSDwellTime(t *testing.T) {
+	sqsMsg := types.Message{
+		MessageId:     aws.String("id-1"),
+		ReceiptHandle: aws.String("h-1"),
+		Attributes: map[string]string{
+			"SentTimestamp":                    "1700000000000",
+			"ApproximateFirstReceiveTimestamp": "1700000030250",
+		},
+	}
+
+	dwell, ok := sqsDwellTime(sqsMsg)
+	require.True(t, ok)
+	assert.Equal(t, 30250*time.Millisecond, dwell)
+
+	msg := service.NewMessage(nil)
+	addSQSMetadata(msg, sqsMsg, false, "", nil)
+	v, exists := msg.MetaGet("sqs_dwell_ms")
+	require.True(t, exists)
+	assert.Equal(t, "30250", v)
+
+	for _, attrs := range []map[string]string{
+		nil,
+		{"SentTimestamp": "1700000000000"},
+		{"SentTimestamp": "1700000000000", "ApproximateFirstReceiveTimestamp": "nope"},
+	} {
+		sqsMsg.Attributes = attrs
+		_, ok = sqsDwellTime(sqsMsg)
+		assert.False(t, ok)
+
+		msg := service.NewMessage(nil)
+		addSQSMetadata(msg, sqsMsg, false, "", nil)
+		_, exists := msg.MetaGet("sqs_dwell_ms")
+		assert.False(t, exists)
+	}
+}
+
+type recordingGauge struct {
+	mu     sync.Mutex
+	values []int64
+}
+
+func (r *recordingGauge) Set(value int64, _ ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = append(r.values, value)
+}
+
+func (r *recordingGauge) last() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.values) == 0 {
+		return 0
+	}
+	return r.values[len(r.values)-1]
+}
+
+func TestSQSMaxReceiveCount(t *testing.T) {
+	now := time.Now()
+	gauge := &recordingGauge{}
+	m := newSQSMaxReceiveCount(gauge, time.Minute)
+
+	m.Observe(2, now)
+	m.Observe(5, now.Add(time.Second))
+	m.Observe(3, now.Add(2*time.Second))
+	assert.Equal(t, []int64{2, 5}, gauge.values)
+
+	// The max is reset once the window elapses
+	m.Observe(1, now.Add(time.Minute))
+	m.Observe(4, now.Add(time.Minute+time.Second))
+	assert.Equal(t, []int64{2, 5, 1, 4}, gauge.values)
+}
+
+func TestSQSInputMaxReceiveCount(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i, count := range []string{"2", "7", "3"} {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+			Attributes: map[string]string{
+				"ApproximateReceiveCount": count,
+			},
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	r := newTestSQSReader(t, conf)
+	gauge := &recordingGauge{}
+	r.maxReceiveCount = newSQSMaxReceiveCount(gauge, time.Hour)
+	r.sqs = newTestMockSQS(t, messages)
+	require.NoError(t, r.Connect(tCtx))
+
+	for range messages {
+		_, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		require.NoError(t, aFn(tCtx, nil))
+	}
+	assert.Equal(t, int64(7), gauge.last())
+}
+
+type attributesSQS struct {
+	*mockSqsInput
+
+	visible, inFlight atomic.Int64
+	requests          atomic.Int32
+}
+
+func (a *attributesSQS) GetQueueAttributes(_ context.Context, input *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	a.requests.Add(1)
+	attrs := map[string]string{}
+	for _, name := range input.AttributeNames {
+		switch name {
+		case types.QueueAttributeNameApproximateNumberOfMessages:
+			attrs[string(name)] = strconv.FormatInt(a.visible.Load(), 10)
+		case types.QueueAttributeNameApproximateNumberOfMessagesNotVisible:
+			attrs[string(name)] = strconv.FormatInt(a.inFlight.Load(), 10)
+		}
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: attrs}, nil
+}
+
+func TestSQSInputBacklogGauges(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.BacklogPollInterval = 10 * time.Millisecond
+	r := newTestSQSReader(t, conf)
+
+	visible, inFlight := &recordingGauge{}, &recordingGauge{}
+	r.backlogVisibleGauge, r.backlogNotVisibleGauge = visible, inFlight
+
+	mockInput := &attributesSQS{mockSqsInput: newTestMockSQS(t, nil)}
+	mockInput.visible.Store(120)
+	mockInput.inFlight.Store(7)
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	assert.Eventually(t, func() bool {
+		return visible.last() == 120 && inFlight.last() == 7
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mockInput.visible.Store(30)
+	mockInput.inFlight.Store(0)
+	assert.Eventually(t, func() bool {
+		return visible.last() == 30 && inFlight.last() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Polling stops once the input is closed.
+	closeCtx, done := context.WithTimeout(tCtx, 5*time.Second)
+	defer done()
+	require.NoError(t, r.Close(closeCtx))
+	requests := mockInput.requests.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, requests, mockInput.requests.Load())
+}
+
+func TestSQSInputBacklogDisabled(t *testing.T) {
+	r := newTestSQSReader(t, testSQSReaderConfig())
+	mockInput := &attributesSQS{mockSqsInput: newTestMockSQS(t, nil)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(t.Context()))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), mockInput.requests.Load())
+}
+
+type blockedReceiveSQS struct {
+	*mockSqsInput
+}
+
+func (*blockedReceiveSQS) ReceiveMessage(ctx context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSQSInputHeartbeat(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.WaitTimeSeconds = 1
+	conf.HeartbeatInterval = 100 * time.Millisecond
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+
+	lastReceive := &recordingGauge{}
+	r.lastReceiveGauge = lastReceive
+	r.sqs = newTestMockSQS(t, nil)
+
+	start := time.Now()
+	require.NoError(t, r.Connect(tCtx))
+
+	// The heartbeat fires every interval while the queue is idle.
+	require.Eventually(t, func() bool {
+		return strings.Count(logs.String(), "Connected, idle,") >= 5
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 5*conf.HeartbeatInterval)
+	assert.Contains(t, logs.String(), "level=INFO")
+	assert.NotContains(t, logs.String(), "No receive requests")
+	assert.InDelta(t, time.Now().Unix(), lastReceive.last(), 1)
+
+	closeCtx, done := context.WithTimeout(tCtx, 5*time.Second)
+	defer done()
+	require.NoError(t, r.Close(closeCtx))
+	heartbeats := strings.Count(logs.String(), "Connected, idle,")
+	time.Sleep(3 * conf.HeartbeatInterval)
+	assert.Equal(t, heartbeats, strings.Count(logs.String(), "Connected, idle,"))
+}
+
+func TestSQSInputHeartbeatStuck(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.HeartbeatInterval = 50 * time.Millisecond
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+
+	lastReceive := &recordingGauge{}
+	r.lastReceiveGauge = lastReceive
+	r.sqs = &blockedReceiveSQS{mockSqsInput: newTestMockSQS(t, nil)}
+	require.NoError(t, r.Connect(tCtx))
+
+	require.Eventually(t, func() bool {
+		return strings.Count(logs.String(), "No receive requests to SQS completed in the last 50ms") >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.NotContains(t, logs.String(), "Connected")
+
+	// The gauge is only set once a receive request has completed.
+	assert.Equal(t, int64(0), lastReceive.last())
+}
+
+// drainRecordingSQS returns at most MaxNumberOfMessages messages from each
+// receive and counts the calls made to SQS.
+type drainRecordingSQS struct {
+	*mockSqsInput
+
+	receives          atomic.Int32
+	deletes           atomic.Int32
+	visibilityChanges atomic.Int32
+	maxMessages       atomic.Int32
+}
+
+func (d *drainRecordingSQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	d.receives.Add(1)
+	d.maxMessages.Store(input.MaxNumberOfMessages)
+	res, err := d.mockSqsInput.ReceiveMessage(ctx, input, opts...)
+	if err == nil && len(res.Messages) > int(input.MaxNumberOfMessages) {
+		// Make the surplus messages visible again.
+		d.do(func() {
+			for _, m := range res.Messages[input.MaxNumberOfMessages:] {
+				delete(d.mesTimeouts, *m.MessageId)
+			}
+		})
+		res.Messages = res.Messages[:input.MaxNumberOfMessages]
+	}
+	return res, err
+}
+
+func (d *drainRecordingSQS) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput, opts ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	d.deletes.Add(1)
+	return d.mockSqsInput.DeleteMessageBatch(ctx, input, opts...)
+}
+
+func (d *drainRecordingSQS) ChangeMessageVisibilityBatch(ctx context.Context, input *sqs.ChangeMessageVisibilityBatchInput, opts ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	d.visibilityChanges.Add(1)
+	return d.mockSqsInput.ChangeMessageVisibilityBatch(ctx, input, opts...)
+}
+
+func TestSQSInputFastDrain(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 1000 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("r-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.FastDrain = true
+	// Messages are not tracked in flight, and so this limit does not apply.
+	conf.MaxOutstanding = 5
+	r := newTestSQSReader(t, conf)
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	readCtx, done := context.WithTimeout(tCtx, 10*time.Second)
+	defer done()
+
+	var bodies []string
+	for range messages {
+		m, aFn, err := r.Read(readCtx)
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		bodies = append(bodies, string(b))
+		// Rejecting a drained message has no effect.
+		require.NoError(t, aFn(readCtx, errors.New("nope")))
+	}
+	assert.Len(t, bodies, len(messages))
+	assert.Equal(t, "message-0", bodies[0])
+	assert.Equal(t, "message-999", bodies[len(bodies)-1])
+
+	// Each poll is deleted with a single request before being delivered.
+	assert.Empty(t, remainingSQSMessageIDs(mockInput.mockSqsInput))
+	assert.Equal(t, int32(10), mockInput.maxMessages.Load())
+	assert.GreaterOrEqual(t, mockInput.deletes.Load(), int32(100))
+	assert.LessOrEqual(t, mockInput.deletes.Load(), mockInput.receives.Load())
+	assert.Equal(t, 0, r.inFlight.Size())
+
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, int32(0), mockInput.visibilityChanges.Load())
+}
+
+func TestSQSInputFastDrainConfig(t *testing.T) {
+	parse := func(t *testing.T, yaml string) (sqsiConfig, error) {
+		t.Helper()
+		pConf, err := sqsInputSpec().ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return sqsiConfigFromParsed(pConf)
+	}
+
+	conf, err := parse(t, `
+url: https://sqs.us-east-1.amazonaws.com/123456789012/orders
+max_number_of_messages: 1
+fast_drain: true
+`)
+	require.NoError(t, err)
+	assert.True(t, conf.FastDrain)
+	assert.Equal(t, 10, conf.MaxNumberOfMessages)
+
+	for _, test := range []struct {
+		name        string
+		yaml        string
+		errContains string
+	}{
+		{
+			name:        "delete disabled",
+			yaml:        `delete_message: false`,
+			errContains: "field delete_message must be enabled when fast_drain is enabled",
+		},
+		{
+			name:        "preserve poll order",
+			yaml:        `preserve_poll_order: true`,
+			errContains: "field preserve_poll_order cannot be set when fast_drain is enabled",
+		},
+		{
+			name:        "quarantine",
+			yaml:        `quarantine_threshold: 3`,
+			errContains: "field quarantine_threshold cannot be set when fast_drain is enabled",
+		},
+		{
+			name: "multiple conflicts",
+			yaml: `
+preserve_poll_order: true
+quarantine_threshold: 3
+`,
+			errContains: "field quarantine_threshold cannot be set when fast_drain is enabled",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parse(t, `
+url: https://sqs.us-east-1.amazonaws.com/123456789012/orders
+fast_drain: true
+`+test.yaml)
+			require.ErrorContains(t, err, test.errContains)
+		})
+	}
+}
+
+func TestSQSInputStartupDelay(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.StartupDelay = 500 * time.Millisecond
+	r := newTestSQSReader(t, conf)
+
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, []types.Message{
+		{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+	})}
+	r.sqs = mockInput
+
+	start := time.Now()
+	require.NoError(t, r.Connect(tCtx))
+
+	time.Sleep(conf.StartupDelay / 2)
+	assert.Equal(t, int32(0), mockInput.receives.Load())
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), conf.StartupDelay)
+	assert.Positive(t, mockInput.receives.Load())
+
+	b, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSInputStartupDelayClose(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.StartupDelay = time.Hour
+	r := newTestSQSReader(t, conf)
+
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, nil)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	// Closing is not held up by the delay.
+	closeCtx, done := context.WithTimeout(tCtx, 5*time.Second)
+	defer done()
+	require.NoError(t, r.Close(closeCtx))
+	assert.Equal(t, int32(0), mockInput.receives.Load())
+}
+
+// attemptRecordingSQS records the ReceiveRequestAttemptId of each receive,
+// failing the first receives with a network error.
+type attemptRecordingSQS struct {
+	*mockSqsInput
+
+	mu       sync.Mutex
+	attempts []string
+	failures int
+}
+
+func (a *attemptRecordingSQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	a.mu.Lock()
+	a.attempts = append(a.attempts, aws.ToString(input.ReceiveRequestAttemptId))
+	fail := a.failures > 0
+	if fail {
+		a.failures--
+	}
+	a.mu.Unlock()
+	if fail {
+		return nil, errors.New("connection reset by peer")
+	}
+	return a.mockSqsInput.ReceiveMessage(ctx, input, opts...)
+}
+
+func (a *attemptRecordingSQS) receiveAttempts() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.attempts)
+}
+
+func TestSQSInputReceiveAttemptID(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.URL = "http://localhost/123456789012/orders.fifo"
+	conf.ReceiveAttemptID = true
+	r := newTestSQSReader(t, conf)
+
+	mockInput := &attemptRecordingSQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		}),
+		failures: 2,
+	}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	_, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	require.Eventually(t, func() bool {
+		return len(mockInput.receiveAttempts()) >= 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The failed receives and the retry that succeeds share an attempt ID,
+	// and the receive after it uses a new one.
+	attempts := mockInput.receiveAttempts()
+	require.NotEmpty(t, attempts[0])
+	assert.Equal(t, attempts[0], attempts[1])
+	assert.Equal(t, attempts[0], attempts[2])
+	assert.NotEqual(t, attempts[2], attempts[3])
+	assert.NotEmpty(t, attempts[3])
+}
+
+func TestSQSInputReceiveAttemptIDDisabled(t *testing.T) {
+	tCtx := t.Context()
+
+	r := newTestSQSReader(t, testSQSReaderConfig())
+	mockInput := &attemptRecordingSQS{mockSqsInput: newTestMockSQS(t, nil)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	require.Eventually(t, func() bool {
+		return len(mockInput.receiveAttempts()) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	for _, id := range mockInput.receiveAttempts() {
+		assert.Empty(t, id)
+	}
+}
+
+func TestSQSReceiveAttempts(t *testing.T) {
+	now := time.Now()
+	attempts := newSQSReceiveAttempts(2)
+
+	first, err := attempts.Next(0, now)
+	require.NoError(t, err)
+	other, err := attempts.Next(1, now)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	// A slot keeps its ID until it is done or expires.
+	retry, err := attempts.Next(0, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, first, retry)
+
+	expired, err := attempts.Next(0, now.Add(sqsiReceiveAttemptTTL))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, expired)
+
+	attempts.Done(0)
+	next, err := attempts.Next(0, now.Add(sqsiReceiveAttemptTTL))
+	require.NoError(t, err)
+	assert.NotEqual(t, expired, next)
+
+	retry, err = attempts.Next(1, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, other, retry)
+}
+
+// queueTagsSQS returns fixed queue tags, or an error when err is set.
+type queueTagsSQS struct {
+	*mockSqsInput
+
+	tags map[string]string
+	err  error
+}
+
+func (q *queueTagsSQS) ListQueueTags(context.Context, *sqs.ListQueueTagsInput, ...func(*sqs.Options)) (*sqs.ListQueueTagsOutput, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	return &sqs.ListQueueTagsOutput{Tags: q.tags}, nil
+}
+
+func TestSQSInputQueueTags(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.LoadQueueTags = true
+	r := newTestSQSReader(t, conf)
+	r.sqs = &queueTagsSQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+			{Body: aws.String("bar"), MessageId: aws.String("bar"), ReceiptHandle: aws.String("bar")},
+		}),
+		tags: map[string]string{"cost-center": "1234", "team": "payments"},
+	}
+	require.NoError(t, r.Connect(tCtx))
+
+	for range 2 {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		require.NoError(t, aFn(tCtx, nil))
+
+		v, ok := m.MetaGetMut("sqs_tag_cost-center")
+		require.True(t, ok)
+		assert.Equal(t, "1234", v)
+		v, ok = m.MetaGetMut("sqs_tag_team")
+		require.True(t, ok)
+		assert.Equal(t, "payments", v)
+	}
+}
+
+func TestSQSInputQueueTagsFailure(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.LoadQueueTags = true
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	r.sqs = &queueTagsSQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		}),
+		err: errors.New("access denied"),
+	}
+	require.NoError(t, r.Connect(tCtx))
+	assert.Contains(t, logs.String(), "Failed to list SQS queue tags: access denied")
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	_ = m.MetaWalkMut(func(k string, _ any) error {
+		assert.NotContains(t, k, "sqs_tag_")
+		return nil
+	})
+}
+
+func TestSQSInputMaxMessages(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 5 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.MaxMessages = 3
+	r := newTestSQSReader(t, conf)
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	var acks []service.AckFunc
+	var bodies []string
+	for range 3 {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		bodies = append(bodies, string(b))
+		acks = append(acks, aFn)
+	}
+	assert.Equal(t, []string{"message-0", "message-1", "message-2"}, bodies)
+
+	readCtx, cancel := context.WithTimeout(tCtx, 5*time.Second)
+	defer cancel()
+	_, _, err := r.Read(readCtx)
+	require.ErrorIs(t, err, service.ErrEndOfInput)
+
+	// Messages read before the end of input are still acknowledged.
+	for _, aFn := range acks {
+		require.NoError(t, aFn(tCtx, nil))
+	}
+	closeCtx, done := context.WithTimeout(tCtx, 5*time.Second)
+	defer done()
+	require.NoError(t, r.Close(closeCtx))
+
+	assert.Equal(t, []string{"id-3", "id-4"}, remainingSQSMessageIDs(mockInput.mockSqsInput))
+	assert.Equal(t, int32(1), mockInput.receives.Load())
+	assert.Equal(t, int32(3), mockInput.maxMessages.Load())
+}
+
+func TestSQSInputMaxRuntime(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.MaxRuntime = 200 * time.Millisecond
+	r, _ := startTestSQSReader(t, conf, []types.Message{
+		{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+	})
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	readCtx, cancel := context.WithTimeout(tCtx, 5*time.Second)
+	defer cancel()
+	started := time.Now()
+	_, _, err = r.Read(readCtx)
+	require.ErrorIs(t, err, service.ErrEndOfInput)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSReceiveLimits(t *testing.T) {
+	assert.Nil(t, newSQSReceiveLimits(0, 0))
+
+	now := time.Now()
+	l := newSQSReceiveLimits(10, time.Minute)
+	l.Start(now)
+	l.Start(now.Add(time.Hour))
+
+	n, ok := l.Remaining(now)
+	require.True(t, ok)
+	assert.Equal(t, 10, n)
+
+	l.Add(4)
+	n, ok = l.Remaining(now)
+	require.True(t, ok)
+	assert.Equal(t, 6, n)
+
+	until, ok := l.Until(now.Add(10 * time.Second))
+	require.True(t, ok)
+	assert.Equal(t, 50*time.Second, until)
+
+	_, ok = l.Remaining(now.Add(time.Minute))
+	assert.False(t, ok)
+
+	l.Add(6)
+	_, ok = l.Remaining(now)
+	assert.False(t, ok)
+
+	l = newSQSReceiveLimits(0, time.Minute)
+	l.Start(now)
+	n, ok = l.Remaining(now)
+	require.True(t, ok)
+	assert.Equal(t, -1, n)
+}
+
+// stallRecordingSQS counts receive calls and records visibility changes.
+type stallRecordingSQS struct {
+	*visibilityRecordingSQS
+
+	receives atomic.Int32
+}
+
+func (s *stallRecordingSQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	s.receives.Add(1)
+	return s.mockSqsInput.ReceiveMessage(ctx, input, opts...)
+}
+
+func TestSQSInputStallTimeout(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.MessageTimeout = 2 * time.Second
+	conf.StallTimeout = 300 * time.Millisecond
+	conf.StallMessageTimeout = time.Minute
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	mockInput := &stallRecordingSQS{visibilityRecordingSQS: &visibilityRecordingSQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		}),
+		changes: map[string][]int32{},
+	}}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	// The output is stalled and never acknowledges the message.
+	_, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "pausing receiving from queue")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Receiving is paused while the message is refreshed with the stall
+	// timeout, which is not due again within the test.
+	receives := mockInput.receives.Load()
+	require.Eventually(t, func() bool {
+		return len(mockInput.changesFor("foo")) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, receives, mockInput.receives.Load())
+	assert.Equal(t, []int32{60}, mockInput.changesFor("foo"))
+
+	// Progress resumes receiving.
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "Resuming receiving from queue")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return mockInput.receives.Load() > receives
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestSQSStallDetector(t *testing.T) {
+	var d *sqsStallDetector
+	assert.False(t, d.Stalled(time.Now()))
+	d.Progress(time.Now())
+
+	now := time.Now()
+	d = newSQSStallDetector(time.Minute, now)
+	assert.False(t, d.Stalled(now.Add(59*time.Second)))
+	assert.True(t, d.Stalled(now.Add(time.Minute)))
+
+	d.Progress(now.Add(time.Minute))
+	assert.False(t, d.Stalled(now.Add(time.Minute)))
+
+	// Progress never moves backwards.
+	d.Progress(now)
+	assert.True(t, d.Stalled(now.Add(2*time.Minute)))
+}
+
+func TestSQSInputDrainMarker(t *testing.T) {
+	tCtx := t.Context()
+
+	newMessage := func(i int) types.Message {
+		return types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		}
+	}
+
+	conf := testSQSReaderConfig()
+	conf.EmitDrainMarker = true
+	conf.DrainMarkerReceives = 3
+	r := newTestSQSReader(t, conf)
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, []types.Message{newMessage(0), newMessage(1)})}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	read := func(t *testing.T) *service.Message {
+		t.Helper()
+		readCtx, cancel := context.WithTimeout(tCtx, 5*time.Second)
+		defer cancel()
+		m, aFn, err := r.Read(readCtx)
+		require.NoError(t, err)
+		require.NoError(t, aFn(tCtx, nil))
+		return m
+	}
+	assertMarker := func(t *testing.T, m *service.Message) {
+		t.Helper()
+		v, _ := m.MetaGet("sqs_drain_complete")
+		assert.Equal(t, "true", v)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Empty(t, b)
+	}
+
+	for i := range 2 {
+		m := read(t)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("message-%v", i), string(b))
+		_, exists := m.MetaGet("sqs_drain_complete")
+		assert.False(t, exists)
+	}
+
+	// The marker follows the empty receives once every message is acked.
+	assertMarker(t, read(t))
+	assert.GreaterOrEqual(t, mockInput.receives.Load(), int32(1+conf.DrainMarkerReceives))
+
+	// Only a single marker is emitted for each drain.
+	readCtx, cancel := context.WithTimeout(tCtx, 300*time.Millisecond)
+	defer cancel()
+	_, _, err := r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Further messages result in another marker once the queue drains again.
+	mockInput.do(func() {
+		mockInput.messages = append(mockInput.messages, newMessage(2))
+	})
+	b, err := read(t).AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-2", string(b))
+	assertMarker(t, read(t))
+}
+
+func TestSQSInputRateLimit(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 5 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	// Grants a single access every interval.
+	const interval = 50 * time.Millisecond
+	var (
+		limitMut sync.Mutex
+		next     time.Time
+		grants   int32
+	)
+	mgr := service.MockResources(service.MockResourcesOptAddRateLimit("sqs_quota", func(context.Context) (time.Duration, error) {
+		limitMut.Lock()
+		defer limitMut.Unlock()
+		now := time.Now()
+		if now.Before(next) {
+			return next.Sub(now), nil
+		}
+		next = now.Add(interval)
+		grants++
+		return 0, nil
+	}))
+
+	conf := testSQSReaderConfig()
+	conf.MaxNumberOfMessages = 1
+	conf.RateLimit = "sqs_quota"
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+	r.sqs = mockInput
+
+	start := time.Now()
+	require.NoError(t, r.Connect(tCtx))
+	for i := range len(messages) {
+		readCtx, cancel := context.WithTimeout(tCtx, 5*time.Second)
+		m, aFn, err := r.Read(readCtx)
+		cancel()
+		require.NoError(t, err)
+		b, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("message-%v", i), string(b))
+		require.NoError(t, aFn(tCtx, nil))
+	}
+
+	// Each message needed its own receive, each of which waited for access.
+	assert.GreaterOrEqual(t, time.Since(start), time.Duration(len(messages)-1)*interval)
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput.mockSqsInput)) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	calls := mockInput.receives.Load() + mockInput.deletes.Load() + mockInput.visibilityChanges.Load()
+	limitMut.Lock()
+	assert.GreaterOrEqual(t, grants, calls)
+	limitMut.Unlock()
+
+	t.Run("missing resource", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.RateLimit = "nope"
+		_, err := newAWSSQSReader(conf, aws.Config{}, service.MockResources())
+		require.ErrorContains(t, err, "unknown rate limit resource: nope")
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		mgr := service.MockResources(service.MockResourcesOptAddRateLimit("sqs_quota", func(context.Context) (time.Duration, error) {
+			return time.Hour, nil
+		}))
+		conf := testSQSReaderConfig()
+		conf.RateLimit = "sqs_quota"
+		r := newTestSQSReaderWithResources(t, conf, mgr)
+		mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+		r.sqs = mockInput
+
+		waitCtx, cancel := context.WithTimeout(tCtx, 50*time.Millisecond)
+		defer cancel()
+		_, err := r.client().ReceiveMessage(waitCtx, &sqs.ReceiveMessageInput{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Zero(t, mockInput.receives.Load())
+	})
+}
+
+func TestSQSInputStrictMaxOutstanding(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 20 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.MaxOutstanding = 5
+	conf.StrictMaxOutstanding = true
+	r := newTestSQSReader(t, conf)
+	mockInput := &drainRecordingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	read := func(t *testing.T) service.AckFunc {
+		t.Helper()
+		readCtx, cancel := context.WithTimeout(tCtx, 5*time.Second)
+		defer cancel()
+		_, aFn, err := r.Read(readCtx)
+		require.NoError(t, err)
+		return aFn
+	}
+
+	// The first poll requests no more messages than the limit.
+	var acks []service.AckFunc
+	for range 5 {
+		acks = append(acks, read(t))
+	}
+	assert.Equal(t, int32(5), mockInput.maxMessages.Load())
+
+	// No further messages are received while the limit is reached.
+	receives := mockInput.receives.Load()
+	readCtx, cancel := context.WithTimeout(tCtx, 300*time.Millisecond)
+	defer cancel()
+	_, _, err := r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, receives, mockInput.receives.Load())
+	assert.Equal(t, 5, r.inFlight.Size())
+
+	// With the limit nearly reached a poll only requests the remaining
+	// capacity.
+	require.NoError(t, acks[0](tCtx, nil))
+	require.NoError(t, acks[1](tCtx, nil))
+	for range 2 {
+		read(t)
+	}
+	assert.Equal(t, int32(2), mockInput.maxMessages.Load())
+	assert.Equal(t, 5, r.inFlight.Size())
+
+	t.Run("warns of a soft limit", func(t *testing.T) {
+		logs := &sqsTestLogBuffer{}
+		mgr := service.MockResources(service.MockResourcesOptUseLogger(
+			service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+		))
+
+		conf := testSQSReaderConfig()
+		conf.MaxOutstanding = 5
+		newTestSQSReaderWithResources(t, conf, mgr)
+		assert.Contains(t, logs.String(), "Enable strict_max_outstanding")
+
+		logs = &sqsTestLogBuffer{}
+		mgr = service.MockResources(service.MockResourcesOptUseLogger(
+			service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+		))
+		conf.StrictMaxOutstanding = true
+		newTestSQSReaderWithResources(t, conf, mgr)
+		assert.NotContains(t, logs.String(), "Enable strict_max_outstanding")
+	})
+}
+
+type recordingTimer struct {
+	mu     sync.Mutex
+	labels []string
+	deltas []int64
+}
+
+func (r *recordingTimer) Timing(delta int64, labelValues ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.labels = append(r.labels, labelValues...)
+	r.deltas = append(r.deltas, delta)
+}
+
+func (r *recordingTimer) recorded() ([]string, []int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.labels), slices.Clone(r.deltas)
+}
+
+func TestSQSInputAckLatency(t *testing.T) {
+	tCtx := t.Context()
+
+	r, _ := startTestSQSReader(t, testSQSReaderConfig(), []types.Message{
+		{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		{Body: aws.String("bar"), MessageId: aws.String("bar"), ReceiptHandle: aws.String("bar")},
+	})
+	latency := &recordingTimer{}
+	r.ackLatencyMetric = latency
+
+	_, ackFoo, err := r.Read(tCtx)
+	require.NoError(t, err)
+	_, nackBar, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, ackFoo(tCtx, nil))
+	require.NoError(t, nackBar(tCtx, errors.New("processing failed")))
+
+	labels, deltas := latency.recorded()
+	assert.Equal(t, []string{"ack", "nack"}, labels)
+	require.Len(t, deltas, 2)
+	for _, d := range deltas {
+		assert.GreaterOrEqual(t, d, (50 * time.Millisecond).Nanoseconds())
+		assert.Less(t, d, (5 * time.Second).Nanoseconds())
+	}
+}
+
+// redrivePolicySQS returns a fixed RedrivePolicy queue attribute.
+type redrivePolicySQS struct {
+	*mockSqsInput
+
+	policy string
+}
+
+func (r *redrivePolicySQS) GetQueueAttributes(_ context.Context, input *sqs.GetQueueAttributesInput, _ ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	attrs := map[string]string{}
+	if slices.Contains(input.AttributeNames, types.QueueAttributeNameRedrivePolicy) && r.policy != "" {
+		attrs[string(types.QueueAttributeNameRedrivePolicy)] = r.policy
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: attrs}, nil
+}
+
+func TestSQSRedrivePolicyParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		expected    sqsRedrivePolicy
+		errContains string
+	}{
+		{
+			name:     "numeric count",
+			policy:   `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:orders-dlq","maxReceiveCount":5}`,
+			expected: sqsRedrivePolicy{DeadLetterTargetARN: "arn:aws:sqs:us-east-1:123456789012:orders-dlq", MaxReceiveCount: 5},
+		},
+		{
+			name:     "string count",
+			policy:   `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:orders-dlq","maxReceiveCount":"10"}`,
+			expected: sqsRedrivePolicy{DeadLetterTargetARN: "arn:aws:sqs:us-east-1:123456789012:orders-dlq", MaxReceiveCount: 10},
+		},
+		{
+			name:        "missing target",
+			policy:      `{"maxReceiveCount":5}`,
+			errContains: "missing deadLetterTargetArn",
+		},
+		{
+			name:        "invalid count",
+			policy:      `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:orders-dlq","maxReceiveCount":"many"}`,
+			errContains: "invalid maxReceiveCount",
+		},
+		{
+			name:        "zero count",
+			policy:      `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:orders-dlq","maxReceiveCount":0}`,
+			errContains: "invalid maxReceiveCount",
+		},
+		{
+			name:        "invalid json",
+			policy:      `{"deadLetterTargetArn":`,
+			errContains: "unexpected end of JSON input",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := parseSQSRedrivePolicy(test.policy)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, p)
+		})
+	}
+}
+
+func TestSQSInputRedrivePolicy(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.FetchRedrivePolicy = true
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	r.sqs = &redrivePolicySQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		}),
+		policy: `{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:orders-dlq","maxReceiveCount":"5"}`,
+	}
+	require.NoError(t, r.Connect(tCtx))
+
+	assert.Contains(t, logs.String(), "moves messages to dead letter queue arn:aws:sqs:us-east-1:123456789012:orders-dlq after 5 receives")
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	v, ok := m.MetaGetMut("sqs_redrive_dead_letter_target_arn")
+	require.True(t, ok)
+	assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:orders-dlq", v)
+	v, ok = m.MetaGetMut("sqs_redrive_max_receive_count")
+	require.True(t, ok)
+	assert.Equal(t, "5", v)
+}
+
+func TestSQSInputNoRedrivePolicy(t *testing.T) {
+	tCtx := t.Context()
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.FetchRedrivePolicy = true
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	r.sqs = &redrivePolicySQS{
+		mockSqsInput: newTestMockSQS(t, []types.Message{
+			{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+		}),
+	}
+	require.NoError(t, r.Connect(tCtx))
+
+	assert.Contains(t, logs.String(), "has no redrive policy")
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	_, ok := m.MetaGetMut("sqs_redrive_dead_letter_target_arn")
+	assert.False(t, ok)
+	_, ok = m.MetaGetMut("sqs_redrive_max_receive_count")
+	assert.False(t, ok)
+}
+
+type userAgentCapturingClient struct {
+	userAgent string
+}
+
+var errRequestCaptured = errors.New("request captured")
+
+func (c *userAgentCapturingClient) Do(req *http.Request) (*http.Response, error) {
+	c.userAgent = req.Header.Get("User-Agent")
+	return nil, errRequestCaptured
+}
+
+func TestSQSInputUserAgentSuffix(t *testing.T) {
+	userAgent := func(t *testing.T, suffix string) string {
+		t.Helper()
+
+		conf := testSQSReaderConfig()
+		conf.UserAgentSuffix = suffix
+		r := newTestSQSReader(t, conf)
+
+		client := &userAgentCapturingClient{}
+		opts := append(r.sqsClientOptions(), func(o *sqs.Options) {
+			o.Region = "us-east-1"
+			o.HTTPClient = client
+			o.RetryMaxAttempts = 1
+		})
+		_, err := sqs.NewFromConfig(r.aconf, opts...).ReceiveMessage(t.Context(), &sqs.ReceiveMessageInput{
+			QueueUrl: aws.String(conf.URL),
+		})
+		require.ErrorIs(t, err, errRequestCaptured)
+		return client.userAgent
+	}
+
+	assert.Contains(t, userAgent(t, "app/order-service"), " app/order-service")
+	assert.Contains(t, userAgent(t, "order service"), " order-service")
+	assert.NotContains(t, userAgent(t, ""), "order-service")
+}
+
+func TestSQSInputMaxInFlightDeliveries(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 20 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	// The mock queue deletes from the slice it is given in place.
+	blockedMessages := slices.Clone(messages[:4])
+
+	conf := testSQSReaderConfig()
+	conf.MaxInFlightDeliveries = 3
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	var inFlight, maxInFlight atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				readCtx, done := context.WithTimeout(tCtx, 500*time.Millisecond)
+				_, aFn, err := r.Read(readCtx)
+				done()
+				if err != nil {
+					return
+				}
+				n := inFlight.Add(1)
+				for {
+					if m := maxInFlight.Load(); n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				inFlight.Add(-1)
+				assert.NoError(t, aFn(tCtx, nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), maxInFlight.Load())
+	assert.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Reads are blocked until a delivered message is acked.
+	r2, _ := startTestSQSReader(t, conf, blockedMessages)
+	var aFns []service.AckFunc
+	for range 3 {
+		_, aFn, err := r2.Read(tCtx)
+		require.NoError(t, err)
+		aFns = append(aFns, aFn)
+	}
+	readCtx, done := context.WithTimeout(tCtx, 100*time.Millisecond)
+	defer done()
+	_, _, err := r2.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, aFns[0](tCtx, nil))
+	_, _, err = r2.Read(tCtx)
+	require.NoError(t, err)
+}
+
+func TestSQSInputPrefetch(t *testing.T) {
+	tCtx := t.Context()
+
+	var messages []types.Message
+	for i := range 10 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.PrefetchCount = 5
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	// The buffer fills without any messages being read.
+	require.Eventually(t, func() bool {
+		return len(r.messagesChan) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-0", string(b))
+	require.NoError(t, aFn(tCtx, nil))
+
+	// Reading a message makes room for the next to be prefetched.
+	require.Eventually(t, func() bool {
+		return len(r.messagesChan) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Messages that were never read have their visibility reset on shutdown.
+	closeCtx, done := context.WithTimeout(tCtx, 5*time.Second)
+	defer done()
+	require.NoError(t, r.Close(closeCtx))
+	mockInput.do(func() {
+		for i := 1; i < 10; i++ {
+			assert.Equal(t, int32(0), mockInput.mesTimeouts[fmt.Sprintf("id-%v", i)], "id-%v", i)
+		}
+	})
+}
+
+type concurrencyTrackingSQS struct {
+	*mockSqsInput
+
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (c *concurrencyTrackingSQS) ChangeMessageVisibilityBatch(ctx context.Context, input *sqs.ChangeMessageVisibilityBatchInput, opts ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		if m := c.maxInFlight.Load(); n <= m || c.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	return c.mockSqsInput.ChangeMessageVisibilityBatch(ctx, input, opts...)
+}
+
+func TestSQSInputRefreshConcurrency(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{}
+	for i := range 200 {
+		messages = append(messages, types.Message{
+			Body:          aws.String(fmt.Sprintf("message-%v", i)),
+			MessageId:     aws.String(fmt.Sprintf("id-%v", i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("h-%v", i)),
+		})
+	}
+
+	conf := testSQSReaderConfig()
+	conf.MaxOutstanding = 1000
+	conf.MessageTimeout = 2 * time.Second
+	conf.RefreshConcurrency = 4
+
+	r := newTestSQSReader(t, conf)
+	mockInput := &concurrencyTrackingSQS{mockSqsInput: newTestMockSQS(t, messages)}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	for range messages {
+		_, _, err := r.Read(tCtx)
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return mockInput.maxInFlight.Load() > 1
+	}, 10*time.Second, 100*time.Millisecond)
+	assert.LessOrEqual(t, mockInput.maxInFlight.Load(), int32(4))
+}
+
+type visibilityRecordingSQS struct {
+	*mockSqsInput
+
+	mtx     sync.Mutex
+	changes map[string][]int32
+}
+
+func (v *visibilityRecordingSQS) ChangeMessageVisibilityBatch(ctx context.Context, input *sqs.ChangeMessageVisibilityBatchInput, opts ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityBatchOutput, error) {
+	v.mtx.Lock()
+	for _, entry := range input.Entries {
+		v.changes[*entry.Id] = append(v.changes[*entry.Id], entry.VisibilityTimeout)
+	}
+	v.mtx.Unlock()
+	return v.mockSqsInput.ChangeMessageVisibilityBatch(ctx, input, opts...)
+}
+
+func (v *visibilityRecordingSQS) changesFor(id string) []int32 {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	return slices.Clone(v.changes[id])
+}
+
+func TestSQSInputMessageTimeoutOverride(t *testing.T) {
+	tCtx := t.Context()
+
+	withHint := func(id, hint string) types.Message {
+		return types.Message{
+			Body:          aws.String(id),
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String(id),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"processing_hint": {DataType: aws.String("String"), StringValue: aws.String(hint)},
+			},
+		}
+	}
+	messages := []types.Message{
+		withHint("quick", "2s"),
+		withHint("slow", "20s"),
+	}
+
+	override, err := service.NewInterpolatedString(`${! @processing_hint }`)
+	require.NoError(t, err)
+
+	conf := testSQSReaderConfig()
+	conf.MessageTimeout = 4 * time.Second
+	conf.MessageTimeoutOverride = override
+
+	r := newTestSQSReader(t, conf)
+	mockInput := &visibilityRecordingSQS{
+		mockSqsInput: newTestMockSQS(t, messages),
+		changes:      map[string][]int32{},
+	}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	for range messages {
+		_, _, err := r.Read(tCtx)
+		require.NoError(t, err)
+	}
+
+	// The quick message is refreshed repeatedly with its own timeout.
+	require.Eventually(t, func() bool {
+		return len(mockInput.changesFor("quick")) >= 3
+	}, 10*time.Second, 100*time.Millisecond)
+
+	for _, v := range mockInput.changesFor("quick") {
+		assert.Equal(t, int32(2), v)
+	}
+	// The slow message is extended to its own timeout once and then left
+	// alone for the remainder of that window.
+	assert.Equal(t, []int32{20}, mockInput.changesFor("slow"))
+}
+
+func TestSQSInputDedupe(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+		{
+			Body:          aws.String("message-2"),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.DedupeTTL = time.Minute
+	conf.DedupeMaxEntries = 100
+	r, mockInput := startTestSQSReader(t, conf, messages)
+
+	var acks []service.AckFunc
+	for _, exp := range []string{"message-1", "message-2"} {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+
+		mBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(mBytes))
+		acks = append(acks, aFn)
+	}
+
+	// Simulate a redelivery of the first message
+	mockInput.do(func() {
+		mockInput.mesTimeouts["id-1"] = 0
+	})
+
+	// The duplicate is not delivered, but is left on the queue as the first
+	// delivery is still in flight.
+	readCtx, done := context.WithTimeout(tCtx, 2*time.Second)
+	defer done()
+	_, _, err := r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"id-1", "id-2"}, remainingSQSMessageIDs(mockInput))
+
+	for _, aFn := range acks {
+		require.NoError(t, aFn(tCtx, nil))
+	}
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputDedupeNackInFlight(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.DedupeTTL = time.Minute
+	conf.DedupeMaxEntries = 100
+	r, mockInput := startTestSQSReader(t, conf, []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	})
+
+	_, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	// Redeliver the message before the first delivery is acked
+	mockInput.do(func() {
+		mockInput.mesTimeouts["id-1"] = 0
+	})
+	readCtx, done := context.WithTimeout(tCtx, 2*time.Second)
+	defer done()
+	_, _, err = r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Nacking the first delivery leaves the message on the queue to be
+	// delivered again.
+	require.NoError(t, aFn(tCtx, errors.New("nope")))
+	assert.Equal(t, []string{"id-1"}, remainingSQSMessageIDs(mockInput))
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	mBytes, err := m.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-1", string(mBytes))
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputDedupeAcked(t *testing.T) {
+	tCtx := t.Context()
+
+	key, err := service.NewInterpolatedString(`${! content() }`)
+	require.NoError(t, err)
+
+	conf := testSQSReaderConfig()
+	conf.DedupeTTL = time.Minute
+	conf.DedupeMaxEntries = 100
+	conf.DedupeKey = key
+	r, mockInput := startTestSQSReader(t, conf, []types.Message{
+		{
+			Body:          aws.String("same"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+		{
+			Body:          aws.String("same"),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		},
+	})
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	id, _ := m.MetaGet("sqs_message_id")
+	require.Equal(t, "id-1", id)
+
+	// The second message is held whilst the first is in flight
+	readCtx, done := context.WithTimeout(tCtx, 2*time.Second)
+	defer done()
+	_, _, err = r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"id-1", "id-2"}, remainingSQSMessageIDs(mockInput))
+
+	// Once the first message is acked the second is deleted as a duplicate
+	// when it is received again.
+	require.NoError(t, aFn(tCtx, nil))
+	mockInput.do(func() {
+		mockInput.mesTimeouts["id-2"] = 0
+	})
+	readCtx, done = context.WithTimeout(tCtx, 2*time.Second)
+	defer done()
+	_, _, err = r.Read(readCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSDedupeCache(t *testing.T) {
+	now := time.Now()
+	c := newSQSDedupeCache(time.Minute, 2)
+
+	assert.Equal(t, sqsDedupeNew, c.Begin("a", now))
+	assert.Equal(t, sqsDedupeInFlight, c.Begin("a", now.Add(time.Second)))
+	assert.Equal(t, 0, c.Len())
+
+	// Forgotten keys are no longer in flight
+	c.Forget("a")
+	assert.Equal(t, sqsDedupeNew, c.Begin("a", now))
+
+	// Committed keys are duplicates until the TTL
+	c.Commit("a", now)
+	assert.Equal(t, sqsDedupeAcked, c.Begin("a", now.Add(30*time.Second)))
+	assert.Equal(t, sqsDedupeNew, c.Begin("a", now.Add(time.Minute)))
+	assert.Equal(t, 0, c.Len())
+
+	// Oldest keys are evicted once the cache is full
+	for _, key := range []string{"b", "c", "d"} {
+		assert.Equal(t, sqsDedupeNew, c.Begin(key, now))
+		c.Commit(key, now)
+	}
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, sqsDedupeNew, c.Begin("b", now))
+	assert.Equal(t, sqsDedupeAcked, c.Begin("d", now))
+}
+
+func TestSQSInputQuarantine(t *testing.T) {
+	messages := []types.Message{
+		{
+			Body:          aws.String("poison"),
+			MessageId:     aws.String("id-poison"),
+			ReceiptHandle: aws.String("h-poison"),
+		},
+	}
+
+	for _, action := range []string{
+		sqsQuarantineActionDelete,
+		sqsQuarantineActionExtendVisibility,
+		sqsQuarantineActionTag,
+	} {
+		t.Run(action, func(t *testing.T) {
+			tCtx := t.Context()
+
+			conf := testSQSReaderConfig()
+			conf.MaxNumberOfMessages = 1
+			conf.QuarantineThreshold = 3
+			conf.QuarantineAction = action
+			conf.QuarantineVisibility = time.Hour
+			conf.QuarantineMaxEntries = 10
+
+			r := newTestSQSReader(t, conf)
+			mockInput := &visibilityRecordingSQS{
+				mockSqsInput: newTestMockSQS(t, slices.Clone(messages)),
+				changes:      map[string][]int32{},
+			}
+			r.sqs = mockInput
+			require.NoError(t, r.Connect(tCtx))
+
+			for i := range conf.QuarantineThreshold {
+				m, aFn, err := r.Read(tCtx)
+				require.NoError(t, err)
+				_, exists := m.MetaGet("sqs_quarantined")
+				assert.False(t, exists, "nack %v", i)
+				require.NoError(t, aFn(tCtx, errors.New("downstream failed")))
+			}
+
+			switch action {
+			case sqsQuarantineActionDelete:
+				require.Eventually(t, func() bool {
+					return len(remainingSQSMessageIDs(mockInput.mockSqsInput)) == 0
+				}, 5*time.Second, 100*time.Millisecond)
+			case sqsQuarantineActionExtendVisibility:
+				require.Eventually(t, func() bool {
+					changes := mockInput.changesFor("id-poison")
+					return len(changes) == 3 && changes[2] == 3600
+				}, 5*time.Second, 100*time.Millisecond)
+				assert.Equal(t, []int32{0, 0, 3600}, mockInput.changesFor("id-poison"))
+				assert.Equal(t, []string{"id-poison"}, remainingSQSMessageIDs(mockInput.mockSqsInput))
+			case sqsQuarantineActionTag:
+				m, aFn, err := r.Read(tCtx)
+				require.NoError(t, err)
+				v, _ := m.MetaGet("sqs_quarantined")
+				assert.Equal(t, "true", v)
+				v, _ = m.MetaGet("sqs_quarantine_reason")
+				assert.Equal(t, "downstream failed", v)
+
+				// Acking the message forgets its history
+				require.NoError(t, aFn(tCtx, nil))
+				assert.Equal(t, 0, r.nacks.Len())
+			}
+		})
+	}
+}
+
+func TestSQSNackTracker(t *testing.T) {
+	tr := newSQSNackTracker(2)
+
+	assert.Equal(t, 1, tr.Nacked("a", "foo"))
+	assert.Equal(t, 2, tr.Nacked("a", "foo"))
+
+	// A different reason restarts the count
+	assert.Equal(t, 1, tr.Nacked("a", "bar"))
+	assert.Equal(t, 2, tr.Nacked("a", "bar"))
+	reason, count := tr.Get("a")
+	assert.Equal(t, "bar", reason)
+	assert.Equal(t, 2, count)
+
+	// The least recently nacked messages are evicted once full
+	assert.Equal(t, 1, tr.Nacked("b", "foo"))
+	assert.Equal(t, 3, tr.Nacked("a", "bar"))
+	assert.Equal(t, 1, tr.Nacked("c", "foo"))
+	assert.Equal(t, 2, tr.Len())
+	_, count = tr.Get("b")
+	assert.Equal(t, 0, count)
+
+	tr.Forget("a")
+	_, count = tr.Get("a")
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 1, tr.Len())
+}
+
+type recordingCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (r *recordingCounter) Incr(count int64, labelValues ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[strings.Join(labelValues, ",")] += count
+}
+
+func (r *recordingCounter) get(labelValues ...string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[strings.Join(labelValues, ",")]
+}
+
+type erroringSQS struct {
+	*mockSqsInput
+
+	mu            sync.Mutex
+	receiveErrors []error
+	deleteErrors  []error
+}
+
+func popError(mu *sync.Mutex, errs *[]error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+func (e *erroringSQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if err := popError(&e.mu, &e.receiveErrors); err != nil {
+		return nil, err
+	}
+	return e.mockSqsInput.ReceiveMessage(ctx, input, opts...)
+}
+
+func (e *erroringSQS) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput, opts ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	if err := popError(&e.mu, &e.deleteErrors); err != nil {
+		return nil, err
+	}
+	return e.mockSqsInput.DeleteMessageBatch(ctx, input, opts...)
+}
+
+func TestSQSErrorCategory(t *testing.T) {
+	tests := []struct {
+		err      error
+		category string
+	}{
+		{&smithy.GenericAPIError{Code: "ThrottlingException"}, sqsiErrCategoryThrottling},
+		{&smithy.GenericAPIError{Code: "AWS.SimpleQueueService.RequestThrottled"}, sqsiErrCategoryThrottling},
+		{&smithy.GenericAPIError{Code: "OverLimit", Fault: smithy.FaultClient}, sqsiErrCategoryThrottling},
+		{&smithy.GenericAPIError{Code: "AccessDenied"}, sqsiErrCategoryAuth},
+		{&smithy.GenericAPIError{Code: "InvalidClientTokenId"}, sqsiErrCategoryAuth},
+		{&smithy.GenericAPIError{Code: "AWS.SimpleQueueService.NonExistentQueue"}, sqsiErrCategoryNotFound},
+		{&smithy.GenericAPIError{Code: "InvalidParameterValue", Fault: smithy.FaultClient}, sqsiErrCategoryClient},
+		{&smithy.GenericAPIError{Code: "InternalError", Fault: smithy.FaultServer}, sqsiErrCategoryServer},
+		{&smithy.GenericAPIError{Code: "Whatever"}, sqsiErrCategoryUnknown},
+		{fmt.Errorf("wrapped: %w", &smithy.GenericAPIError{Code: "ThrottlingException"}), sqsiErrCategoryThrottling},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, sqsiErrCategoryNetwork},
+		{context.DeadlineExceeded, sqsiErrCategoryTimeout},
+		{errors.New("nope"), sqsiErrCategoryUnknown},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.category, sqsErrorCategory(test.err), test.err.Error())
+	}
+}
+
+func TestSQSInputErrorMetrics(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	}
+
+	r := newTestSQSReader(t, testSQSReaderConfig())
+	counter := &recordingCounter{counts: map[string]int64{}}
+	r.errReporter = newSQSErrorReporter(counter, time.Hour)
+
+	mockInput := &erroringSQS{
+		mockSqsInput: newTestMockSQS(t, messages),
+		receiveErrors: []error{
+			&smithy.GenericAPIError{Code: "ThrottlingException"},
+			&smithy.GenericAPIError{Code: "ThrottlingException"},
+			&smithy.GenericAPIError{Code: "AccessDenied"},
+			&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+		},
+		deleteErrors: []error{
+			&smithy.GenericAPIError{Code: "ThrottlingException"},
+		},
+	}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	_, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), counter.get(sqsiOpReceive, sqsiErrCategoryThrottling))
+	assert.Equal(t, int64(1), counter.get(sqsiOpReceive, sqsiErrCategoryAuth))
+	assert.Equal(t, int64(1), counter.get(sqsiOpReceive, sqsiErrCategoryNetwork))
+
+	require.NoError(t, aFn(tCtx, nil))
+	require.Eventually(t, func() bool {
+		return counter.get(sqsiOpDelete, sqsiErrCategoryThrottling) == 1
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputThrottledReceive(t *testing.T) {
+	readAfterError := func(t *testing.T, receiveErr error) (time.Duration, int64) {
+		t.Helper()
+		tCtx := t.Context()
+
+		r := newTestSQSReader(t, testSQSReaderConfig())
+		counter := &recordingCounter{counts: map[string]int64{}}
+		r.throttledMetric = counter
+		r.sqs = &erroringSQS{
+			mockSqsInput: newTestMockSQS(t, []types.Message{
+				{
+					Body:          aws.String("message-1"),
+					MessageId:     aws.String("id-1"),
+					ReceiptHandle: aws.String("h-1"),
+				},
+			}),
+			receiveErrors: []error{receiveErr},
+		}
+
+		start := time.Now()
+		require.NoError(t, r.Connect(tCtx))
+		_, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		elapsed := time.Since(start)
+		require.NoError(t, aFn(tCtx, nil))
+		return elapsed, counter.get()
+	}
+
+	t.Run("throttled", func(t *testing.T) {
+		elapsed, throttled := readAfterError(t, &smithy.GenericAPIError{Code: "OverLimit", Fault: smithy.FaultClient})
+		assert.GreaterOrEqual(t, elapsed, sqsiThrottleInitialBackOff/2)
+		assert.Equal(t, int64(1), throttled)
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		elapsed, throttled := readAfterError(t, &smithy.GenericAPIError{Code: "InternalError", Fault: smithy.FaultServer})
+		// The first back off after other errors is at most 750ms.
+		assert.Less(t, elapsed, sqsiThrottleInitialBackOff)
+		assert.Equal(t, int64(0), throttled)
+	})
+}
+
+// brokenAfterFirstReceiveSQS serves the first receive from the underlying
+// mock and fails every receive after that with a connection error.
+type brokenAfterFirstReceiveSQS struct {
+	*mockSqsInput
+
+	receives atomic.Int32
+}
+
+func (b *brokenAfterFirstReceiveSQS) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	if b.receives.Add(1) > 1 {
+		return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	}
+	return b.mockSqsInput.ReceiveMessage(ctx, input, opts...)
+}
+
+func TestSQSInputClientRebuild(t *testing.T) {
+	tCtx := t.Context()
+
+	conf := testSQSReaderConfig()
+	conf.ClientRebuildThreshold = 3
+	r := newTestSQSReader(t, conf)
+
+	mockInput := newTestMockSQS(t, []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	})
+	broken := &brokenAfterFirstReceiveSQS{mockSqsInput: mockInput}
+
+	var loads, rebuilds atomic.Int32
+	r.loadConfig = func(context.Context) (aws.Config, error) {
+		loads.Add(1)
+		return aws.Config{}, nil
+	}
+	r.newClient = func(aws.Config) sqsAPI {
+		rebuilds.Add(1)
+		return mockInput
+	}
+	r.sqs = broken
+	require.NoError(t, r.Connect(tCtx))
+
+	msg1, aFn1, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err := msg1.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-1", string(b))
+
+	mockInput.do(func() {
+		mockInput.messages = append(mockInput.messages, types.Message{
+			Body:          aws.String("message-2"),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		})
+	})
+
+	// The second message can only be received once the broken client has
+	// been replaced.
+	msg2, aFn2, err := r.Read(tCtx)
+	require.NoError(t, err)
+	b, err = msg2.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "message-2", string(b))
+
+	assert.Equal(t, int32(1), loads.Load())
+	assert.Equal(t, int32(1), rebuilds.Load())
+	assert.Equal(t, int32(1+conf.ClientRebuildThreshold), broken.receives.Load())
+
+	// The message received before the rebuild remains in flight and is
+	// deleted through the new client.
+	require.NoError(t, aFn1(tCtx, nil))
+	require.NoError(t, aFn2(tCtx, nil))
+	assert.Eventually(t, func() bool {
+		return len(remainingSQSMessageIDs(mockInput)) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestSQSInputClientRebuildDisabledByDefault(t *testing.T) {
+	pConf, err := sqsInputSpec().ParseYAML(`url: https://sqs.us-east-1.amazonaws.com/123456789012/orders`, nil)
+	require.NoError(t, err)
+	conf, err := sqsiConfigFromParsed(pConf)
+	require.NoError(t, err)
+	assert.Zero(t, conf.ClientRebuildThreshold)
+}
+
+func TestSQSErrorReporterRateLimit(t *testing.T) {
+	counter := &recordingCounter{counts: map[string]int64{}}
+	r := newSQSErrorReporter(counter, time.Hour)
+
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException"}
+
+	_, shouldLog := r.Report(sqsiOpReceive, throttled)
+	assert.True(t, shouldLog)
+	_, shouldLog = r.Report(sqsiOpReceive, throttled)
+	assert.False(t, shouldLog)
+
+	// Other operations and categories are limited separately
+	_, shouldLog = r.Report(sqsiOpDelete, throttled)
+	assert.True(t, shouldLog)
+	category, shouldLog := r.Report(sqsiOpReceive, errors.New("nope"))
+	assert.True(t, shouldLog)
+	assert.Equal(t, sqsiErrCategoryUnknown, category)
+
+	assert.Equal(t, int64(2), counter.get(sqsiOpReceive, sqsiErrCategoryThrottling))
+}
+
+func TestSQSMetadataCoerceAttributeTypes(t *testing.T) {
+	sqsMsg := types.Message{
+		MessageId:     aws.String("id-1"),
+		ReceiptHandle: aws.String("h-1"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"str":    {DataType: aws.String("String"), StringValue: aws.String("42")},
+			"int":    {DataType: aws.String("Number"), StringValue: aws.String("42")},
+			"float":  {DataType: aws.String("Number.float"), StringValue: aws.String("4.5")},
+			"binary": {DataType: aws.String("Binary"), BinaryValue: []byte("hello")},
+		},
+	}
+
+	msg := service.NewMessage(nil)
+	addSQSMetadata(msg, sqsMsg, false, "", nil)
+
+	for k, exp := range map[string]any{"str": "42", "int": "42", "float": "4.5"} {
+		v, exists := msg.MetaGetMut(k)
+		require.True(t, exists, k)
+		assert.Equal(t, exp, v, k)
+	}
+	_, exists := msg.MetaGetMut("binary")
+	assert.False(t, exists)
+
+	msg = service.NewMessage(nil)
+	addSQSMetadata(msg, sqsMsg, true, "", nil)
+
+	for k, exp := range map[string]any{"str": "42", "int": int64(42), "float": 4.5, "binary": []byte("hello")} {
+		v, exists := msg.MetaGetMut(k)
+		require.True(t, exists, k)
+		assert.Equal(t, exp, v, k)
+	}
+}
+
+func TestSQSInputAttributePrefix(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"sqs_message_id": {DataType: aws.String("String"), StringValue: aws.String("clobbered")},
+				"tenant":         {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			},
+		},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.AttributePrefix = "attr_"
+	r, _ := startTestSQSReader(t, conf, messages)
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+
+	// Attributes no longer collide with the fields added by the input.
+	for k, exp := range map[string]string{
+		"sqs_message_id":      "id-1",
+		"attr_sqs_message_id": "clobbered",
+		"attr_tenant":         "acme",
+	} {
+		v, exists := m.MetaGet(k)
+		require.True(t, exists, k)
+		assert.Equal(t, exp, v, k)
+	}
+	_, exists := m.MetaGet("tenant")
+	assert.False(t, exists)
+
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSInputAttributeFilter(t *testing.T) {
+	sqsMsg := types.Message{
+		MessageId:     aws.String("id-1"),
+		ReceiptHandle: aws.String("h-1"),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"tenant":          {DataType: aws.String("String"), StringValue: aws.String("acme")},
+			"trace_id":        {DataType: aws.String("String"), StringValue: aws.String("abc")},
+			"x-internal-auth": {DataType: aws.String("String"), StringValue: aws.String("secret")},
+			"x-internal-hop":  {DataType: aws.String("String"), StringValue: aws.String("3")},
+		},
+	}
+
+	for _, test := range []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{
+			name:     "all",
+			expected: []string{"tenant", "trace_id", "x-internal-auth", "x-internal-hop"},
+		},
+		{
+			name:     "include",
+			include:  []string{"tenant", "x-internal-*"},
+			expected: []string{"tenant", "x-internal-auth", "x-internal-hop"},
+		},
+		{
+			name:     "exclude",
+			exclude:  []string{"x-internal-*"},
+			expected: []string{"tenant", "trace_id"},
+		},
+		{
+			name:     "exclude takes precedence",
+			include:  []string{"trace_id", "x-internal-*"},
+			exclude:  []string{"x-internal-auth"},
+			expected: []string{"trace_id", "x-internal-hop"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := newSQSAttributeFilter(test.include, test.exclude)
+			require.NoError(t, err)
+
+			msg := service.NewMessage(nil)
+			addSQSMetadata(msg, sqsMsg, false, "attr_", filter)
+
+			var keys []string
+			require.NoError(t, msg.MetaWalkMut(func(k string, _ any) error {
+				if strings.HasPrefix(k, "attr_") {
+					keys = append(keys, strings.TrimPrefix(k, "attr_"))
+				}
+				return nil
+			}))
+			assert.ElementsMatch(t, test.expected, keys)
+		})
+	}
+
+	_, err := newSQSAttributeFilter(nil, []string{"x-["})
+	require.Error(t, err)
+}
+
+func TestSQSInputTracingAttributes(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+			Attributes: map[string]string{
+				"ApproximateReceiveCount": "3",
+			},
+		},
+		{
+			Body:          aws.String("message-2"),
+			MessageId:     aws.String("id-2"),
+			ReceiptHandle: aws.String("h-2"),
+		},
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	conf := testSQSReaderConfig()
+	conf.URL = "https://sqs.us-east-1.amazonaws.com/123456789012/orders"
+	r := newTestSQSReader(t, conf)
+	r.tracer = tp
+	r.sqs = newTestMockSQS(t, messages)
+	require.NoError(t, r.Connect(tCtx))
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	assert.True(t, trace.SpanFromContext(m.Context()).IsRecording())
+
+	spans := recorder.Started()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "input_aws_sqs", spans[0].Name())
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "aws_sqs", attrs["messaging.system"].AsString())
+	assert.Equal(t, "orders", attrs["messaging.destination.name"].AsString())
+	assert.Equal(t, "id-1", attrs["messaging.message.id"].AsString())
+	assert.Equal(t, int64(2), attrs["messaging.batch.message_count"].AsInt64())
+	assert.Equal(t, int64(0), attrs["aws.sqs.in_flight_count"].AsInt64())
+	assert.Equal(t, int64(3), attrs["aws.sqs.approximate_receive_count"].AsInt64())
+
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSInputTracingDisabled(t *testing.T) {
+	tCtx := t.Context()
+
+	r, _ := startTestSQSReader(t, testSQSReaderConfig(), []types.Message{
+		{
+			Body:          aws.String("message-1"),
+			MessageId:     aws.String("id-1"),
+			ReceiptHandle: aws.String("h-1"),
+		},
+	})
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	assert.False(t, trace.SpanFromContext(m.Context()).SpanContext().IsValid())
+	require.NoError(t, aFn(tCtx, nil))
+}
+
+func TestSQSQueueName(t *testing.T) {
+	for in, exp := range map[string]string{
+		"https://sqs.us-east-1.amazonaws.com/123456789012/orders":      "orders",
+		"https://sqs.us-east-1.amazonaws.com/123456789012/orders.fifo": "orders.fifo",
+		"http://localhost:4566/000000000000/queue/":                    "queue",
+		"http://foo.example.com":                                       "",
+	} {
+		assert.Equal(t, exp, sqsQueueName(in), in)
+	}
+}
+
+func TestSQSSequenceTracker(t *testing.T) {
+	tr := newSQSSequenceTracker(2)
+
+	_, ordered := tr.Delivered("a", "9")
+	assert.True(t, ordered)
+	_, ordered = tr.Delivered("a", "10")
+	assert.True(t, ordered, "sequence numbers are compared numerically")
+	_, ordered = tr.Delivered("a", "10")
+	assert.True(t, ordered, "redelivery of the same message")
+
+	previous, ordered := tr.Delivered("a", "9")
+	assert.False(t, ordered)
+	assert.Equal(t, "10", previous)
+
+	_, ordered = tr.Delivered("b", "1")
+	assert.True(t, ordered)
+
+	// Adding a third group evicts the least recently delivered, which is a.
+	_, ordered = tr.Delivered("c", "1")
+	assert.True(t, ordered)
+	_, ordered = tr.Delivered("a", "1")
+	assert.True(t, ordered)
+}
+
+type sqsTestLogBuffer struct {
+	mut sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *sqsTestLogBuffer) Write(p []byte) (int, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *sqsTestLogBuffer) String() string {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return b.buf.String()
+}
+
+func TestSQSInputDetectSequenceGaps(t *testing.T) {
+	tCtx := t.Context()
+
+	fifoMessage := func(id, group, seq string) types.Message {
+		return types.Message{
+			Body:          aws.String(id),
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String(id),
+			Attributes: map[string]string{
+				"MessageGroupId": group,
+				"SequenceNumber": seq,
+			},
+		}
+	}
+
+	logs := &sqsTestLogBuffer{}
+	mgr := service.MockResources(service.MockResourcesOptUseLogger(
+		service.NewLoggerFromSlog(slog.New(slog.NewTextHandler(logs, nil))),
+	))
+
+	conf := testSQSReaderConfig()
+	conf.DetectSequenceGaps = true
+	r := newTestSQSReaderWithResources(t, conf, mgr)
+	r.sqs = newTestMockSQS(t, []types.Message{
+		fifoMessage("id-1", "group-a", "18887000000000000001"),
+		fifoMessage("id-2", "group-b", "18887000000000000002"),
+		fifoMessage("id-3", "group-a", "18887000000000000003"),
+		fifoMessage("id-4", "group-b", "18887000000000000004"),
+		fifoMessage("id-5", "group-a", "18887000000000000000"),
+	})
+	require.NoError(t, r.Connect(tCtx))
+
+	var seqs []string
+	for range 5 {
+		m, aFn, err := r.Read(tCtx)
+		require.NoError(t, err)
+		seq, _ := m.MetaGet("sqs_sequence_number")
+		seqs = append(seqs, seq)
+		require.NoError(t, aFn(tCtx, nil))
+	}
+	assert.Equal(t, []string{
+		"18887000000000000001",
+		"18887000000000000002",
+		"18887000000000000003",
+		"18887000000000000004",
+		"18887000000000000000",
+	}, seqs)
+
+	out := logs.String()
+	assert.Contains(t, out, "level=WARN")
+	assert.Contains(t, out, "Message id-5 of group group-a was delivered out of order: sequence number 18887000000000000000 is lower than the previously delivered 18887000000000000003")
+	assert.NotContains(t, out, "id-4 of group")
+}
+
+// cappedReceiveSQS receives at most MaxNumberOfMessages messages per call, as
+// SQS does.
+type cappedReceiveSQS struct {
+	*mockSqsInput
+}
+
+func (c *cappedReceiveSQS) ReceiveMessage(_ context.Context, input *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var messages []types.Message
+	for _, message := range c.messages {
+		if len(messages) >= int(input.MaxNumberOfMessages) {
+			break
+		}
+		if timeout, found := c.mesTimeouts[*message.MessageId]; !found || timeout == 0 {
+			messages = append(messages, message)
+			c.mesTimeouts[*message.MessageId] = c.queueTimeout
+		}
+	}
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func TestSQSInputReceiveBatchMultiplier(t *testing.T) {
+	tCtx := t.Context()
+
+	newMessages := func(n int) (messages []types.Message) {
+		for i := range n {
+			id := "id-" + strconv.Itoa(i)
+			messages = append(messages, types.Message{
+				Body:          aws.String("message-" + strconv.Itoa(i)),
+				MessageId:     aws.String(id),
+				ReceiptHandle: aws.String(id),
+			})
+		}
+		return
+	}
+
+	startReader := func(t *testing.T, conf sqsiConfig, n int) (*awsSQSReader, *cappedReceiveSQS) {
+		t.Helper()
+		r := newTestSQSReader(t, conf)
+		client := &cappedReceiveSQS{mockSqsInput: newTestMockSQS(t, newMessages(n))}
+		r.sqs = client
+		require.NoError(t, r.Connect(tCtx))
+		return r, client
+	}
+
+	t.Run("coalesced", func(t *testing.T) {
+		conf := testSQSReaderConfig()
+		conf.ReceiveBatchMultiplier = 3
+		r, client := startReader(t, conf, 25)
+
+		batch, aFn, err := r.ReadBatch(tCtx)
+		require.NoError(t, err)
+		// The batch is larger than a single call is able to receive.
+		require.Len(t, batch, 25)
+
+		ids := map[string]struct{}{}
+		for _, m := range batch {
+			id, _ := m.MetaGet("sqs_message_id")
+			ids[id] = struct{}{}
+		}
+		assert.Len(t, ids, 25)
+
+		require.NoError(t, aFn(tCtx, nil))
+		assert.Eventually(t, func() bool {
+			return len(remainingSQSMessageIDs(client.mockSqsInput)) == 0
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("single", func(t *testing.T) {
+		r, client := startReader(t, testSQSReaderConfig(), 25)
+
+		batch, aFn, err := r.ReadBatch(tCtx)
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		require.NoError(t, aFn(tCtx, nil))
+
+		assert.Eventually(t, func() bool {
+			return slices.Equal(remainingSQSMessageIDs(client.mockSqsInput), []string{
+				"id-1", "id-2", "id-3", "id-4", "id-5", "id-6", "id-7", "id-8", "id-9", "id-10",
+				"id-11", "id-12", "id-13", "id-14", "id-15", "id-16", "id-17", "id-18", "id-19",
+				"id-20", "id-21", "id-22", "id-23", "id-24",
+			})
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+}
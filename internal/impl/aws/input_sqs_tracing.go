@@ -0,0 +1,74 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"net/url"
+	"path"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	sqsiTracerName = "rpcn-aws-sqs"
+
+	// The span name matches the one that would otherwise be created for each
+	// message by the input, so that traces look the same with or without the
+	// SQS specific attributes.
+	sqsiSpanName = "input_aws_sqs"
+)
+
+// sqsQueueName extracts the name of a queue from its URL, which is the last
+// segment of the path.
+func sqsQueueName(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return ""
+}
+
+// startSpan creates the tracing span of a message received from SQS and
+// annotates it with attributes describing the queue and message. If tracing
+// is disabled the message is returned unchanged.
+func (a *awsSQSReader) startSpan(msg *service.Message, sqsMsg sqsMessage) *service.Message {
+	ctx, span := a.tracer.Tracer(sqsiTracerName).Start(msg.Context(), sqsiSpanName, trace.WithSpanKind(trace.SpanKindConsumer))
+	if !span.IsRecording() {
+		return msg
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", "aws_sqs"),
+		attribute.String("messaging.operation.type", "receive"),
+		attribute.String("messaging.destination.name", a.queueName),
+		attribute.String("aws.sqs.queue.url", a.conf.URL),
+		attribute.Int("messaging.batch.message_count", sqsMsg.batchSize),
+		attribute.Int("aws.sqs.in_flight_count", sqsMsg.inFlight),
+	}
+	if sqsMsg.MessageId != nil {
+		attrs = append(attrs, attribute.String("messaging.message.id", *sqsMsg.MessageId))
+	}
+	if count, ok := sqsReceiveCount(sqsMsg.Message); ok {
+		attrs = append(attrs, attribute.Int64("aws.sqs.approximate_receive_count", count))
+	}
+	span.SetAttributes(attrs...)
+	return msg.WithContext(ctx)
+}
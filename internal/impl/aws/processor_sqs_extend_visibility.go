@@ -0,0 +1,138 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	sqsevpFieldTimeout = "timeout"
+
+	// The range of visibility timeouts accepted by SQS
+	sqsevpMinTimeout = time.Second
+	sqsevpMaxTimeout = 12 * time.Hour
+)
+
+type sqsVisibilityExtenderKeyType int
+
+var sqsVisibilityExtenderKey sqsVisibilityExtenderKeyType
+
+// sqsVisibilityExtender sets the visibility timeout of the SQS message that a
+// message was created from, returning false if it is no longer in flight.
+type sqsVisibilityExtender func(timeout time.Duration) bool
+
+// withSQSVisibilityExtender returns a message with a context that allows the
+// visibility timeout of the SQS message it was created from to be extended
+// whilst it is tracked as in flight.
+func withSQSVisibilityExtender(msg *service.Message, tracker *sqsInFlightTracker, id string) *service.Message {
+	extend := sqsVisibilityExtender(func(timeout time.Duration) bool {
+		return tracker.Extend(id, timeout)
+	})
+	return msg.WithContext(context.WithValue(msg.Context(), sqsVisibilityExtenderKey, extend))
+}
+
+func sqsExtendVisibilityProcessorSpec() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services", "AWS").
+		Version("4.64.0").
+		Summary("Extends the visibility timeout of messages consumed by an `aws_sqs` input that need longer to process than its `message_timeout`.").
+		Description(`
+The `+"`aws_sqs`"+` input keeps each message that it has delivered hidden from other consumers by refreshing its visibility timeout whenever half of the timeout has elapsed, until the message is acknowledged. This processor changes the visibility timeout used for a single message, which allows rare messages that take far longer to process, such as those with large payloads, to be held for longer without raising `+"`message_timeout`"+` for every message.
+
+When a message passes through this processor the new timeout replaces the timeout of the message within the in-flight tracker of the input, and the message is refreshed with it within a second. From then on the message is refreshed whenever half of the new timeout has elapsed, until it is acknowledged or rejected. Messages that are no longer in flight, or that were not consumed by an `+"`aws_sqs`"+` input, pass through unchanged. Messages are never modified by this processor, and it does not call the SQS API itself.
+
+If `+"`"+sqsevpFieldTimeout+"`"+` resolves to an empty string the message passes through unchanged, and if it cannot be parsed as a duration between 1s and 12h the message is flagged as having failed, allowing you to use xref:configuration:error_handling.adoc[standard processor error handling patterns].`).
+		Fields(
+			service.NewInterpolatedStringField(sqsevpFieldTimeout).
+				Description("The visibility timeout to apply to the message, which is refreshed whenever half of it has elapsed. Valid values: 1s to 12h.").
+				Example("1h").
+				Example(`${! if content().length() > 10000000 { "2h" } else { "" } }`),
+		).
+		Example(
+			"Extend large messages",
+			"Holds messages with large payloads for an hour at a time while they are processed by a slow stage, and all other messages for the default of 30 seconds.",
+			`
+input:
+  aws_sqs:
+    url: https://sqs.us-east-1.amazonaws.com/123456789012/documents
+pipeline:
+  processors:
+    - aws_sqs_extend_visibility:
+        timeout: '${! if content().length() > 10000000 { "1h" } else { "" } }'
+    - aws_lambda:
+        function: extract-document-text
+`,
+		)
+}
+
+func init() {
+	service.MustRegisterProcessor("aws_sqs_extend_visibility", sqsExtendVisibilityProcessorSpec(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			return newSQSExtendVisibilityProcessorFromParsed(conf, mgr)
+		})
+}
+
+//------------------------------------------------------------------------------
+
+type sqsExtendVisibilityProcessor struct {
+	log *service.Logger
+
+	timeout *service.InterpolatedString
+}
+
+func newSQSExtendVisibilityProcessorFromParsed(conf *service.ParsedConfig, mgr *service.Resources) (p *sqsExtendVisibilityProcessor, err error) {
+	p = &sqsExtendVisibilityProcessor{
+		log: mgr.Logger(),
+	}
+	if p.timeout, err = conf.FieldInterpolatedString(sqsevpFieldTimeout); err != nil {
+		return
+	}
+	return
+}
+
+func (p *sqsExtendVisibilityProcessor) Process(_ context.Context, msg *service.Message) (service.MessageBatch, error) {
+	extend, ok := msg.Context().Value(sqsVisibilityExtenderKey).(sqsVisibilityExtender)
+	if !ok {
+		return service.MessageBatch{msg}, nil
+	}
+	timeoutStr, err := p.timeout.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("timeout interpolation error: %w", err)
+	}
+	if timeoutStr == "" {
+		return service.MessageBatch{msg}, nil
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %w", err)
+	}
+	if timeout < sqsevpMinTimeout || timeout > sqsevpMaxTimeout {
+		return nil, fmt.Errorf("timeout %v must be between %v and %v", timeout, sqsevpMinTimeout, sqsevpMaxTimeout)
+	}
+	if !extend(timeout) {
+		p.log.Debugf("Message is no longer in flight, its visibility timeout was not extended")
+	}
+	return service.MessageBatch{msg}, nil
+}
+
+func (*sqsExtendVisibilityProcessor) Close(context.Context) error {
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func newTestSQSExtendVisibilityProcessor(t *testing.T, yaml string) *sqsExtendVisibilityProcessor {
+	t.Helper()
+
+	conf, err := sqsExtendVisibilityProcessorSpec().ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	p, err := newSQSExtendVisibilityProcessorFromParsed(conf, service.MockResources())
+	require.NoError(t, err)
+	return p
+}
+
+func TestSQSExtendVisibilitySingleMessage(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{Body: aws.String("small"), MessageId: aws.String("small"), ReceiptHandle: aws.String("small")},
+		{Body: aws.String("large"), MessageId: aws.String("large"), ReceiptHandle: aws.String("large")},
+	}
+
+	conf := testSQSReaderConfig()
+	conf.MessageTimeout = 6 * time.Second
+
+	r := newTestSQSReader(t, conf)
+	mockInput := &visibilityRecordingSQS{
+		mockSqsInput: newTestMockSQS(t, messages),
+		changes:      map[string][]int32{},
+	}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	p := newTestSQSExtendVisibilityProcessor(t, `
+timeout: '${! if content() == "large" { "1h" } else { "" } }'
+`)
+
+	for range messages {
+		m, _, err := r.Read(tCtx)
+		require.NoError(t, err)
+
+		batch, err := p.Process(tCtx, m)
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		assert.Same(t, m, batch[0])
+	}
+
+	// Only the extended message is refreshed with the new timeout, and it is
+	// refreshed before half of message_timeout has elapsed.
+	require.Eventually(t, func() bool {
+		return slices.Contains(mockInput.changesFor("large"), 3600)
+	}, time.Second*2, 50*time.Millisecond)
+	assert.Empty(t, mockInput.changesFor("small"))
+
+	require.Eventually(t, func() bool {
+		return len(mockInput.changesFor("small")) > 0
+	}, 10*time.Second, 100*time.Millisecond)
+	for _, v := range mockInput.changesFor("small") {
+		assert.Equal(t, int32(6), v)
+	}
+	assert.Equal(t, []int32{3600}, mockInput.changesFor("large"))
+}
+
+func TestSQSExtendVisibilityNotInFlight(t *testing.T) {
+	tCtx := t.Context()
+
+	messages := []types.Message{
+		{Body: aws.String("foo"), MessageId: aws.String("foo"), ReceiptHandle: aws.String("foo")},
+	}
+
+	r := newTestSQSReader(t, testSQSReaderConfig())
+	mockInput := &visibilityRecordingSQS{
+		mockSqsInput: newTestMockSQS(t, messages),
+		changes:      map[string][]int32{},
+	}
+	r.sqs = mockInput
+	require.NoError(t, r.Connect(tCtx))
+
+	m, aFn, err := r.Read(tCtx)
+	require.NoError(t, err)
+	require.NoError(t, aFn(tCtx, nil))
+
+	p := newTestSQSExtendVisibilityProcessor(t, `timeout: 1h`)
+	batch, err := p.Process(tCtx, m)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+
+	time.Sleep(time.Second * 2)
+	assert.Empty(t, mockInput.changesFor("foo"))
+}
+
+func TestSQSExtendVisibilityPassThrough(t *testing.T) {
+	p := newTestSQSExtendVisibilityProcessor(t, `timeout: 1h`)
+
+	msg := service.NewMessage([]byte("foo"))
+	batch, err := p.Process(t.Context(), msg)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Same(t, msg, batch[0])
+}
+
+func TestSQSExtendVisibilityInvalidTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     string
+		errContains string
+	}{
+		{name: "not a duration", timeout: "soon", errContains: "failed to parse timeout"},
+		{name: "too short", timeout: "500ms", errContains: "must be between"},
+		{name: "too long", timeout: "13h", errContains: "must be between"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newTestSQSExtendVisibilityProcessor(t, `timeout: '${! content() }'`)
+
+			var extended bool
+			extend := sqsVisibilityExtender(func(time.Duration) bool {
+				extended = true
+				return true
+			})
+			msg := service.NewMessage([]byte(test.timeout))
+			msg = msg.WithContext(context.WithValue(t.Context(), sqsVisibilityExtenderKey, extend))
+
+			_, err := p.Process(t.Context(), msg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+			assert.False(t, extended)
+		})
+	}
+}
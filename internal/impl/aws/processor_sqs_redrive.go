@@ -0,0 +1,265 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/aws/config"
+)
+
+const (
+	sqsrpFieldSourceARN         = "source_arn"
+	sqsrpFieldDestinationARN    = "destination_arn"
+	sqsrpFieldMaxMessagesPerSec = "max_messages_per_second"
+	sqsrpFieldWaitForCompletion = "wait_for_completion"
+	sqsrpFieldPollInterval      = "poll_interval"
+
+	// Message move task statuses
+	sqsrpStatusRunning    = "RUNNING"
+	sqsrpStatusCancelling = "CANCELLING"
+	sqsrpStatusCancelled  = "CANCELLED"
+	sqsrpStatusFailed     = "FAILED"
+)
+
+func sqsRedriveProcessorSpec() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Categories("Services", "AWS").
+		Version("4.64.0").
+		Summary("Starts a task that moves messages from an SQS dead-letter queue back to a source queue for each message, and optionally waits for it to finish.").
+		Description(`
+This processor uses the SQS `+"`StartMessageMoveTask`"+` API in order to redrive the messages of a dead-letter queue, and the `+"`ListMessageMoveTasks`"+` API in order to track the progress of the task. The contents of each message are replaced with the status of its task:
+
+`+"```json"+`
+{
+  "task_handle": "AQEB...",
+  "status": "COMPLETED",
+  "approximate_number_of_messages_moved": 10,
+  "approximate_number_of_messages_to_move": 10,
+  "failure_reason": ""
+}
+`+"```"+`
+
+Only one move task can be active for a given dead-letter queue at a time. If a task fails or is cancelled whilst waiting for completion then the message is flagged as having failed, allowing you to use xref:configuration:error_handling.adoc[standard processor error handling patterns].
+
+== Credentials
+
+By default Redpanda Connect will use a shared credentials file when connecting to AWS services. It's also possible to set them explicitly at the component level, allowing you to transfer data across accounts. You can find out more in xref:guides:cloud/aws.adoc[].`).
+		Fields(
+			service.NewInterpolatedStringField(sqsrpFieldSourceARN).
+				Description("The ARN of the dead-letter queue to move messages from.").
+				Example("arn:aws:sqs:us-east-1:123456789012:orders-dlq"),
+			service.NewInterpolatedStringField(sqsrpFieldDestinationARN).
+				Description("The ARN of the queue to move messages to. By default messages are moved back to the queues that they were originally sent to.").
+				Optional(),
+			service.NewIntField(sqsrpFieldMaxMessagesPerSec).
+				Description("An optional limit on the number of messages moved per second, which SQS requires to be between 1 and 500. By default SQS optimizes the rate.").
+				LintRule(`root = if this < 1 || this > 500 { [ "field must be between 1 and 500" ] }`).
+				Optional().
+				Advanced(),
+			service.NewBoolField(sqsrpFieldWaitForCompletion).
+				Description("Whether to wait for the move task to complete before the message continues through the pipeline. When disabled the status of the task is returned as soon as it has started.").
+				Default(true),
+			service.NewDurationField(sqsrpFieldPollInterval).
+				Description("The period of time between checks of the progress of a running move task, which must be greater than zero.").
+				Default("5s").
+				Advanced(),
+		).
+		Fields(config.SessionFields()...).
+		Example(
+			"Redrive on demand",
+			"Starts a redrive of a dead-letter queue each time a message is received over HTTP, and responds with the result once it has finished.",
+			`
+input:
+  http_server:
+    path: /redrive
+pipeline:
+  processors:
+    - aws_sqs_redrive:
+        source_arn: arn:aws:sqs:us-east-1:123456789012:orders-dlq
+output:
+  sync_response: {}
+`,
+		)
+}
+
+func init() {
+	service.MustRegisterProcessor("aws_sqs_redrive", sqsRedriveProcessorSpec(),
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+			sess, err := GetSession(context.TODO(), conf)
+			if err != nil {
+				return nil, err
+			}
+			return newSQSRedriveProcessorFromParsed(conf, sqs.NewFromConfig(sess), mgr)
+		})
+}
+
+//------------------------------------------------------------------------------
+
+type sqsRedriveAPI interface {
+	StartMessageMoveTask(context.Context, *sqs.StartMessageMoveTaskInput, ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error)
+	ListMessageMoveTasks(context.Context, *sqs.ListMessageMoveTasksInput, ...func(*sqs.Options)) (*sqs.ListMessageMoveTasksOutput, error)
+}
+
+type sqsRedriveProcessor struct {
+	client sqsRedriveAPI
+	log    *service.Logger
+
+	sourceARN         *service.InterpolatedString
+	destinationARN    *service.InterpolatedString
+	maxMessagesPerSec *int32
+	waitForCompletion bool
+	pollInterval      time.Duration
+}
+
+func newSQSRedriveProcessorFromParsed(conf *service.ParsedConfig, client sqsRedriveAPI, mgr *service.Resources) (p *sqsRedriveProcessor, err error) {
+	p = &sqsRedriveProcessor{
+		client: client,
+		log:    mgr.Logger(),
+	}
+	if p.sourceARN, err = conf.FieldInterpolatedString(sqsrpFieldSourceARN); err != nil {
+		return
+	}
+	if conf.Contains(sqsrpFieldDestinationARN) {
+		if p.destinationARN, err = conf.FieldInterpolatedString(sqsrpFieldDestinationARN); err != nil {
+			return
+		}
+	}
+	if conf.Contains(sqsrpFieldMaxMessagesPerSec) {
+		var rate int
+		if rate, err = conf.FieldInt(sqsrpFieldMaxMessagesPerSec); err != nil {
+			return
+		}
+		if rate < 1 || rate > 500 {
+			err = errors.New("field " + sqsrpFieldMaxMessagesPerSec + " must be between 1 and 500")
+			return
+		}
+		p.maxMessagesPerSec = aws.Int32(int32(rate))
+	}
+	if p.waitForCompletion, err = conf.FieldBool(sqsrpFieldWaitForCompletion); err != nil {
+		return
+	}
+	if p.pollInterval, err = conf.FieldDuration(sqsrpFieldPollInterval); err != nil {
+		return
+	}
+	if p.pollInterval <= 0 {
+		err = errors.New("field " + sqsrpFieldPollInterval + " must be greater than zero")
+		return
+	}
+	return
+}
+
+func (p *sqsRedriveProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	sourceARN, err := p.sourceARN.TryString(msg)
+	if err != nil {
+		return nil, fmt.Errorf("source arn interpolation error: %w", err)
+	}
+	input := &sqs.StartMessageMoveTaskInput{
+		SourceArn:                    aws.String(sourceARN),
+		MaxNumberOfMessagesPerSecond: p.maxMessagesPerSec,
+	}
+	if p.destinationARN != nil {
+		destinationARN, err := p.destinationARN.TryString(msg)
+		if err != nil {
+			return nil, fmt.Errorf("destination arn interpolation error: %w", err)
+		}
+		if destinationARN != "" {
+			input.DestinationArn = aws.String(destinationARN)
+		}
+	}
+
+	started, err := p.client.StartMessageMoveTask(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start message move task for %v: %w", sourceARN, err)
+	}
+	taskHandle := aws.ToString(started.TaskHandle)
+	p.log.Infof("Started message move task for %v", sourceARN)
+
+	task, err := p.waitForTask(ctx, sourceARN, taskHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	out := msg.Copy()
+	out.SetStructuredMut(map[string]any{
+		"task_handle":                            taskHandle,
+		"status":                                 aws.ToString(task.Status),
+		"approximate_number_of_messages_moved":   task.ApproximateNumberOfMessagesMoved,
+		"approximate_number_of_messages_to_move": aws.ToInt64(task.ApproximateNumberOfMessagesToMove),
+		"failure_reason":                         aws.ToString(task.FailureReason),
+	})
+	switch aws.ToString(task.Status) {
+	case sqsrpStatusFailed:
+		out.SetError(fmt.Errorf("message move task for %v failed: %v", sourceARN, aws.ToString(task.FailureReason)))
+	case sqsrpStatusCancelled:
+		out.SetError(fmt.Errorf("message move task for %v was cancelled", sourceARN))
+	}
+	return service.MessageBatch{out}, nil
+}
+
+// waitForTask polls the status of the most recent move task of a source queue
+// until it is no longer running, or returns its current status immediately if
+// we are not waiting for completion.
+func (p *sqsRedriveProcessor) waitForTask(ctx context.Context, sourceARN, taskHandle string) (types.ListMessageMoveTasksResultEntry, error) {
+	var lastMoved int64 = -1
+	for {
+		res, err := p.client.ListMessageMoveTasks(ctx, &sqs.ListMessageMoveTasksInput{
+			SourceArn:  aws.String(sourceARN),
+			MaxResults: aws.Int32(1),
+		})
+		if err != nil {
+			return types.ListMessageMoveTasksResultEntry{}, fmt.Errorf("failed to list message move tasks for %v: %w", sourceARN, err)
+		}
+		if len(res.Results) == 0 {
+			return types.ListMessageMoveTasksResultEntry{}, fmt.Errorf("message move task for %v not found", sourceARN)
+		}
+
+		// The task handle is only returned whilst a task is running, so if it
+		// is present it must match the task we started.
+		task := res.Results[0]
+		if task.TaskHandle != nil && *task.TaskHandle != taskHandle {
+			return task, errors.New("a different message move task is running for " + sourceARN)
+		}
+
+		status := aws.ToString(task.Status)
+		if !p.waitForCompletion || (status != sqsrpStatusRunning && status != sqsrpStatusCancelling) {
+			return task, nil
+		}
+		if task.ApproximateNumberOfMessagesMoved != lastMoved {
+			lastMoved = task.ApproximateNumberOfMessagesMoved
+			p.log.Infof("Message move task for %v has moved %v of %v messages", sourceARN, lastMoved, aws.ToInt64(task.ApproximateNumberOfMessagesToMove))
+		}
+
+		select {
+		case <-time.After(p.pollInterval):
+		case <-ctx.Done():
+			return task, ctx.Err()
+		}
+	}
+}
+
+func (*sqsRedriveProcessor) Close(context.Context) error {
+	return nil
+}
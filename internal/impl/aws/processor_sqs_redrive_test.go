@@ -0,0 +1,213 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+type mockSQSRedrive struct {
+	mu       sync.Mutex
+	starts   []*sqs.StartMessageMoveTaskInput
+	lists    int
+	statuses []types.ListMessageMoveTasksResultEntry
+}
+
+func (m *mockSQSRedrive) StartMessageMoveTask(_ context.Context, input *sqs.StartMessageMoveTaskInput, _ ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.starts = append(m.starts, input)
+	return &sqs.StartMessageMoveTaskOutput{TaskHandle: aws.String("task-1")}, nil
+}
+
+func (m *mockSQSRedrive) ListMessageMoveTasks(_ context.Context, input *sqs.ListMessageMoveTasksInput, _ ...func(*sqs.Options)) (*sqs.ListMessageMoveTasksOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.statuses[min(m.lists, len(m.statuses)-1)]
+	m.lists++
+	entry.SourceArn = input.SourceArn
+	return &sqs.ListMessageMoveTasksOutput{
+		Results: []types.ListMessageMoveTasksResultEntry{entry},
+	}, nil
+}
+
+func moveTaskEntry(status string, moved int64, handle *string) types.ListMessageMoveTasksResultEntry {
+	return types.ListMessageMoveTasksResultEntry{
+		Status:                            aws.String(status),
+		ApproximateNumberOfMessagesMoved:  moved,
+		ApproximateNumberOfMessagesToMove: aws.Int64(10),
+		TaskHandle:                        handle,
+	}
+}
+
+func newTestSQSRedriveProcessor(t *testing.T, yaml string, client sqsRedriveAPI) *sqsRedriveProcessor {
+	t.Helper()
+
+	conf, err := sqsRedriveProcessorSpec().ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	p, err := newSQSRedriveProcessorFromParsed(conf, client, service.MockResources())
+	require.NoError(t, err)
+	return p
+}
+
+func TestSQSRedriveWaitForCompletion(t *testing.T) {
+	client := &mockSQSRedrive{
+		statuses: []types.ListMessageMoveTasksResultEntry{
+			moveTaskEntry("RUNNING", 0, aws.String("task-1")),
+			moveTaskEntry("RUNNING", 5, aws.String("task-1")),
+			moveTaskEntry("COMPLETED", 10, nil),
+		},
+	}
+	p := newTestSQSRedriveProcessor(t, `
+source_arn: arn:aws:sqs:us-east-1:123456789012:${! @queue }
+destination_arn: arn:aws:sqs:us-east-1:123456789012:orders
+max_messages_per_second: 50
+poll_interval: 10ms
+`, client)
+
+	msg := service.NewMessage(nil)
+	msg.MetaSetMut("queue", "orders-dlq")
+	batch, err := p.Process(t.Context(), msg)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	require.NoError(t, batch[0].GetError())
+
+	res, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"task_handle":                            "task-1",
+		"status":                                 "COMPLETED",
+		"approximate_number_of_messages_moved":   int64(10),
+		"approximate_number_of_messages_to_move": int64(10),
+		"failure_reason":                         "",
+	}, res)
+
+	require.Len(t, client.starts, 1)
+	assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:orders-dlq", aws.ToString(client.starts[0].SourceArn))
+	assert.Equal(t, "arn:aws:sqs:us-east-1:123456789012:orders", aws.ToString(client.starts[0].DestinationArn))
+	assert.Equal(t, int32(50), aws.ToInt32(client.starts[0].MaxNumberOfMessagesPerSecond))
+	assert.Equal(t, 3, client.lists)
+}
+
+func TestSQSRedriveNoWait(t *testing.T) {
+	client := &mockSQSRedrive{
+		statuses: []types.ListMessageMoveTasksResultEntry{
+			moveTaskEntry("RUNNING", 0, aws.String("task-1")),
+		},
+	}
+	p := newTestSQSRedriveProcessor(t, `
+source_arn: arn:aws:sqs:us-east-1:123456789012:orders-dlq
+wait_for_completion: false
+`, client)
+
+	batch, err := p.Process(t.Context(), service.NewMessage(nil))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+
+	res, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, "RUNNING", res.(map[string]any)["status"])
+	assert.Nil(t, client.starts[0].DestinationArn)
+	assert.Equal(t, 1, client.lists)
+}
+
+func TestSQSRedriveFailed(t *testing.T) {
+	failed := moveTaskEntry("FAILED", 3, nil)
+	failed.FailureReason = aws.String("AccessDenied")
+	client := &mockSQSRedrive{
+		statuses: []types.ListMessageMoveTasksResultEntry{
+			moveTaskEntry("RUNNING", 0, aws.String("task-1")),
+			failed,
+		},
+	}
+	p := newTestSQSRedriveProcessor(t, `
+source_arn: arn:aws:sqs:us-east-1:123456789012:orders-dlq
+poll_interval: 10ms
+`, client)
+
+	batch, err := p.Process(t.Context(), service.NewMessage(nil))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	require.ErrorContains(t, batch[0].GetError(), "AccessDenied")
+}
+
+func TestSQSRedriveOtherTaskRunning(t *testing.T) {
+	client := &mockSQSRedrive{
+		statuses: []types.ListMessageMoveTasksResultEntry{
+			moveTaskEntry("RUNNING", 0, aws.String("task-2")),
+		},
+	}
+	p := newTestSQSRedriveProcessor(t, `
+source_arn: arn:aws:sqs:us-east-1:123456789012:orders-dlq
+`, client)
+
+	_, err := p.Process(t.Context(), service.NewMessage(nil))
+	require.Error(t, err)
+}
+
+func TestSQSRedriveConfigValidation(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		yaml        string
+		errContains string
+	}{
+		{
+			name:        "rate too low",
+			yaml:        `max_messages_per_second: 0`,
+			errContains: "field max_messages_per_second must be between 1 and 500",
+		},
+		{
+			name:        "rate too high",
+			yaml:        `max_messages_per_second: 501`,
+			errContains: "field max_messages_per_second must be between 1 and 500",
+		},
+		{
+			name:        "rate overflows int32",
+			yaml:        `max_messages_per_second: 4294967346`,
+			errContains: "field max_messages_per_second must be between 1 and 500",
+		},
+		{
+			name:        "zero poll interval",
+			yaml:        `poll_interval: 0s`,
+			errContains: "field poll_interval must be greater than zero",
+		},
+		{
+			name:        "negative poll interval",
+			yaml:        `poll_interval: -1s`,
+			errContains: "field poll_interval must be greater than zero",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := sqsRedriveProcessorSpec().ParseYAML(`
+source_arn: arn:aws:sqs:us-east-1:123456789012:orders-dlq
+`+test.yaml, nil)
+			require.NoError(t, err)
+
+			_, err = newSQSRedriveProcessorFromParsed(conf, &mockSQSRedrive{}, service.MockResources())
+			require.ErrorContains(t, err, test.errContains)
+		})
+	}
+}
@@ -16,6 +16,7 @@ package aws
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -52,6 +53,20 @@ func GetSession(ctx context.Context, parsedConf *service.ParsedConfig, opts ...f
 		)))
 	}
 
+	role, _ := credsConf.FieldString("role")
+	tokenFile, _ := credsConf.FieldString("web_identity_token_file")
+	if tokenFile != "" {
+		if role == "" {
+			return aws.Config{}, errors.New("field credentials.role must be set when credentials.web_identity_token_file is set")
+		}
+		if externalID, _ := credsConf.FieldString("role_external_id"); externalID != "" {
+			return aws.Config{}, errors.New("field credentials.role_external_id cannot be set when credentials.web_identity_token_file is set")
+		}
+		if useEC2, _ := credsConf.FieldBool("from_ec2_role"); useEC2 {
+			return aws.Config{}, errors.New("field credentials.from_ec2_role cannot be set when credentials.web_identity_token_file is set")
+		}
+	}
+
 	conf, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return conf, err
@@ -61,7 +76,13 @@ func GetSession(ctx context.Context, parsedConf *service.ParsedConfig, opts ...f
 		conf.BaseEndpoint = &endpoint
 	}
 
-	if role, _ := credsConf.FieldString("role"); role != "" {
+	if role != "" && tokenFile != "" {
+		// Explicitly exchange the token for the role rather than relying on
+		// the default chain picking up the web identity from the environment.
+		stsSvc := sts.NewFromConfig(conf)
+		creds := stscreds.NewWebIdentityRoleProvider(stsSvc, role, stscreds.IdentityTokenFile(tokenFile))
+		conf.Credentials = aws.NewCredentialsCache(creds)
+	} else if role != "" {
 		stsSvc := sts.NewFromConfig(conf)
 
 		var stsOpts []func(*stscreds.AssumeRoleOptions)
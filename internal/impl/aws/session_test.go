@@ -0,0 +1,128 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/aws/config"
+)
+
+func parseTestSessionConfig(t *testing.T, yaml string) *service.ParsedConfig {
+	t.Helper()
+
+	conf, err := service.NewConfigSpec().Fields(config.SessionFields()...).ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return conf
+}
+
+func TestGetSessionWebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-token"), 0o600))
+
+	var mu sync.Mutex
+	var requests []url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		mu.Lock()
+		requests = append(requests, r.PostForm)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>web-identity-id</AccessKeyId>
+      <SecretAccessKey>web-identity-secret</SecretAccessKey>
+      <SessionToken>web-identity-token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	t.Cleanup(srv.Close)
+
+	sess, err := GetSession(t.Context(), parseTestSessionConfig(t, `
+region: us-east-1
+endpoint: `+srv.URL+`
+credentials:
+  id: static-id
+  secret: static-secret
+  role: arn:aws:iam::123456789012:role/orders-consumer
+  web_identity_token_file: `+tokenFile+`
+`))
+	require.NoError(t, err)
+	assert.True(t, aws.IsCredentialsProvider(sess.Credentials, (*stscreds.WebIdentityRoleProvider)(nil)))
+	assert.False(t, aws.IsCredentialsProvider(sess.Credentials, (*stscreds.AssumeRoleProvider)(nil)))
+
+	creds, err := sess.Credentials.Retrieve(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "web-identity-id", creds.AccessKeyID)
+	assert.Equal(t, "web-identity-token", creds.SessionToken)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "AssumeRoleWithWebIdentity", requests[0].Get("Action"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/orders-consumer", requests[0].Get("RoleArn"))
+	assert.Equal(t, "oidc-token", requests[0].Get("WebIdentityToken"))
+}
+
+func TestGetSessionAssumeRole(t *testing.T) {
+	sess, err := GetSession(t.Context(), parseTestSessionConfig(t, `
+region: us-east-1
+credentials:
+  role: arn:aws:iam::123456789012:role/orders-consumer
+`))
+	require.NoError(t, err)
+	assert.True(t, aws.IsCredentialsProvider(sess.Credentials, (*stscreds.AssumeRoleProvider)(nil)))
+	assert.False(t, aws.IsCredentialsProvider(sess.Credentials, (*stscreds.WebIdentityRoleProvider)(nil)))
+}
+
+func TestGetSessionWebIdentityRequiresRole(t *testing.T) {
+	_, err := GetSession(t.Context(), parseTestSessionConfig(t, `
+region: us-east-1
+credentials:
+  web_identity_token_file: /var/run/secrets/eks.amazonaws.com/serviceaccount/token
+`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "credentials.role must be set")
+}
+
+func TestGetSessionWebIdentityConflicts(t *testing.T) {
+	for _, field := range []string{"role_external_id: foo", "from_ec2_role: true"} {
+		_, err := GetSession(t.Context(), parseTestSessionConfig(t, `
+region: us-east-1
+credentials:
+  role: arn:aws:iam::123456789012:role/example
+  web_identity_token_file: /var/run/secrets/eks.amazonaws.com/serviceaccount/token
+  `+field+`
+`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cannot be set when credentials.web_identity_token_file is set")
+	}
+}
@@ -20,11 +20,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -37,10 +42,15 @@ import (
 )
 
 const (
-	bopFieldServerAddress  = "server_address"
-	bopFieldModel          = "model"
-	bopFieldCacheDirectory = "cache_directory"
-	bopFieldDownloadURL    = "download_url"
+	bopFieldServerAddress   = "server_address"
+	bopFieldModel           = "model"
+	bopFieldCacheDirectory  = "cache_directory"
+	bopFieldDownloadURL     = "download_url"
+	bopFieldAPIPath         = "api_path"
+	bopFieldMaxIdleConns    = "max_idle_conns"
+	bopFieldMaxConnsPerHost = "max_conns_per_host"
+	bopFieldMaxRetries      = "max_retries"
+	bopFieldMaxRetryWait    = "max_retry_wait"
 
 	bopFieldRunner = "runner"
 	// Runner fields
@@ -50,6 +60,17 @@ const (
 	bopFieldThreads     = "threads"
 	bopFieldLowVRAM     = "low_vram"
 	bopFieldUseMMap     = "use_mmap"
+	// Runner fields for the local server
+	bopFieldModelsDirectory = "models_directory"
+	bopFieldNumParallel     = "num_parallel"
+	bopFieldEnv             = "env"
+	bopFieldArgs            = "args"
+
+	// Metrics
+	bopMetricRequestLatency = "ollama_request_latency_ns"
+
+	bopDefaultMaxIdleConns    = 2
+	bopDefaultMaxConnsPerHost = 0
 )
 
 func commonFields() []*service.ConfigField {
@@ -74,11 +95,63 @@ func commonFields() []*service.ConfigField {
 				Optional().
 				Advanced().
 				Description("Map the model into memory. This is only support on unix systems and allows loading only the necessary parts of the model as needed."),
-		).Optional().Description(`Options for the model runner that are used when the model is first loaded into memory.`),
+			service.NewStringField(bopFieldModelsDirectory).
+				Optional().
+				Advanced().
+				Version("4.64.0").
+				Example("/mnt/models/ollama").
+				Description("If `"+bopFieldServerAddress+"` is not set - an existing directory that the local Ollama server loads and stores models in, which is set as `OLLAMA_MODELS` for the server. This allows models to be loaded from a pre-warmed volume rather than being downloaded on each startup. By default the server uses its own default directory."),
+			service.NewIntField(bopFieldNumParallel).
+				Optional().
+				Advanced().
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Description("If `"+bopFieldServerAddress+"` is not set - the maximum number of requests that the local Ollama server processes in parallel for each model, which is set as `OLLAMA_NUM_PARALLEL` for the server. By default the server decides based on the available memory."),
+			service.NewStringMapField(bopFieldEnv).
+				Default(map[string]any{}).
+				Advanced().
+				Version("4.64.0").
+				Example(map[string]any{"OLLAMA_KEEP_ALIVE": "24h"}).
+				Description("If `"+bopFieldServerAddress+"` is not set - environment variables to set for the local Ollama server process, in addition to those of this process. Variables set here take precedence over `"+bopFieldModelsDirectory+"` and `"+bopFieldNumParallel+"`."),
+			service.NewStringListField(bopFieldArgs).
+				Default([]string{}).
+				Advanced().
+				Version("4.64.0").
+				Description("If `"+bopFieldServerAddress+"` is not set - additional arguments to pass to the local Ollama server process after the `serve` command."),
+		).Optional().Description(`Options for the model runner that are used when the model is first loaded into memory. When a local Ollama server is started by this processor it is shared with all other Ollama processors, and so the options for the server process are taken from the first processor to start it.`),
 		service.NewStringField(bopFieldServerAddress).
 			Description("The address of the Ollama server to use. Leave the field blank and the processor starts and runs a local Ollama server or specify the address of your own local or remote server.").
 			Example("http://127.0.0.1:11434").
 			Optional(),
+		service.NewStringField(bopFieldAPIPath).
+			Description("If `" + bopFieldServerAddress + "` is set - a path prefix that the Ollama API is mounted under, which is useful when the server is behind a reverse proxy. Must be a clean absolute path.").
+			Example("/ollama").
+			Version("4.64.0").
+			Advanced().
+			Optional(),
+		service.NewIntField(bopFieldMaxIdleConns).
+			Description("If `" + bopFieldServerAddress + "` is set - the maximum number of idle connections to the server that are kept open for reuse. Increasing this reduces the number of connections and TLS handshakes made when many requests are sent concurrently.").
+			Version("4.64.0").
+			Default(bopDefaultMaxIdleConns).
+			LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+			Advanced(),
+		service.NewIntField(bopFieldMaxConnsPerHost).
+			Description("If `" + bopFieldServerAddress + "` is set - the maximum number of connections to the server, including those in use and idle. Requests that exceed the limit wait for a connection to become available. Set to `0` for no limit.").
+			Version("4.64.0").
+			Default(bopDefaultMaxConnsPerHost).
+			LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+			Advanced(),
+		service.NewIntField(bopFieldMaxRetries).
+			Description("If `" + bopFieldServerAddress + "` is set - the maximum number of times to retry a request that the server rejects with a `429 Too Many Requests` status and a `Retry-After` header, waiting for the period given by the header before each retry. This allows the rate limits of gateways in front of the server to be respected. Rejections without a valid `Retry-After` header are not retried, and once the retries are exhausted the rejection is returned as an error. Set to `0` to disable retries.").
+			Version("4.64.0").
+			Default(0).
+			LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+			Advanced(),
+		service.NewDurationField(bopFieldMaxRetryWait).
+			Description("If `" + bopFieldServerAddress + "` is set - the maximum period to wait before retrying a request rejected with a `429 Too Many Requests` status. Rejections with a `Retry-After` header that exceeds this period are returned as an error rather than retried.").
+			Version("4.64.0").
+			Default("1m").
+			Advanced(),
 		service.NewStringField(bopFieldCacheDirectory).
 			Description("If `" + bopFieldServerAddress + "` is not set - the directory to download the ollama binary and use as a model cache.").
 			Example("/opt/cache/connect/ollama").
@@ -245,6 +318,16 @@ type baseOllamaProcessor struct {
 	ticket singleton.Ticket
 	client *api.Client
 	logger *service.Logger
+
+	pullMu sync.Mutex
+	pulled map[string]struct{}
+
+	requestLatency ollamaTimer
+}
+
+// ollamaTimer is satisfied by *service.MetricTimer.
+type ollamaTimer interface {
+	Timing(delta int64, labelValues ...string)
 }
 
 type key int
@@ -258,15 +341,28 @@ type runOllamaConfig struct {
 	fs          *service.FS
 	cacheDir    string
 	downloadURL string
+	// Additional environment variables and arguments for the server process
+	env  []string
+	args []string
 }
 
-func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *baseOllamaProcessor, err error) {
-	p = &baseOllamaProcessor{}
-	p.logger = mgr.Logger()
-	p.model, err = conf.FieldString(bopFieldModel)
+func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (*baseOllamaProcessor, error) {
+	model, err := conf.FieldString(bopFieldModel)
 	if err != nil {
-		return
+		return nil, err
 	}
+	return newBaseProcessorForModel(conf, mgr, model)
+}
+
+// newBaseProcessorForModel creates a base processor that pulls the given model
+// on startup. If model is empty then models must be pulled on demand with
+// ensureModel.
+func newBaseProcessorForModel(conf *service.ParsedConfig, mgr *service.Resources, model string) (p *baseOllamaProcessor, err error) {
+	p = &baseOllamaProcessor{}
+	p.logger = mgr.Logger()
+	p.model = model
+	p.pulled = map[string]struct{}{}
+	p.requestLatency = mgr.Metrics().NewTimer(bopMetricRequestLatency, "model")
 	p.opts, err = extractOptions(conf)
 	if err != nil {
 		return
@@ -282,7 +378,45 @@ func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *ba
 		if err != nil {
 			return
 		}
-		p.client = api.NewClient(u, http.DefaultClient)
+		if conf.Contains(bopFieldAPIPath) {
+			var apiPath string
+			if apiPath, err = conf.FieldString(bopFieldAPIPath); err != nil {
+				return
+			}
+			if err = validateAPIPath(apiPath); err != nil {
+				return
+			}
+			u = u.JoinPath(apiPath)
+		}
+		var httpClient *http.Client
+		if httpClient, err = injectedHTTPClient(mgr); err != nil {
+			return
+		}
+		if httpClient == nil {
+			if httpClient, err = httpClientFromConfig(conf); err != nil {
+				return
+			}
+		} else {
+			warnPoolFieldsIgnored(conf, p.logger)
+		}
+		var maxRetries int
+		if maxRetries, err = conf.FieldInt(bopFieldMaxRetries); err != nil {
+			return
+		}
+		if maxRetries < 0 {
+			err = fmt.Errorf("field `%s` must not be negative", bopFieldMaxRetries)
+			return
+		}
+		var maxRetryWait time.Duration
+		if maxRetryWait, err = conf.FieldDuration(bopFieldMaxRetryWait); err != nil {
+			return
+		}
+		if maxRetryWait < 0 {
+			err = fmt.Errorf("field `%s` must not be negative", bopFieldMaxRetryWait)
+			return
+		}
+		httpClient = withRateLimitRetries(httpClient, maxRetries, maxRetryWait, p.logger)
+		p.client = api.NewClient(u, httpClient)
 	} else {
 		var cacheDir string
 		if conf.Contains(bopFieldCacheDirectory) {
@@ -304,13 +438,17 @@ func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *ba
 				return
 			}
 		}
-		ctx := context.Background()
-		ctx = context.WithValue(ctx, configKey, runOllamaConfig{
+		runConf := runOllamaConfig{
 			logger:      mgr.Logger(),
 			cacheDir:    cacheDir,
 			fs:          mgr.FS(),
 			downloadURL: downloadURL,
-		})
+		}
+		if runConf.env, runConf.args, err = serverProcessFromConfig(conf, mgr.FS()); err != nil {
+			return
+		}
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, configKey, runConf)
 		if ollamaProcess == nil {
 			err = fmt.Errorf("running a local ollama process is not supported on %s, please specify a `%s`", runtime.GOOS, bopFieldServerAddress)
 			return
@@ -332,14 +470,217 @@ func newBaseProcessor(conf *service.ParsedConfig, mgr *service.Resources) (p *ba
 	if err = p.waitForServer(context.Background()); err != nil {
 		return
 	}
-	p.logger.Infof("Pulling %q", p.model)
-	if err = p.pullModel(context.Background()); err != nil {
-		return
+	if p.model != "" {
+		err = p.ensureModel(context.Background(), p.model)
 	}
-	p.logger.Infof("Finished pulling %q", p.model)
 	return
 }
 
+type httpClientKeyType int
+
+var httpClientKey httpClientKeyType
+
+// setTestHTTPClient is a test hook that sets an HTTP client that Ollama
+// processors created with the given resources use to send requests to a remote
+// server, rather than constructing their own from the connection pool fields.
+// The client must be set before the processors are created, and a local Ollama
+// server started by a processor is always connected to directly.
+func setTestHTTPClient(res *service.Resources, client *http.Client) {
+	res.SetGeneric(httpClientKey, client)
+}
+
+// injectedHTTPClient returns the HTTP client set with setTestHTTPClient, or nil
+// if no client was set.
+func injectedHTTPClient(res *service.Resources) (*http.Client, error) {
+	v, exists := res.GetGeneric(httpClientKey)
+	if !exists {
+		return nil, nil
+	}
+	if client, _ := v.(*http.Client); client != nil {
+		return client, nil
+	}
+	return nil, errors.New("the HTTP client set for Ollama processors is nil")
+}
+
+// warnPoolFieldsIgnored logs a warning when the connection pool fields are set
+// to anything other than their defaults, as they have no effect on an injected
+// HTTP client.
+func warnPoolFieldsIgnored(conf *service.ParsedConfig, logger *service.Logger) {
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{bopFieldMaxIdleConns, bopDefaultMaxIdleConns},
+		{bopFieldMaxConnsPerHost, bopDefaultMaxConnsPerHost},
+	} {
+		if v, err := conf.FieldInt(field.name); err == nil && v != field.value {
+			logger.Warnf("Field `%s` is ignored as an HTTP client has been injected", field.name)
+		}
+	}
+}
+
+// httpClientFromConfig creates a client for a remote Ollama server with the
+// connection pool limits of the config.
+func httpClientFromConfig(conf *service.ParsedConfig) (*http.Client, error) {
+	maxIdleConns, err := conf.FieldInt(bopFieldMaxIdleConns)
+	if err != nil {
+		return nil, err
+	}
+	if maxIdleConns < 1 {
+		return nil, fmt.Errorf("field `%s` must be at least 1", bopFieldMaxIdleConns)
+	}
+	maxConnsPerHost, err := conf.FieldInt(bopFieldMaxConnsPerHost)
+	if err != nil {
+		return nil, err
+	}
+	if maxConnsPerHost < 0 {
+		return nil, fmt.Errorf("field `%s` must not be negative", bopFieldMaxConnsPerHost)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// All requests are sent to the same host, and so the idle limit applies to
+	// that host rather than being split between hosts.
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	transport.MaxConnsPerHost = maxConnsPerHost
+	return &http.Client{Transport: transport}, nil
+}
+
+// withRateLimitRetries returns a copy of client that retries requests rejected
+// with a 429 status and a Retry-After header up to maxRetries times, as long as
+// the header does not ask for a wait longer than maxWait.
+func withRateLimitRetries(client *http.Client, maxRetries int, maxWait time.Duration, logger *service.Logger) *http.Client {
+	if maxRetries == 0 {
+		return client
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := *client
+	c.Transport = &rateLimitTransport{next: next, maxRetries: maxRetries, maxWait: maxWait, logger: logger}
+	return &c
+}
+
+// rateLimitTransport retries requests that are rejected with a 429 status,
+// waiting for the period given by the Retry-After header of the response. The
+// Ollama client does not expose the headers of error responses, and so this is
+// handled beneath it.
+type rateLimitTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	maxWait    time.Duration
+	logger     *service.Logger
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retries := 0; ; retries++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || retries >= t.maxRetries {
+			return resp, err
+		}
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		if wait > t.maxWait {
+			t.logger.Debugf("Request to %v was rate limited for %v, which exceeds the maximum retry wait of %v", req.URL.Path, wait, t.maxWait)
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		t.logger.Debugf("Request to %v was rate limited, retrying in %v", req.URL.Path, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date, into the period to wait from now.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// serverProcessFromConfig returns the additional environment variables and
+// arguments of the runner config for a local Ollama server process.
+func serverProcessFromConfig(conf *service.ParsedConfig, fs *service.FS) (env, args []string, err error) {
+	if !conf.Contains(bopFieldRunner) {
+		return nil, nil, nil
+	}
+	if conf.Contains(bopFieldRunner, bopFieldModelsDirectory) {
+		var dir string
+		if dir, err = conf.FieldString(bopFieldRunner, bopFieldModelsDirectory); err != nil {
+			return nil, nil, err
+		}
+		info, err := fs.Stat(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field `%s.%s`: %w", bopFieldRunner, bopFieldModelsDirectory, err)
+		}
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("field `%s.%s`: %s is not a directory", bopFieldRunner, bopFieldModelsDirectory, dir)
+		}
+		env = append(env, "OLLAMA_MODELS="+dir)
+	}
+	if conf.Contains(bopFieldRunner, bopFieldNumParallel) {
+		var n int
+		if n, err = conf.FieldInt(bopFieldRunner, bopFieldNumParallel); err != nil {
+			return nil, nil, err
+		}
+		if n < 1 {
+			return nil, nil, fmt.Errorf("field `%s.%s` must be at least 1", bopFieldRunner, bopFieldNumParallel)
+		}
+		env = append(env, "OLLAMA_NUM_PARALLEL="+strconv.Itoa(n))
+	}
+	vars, err := conf.FieldStringMap(bopFieldRunner, bopFieldEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Sorted so that the environment of the process is deterministic
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, k+"="+vars[k])
+	}
+	if args, err = conf.FieldStringList(bopFieldRunner, bopFieldArgs); err != nil {
+		return nil, nil, err
+	}
+	return env, args, nil
+}
+
+func validateAPIPath(p string) error {
+	if p == "" {
+		return nil
+	}
+	if !strings.HasPrefix(p, "/") || path.Clean(p) != p || strings.ContainsAny(p, "?#") {
+		return fmt.Errorf("field `%s` must be a clean absolute path, got %q", bopFieldAPIPath, p)
+	}
+	return nil
+}
+
 func (o *baseOllamaProcessor) waitForServer(ctx context.Context) error {
 	timeout := time.After(5 * time.Second)
 	tick := time.NewTicker(500 * time.Millisecond)
@@ -356,15 +697,42 @@ func (o *baseOllamaProcessor) waitForServer(ctx context.Context) error {
 }
 
 func (o *baseOllamaProcessor) pullModel(ctx context.Context) error {
+	return o.pullNamedModel(ctx, o.model)
+}
+
+func (o *baseOllamaProcessor) pullNamedModel(ctx context.Context, model string) error {
 	pr := api.PullRequest{
-		Model: o.model,
+		Model: model,
 	}
 	return o.client.Pull(ctx, &pr, func(resp api.ProgressResponse) error {
-		o.logger.Tracef("Pulling %q: %s [%s/%s]", o.model, resp.Status, humanize.Bytes(uint64(resp.Completed)), humanize.Bytes(uint64(resp.Total)))
+		o.logger.Tracef("Pulling %q: %s [%s/%s]", model, resp.Status, humanize.Bytes(uint64(resp.Completed)), humanize.Bytes(uint64(resp.Total)))
 		return nil
 	})
 }
 
+// ensureModel pulls a model unless it has already been pulled by this
+// processor.
+func (o *baseOllamaProcessor) ensureModel(ctx context.Context, model string) error {
+	o.pullMu.Lock()
+	defer o.pullMu.Unlock()
+	if _, ok := o.pulled[model]; ok {
+		return nil
+	}
+	o.logger.Infof("Pulling %q", model)
+	if err := o.pullNamedModel(ctx, model); err != nil {
+		return err
+	}
+	o.logger.Infof("Finished pulling %q", model)
+	o.pulled[model] = struct{}{}
+	return nil
+}
+
+// observeRequest records the latency of a request to the Ollama server that
+// was started at the given time.
+func (o *baseOllamaProcessor) observeRequest(model string, started time.Time) {
+	o.requestLatency.Timing(time.Since(started).Nanoseconds(), model)
+}
+
 func (o *baseOllamaProcessor) Close(ctx context.Context) error {
 	if ollamaProcess == nil {
 		return nil
@@ -16,25 +16,91 @@ package ollama
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 	"unicode/utf8"
 
+	"github.com/Jeffail/gabs/v2"
 	"github.com/ollama/ollama/api"
 
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/license"
 )
 
 const (
-	oepFieldText = "text"
+	oepFieldText                = "text"
+	oepFieldMaxTokensPerRequest = "max_tokens_per_request"
+	oepFieldCombine             = "combine"
+	oepFieldFallbackModels      = "fallback_models"
+	oepFieldMock                = "mock"
+	oepFieldDimensions          = "dimensions"
+	oepFieldTexts               = "texts"
+	oepFieldWindowSize          = "window_size"
+	oepFieldWindowOutput        = "window_output"
+	oepFieldErrorHandling       = "error_handling"
+	oepFieldMapping             = "mapping"
+	oepFieldOutputEncoding      = "output_encoding"
+	oepFieldValidateOutput      = "validate_output"
+	oepFieldEmbeddingType       = "embedding_type"
+	oepFieldOutputPath          = "output_path"
+	oepFieldWarmup              = "warmup"
+	oepFieldWarmupTimeout       = "warmup_timeout"
+	oepFieldChunkSize           = "chunk_size"
+	oepFieldChunkOverlap        = "chunk_overlap"
+	oepFieldChunkUnit           = "chunk_unit"
+	oepFieldChunkOutput         = "chunk_output"
+	oepFieldDedupThreshold      = "dedup_threshold"
+	oepFieldTruncateSide        = "truncate_side"
+	oepFieldTruncateTokens      = "truncate_tokens"
+
+	// A rough estimate of the number of bytes of text per token, used to split
+	// text without needing a tokenizer for the model.
+	oepBytesPerTokenEstimate = 4
+	// The same estimate in characters, used to size chunks of text as chunks
+	// are cut between characters rather than bytes.
+	oepCharsPerTokenEstimate = 4
 )
 
+var errInvalidEmbedding = errors.New("invalid embedding")
+
+// oepEmbeddingPrefixes are the prefixes that asymmetric retrieval models
+// expect for the text of queries and documents, by model family. Families
+// that share a prefix with another family must be listed first.
+var oepEmbeddingPrefixes = []struct {
+	family, query, document string
+}{
+	{family: "nomic-embed-text", query: "search_query: ", document: "search_document: "},
+	{family: "mxbai-embed-large", query: "Represent this sentence for searching relevant passages: "},
+	{family: "snowflake-arctic-embed2", query: "query: "},
+	{family: "snowflake-arctic-embed", query: "Represent this sentence for searching relevant passages: "},
+	{family: "granite-embedding"},
+	{family: "bge-m3"},
+}
+
 func init() {
-	service.MustRegisterProcessor(
+	service.MustRegisterBatchProcessor(
 		"ollama_embeddings",
 		ollamaEmbeddingProcessorConfig(),
-		makeOllamaEmbeddingProcessor,
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+			p, err := makeOllamaEmbeddingProcessor(conf, mgr)
+			if err != nil {
+				return nil, err
+			}
+			return p, nil
+		},
 	)
 }
 
@@ -46,15 +112,243 @@ func ollamaEmbeddingProcessorConfig() *service.ConfigSpec {
 
 By default, the processor starts and runs a locally installed Ollama server. Alternatively, to use an already running Ollama server, add your server details to the `+"`"+bopFieldServerAddress+"`"+` field. You can https://ollama.com/download[download and install Ollama from the Ollama website^].
 
-For more information, see the https://github.com/ollama/ollama/tree/main/docs[Ollama documentation^].`).
+For more information, see the https://github.com/ollama/ollama/tree/main/docs[Ollama documentation^].
+
+== Embedding arrays of text
+
+When `+"`"+oepFieldTexts+"`"+` is set each message can carry an array of texts, such as the chunks of a large document, which are embedded in windows of `+"`"+oepFieldWindowSize+"`"+` texts at a time. Only the embeddings of a single window are held in memory while the texts are embedded, and the shape of the output depends on `+"`"+oepFieldWindowOutput+"`"+`:
+
+- `+"`array`"+`: The embeddings of each window are written to the payload as soon as the window completes, and a single message is emitted with a JSON array of embeddings in the same order as the texts.
+- `+"`split`"+`: A message is emitted for each window with a JSON array of the embeddings of that window, and the position of the first text of the window within the array is added to the `+"`ollama_window_offset`"+` metadata key. An empty array of texts results in no messages.
+
+== Chunking long text
+
+When `+"`"+oepFieldChunkSize+"`"+` is set the text of each message is split into chunks with a sliding window before it is embedded, which is the usual preprocessing of long documents for retrieval augmented generation. Each chunk is `+"`"+oepFieldChunkSize+"`"+` long apart from the last, which holds the remainder of the text, and begins `+"`"+oepFieldChunkSize+"`"+` minus `+"`"+oepFieldChunkOverlap+"`"+` after the start of the previous chunk, so that consecutive chunks share `+"`"+oepFieldChunkOverlap+"`"+` of text and context that spans a boundary is not lost. Chunks are cut at exact positions rather than on whitespace. The sizes are measured according to `+"`"+oepFieldChunkUnit+"`"+`, where tokens are estimated as four characters each, and the shape of the output depends on `+"`"+oepFieldChunkOutput+"`"+`:
+
+- `+"`split`"+`: A message is emitted for each chunk with its embedding, the position of the chunk within the text starting at zero is added to the `+"`ollama_chunk_index`"+` metadata key and the text of the chunk is added to the `+"`ollama_chunk_text`"+` metadata key. Empty text results in no messages.
+- `+"`array`"+`: A single message is emitted with a JSON array of the embeddings of the chunks in order.
+
+For example, text of 1000 characters with a `+"`"+oepFieldChunkSize+"`"+` of 400 and a `+"`"+oepFieldChunkOverlap+"`"+` of 100 results in the chunks of characters 0 to 399, 300 to 699 and 600 to 999.
+
+== Deduplicating near-duplicates
+
+When `+"`"+oepFieldDedupThreshold+"`"+` is set the messages of each batch are embedded and then compared with each other, and a message is dropped when the cosine similarity of its embedding to that of a message kept earlier in the batch is at least the threshold. This keeps the first message of each cluster of near-duplicates, such as overlapping chunks of repetitive documents, and reduces the number of vectors written to an index. The embeddings are compared as they are returned by the model, before `+"`"+oepFieldMapping+"`"+` or `+"`"+oepFieldOutputEncoding+"`"+` are applied, and messages that failed to be embedded or were flagged with an error are always kept.
+
+Each embedding is compared with every embedding kept before it, so the time taken grows with the square of the size of a batch, and messages are only compared within the same batch. The size of batches is controlled by the xref:configuration:batching.adoc[batching policy] of the input.
+
+== Embedding types
+
+Many retrieval models are trained to embed search queries differently from the documents that they search, and expect the text of each to be given a specific prefix. When `+"`"+oepFieldEmbeddingType+"`"+` is `+"`query`"+` or `+"`document`"+` the prefix that the model family expects is added to the text of each request, including each chunk of text split by `+"`"+oepFieldMaxTokensPerRequest+"`"+`. The following model families are supported:
+
+|===
+| Model family | `+"`query`"+` prefix | `+"`document`"+` prefix
+
+| `+"`nomic-embed-text`"+` | `+"`search_query: `"+` | `+"`search_document: `"+`
+| `+"`mxbai-embed-large`"+` | `+"`Represent this sentence for searching relevant passages: `"+` | None
+| `+"`snowflake-arctic-embed`"+` | `+"`Represent this sentence for searching relevant passages: `"+` | None
+| `+"`snowflake-arctic-embed2`"+` | `+"`query: `"+` | None
+| `+"`granite-embedding`"+` | None | None
+| `+"`bge-m3`"+` | None | None
+|===
+
+The family of a model is matched against its name without a namespace or tag, so `+"`nomic-embed-text:v1.5`"+` belongs to the `+"`nomic-embed-text`"+` family. The text of models from other families is embedded unchanged, and a warning is logged when such a model is configured statically or as a fallback model. In that case any prefix the model requires can be added with `+"`"+oepFieldText+"`"+` instead. Embeddings of queries and documents are only comparable when they are generated by the same model.
+
+== Enriching structured payloads
+
+By default the embedding replaces the payload of the message. When `+"`"+oepFieldOutputPath+"`"+` is set the payload must instead be a JSON object, and the embedding is set at the dot separated path within it while the rest of the payload, such as the results of earlier enrichments, is preserved. Objects along the path are created when they do not exist and any existing value at the path is replaced. When `+"`"+oepFieldTexts+"`"+` is set the array of embeddings is set at the path instead. Messages whose payload is not a JSON object are handled according to `+"`"+oepFieldErrorHandling+"`"+`.
+
+As the payload is structured, `+"`"+oepFieldText+"`"+` or `+"`"+oepFieldTexts+"`"+` should usually be set to select the text to embed from it.
+
+== Output encoding
+
+By default each embedding is written as a JSON array of numbers. When `+"`"+oepFieldOutputEncoding+"`"+` is `+"`base64_float32`"+` or `+"`base64_float64`"+` each embedding is instead packed into a compact binary form, which is much smaller for vectors with many dimensions. Each element of the embedding is converted to an IEEE 754 single precision (`+"`base64_float32`"+`) or double precision (`+"`base64_float64`"+`) floating point number, and the elements are concatenated in order as little-endian bytes without any header or padding, so that an embedding of N dimensions is packed into 4N or 8N bytes respectively. The bytes are then encoded with standard base64 encoding including padding, as defined by RFC 4648, and the resulting string replaces the payload of the message. When `+"`"+oepFieldTexts+"`"+` is set the payload is instead a JSON array containing a base64 string for each embedding.
+
+For example, in Python an embedding encoded with `+"`base64_float32`"+` can be decoded with `+"`numpy.frombuffer(base64.b64decode(payload), dtype='<f4')`"+`.
+
+== Error handling
+
+By default a message fails to be processed when its input cannot be resolved, such as when the payload contains invalid UTF-8 or `+"`"+oepFieldTexts+"`"+` does not return an array of strings. When `+"`"+oepFieldErrorHandling+"`"+` is `+"`flag`"+` such messages are instead passed through unchanged and without an embedding, with the error added to the `+"`ollama_error`"+` metadata key, so that they can be routed elsewhere with a xref:components:outputs/switch.adoc[`+"`switch`"+` output] while the rest of the batch is embedded. Errors returned by the Ollama server always fail the message, as they are usually transient and can be retried.
+
+When `+"`"+oepFieldValidateOutput+"`"+` is enabled each embedding returned by the model is checked before it is written to the message, and an embedding that only contains zeros or contains NaN or infinite values is treated as an error. Such embeddings usually indicate that the model failed to load correctly, for example when a GPU runs out of memory, and would otherwise be written to a vector store where they match poorly with everything. Messages with invalid embeddings fail to be processed, or are flagged when `+"`"+oepFieldErrorHandling+"`"+` is `+"`flag`"+`.
+
+== Metrics
+
+The latency of each request to the Ollama server is recorded by the `+"`"+bopMetricRequestLatency+"`"+` timer metric, which is labelled by the `+"`model`"+` of the request.`).
 		Version("4.32.0").
 		Fields(
-			service.NewStringField(bopFieldModel).
-				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website].").
-				Examples("nomic-embed-text", "mxbai-embed-large", "snowflake-artic-embed", "all-minilm"),
+			service.NewInterpolatedStringField(bopFieldModel).
+				Description("The name of the Ollama LLM to use. For a full list of models, see the https://ollama.com/models[Ollama website]. When the name is resolved dynamically from each message the model is pulled the first time it is used.").
+				Examples("nomic-embed-text", "mxbai-embed-large", "snowflake-artic-embed", "all-minilm", "${! @embedding_model }"),
 			service.NewInterpolatedStringField(oepFieldText).
 				Description("The text you want to create vector embeddings for. By default, the processor submits the entire payload as a string.").
 				Optional(),
+			service.NewBloblangField(oepFieldTexts).
+				Description("An optional xref:guides:bloblang/about.adoc[Bloblang query] that returns an array of texts to create vector embeddings for, which cannot be used together with `"+oepFieldText+"`. Refer to the <<embedding-arrays-of-text, embedding arrays of text section>> for the shape of the output.").
+				Version("4.64.0").
+				Example(`root = this.chunks`).
+				Optional().
+				Advanced(),
+			service.NewIntField(oepFieldWindowSize).
+				Description("The number of texts from `"+oepFieldTexts+"` that are embedded before being written to the output.").
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Default(32).
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldWindowOutput, map[string]string{
+				"array": "Emit a single message with the embeddings of all texts.",
+				"split": "Emit a message with the embeddings of each window of texts.",
+			}).
+				Description("How the embeddings of `"+oepFieldTexts+"` are emitted.").
+				Version("4.64.0").
+				Default("array").
+				Advanced(),
+			service.NewIntField(oepFieldChunkSize).
+				Description("An optional size of the chunks that the text of each message is split into before being embedded. Refer to the <<chunking-long-text, chunking long text section>> for details. This cannot be used together with `"+oepFieldTexts+"` or `"+oepFieldMapping+"`.").
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Example(1000).
+				Optional().
+				Advanced(),
+			service.NewIntField(oepFieldChunkOverlap).
+				Description("The size of the text that consecutive chunks share when `"+oepFieldChunkSize+"` is set, which must be less than `"+oepFieldChunkSize+"`.").
+				Version("4.64.0").
+				LintRule(`root = if this < 0 { [ "field must not be negative" ] }`).
+				Default(0).
+				Example(200).
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldChunkUnit, map[string]string{
+				"characters": "Measure chunks in Unicode characters.",
+				"tokens":     "Measure chunks in tokens, estimated as four characters each.",
+			}).
+				Description("The unit that `"+oepFieldChunkSize+"` and `"+oepFieldChunkOverlap+"` are measured in.").
+				Version("4.64.0").
+				Default("characters").
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldChunkOutput, map[string]string{
+				"split": "Emit a message with the embedding of each chunk.",
+				"array": "Emit a single message with the embeddings of all chunks.",
+			}).
+				Description("How the embeddings of chunks are emitted when `"+oepFieldChunkSize+"` is set.").
+				Version("4.64.0").
+				Default("split").
+				Advanced(),
+			service.NewFloatField(oepFieldDedupThreshold).
+				Description("An optional cosine similarity between 0 and 1 at or above which a message is dropped as a near-duplicate of a message kept earlier in the same batch. Refer to the <<deduplicating-near-duplicates, deduplicating near-duplicates section>> for details. This cannot be used together with `"+oepFieldTexts+"` or when `"+oepFieldChunkOutput+"` is `array`.").
+				Version("4.64.0").
+				LintRule(`root = if this <= 0 || this > 1 { [ "field must be greater than 0 and at most 1" ] }`).
+				Example(0.98).
+				Optional().
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldEmbeddingType, map[string]string{
+				"none":     "Embed the text unchanged.",
+				"query":    "Embed the text as a search query, adding the query prefix of the model family.",
+				"document": "Embed the text as a document to be searched, adding the document prefix of the model family.",
+			}).
+				Description("Whether the text is embedded as a search query or as a document to be searched, which asymmetric retrieval models handle differently. Refer to the <<embedding-types, embedding types section>> for the supported model families.").
+				Version("4.64.0").
+				Default("none").
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldErrorHandling, map[string]string{
+				"fail": "Fail to process messages whose input cannot be resolved.",
+				"flag": "Pass messages whose input cannot be resolved through unchanged, with the error added to the `ollama_error` metadata key.",
+			}).
+				Description("How to handle messages whose input cannot be resolved, such as a payload containing invalid UTF-8.").
+				Version("4.64.0").
+				Default("fail").
+				Advanced(),
+			service.NewBloblangField(oepFieldMapping).
+				Description("An optional xref:guides:bloblang/about.adoc[Bloblang mapping] applied to each embedding before it is written to the message, where `this` is the array of numbers of the embedding. This can be used to quantize, clip or scale vectors for stores that require it. The result of the mapping replaces the embedding, and it cannot be used together with `"+oepFieldTexts+"`.").
+				Version("4.64.0").
+				Example(`root = this.map_each(v -> [[v, -0.5].max(), 0.5].min())`).
+				Example(`root = this.map_each(v -> (v * 127).round())`).
+				Optional().
+				Advanced(),
+			service.NewStringAnnotatedEnumField(oepFieldOutputEncoding, map[string]string{
+				"array":          "Write each embedding as a JSON array of numbers.",
+				"base64_float32": "Write each embedding as base64 encoded little-endian 32-bit floats.",
+				"base64_float64": "Write each embedding as base64 encoded little-endian 64-bit floats.",
+			}).
+				Description("How embeddings are encoded when written to the message. Refer to the <<output-encoding, output encoding section>> for the exact layout of the binary encodings.").
+				Version("4.64.0").
+				Default("array").
+				Advanced(),
+			service.NewStringField(oepFieldOutputPath).
+				Description("An optional dot separated path within the structured payload of the message at which to set the embedding, which preserves the rest of the payload rather than replacing it. Refer to the <<enriching-structured-payloads, enriching structured payloads section>> for details.").
+				Version("4.64.0").
+				Example("embedding").
+				Example("vectors.title").
+				Optional().
+				Advanced(),
+			service.NewBoolField(oepFieldValidateOutput).
+				Description("Whether to check that each embedding returned by the model does not only contain zeros and does not contain NaN or infinite values, which indicates a broken model. Refer to the <<error-handling, error handling section>> for how invalid embeddings are handled.").
+				Version("4.64.0").
+				Default(false).
+				Advanced(),
+			service.NewIntField(ocpFieldSeed).
+				Optional().
+				Advanced().
+				Description("Sets the random number seed to use for generation, which makes embeddings reproducible across runs. Whether the seed is honored depends on the model and runtime being used.").
+				Version("4.64.0").
+				Example(42),
+			service.NewIntField(oepFieldMaxTokensPerRequest).
+				Optional().
+				Advanced().
+				Description("An optional limit on the number of tokens sent to the model in a single request, which can be used to embed text that is longer than the context window of the model. Text is split on whitespace into chunks, the number of tokens in each chunk is estimated as one token for every four bytes of text, and the resulting embeddings are combined according to `"+oepFieldCombine+"`.").
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Example(2048),
+			service.NewStringAnnotatedEnumField(oepFieldCombine, map[string]string{
+				"mean":  "Embed each chunk and use the element-wise mean of the resulting vectors.",
+				"first": "Only embed the first chunk of the text.",
+				"error": "Fail to process messages with text that exceeds the limit.",
+			}).
+				Advanced().
+				Description("How to combine the embeddings of text that was split due to `"+oepFieldMaxTokensPerRequest+"`.").
+				Version("4.64.0").
+				Default("mean"),
+			service.NewStringAnnotatedEnumField(oepFieldTruncateSide, map[string]string{
+				"server": "Send the text as it is, leaving text that exceeds the context window of the model to be handled by the server.",
+				"left":   "Discard the start of text that exceeds `" + oepFieldTruncateTokens + "`, keeping its end.",
+				"right":  "Discard the end of text that exceeds `" + oepFieldTruncateTokens + "`, keeping its start.",
+			}).
+				Advanced().
+				Description("Which side of the text to discard when it is longer than `"+oepFieldTruncateTokens+"`. The Ollama API does not accept a truncation side, so text is truncated before it is sent. The number of tokens is estimated as one token for every four bytes of text, and text is cut between characters. This applies to the text resolved from `"+oepFieldText+"` or the payload before any prefix of `"+oepFieldEmbeddingType+"` is added, and cannot be used together with `"+oepFieldTexts+"`.").
+				Version("4.64.0").
+				Default("server"),
+			service.NewIntField(oepFieldTruncateTokens).
+				Optional().
+				Advanced().
+				Description("The maximum number of tokens of text to embed when `"+oepFieldTruncateSide+"` is `left` or `right`, which should be less than the context window of the model.").
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Example(512),
+			service.NewStringListField(oepFieldFallbackModels).
+				Optional().
+				Advanced().
+				Description("An optional list of models to try in order when the Ollama server reports that a model is missing or overloaded. Fallback models are pulled the first time they are used, and when this field is set the name of the model that generated each embedding is added to the `ollama_model` metadata key. Embeddings generated by different models are generally not comparable with each other, so mixing them within the same vector store is at your own risk.").
+				Version("4.64.0").
+				Example([]string{"all-minilm"}),
+			service.NewBoolField(oepFieldWarmup).
+				Advanced().
+				Description("Whether to embed a short text with the model when the processor is created, which loads the model into memory so that the first message is not delayed while the model loads. The model is kept loaded for as long as the server keeps idle models, which for a local server can be configured with `OLLAMA_KEEP_ALIVE` in `"+bopFieldRunner+"."+bopFieldEnv+"`. Failing to warm up the model, such as with remote servers that do not allow models to be loaded, is logged and does not prevent the processor from starting. Warming up is skipped when `"+bopFieldModel+"` is dynamic or when `"+oepFieldMock+"` is enabled.").
+				Version("4.64.0").
+				Default(false),
+			service.NewDurationField(oepFieldWarmupTimeout).
+				Advanced().
+				Description("The maximum period to wait for the model to be warmed up when `"+oepFieldWarmup+"` is enabled, after which the processor starts regardless and the model is loaded by the first message instead.").
+				Version("4.64.0").
+				Default("1m"),
+			service.NewBoolField(oepFieldMock).
+				Advanced().
+				Description("Whether to generate deterministic pseudo-random embeddings from a hash of the text instead of using a model, which allows pipelines to be tested without an Ollama server. When enabled no server is started or connected to and no models are pulled. This must never be enabled in production as the resulting vectors carry no meaning.").
+				Version("4.64.0").
+				Default(false),
+			service.NewIntField(oepFieldDimensions).
+				Advanced().
+				Description("The number of dimensions of the embeddings generated when `"+oepFieldMock+"` is enabled.").
+				Version("4.64.0").
+				LintRule(`root = if this < 1 { [ "field must be at least 1" ] }`).
+				Default(768),
 		).Fields(commonFields()...).
 		Example(
 			"Store embedding vectors in Qdrant",
@@ -103,12 +397,24 @@ output:
 `)
 }
 
-func makeOllamaEmbeddingProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
+func makeOllamaEmbeddingProcessor(conf *service.ParsedConfig, mgr *service.Resources) (*ollamaEmbeddingProcessor, error) {
 	if err := license.CheckRunningEnterprise(mgr); err != nil {
 		return nil, err
 	}
 
 	p := ollamaEmbeddingProcessor{}
+	model, err := conf.FieldInterpolatedString(bopFieldModel)
+	if err != nil {
+		return nil, err
+	}
+	staticModel, isStatic := model.Static()
+	if isStatic {
+		if staticModel == "" {
+			return nil, fmt.Errorf("field `%s` must not be empty", bopFieldModel)
+		}
+	} else {
+		p.dynamicModel = model
+	}
 	if conf.Contains(oepFieldText) {
 		pf, err := conf.FieldInterpolatedString(oepFieldText)
 		if err != nil {
@@ -116,41 +422,715 @@ func makeOllamaEmbeddingProcessor(conf *service.ParsedConfig, mgr *service.Resou
 		}
 		p.text = pf
 	}
-	b, err := newBaseProcessor(conf, mgr)
+	if conf.Contains(oepFieldTexts) {
+		if p.text != nil {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldText, oepFieldTexts)
+		}
+		if p.texts, err = conf.FieldBloblang(oepFieldTexts); err != nil {
+			return nil, err
+		}
+	}
+	if p.windowSize, err = conf.FieldInt(oepFieldWindowSize); err != nil {
+		return nil, err
+	}
+	if p.windowSize < 1 {
+		return nil, fmt.Errorf("field `%s` must be at least 1", oepFieldWindowSize)
+	}
+	if p.windowOutput, err = conf.FieldString(oepFieldWindowOutput); err != nil {
+		return nil, err
+	}
+	if conf.Contains(oepFieldChunkSize) {
+		if p.texts != nil {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldTexts, oepFieldChunkSize)
+		}
+		if p.chunkSize, err = conf.FieldInt(oepFieldChunkSize); err != nil {
+			return nil, err
+		}
+		if p.chunkSize < 1 {
+			return nil, fmt.Errorf("field `%s` must be at least 1", oepFieldChunkSize)
+		}
+		if p.chunkOverlap, err = conf.FieldInt(oepFieldChunkOverlap); err != nil {
+			return nil, err
+		}
+		if p.chunkOverlap < 0 || p.chunkOverlap >= p.chunkSize {
+			return nil, fmt.Errorf("field `%s` must not be negative and must be less than `%s`", oepFieldChunkOverlap, oepFieldChunkSize)
+		}
+		unit, err := conf.FieldString(oepFieldChunkUnit)
+		if err != nil {
+			return nil, err
+		}
+		if unit == "tokens" {
+			p.chunkSize *= oepCharsPerTokenEstimate
+			p.chunkOverlap *= oepCharsPerTokenEstimate
+		}
+		if p.chunkOutput, err = conf.FieldString(oepFieldChunkOutput); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(oepFieldDedupThreshold) {
+		if p.texts != nil {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldTexts, oepFieldDedupThreshold)
+		}
+		if p.chunkSize > 0 && p.chunkOutput == "array" {
+			return nil, fmt.Errorf("field `%s` cannot be used when `%s` is `array`", oepFieldDedupThreshold, oepFieldChunkOutput)
+		}
+		if p.dedupThreshold, err = conf.FieldFloat(oepFieldDedupThreshold); err != nil {
+			return nil, err
+		}
+		if p.dedupThreshold <= 0 || p.dedupThreshold > 1 {
+			return nil, fmt.Errorf("field `%s` must be greater than 0 and at most 1", oepFieldDedupThreshold)
+		}
+	}
+	if p.embeddingType, err = conf.FieldString(oepFieldEmbeddingType); err != nil {
+		return nil, err
+	}
+	if p.errorHandling, err = conf.FieldString(oepFieldErrorHandling); err != nil {
+		return nil, err
+	}
+	if conf.Contains(oepFieldMapping) {
+		if p.texts != nil {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldTexts, oepFieldMapping)
+		}
+		if p.chunkSize > 0 {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldChunkSize, oepFieldMapping)
+		}
+		if p.mapping, err = conf.FieldBloblang(oepFieldMapping); err != nil {
+			return nil, err
+		}
+	}
+	if p.outputEncoding, err = conf.FieldString(oepFieldOutputEncoding); err != nil {
+		return nil, err
+	}
+	if conf.Contains(oepFieldOutputPath) {
+		if p.outputPath, err = conf.FieldString(oepFieldOutputPath); err != nil {
+			return nil, err
+		}
+	}
+	if p.validateOutput, err = conf.FieldBool(oepFieldValidateOutput); err != nil {
+		return nil, err
+	}
+	if conf.Contains(oepFieldMaxTokensPerRequest) {
+		maxTokens, err := conf.FieldInt(oepFieldMaxTokensPerRequest)
+		if err != nil {
+			return nil, err
+		}
+		if maxTokens < 1 {
+			return nil, fmt.Errorf("field `%s` must be at least 1", oepFieldMaxTokensPerRequest)
+		}
+		p.maxChunkBytes = maxTokens * oepBytesPerTokenEstimate
+	}
+	if p.combine, err = conf.FieldString(oepFieldCombine); err != nil {
+		return nil, err
+	}
+	if p.truncateSide, err = conf.FieldString(oepFieldTruncateSide); err != nil {
+		return nil, err
+	}
+	if p.truncateSide == "server" {
+		if conf.Contains(oepFieldTruncateTokens) {
+			return nil, fmt.Errorf("field `%s` requires `%s` to be `left` or `right`", oepFieldTruncateTokens, oepFieldTruncateSide)
+		}
+	} else {
+		if p.texts != nil {
+			return nil, fmt.Errorf("fields `%s` and `%s` cannot both be set", oepFieldTexts, oepFieldTruncateSide)
+		}
+		if !conf.Contains(oepFieldTruncateTokens) {
+			return nil, fmt.Errorf("field `%s` must be set when `%s` is `%s`", oepFieldTruncateTokens, oepFieldTruncateSide, p.truncateSide)
+		}
+		maxTokens, err := conf.FieldInt(oepFieldTruncateTokens)
+		if err != nil {
+			return nil, err
+		}
+		if maxTokens < 1 {
+			return nil, fmt.Errorf("field `%s` must be at least 1", oepFieldTruncateTokens)
+		}
+		p.truncateBytes = maxTokens * oepBytesPerTokenEstimate
+	}
+	if conf.Contains(oepFieldFallbackModels) {
+		if p.fallbackModels, err = conf.FieldStringList(oepFieldFallbackModels); err != nil {
+			return nil, err
+		}
+	}
+	if p.embeddingType != "none" {
+		models := p.fallbackModels
+		if isStatic {
+			models = append([]string{staticModel}, models...)
+		}
+		for _, m := range models {
+			if _, known := embeddingPrefix(m, p.embeddingType); !known {
+				mgr.Logger().Warnf("Model %q is not from a supported model family, its text is embedded unchanged regardless of `%s`", m, oepFieldEmbeddingType)
+			}
+		}
+	}
+	warmup, err := conf.FieldBool(oepFieldWarmup)
+	if err != nil {
+		return nil, err
+	}
+	warmupTimeout, err := conf.FieldDuration(oepFieldWarmupTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if p.mock, err = conf.FieldBool(oepFieldMock); err != nil {
+		return nil, err
+	}
+	if p.mock {
+		if p.dimensions, err = conf.FieldInt(oepFieldDimensions); err != nil {
+			return nil, err
+		}
+		if p.dimensions < 1 {
+			return nil, fmt.Errorf("field `%s` must be at least 1", oepFieldDimensions)
+		}
+		mgr.Logger().Warn("Mock mode is enabled, embeddings are generated from a hash of the text and not by a model")
+		p.baseOllamaProcessor = &baseOllamaProcessor{
+			model:  staticModel,
+			logger: mgr.Logger(),
+			pulled: map[string]struct{}{},
+
+			requestLatency: mgr.Metrics().NewTimer(bopMetricRequestLatency, "model"),
+		}
+		return &p, nil
+	}
+	b, err := newBaseProcessorForModel(conf, mgr, staticModel)
 	if err != nil {
 		return nil, err
 	}
 	p.baseOllamaProcessor = b
+	if warmup {
+		if isStatic {
+			p.warmUp(staticModel, warmupTimeout)
+		} else {
+			mgr.Logger().Warnf("Field `%s` is ignored as `%s` is dynamic", oepFieldWarmup, bopFieldModel)
+		}
+	}
 	return &p, nil
 }
 
+// warmUp embeds a short text with the model so that the server loads it into
+// memory before the first message is processed, giving up after timeout.
+func (o *ollamaEmbeddingProcessor) warmUp(model string, timeout time.Duration) {
+	ctx, done := context.WithTimeout(context.Background(), timeout)
+	defer done()
+
+	started := time.Now()
+	if _, err := o.generateEmbedding(ctx, model, "warmup"); err != nil {
+		o.logger.Warnf("Failed to warm up %q: %v", model, err)
+		return
+	}
+	o.logger.Infof("Warmed up %q in %v", model, time.Since(started))
+}
+
 type ollamaEmbeddingProcessor struct {
 	*baseOllamaProcessor
 
-	text *service.InterpolatedString
+	text           *service.InterpolatedString
+	texts          *bloblang.Executor
+	windowSize     int
+	windowOutput   string
+	chunkSize      int
+	chunkOverlap   int
+	chunkOutput    string
+	dedupThreshold float64
+	embeddingType  string
+	errorHandling  string
+	mapping        *bloblang.Executor
+	outputEncoding string
+	outputPath     string
+	validateOutput bool
+	dynamicModel   *service.InterpolatedString
+	maxChunkBytes  int
+	combine        string
+	truncateSide   string
+	truncateBytes  int
+	fallbackModels []string
+	mock           bool
+	dimensions     int
+}
+
+func (o *ollamaEmbeddingProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	var embeddings map[*service.Message][]float64
+	var record embeddingRecorder
+	if o.dedupThreshold > 0 {
+		embeddings = make(map[*service.Message][]float64, len(batch))
+		record = func(m *service.Message, e []float64) {
+			embeddings[m] = e
+		}
+	}
+
+	out := make(service.MessageBatch, 0, len(batch))
+	for _, msg := range batch {
+		res, err := o.process(ctx, msg, record)
+		if err != nil {
+			m := msg.Copy()
+			m.SetError(err)
+			out = append(out, m)
+			continue
+		}
+		out = append(out, res...)
+	}
+	if embeddings != nil {
+		out = o.dropNearDuplicates(out, embeddings)
+	}
+	return []service.MessageBatch{out}, nil
 }
 
 func (o *ollamaEmbeddingProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	return o.process(ctx, msg, nil)
+}
+
+// embeddingRecorder is called with each emitted message that holds a single
+// embedding, along with the embedding as returned by the model.
+type embeddingRecorder func(m *service.Message, e []float64)
+
+func (o *ollamaEmbeddingProcessor) process(ctx context.Context, msg *service.Message, record embeddingRecorder) (service.MessageBatch, error) {
+	if err := o.checkOutputPayload(msg); err != nil {
+		return o.inputError(msg, err)
+	}
+	if o.texts != nil {
+		return o.processTexts(ctx, msg)
+	}
+	if o.chunkSize > 0 {
+		return o.processChunks(ctx, msg, record)
+	}
 	p, err := o.computeText(msg)
+	if err != nil {
+		return o.inputError(msg, err)
+	}
+	model, err := o.computeModel(ctx, msg)
 	if err != nil {
 		return nil, err
 	}
-	e, err := o.generateEmbedding(ctx, p)
+	e, model, err := o.generateEmbeddingWithFallback(ctx, model, p)
 	if err != nil {
+		if errors.Is(err, errInvalidEmbedding) {
+			return o.inputError(msg, err)
+		}
 		return nil, err
 	}
 	m := msg.Copy()
+	if len(o.fallbackModels) > 0 {
+		m.MetaSetMut("ollama_model", model)
+	}
+	if record != nil {
+		record(m, e)
+	}
 	s := make([]any, len(e))
 	for i, f := range e {
 		s[i] = f
 	}
-	m.SetStructuredMut(s)
+	var v any = s
+	if o.mapping != nil {
+		if v, err = o.mapping.Query(v); err != nil {
+			return nil, fmt.Errorf("unable to execute `%s`: %w", oepFieldMapping, err)
+		}
+	}
+	if o.outputEncoding != "array" {
+		if o.mapping != nil {
+			if e, err = embeddingFromValue(v); err != nil {
+				return nil, fmt.Errorf("unable to encode the result of `%s`: %w", oepFieldMapping, err)
+			}
+		}
+		packed := appendPackedEmbedding(nil, e, o.outputEncoding)
+		if o.outputPath == "" {
+			m.SetBytes(packed)
+			return service.MessageBatch{m}, nil
+		}
+		v = string(packed)
+	}
+	if err := o.setOutput(m, v); err != nil {
+		return o.inputError(msg, err)
+	}
 	return service.MessageBatch{m}, nil
 }
 
+// dropNearDuplicates removes each message whose embedding has a cosine
+// similarity of at least the dedup threshold to the embedding of a message
+// kept before it. Messages without an embedding are always kept.
+func (o *ollamaEmbeddingProcessor) dropNearDuplicates(batch service.MessageBatch, embeddings map[*service.Message][]float64) service.MessageBatch {
+	var kept [][]float64
+	out := batch[:0]
+	for _, m := range batch {
+		e, ok := embeddings[m]
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		duplicate := false
+		for _, k := range kept {
+			if cosineSimilarity(e, k) >= o.dedupThreshold {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		kept = append(kept, e)
+		out = append(out, m)
+	}
+	if dropped := len(batch) - len(out); dropped > 0 {
+		o.logger.Debugf("Dropped %v near-duplicate messages from a batch of %v", dropped, len(batch))
+	}
+	return out
+}
+
+// cosineSimilarity returns the cosine of the angle between two embeddings,
+// which is zero when either only contains zeros or their dimensions differ.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// checkOutputPayload returns an error if an output path is set and the payload
+// of a message is not an object that the result can be set within, which
+// avoids generating embeddings that cannot be written.
+func (o *ollamaEmbeddingProcessor) checkOutputPayload(msg *service.Message) error {
+	if o.outputPath == "" {
+		return nil
+	}
+	doc, err := msg.AsStructured()
+	if err != nil {
+		return fmt.Errorf("unable to set `%s` as the payload is not structured: %w", oepFieldOutputPath, err)
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return fmt.Errorf("unable to set `%s` as the payload is not an object, got %T", oepFieldOutputPath, doc)
+	}
+	return nil
+}
+
+// setOutput writes the result of a message to it, either replacing the payload
+// or, when an output path is set, setting the result at the path within the
+// existing structured payload.
+func (o *ollamaEmbeddingProcessor) setOutput(m *service.Message, v any) error {
+	if o.outputPath == "" {
+		m.SetStructuredMut(v)
+		return nil
+	}
+	doc, err := m.AsStructuredMut()
+	if err != nil {
+		return err
+	}
+	if _, err := gabs.Wrap(doc).SetP(v, o.outputPath); err != nil {
+		return fmt.Errorf("unable to set `%s`: %w", oepFieldOutputPath, err)
+	}
+	m.SetStructuredMut(doc)
+	return nil
+}
+
+// setEmbedding writes a single embedding to a message in the configured output
+// encoding.
+func (o *ollamaEmbeddingProcessor) setEmbedding(m *service.Message, e []float64) error {
+	if o.outputEncoding == "array" {
+		return o.setOutputBytes(m, appendEmbeddings(nil, [][]float64{e}, false, o.outputEncoding))
+	}
+	packed := appendPackedEmbedding(nil, e, o.outputEncoding)
+	if o.outputPath == "" {
+		m.SetBytes(packed)
+		return nil
+	}
+	return o.setOutput(m, string(packed))
+}
+
+// setOutputBytes writes a JSON encoded result to a message.
+func (o *ollamaEmbeddingProcessor) setOutputBytes(m *service.Message, b []byte) error {
+	if o.outputPath == "" {
+		m.SetBytes(b)
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return o.setOutput(m, v)
+}
+
+// embeddingFromValue converts the result of a mapping into an embedding.
+func embeddingFromValue(v any) ([]float64, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of numbers, got %T", v)
+	}
+	e := make([]float64, len(arr))
+	for i, n := range arr {
+		f, err := bloblang.ValueAsFloat64(n)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		e[i] = f
+	}
+	return e, nil
+}
+
+// appendPackedEmbedding appends an embedding to dst packed as little-endian
+// floats of the width of the given encoding, encoded as base64.
+func appendPackedEmbedding(dst []byte, e []float64, encoding string) []byte {
+	var packed []byte
+	if encoding == "base64_float32" {
+		packed = make([]byte, 0, 4*len(e))
+		for _, f := range e {
+			packed = binary.LittleEndian.AppendUint32(packed, math.Float32bits(float32(f)))
+		}
+	} else {
+		packed = make([]byte, 0, 8*len(e))
+		for _, f := range e {
+			packed = binary.LittleEndian.AppendUint64(packed, math.Float64bits(f))
+		}
+	}
+	return base64.StdEncoding.AppendEncode(dst, packed)
+}
+
+// inputError handles a message whose input could not be resolved, or whose
+// embedding is invalid, according to the configured error handling.
+func (o *ollamaEmbeddingProcessor) inputError(msg *service.Message, err error) (service.MessageBatch, error) {
+	if o.errorHandling != "flag" {
+		return nil, err
+	}
+	m := msg.Copy()
+	m.MetaSetMut("ollama_error", err.Error())
+	return service.MessageBatch{m}, nil
+}
+
+// processTexts embeds the array of texts resolved from a message in windows,
+// emitting the embeddings according to the configured window output.
+func (o *ollamaEmbeddingProcessor) processTexts(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
+	texts, err := o.computeTexts(msg)
+	if err != nil {
+		return o.inputError(msg, err)
+	}
+	model, err := o.computeModel(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch service.MessageBatch
+	if o.windowOutput == "split" {
+		var serr error
+		model, err = o.embedWindows(ctx, model, texts, func(offset int, window [][]float64) {
+			m := msg.Copy()
+			if err := o.setOutputBytes(m, append(appendEmbeddings([]byte{'['}, window, false, o.outputEncoding), ']')); err != nil && serr == nil {
+				serr = err
+			}
+			m.MetaSetMut("ollama_window_offset", strconv.Itoa(offset))
+			batch = append(batch, m)
+		})
+		if err == nil && serr != nil {
+			return o.inputError(msg, serr)
+		}
+	} else {
+		buf := []byte{'['}
+		model, err = o.embedWindows(ctx, model, texts, func(offset int, window [][]float64) {
+			buf = appendEmbeddings(buf, window, offset > 0, o.outputEncoding)
+		})
+		m := msg.Copy()
+		if err == nil {
+			if err := o.setOutputBytes(m, append(buf, ']')); err != nil {
+				return o.inputError(msg, err)
+			}
+		}
+		batch = service.MessageBatch{m}
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidEmbedding) {
+			return o.inputError(msg, err)
+		}
+		return nil, err
+	}
+	if len(o.fallbackModels) > 0 {
+		for _, m := range batch {
+			m.MetaSetMut("ollama_model", model)
+		}
+	}
+	return batch, nil
+}
+
+// processChunks splits the text of a message into overlapping chunks and
+// embeds each of them, emitting the embeddings according to the configured
+// chunk output.
+func (o *ollamaEmbeddingProcessor) processChunks(ctx context.Context, msg *service.Message, record embeddingRecorder) (service.MessageBatch, error) {
+	text, err := o.computeText(msg)
+	if err != nil {
+		return o.inputError(msg, err)
+	}
+	chunks := chunkText(text, o.chunkSize, o.chunkOverlap)
+	model, err := o.computeModel(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var batch service.MessageBatch
+	var serr error
+	if o.chunkOutput == "split" {
+		model, err = o.embedWindows(ctx, model, chunks, func(offset int, window [][]float64) {
+			for i, e := range window {
+				m := msg.Copy()
+				if err := o.setEmbedding(m, e); err != nil && serr == nil {
+					serr = err
+				}
+				m.MetaSetMut("ollama_chunk_index", strconv.Itoa(offset+i))
+				m.MetaSetMut("ollama_chunk_text", chunks[offset+i])
+				if record != nil {
+					record(m, e)
+				}
+				batch = append(batch, m)
+			}
+		})
+	} else {
+		buf := []byte{'['}
+		model, err = o.embedWindows(ctx, model, chunks, func(offset int, window [][]float64) {
+			buf = appendEmbeddings(buf, window, offset > 0, o.outputEncoding)
+		})
+		m := msg.Copy()
+		if err == nil {
+			serr = o.setOutputBytes(m, append(buf, ']'))
+		}
+		batch = service.MessageBatch{m}
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidEmbedding) {
+			return o.inputError(msg, err)
+		}
+		return nil, err
+	}
+	if serr != nil {
+		return o.inputError(msg, serr)
+	}
+	if len(o.fallbackModels) > 0 {
+		for _, m := range batch {
+			m.MetaSetMut("ollama_model", model)
+		}
+	}
+	return batch, nil
+}
+
+// chunkText splits text into chunks of size characters, each starting size
+// minus overlap characters after the previous one, such that consecutive
+// chunks share overlap characters. The last chunk ends with the text and may
+// be shorter, and empty text results in no chunks.
+func chunkText(text string, size, overlap int) []string {
+	// The byte offset of each character, followed by the length of the text
+	offsets := make([]int, 0, len(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	n := len(offsets)
+	if n == 0 {
+		return nil
+	}
+	offsets = append(offsets, len(text))
+
+	var chunks []string
+	for first := 0; ; first += size - overlap {
+		last := min(first+size, n)
+		chunks = append(chunks, text[offsets[first]:offsets[last]])
+		if last == n {
+			return chunks
+		}
+	}
+}
+
+func (o *ollamaEmbeddingProcessor) computeTexts(msg *service.Message) ([]string, error) {
+	v, err := queryValue(msg, o.texts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to execute `%s`: %w", oepFieldTexts, err)
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected `%s` to return an array, got %T", oepFieldTexts, v)
+	}
+	texts := make([]string, len(arr))
+	for i, e := range arr {
+		if texts[i], ok = e.(string); !ok {
+			return nil, fmt.Errorf("expected element %d of `%s` to be a string, got %T", i, oepFieldTexts, e)
+		}
+	}
+	return texts, nil
+}
+
+// queryValue executes a Bloblang query against a message and returns the
+// structured result.
+func queryValue(msg *service.Message, query *bloblang.Executor) (any, error) {
+	res, err := msg.BloblangQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, errors.New("query deleted the message")
+	}
+	if !res.HasStructured() {
+		// Results that are strings or bytes are stored as the raw payload.
+		b, err := res.AsBytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return res.AsStructured()
+}
+
+// embedWindows embeds texts in windows of at most windowSize texts, calling fn
+// with the offset and embeddings of each window. The window passed to fn is
+// reused and so must not be retained, which bounds the embeddings held at any
+// time to a single window. The name of the model that generated the embeddings
+// is returned, once a fallback model is used it is kept for the remaining texts
+// so that the embeddings of a message are comparable with each other.
+func (o *ollamaEmbeddingProcessor) embedWindows(ctx context.Context, model string, texts []string, fn func(offset int, window [][]float64)) (string, error) {
+	window := make([][]float64, 0, min(o.windowSize, len(texts)))
+	for offset := 0; offset < len(texts); offset += o.windowSize {
+		window = window[:0]
+		for _, text := range texts[offset:min(offset+o.windowSize, len(texts))] {
+			e, used, err := o.generateEmbeddingWithFallback(ctx, model, text)
+			if err != nil {
+				return "", err
+			}
+			model = used
+			window = append(window, e)
+		}
+		fn(offset, window)
+		clear(window)
+	}
+	return model, nil
+}
+
+// appendEmbeddings appends embeddings to dst as comma separated JSON arrays, or
+// JSON strings when packed by the given encoding, preceded by a comma if more
+// is true.
+func appendEmbeddings(dst []byte, embeddings [][]float64, more bool, encoding string) []byte {
+	for i, e := range embeddings {
+		if more || i > 0 {
+			dst = append(dst, ',')
+		}
+		if encoding != "array" {
+			dst = append(appendPackedEmbedding(append(dst, '"'), e, encoding), '"')
+			continue
+		}
+		dst = append(dst, '[')
+		for j, f := range e {
+			if j > 0 {
+				dst = append(dst, ',')
+			}
+			dst = strconv.AppendFloat(dst, f, 'g', -1, 64)
+		}
+		dst = append(dst, ']')
+	}
+	return dst
+}
+
 func (o *ollamaEmbeddingProcessor) computeText(msg *service.Message) (string, error) {
 	if o.text != nil {
-		return o.text.TryString(msg)
+		text, err := o.text.TryString(msg)
+		if err != nil {
+			return "", err
+		}
+		return truncateText(text, o.truncateBytes, o.truncateSide), nil
 	}
 	b, err := msg.AsBytes()
 	if err != nil {
@@ -159,21 +1139,245 @@ func (o *ollamaEmbeddingProcessor) computeText(msg *service.Message) (string, er
 	if !utf8.Valid(b) {
 		return "", errors.New("message payload contained invalid UTF8")
 	}
-	return string(b), nil
+	return truncateText(string(b), o.truncateBytes, o.truncateSide), nil
+}
+
+// truncateText returns text cut to at most maxBytes without splitting a UTF-8
+// character, keeping its end when side is left and its start when side is
+// right. Text is returned unchanged for any other side.
+func truncateText(text string, maxBytes int, side string) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	switch side {
+	case "left":
+		cut := len(text) - maxBytes
+		for cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut++
+		}
+		return text[cut:]
+	case "right":
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		return text[:cut]
+	}
+	return text
+}
+
+func (o *ollamaEmbeddingProcessor) computeModel(ctx context.Context, msg *service.Message) (string, error) {
+	if o.dynamicModel == nil {
+		return o.model, nil
+	}
+	model, err := o.dynamicModel.TryString(msg)
+	if err != nil {
+		return "", fmt.Errorf("unable to interpolate `%s`: %w", bopFieldModel, err)
+	}
+	if model == "" {
+		return "", fmt.Errorf("field `%s` resolved to an empty model name", bopFieldModel)
+	}
+	if o.mock {
+		return model, nil
+	}
+	if err := o.ensureModel(ctx, model); err != nil {
+		return "", fmt.Errorf("unable to pull model %q: %w", model, err)
+	}
+	return model, nil
+}
+
+// generateEmbeddingWithFallback embeds text with the given model, trying each
+// of the fallback models in turn if the model is missing or overloaded. The
+// name of the model that generated the embedding is returned.
+func (o *ollamaEmbeddingProcessor) generateEmbeddingWithFallback(ctx context.Context, model, text string) ([]float64, string, error) {
+	e, err := o.generateCombinedEmbedding(ctx, model, text)
+	for _, fallback := range o.fallbackModels {
+		if err == nil || !isFallbackError(err) {
+			break
+		}
+		o.logger.Warnf("Failed to generate embedding with model %q, trying fallback model %q: %v", model, fallback, err)
+		model = fallback
+		if err = o.ensureModel(ctx, model); err != nil {
+			err = fmt.Errorf("unable to pull model %q: %w", model, err)
+			continue
+		}
+		if e, err = o.generateCombinedEmbedding(ctx, model, text); err == nil {
+			o.logger.Debugf("Generated embedding with fallback model %q", model)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if o.validateOutput {
+		if err := validateEmbedding(e); err != nil {
+			return nil, "", fmt.Errorf("model %q returned an %w", model, err)
+		}
+	}
+	return e, model, nil
 }
 
-func (o *ollamaEmbeddingProcessor) generateEmbedding(ctx context.Context, text string) ([]float64, error) {
+// validateEmbedding returns an error wrapping errInvalidEmbedding if an
+// embedding is empty, only contains zeros, or contains NaN or infinite values.
+func validateEmbedding(e []float64) error {
+	if len(e) == 0 {
+		return fmt.Errorf("%w: embedding is empty", errInvalidEmbedding)
+	}
+	allZero := true
+	for i, f := range e {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%w: element %d is %v", errInvalidEmbedding, i, f)
+		}
+		if f != 0 {
+			allZero = false
+		}
+	}
+	if allZero {
+		return fmt.Errorf("%w: all %d elements are zero", errInvalidEmbedding, len(e))
+	}
+	return nil
+}
+
+// isFallbackError returns whether an error from the Ollama server indicates
+// that another model might succeed, such as a missing or overloaded model.
+func isFallbackError(err error) bool {
+	var serr api.StatusError
+	if !errors.As(err, &serr) {
+		return false
+	}
+	return serr.StatusCode == http.StatusNotFound ||
+		serr.StatusCode == http.StatusTooManyRequests ||
+		serr.StatusCode >= http.StatusInternalServerError
+}
+
+// generateCombinedEmbedding embeds text that may need to be split into
+// multiple requests in order to fit within maxChunkBytes.
+func (o *ollamaEmbeddingProcessor) generateCombinedEmbedding(ctx context.Context, model, text string) ([]float64, error) {
+	if o.maxChunkBytes <= 0 || len(text) <= o.maxChunkBytes {
+		return o.generateEmbedding(ctx, model, text)
+	}
+	chunks := splitText(text, o.maxChunkBytes)
+	switch o.combine {
+	case "first":
+		return o.generateEmbedding(ctx, model, chunks[0])
+	case "mean":
+		var sum []float64
+		for _, chunk := range chunks {
+			e, err := o.generateEmbedding(ctx, model, chunk)
+			if err != nil {
+				return nil, err
+			}
+			if sum == nil {
+				sum = make([]float64, len(e))
+			} else if len(e) != len(sum) {
+				return nil, fmt.Errorf("mismatched embedding lengths %d and %d", len(sum), len(e))
+			}
+			for i, f := range e {
+				sum[i] += f
+			}
+		}
+		for i := range sum {
+			sum[i] /= float64(len(chunks))
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("text of %d bytes exceeds the `%s` limit", len(text), oepFieldMaxTokensPerRequest)
+}
+
+// splitText splits text into chunks of at most maxBytes bytes, preferring to
+// split on whitespace and never splitting a UTF-8 character.
+func splitText(text string, maxBytes int) []string {
+	var chunks []string
+	for len(text) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if r, _ := utf8.DecodeRuneInString(text[cut:]); !unicode.IsSpace(r) {
+			if i := strings.LastIndexFunc(text[:cut], unicode.IsSpace); i > 0 {
+				cut = i
+			}
+		}
+		if cut == 0 {
+			// A single character exceeds the limit, so include it anyway
+			_, cut = utf8.DecodeRuneInString(text)
+		}
+		if chunk := strings.TrimRightFunc(text[:cut], unicode.IsSpace); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = strings.TrimLeftFunc(text[cut:], unicode.IsSpace)
+	}
+	if text != "" || len(chunks) == 0 {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+// embeddingPrefix returns the prefix that the family of a model expects for
+// text of the given embedding type, and whether the family is known.
+func embeddingPrefix(model, embeddingType string) (string, bool) {
+	if i := strings.LastIndexByte(model, '/'); i >= 0 {
+		model = model[i+1:]
+	}
+	if i := strings.IndexByte(model, ':'); i >= 0 {
+		model = model[:i]
+	}
+	for _, p := range oepEmbeddingPrefixes {
+		if !strings.HasPrefix(model, p.family) {
+			continue
+		}
+		switch embeddingType {
+		case "query":
+			return p.query, true
+		case "document":
+			return p.document, true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+func (o *ollamaEmbeddingProcessor) generateEmbedding(ctx context.Context, model, text string) ([]float64, error) {
+	if o.embeddingType != "none" {
+		prefix, _ := embeddingPrefix(model, o.embeddingType)
+		text = prefix + text
+	}
+	if o.mock {
+		return mockEmbedding(text, o.dimensions), nil
+	}
 	var req api.EmbeddingRequest
-	req.Model = o.model
+	req.Model = model
 	req.Prompt = text
 	req.Options = o.opts
+	started := time.Now()
 	resp, err := o.client.Embeddings(ctx, &req)
+	o.observeRequest(model, started)
 	if err != nil {
 		return nil, err
 	}
 	return resp.Embedding, nil
 }
 
+// mockEmbedding returns a deterministic unit vector of the given dimensions
+// that is derived from a hash of the text.
+func mockEmbedding(text string, dimensions int) []float64 {
+	rng := rand.New(rand.NewChaCha8(sha256.Sum256([]byte(text))))
+	e := make([]float64, dimensions)
+	var norm float64
+	for i := range e {
+		e[i] = rng.Float64()*2 - 1
+		norm += e[i] * e[i]
+	}
+	norm = math.Sqrt(norm)
+	for i := range e {
+		e[i] /= norm
+	}
+	return e
+}
+
 func (o *ollamaEmbeddingProcessor) Close(ctx context.Context) error {
+	if o.mock {
+		// No server was started so there is nothing to release.
+		return nil
+	}
 	return o.baseOllamaProcessor.Close(ctx)
 }
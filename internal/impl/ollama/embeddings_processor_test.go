@@ -15,9 +15,23 @@
 package ollama
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ollama/ollama/api"
 	"github.com/stretchr/testify/assert"
@@ -26,6 +40,8 @@ import (
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 	"github.com/redpanda-data/benthos/v4/public/service/integration"
+
+	"github.com/redpanda-data/connect/v4/internal/license"
 )
 
 func createEmbeddingsProcessorForTest(t *testing.T, addr string) *ollamaEmbeddingProcessor {
@@ -72,3 +88,1434 @@ func TestOllamaEmbeddingsIntegration(t *testing.T) {
 		require.IsType(t, float64(0), embd.([]any)[i])
 	}
 }
+
+type stubOllamaRequest struct {
+	path string
+	body []byte
+}
+
+type stubOllamaServer struct {
+	*httptest.Server
+
+	prefix   string
+	mu       sync.Mutex
+	requests []stubOllamaRequest
+
+	// embed optionally computes the embedding returned for a prompt
+	embed func(prompt string) []float64
+	// status optionally returns an error status code for a model
+	status func(model string) int
+	// rateLimit optionally rejects a request with a 429 status, returning the
+	// Retry-After header of the rejection
+	rateLimit func() (retryAfter string, limited bool)
+}
+
+// newStubOllamaServer starts a server that implements the subset of the Ollama
+// API used by the embeddings processor, mounted under prefix.
+func newStubOllamaServer(t *testing.T, prefix string) *stubOllamaServer {
+	t.Helper()
+
+	s := &stubOllamaServer{prefix: prefix}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, stubOllamaRequest{path: r.URL.Path, body: body})
+		s.mu.Unlock()
+		switch r.URL.Path {
+		case prefix + "/":
+		case prefix + "/api/pull":
+			_, _ = w.Write([]byte(`{"status":"success"}` + "\n"))
+		case prefix + "/api/embeddings":
+			var req api.EmbeddingRequest
+			_ = json.Unmarshal(body, &req)
+			if s.rateLimit != nil {
+				if retryAfter, limited := s.rateLimit(); limited {
+					if retryAfter != "" {
+						w.Header().Set("Retry-After", retryAfter)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					_, _ = w.Write([]byte(`{"error":"rate limited"}`))
+					return
+				}
+			}
+			if s.status != nil {
+				if code := s.status(req.Model); code != 0 {
+					w.WriteHeader(code)
+					_, _ = w.Write([]byte(`{"error":"model unavailable"}`))
+					return
+				}
+			}
+			if s.embed == nil {
+				_, _ = w.Write([]byte(`{"embedding":[0.5,0.25]}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(api.EmbeddingResponse{Embedding: s.embed(req.Prompt)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubOllamaServer) requestsTo(path string) (bodies [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.requests {
+		if r.path == s.prefix+path {
+			bodies = append(bodies, r.body)
+		}
+	}
+	return
+}
+
+func newEmbeddingsProcessorFromYAML(t *testing.T, yaml string) service.Processor {
+	t.Helper()
+
+	conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	mgr := service.MockResources()
+	license.InjectTestService(mgr)
+
+	proc, err := makeOllamaEmbeddingProcessor(conf, mgr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = proc.Close(context.Background()) })
+	return proc
+}
+
+func TestOllamaEmbeddingsAPIPath(t *testing.T) {
+	srv := newStubOllamaServer(t, "/ollama")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+api_path: /ollama
+`)
+
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	embd, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, []any{0.5, 0.25}, embd)
+
+	assert.Len(t, srv.requestsTo("/api/pull"), 1)
+	assert.Len(t, srv.requestsTo("/api/embeddings"), 1)
+}
+
+func TestOllamaEmbeddingsSeed(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+seed: 42
+`)
+
+	_, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+
+	bodies := srv.requestsTo("/api/embeddings")
+	require.Len(t, bodies, 1)
+	var req api.EmbeddingRequest
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	assert.Equal(t, "hello world", req.Prompt)
+	assert.EqualValues(t, 42, req.Options["seed"])
+}
+
+func TestOllamaValidateAPIPath(t *testing.T) {
+	for _, p := range []string{"", "/", "/ollama", "/a/b"} {
+		assert.NoError(t, validateAPIPath(p), p)
+	}
+	for _, p := range []string{"ollama", "/ollama/", "/a/../b", "/a//b", "/a?b=c"} {
+		assert.Error(t, validateAPIPath(p), p)
+	}
+}
+
+func TestOllamaHTTPClientConnectionPool(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		yaml            string
+		maxIdleConns    int
+		maxConnsPerHost int
+		errContains     string
+	}{
+		{
+			name:            "defaults",
+			yaml:            `model: all-minilm`,
+			maxIdleConns:    2,
+			maxConnsPerHost: 0,
+		},
+		{
+			name: "configured",
+			yaml: `
+model: all-minilm
+max_idle_conns: 64
+max_conns_per_host: 128
+`,
+			maxIdleConns:    64,
+			maxConnsPerHost: 128,
+		},
+		{
+			name: "no idle connections",
+			yaml: `
+model: all-minilm
+max_idle_conns: 0
+`,
+			errContains: "max_idle_conns",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(test.yaml, nil)
+			require.NoError(t, err)
+
+			client, err := httpClientFromConfig(conf)
+			if test.errContains != "" {
+				require.ErrorContains(t, err, test.errContains)
+				return
+			}
+			require.NoError(t, err)
+
+			transport, ok := client.Transport.(*http.Transport)
+			require.True(t, ok)
+			assert.Equal(t, test.maxIdleConns, transport.MaxIdleConns)
+			assert.Equal(t, test.maxIdleConns, transport.MaxIdleConnsPerHost)
+			assert.Equal(t, test.maxConnsPerHost, transport.MaxConnsPerHost)
+		})
+	}
+}
+
+// recordingRoundTripper records the path of each request before sending it.
+type recordingRoundTripper struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.paths = append(r.paths, req.URL.Path)
+	r.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (r *recordingRoundTripper) requestPaths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.paths)
+}
+
+func TestOllamaInjectedHTTPClient(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(`
+model: all-minilm
+server_address: `+srv.URL+`
+`, nil)
+	require.NoError(t, err)
+
+	mgr := service.MockResources()
+	license.InjectTestService(mgr)
+	rt := &recordingRoundTripper{}
+	setTestHTTPClient(mgr, &http.Client{Transport: rt})
+
+	proc, err := makeOllamaEmbeddingProcessor(conf, mgr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = proc.Close(context.Background()) })
+
+	_, err = proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+
+	paths := rt.requestPaths()
+	assert.Contains(t, paths, "/api/pull")
+	assert.Contains(t, paths, "/api/embeddings")
+	assert.Len(t, paths, len(srv.requestsTo("/"))+len(srv.requestsTo("/api/pull"))+len(srv.requestsTo("/api/embeddings")))
+
+	t.Run("nil client", func(t *testing.T) {
+		mgr := service.MockResources()
+		license.InjectTestService(mgr)
+		setTestHTTPClient(mgr, nil)
+
+		_, err := makeOllamaEmbeddingProcessor(conf, mgr)
+		require.ErrorContains(t, err, "HTTP client set for Ollama processors is nil")
+	})
+}
+
+func TestOllamaEmbeddingsRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		maxRetries int
+		retryAfter string
+		limits     int
+		requests   int
+		minElapsed time.Duration
+		errStatus  int
+	}{
+		{
+			name:       "retried after delay",
+			maxRetries: 3,
+			retryAfter: "1",
+			limits:     1,
+			requests:   2,
+			minElapsed: time.Second,
+		},
+		{
+			name:       "retried with zero delay",
+			maxRetries: 3,
+			retryAfter: "0",
+			limits:     3,
+			requests:   4,
+		},
+		{
+			name:       "retries exhausted",
+			maxRetries: 2,
+			retryAfter: "0",
+			limits:     10,
+			requests:   3,
+			errStatus:  http.StatusTooManyRequests,
+		},
+		{
+			name:       "retry after exceeds max wait",
+			maxRetries: 3,
+			retryAfter: "120",
+			limits:     1,
+			requests:   1,
+			errStatus:  http.StatusTooManyRequests,
+		},
+		{
+			name:       "no retry after header",
+			maxRetries: 3,
+			limits:     1,
+			requests:   1,
+			errStatus:  http.StatusTooManyRequests,
+		},
+		{
+			name:       "retries disabled",
+			retryAfter: "0",
+			limits:     1,
+			requests:   1,
+			errStatus:  http.StatusTooManyRequests,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newStubOllamaServer(t, "")
+			var limited atomic.Int32
+			srv.rateLimit = func() (string, bool) {
+				return test.retryAfter, limited.Add(1) <= int32(test.limits)
+			}
+			proc := newEmbeddingsProcessorFromYAML(t, fmt.Sprintf(`
+model: all-minilm
+server_address: %s
+max_retries: %d
+`, srv.URL, test.maxRetries))
+
+			started := time.Now()
+			batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+			assert.GreaterOrEqual(t, time.Since(started), test.minElapsed)
+			assert.Len(t, srv.requestsTo("/api/embeddings"), test.requests)
+			if test.errStatus != 0 {
+				var serr api.StatusError
+				require.ErrorAs(t, err, &serr)
+				assert.Equal(t, test.errStatus, serr.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+
+			embd, err := batch[0].AsStructured()
+			require.NoError(t, err)
+			assert.Equal(t, []any{0.5, 0.25}, embd)
+
+			// Each retry sends the same request again
+			bodies := srv.requestsTo("/api/embeddings")
+			for _, body := range bodies[1:] {
+				assert.Equal(t, bodies[0], body)
+			}
+		})
+	}
+}
+
+func TestOllamaParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 11, 5, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{value: "0", wait: 0, ok: true},
+		{value: "120", wait: 2 * time.Minute, ok: true},
+		{value: "Tue, 05 Nov 2024 12:00:30 GMT", wait: 30 * time.Second, ok: true},
+		{value: "Tue, 05 Nov 2024 11:59:00 GMT", wait: 0, ok: true},
+		{value: ""},
+		{value: "-1"},
+		{value: "soon"},
+	} {
+		wait, ok := parseRetryAfter(test.value, now)
+		assert.Equal(t, test.ok, ok, test.value)
+		assert.Equal(t, test.wait, wait, test.value)
+	}
+}
+
+func TestOllamaEmbeddingsDynamicModel(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: ${! @model }
+server_address: `+srv.URL+`
+`)
+
+	// Nothing is pulled until a message selects a model
+	assert.Empty(t, srv.requestsTo("/api/pull"))
+
+	for _, model := range []string{"all-minilm", "nomic-embed-text", "all-minilm"} {
+		msg := service.NewMessage([]byte("hello world"))
+		msg.MetaSetMut("model", model)
+		_, err := proc.Process(t.Context(), msg)
+		require.NoError(t, err)
+	}
+
+	var pulled []string
+	for _, body := range srv.requestsTo("/api/pull") {
+		var req api.PullRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		pulled = append(pulled, req.Model)
+	}
+	assert.Equal(t, []string{"all-minilm", "nomic-embed-text"}, pulled)
+
+	var used []string
+	for _, body := range srv.requestsTo("/api/embeddings") {
+		var req api.EmbeddingRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		used = append(used, req.Model)
+	}
+	assert.Equal(t, []string{"all-minilm", "nomic-embed-text", "all-minilm"}, used)
+
+	msg := service.NewMessage([]byte("hello world"))
+	msg.MetaSetMut("model", "")
+	_, err := proc.Process(t.Context(), msg)
+	require.Error(t, err)
+}
+
+func TestOllamaEmbeddingsMaxTokensPerRequest(t *testing.T) {
+	embeddings := map[string][]float64{
+		"aaaa": {1, 2},
+		"bbbb": {3, 4},
+		"cccc": {5, 6},
+	}
+	// Two tokens are estimated as eight bytes of text
+	const text = "aaaa bbbb cccc"
+
+	tests := []struct {
+		combine  string
+		expected []any
+		prompts  []string
+	}{
+		{combine: "mean", expected: []any{3.0, 4.0}, prompts: []string{"aaaa", "bbbb", "cccc"}},
+		{combine: "first", expected: []any{1.0, 2.0}, prompts: []string{"aaaa"}},
+		{combine: "error", prompts: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.combine, func(t *testing.T) {
+			srv := newStubOllamaServer(t, "")
+			srv.embed = func(prompt string) []float64 {
+				return embeddings[prompt]
+			}
+			proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+max_tokens_per_request: 2
+combine: `+test.combine+`
+`)
+
+			batch, err := proc.Process(t.Context(), service.NewMessage([]byte(text)))
+			var prompts []string
+			for _, body := range srv.requestsTo("/api/embeddings") {
+				var req api.EmbeddingRequest
+				require.NoError(t, json.Unmarshal(body, &req))
+				prompts = append(prompts, req.Prompt)
+			}
+			assert.Equal(t, test.prompts, prompts)
+
+			if test.expected == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+			embd, err := batch[0].AsStructured()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, embd)
+		})
+	}
+}
+
+func TestOllamaEmbeddingsFallbackModels(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	srv.status = func(model string) int {
+		switch model {
+		case "nomic-embed-text":
+			return http.StatusServiceUnavailable
+		case "mxbai-embed-large":
+			return http.StatusNotFound
+		}
+		return 0
+	}
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+server_address: `+srv.URL+`
+fallback_models: [ mxbai-embed-large, all-minilm ]
+`)
+
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+
+	embd, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, []any{0.5, 0.25}, embd)
+
+	model, exists := batch[0].MetaGetMut("ollama_model")
+	require.True(t, exists)
+	assert.Equal(t, "all-minilm", model)
+
+	var used []string
+	for _, body := range srv.requestsTo("/api/embeddings") {
+		var req api.EmbeddingRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		used = append(used, req.Model)
+	}
+	assert.Equal(t, []string{"nomic-embed-text", "mxbai-embed-large", "all-minilm"}, used)
+
+	// Errors that another model would not fix are returned immediately
+	srv.status = func(string) int { return http.StatusBadRequest }
+	_, err = proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.Error(t, err)
+	assert.Len(t, srv.requestsTo("/api/embeddings"), 4)
+}
+
+func TestOllamaEmbeddingsWarmup(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+warmup: true
+`)
+
+	bodies := srv.requestsTo("/api/embeddings")
+	require.Len(t, bodies, 1)
+	var req api.EmbeddingRequest
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	assert.Equal(t, "all-minilm", req.Model)
+
+	_, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+	assert.Len(t, srv.requestsTo("/api/embeddings"), 2)
+}
+
+func TestOllamaEmbeddingsWarmupDisabled(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+`)
+	assert.Empty(t, srv.requestsTo("/api/embeddings"))
+
+	newEmbeddingsProcessorFromYAML(t, `
+model: ${! @model }
+server_address: `+srv.URL+`
+warmup: true
+`)
+	assert.Empty(t, srv.requestsTo("/api/embeddings"))
+}
+
+func TestOllamaEmbeddingsWarmupFailure(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	srv.status = func(string) int { return http.StatusForbidden }
+	newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+warmup: true
+`)
+	assert.Len(t, srv.requestsTo("/api/embeddings"), 1)
+}
+
+func TestOllamaEmbeddingsWarmupTimeout(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	srv.rateLimit = func() (string, bool) { return "60", true }
+
+	started := time.Now()
+	newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+max_retries: 3
+warmup: true
+warmup_timeout: 100ms
+`)
+	assert.Less(t, time.Since(started), 10*time.Second)
+	assert.Len(t, srv.requestsTo("/api/embeddings"), 1)
+}
+
+func TestOllamaEmbeddingsEmbeddingType(t *testing.T) {
+	tests := []struct {
+		model         string
+		embeddingType string
+		prompt        string
+	}{
+		{model: "nomic-embed-text", embeddingType: "none", prompt: "hello world"},
+		{model: "nomic-embed-text", embeddingType: "query", prompt: "search_query: hello world"},
+		{model: "nomic-embed-text:v1.5", embeddingType: "document", prompt: "search_document: hello world"},
+		{model: "mxbai-embed-large", embeddingType: "query", prompt: "Represent this sentence for searching relevant passages: hello world"},
+		{model: "mxbai-embed-large", embeddingType: "document", prompt: "hello world"},
+		{model: "snowflake-arctic-embed:335m", embeddingType: "query", prompt: "Represent this sentence for searching relevant passages: hello world"},
+		{model: "snowflake-arctic-embed2", embeddingType: "query", prompt: "query: hello world"},
+		{model: "bge-m3", embeddingType: "query", prompt: "hello world"},
+		{model: "all-minilm", embeddingType: "query", prompt: "hello world"},
+	}
+	for _, test := range tests {
+		t.Run(test.model+"/"+test.embeddingType, func(t *testing.T) {
+			srv := newStubOllamaServer(t, "")
+			proc := newEmbeddingsProcessorFromYAML(t, `
+model: `+test.model+`
+server_address: `+srv.URL+`
+embedding_type: `+test.embeddingType+`
+`)
+
+			_, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+			require.NoError(t, err)
+
+			bodies := srv.requestsTo("/api/embeddings")
+			require.Len(t, bodies, 1)
+			var req api.EmbeddingRequest
+			require.NoError(t, json.Unmarshal(bodies[0], &req))
+			assert.Equal(t, test.prompt, req.Prompt)
+		})
+	}
+}
+
+func TestOllamaEmbeddingsMock(t *testing.T) {
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+server_address: http://localhost:1
+mock: true
+dimensions: 16
+`)
+
+	embed := func(text string) []any {
+		t.Helper()
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte(text)))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		embd, err := batch[0].AsStructured()
+		require.NoError(t, err)
+		return embd.([]any)
+	}
+
+	first := embed("hello world")
+	require.Len(t, first, 16)
+	assert.Equal(t, first, embed("hello world"))
+	assert.NotEqual(t, first, embed("goodbye world"))
+
+	var norm float64
+	for _, v := range first {
+		norm += v.(float64) * v.(float64)
+	}
+	assert.InDelta(t, 1, norm, 1e-9)
+}
+
+func TestOllamaEmbeddingsTexts(t *testing.T) {
+	texts := make([]any, 1000)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("chunk %d", i)
+	}
+	input := func() *service.Message {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]any{"chunks": texts})
+		return msg
+	}
+	decode := func(t *testing.T, msg *service.Message) (embeddings [][]float64) {
+		t.Helper()
+		b, err := msg.AsBytes()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &embeddings))
+		return
+	}
+
+	t.Run("array", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+texts: root = this.chunks
+window_size: 10
+`)
+		batch, err := proc.Process(t.Context(), input())
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+
+		embeddings := decode(t, batch[0])
+		require.Len(t, embeddings, len(texts))
+		for i, e := range embeddings {
+			assert.Equal(t, mockEmbedding(texts[i].(string), 8), e)
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+texts: root = this.chunks.slice(0, 25)
+window_size: 10
+window_output: split
+`)
+		batch, err := proc.Process(t.Context(), input())
+		require.NoError(t, err)
+		require.Len(t, batch, 3)
+
+		for i, expectedLen := range []int{10, 10, 5} {
+			offset, _ := batch[i].MetaGet("ollama_window_offset")
+			assert.Equal(t, strconv.Itoa(i*10), offset)
+
+			embeddings := decode(t, batch[i])
+			require.Len(t, embeddings, expectedLen)
+			for j, e := range embeddings {
+				assert.Equal(t, mockEmbedding(texts[i*10+j].(string), 8), e)
+			}
+		}
+	})
+
+	t.Run("bounded window", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+texts: root = this.chunks
+window_size: 10
+`).(*ollamaEmbeddingProcessor)
+
+		strs := make([]string, len(texts))
+		for i, text := range texts {
+			strs[i] = text.(string)
+		}
+
+		// Every window shares the same backing array, so only a single window
+		// of embeddings is ever held at once.
+		var first *[]float64
+		windows := 0
+		_, err := proc.embedWindows(t.Context(), "nomic-embed-text", strs, func(offset int, window [][]float64) {
+			require.Equal(t, windows*10, offset)
+			require.Len(t, window, 10)
+			require.Equal(t, 10, cap(window))
+			if first == nil {
+				first = &window[0]
+			}
+			require.Same(t, first, &window[0])
+			windows++
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 100, windows)
+	})
+
+	t.Run("invalid texts", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+texts: root = [ "foo", 10 ]
+`)
+		_, err := proc.Process(t.Context(), input())
+		require.ErrorContains(t, err, "expected element 1 of `texts` to be a string")
+	})
+}
+
+func TestOllamaEmbeddingsChunks(t *testing.T) {
+	t.Run("split", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+chunk_size: 4
+chunk_overlap: 1
+window_size: 2
+`)
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte("abcdefghij")))
+		require.NoError(t, err)
+		require.Len(t, batch, 3)
+
+		for i, chunk := range []string{"abcd", "defg", "ghij"} {
+			index, _ := batch[i].MetaGet("ollama_chunk_index")
+			assert.Equal(t, strconv.Itoa(i), index)
+			text, _ := batch[i].MetaGet("ollama_chunk_text")
+			assert.Equal(t, chunk, text)
+
+			b, err := batch[i].AsBytes()
+			require.NoError(t, err)
+			var e []float64
+			require.NoError(t, json.Unmarshal(b, &e))
+			assert.Equal(t, mockEmbedding(chunk, 8), e)
+		}
+	})
+
+	t.Run("array", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+chunk_size: 2
+chunk_overlap: 1
+chunk_unit: tokens
+chunk_output: array
+`)
+		// Tokens are estimated as four characters each regardless of how
+		// many bytes the characters are encoded with.
+		text := strings.Repeat("é", 8) + strings.Repeat("b", 8)
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte(text)))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+
+		b, err := batch[0].AsBytes()
+		require.NoError(t, err)
+		var embeddings [][]float64
+		require.NoError(t, json.Unmarshal(b, &embeddings))
+		require.Len(t, embeddings, 3)
+		for i, chunk := range []string{"éééééééé", "éééébbbb", "bbbbbbbb"} {
+			assert.Equal(t, mockEmbedding(chunk, 8), embeddings[i])
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+chunk_size: 4
+`)
+		batch, err := proc.Process(t.Context(), service.NewMessage(nil))
+		require.NoError(t, err)
+		assert.Empty(t, batch)
+	})
+}
+
+func TestOllamaEmbeddingsChunkConfig(t *testing.T) {
+	for _, test := range []struct {
+		yaml        string
+		errContains string
+	}{
+		{yaml: "chunk_size: 4\nchunk_overlap: 4", errContains: "must be less than `chunk_size`"},
+		{yaml: "chunk_size: 4\ntexts: root = this.chunks", errContains: "cannot both be set"},
+		{yaml: "chunk_size: 4\nmapping: root = this", errContains: "cannot both be set"},
+	} {
+		conf, err := ollamaEmbeddingProcessorConfig().ParseYAML("model: nomic-embed-text\nmock: true\n"+test.yaml, nil)
+		require.NoError(t, err)
+
+		mgr := service.MockResources()
+		license.InjectTestService(mgr)
+
+		_, err = makeOllamaEmbeddingProcessor(conf, mgr)
+		require.Error(t, err, test.yaml)
+		assert.Contains(t, err.Error(), test.errContains)
+	}
+}
+
+func TestOllamaEmbeddingsChunkText(t *testing.T) {
+	tests := []struct {
+		text     string
+		size     int
+		overlap  int
+		expected []string
+	}{
+		{text: "", size: 4, overlap: 1, expected: nil},
+		{text: "abc", size: 4, overlap: 1, expected: []string{"abc"}},
+		{text: "abcd", size: 4, overlap: 1, expected: []string{"abcd"}},
+		{text: "abcde", size: 4, overlap: 1, expected: []string{"abcd", "de"}},
+		{text: "abcdefgh", size: 4, overlap: 0, expected: []string{"abcd", "efgh"}},
+		{text: "abcdefghij", size: 4, overlap: 2, expected: []string{"abcd", "cdef", "efgh", "ghij"}},
+		{text: "abcdef", size: 3, overlap: 2, expected: []string{"abc", "bcd", "cde", "def"}},
+		{text: "héllo wörld", size: 5, overlap: 2, expected: []string{"héllo", "lo wö", "wörld"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, chunkText(test.text, test.size, test.overlap), test.text)
+	}
+}
+
+func TestOllamaEmbeddingsDedup(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	srv.embed = func(prompt string) []float64 {
+		switch prompt {
+		case "hello world":
+			return []float64{1, 0, 0}
+		case "hello world!":
+			return []float64{0.99, 0.01, 0}
+		case "Hello world.":
+			return []float64{0.98, 0, 0.02}
+		}
+		return []float64{0, 1, 0}
+	}
+
+	input := func() service.MessageBatch {
+		var batch service.MessageBatch
+		for _, text := range []string{"hello world", "hello world!", "goodbye world", "Hello world.", "\xff"} {
+			m := service.NewMessage([]byte(text))
+			m.MetaSetMut("text", text)
+			batch = append(batch, m)
+		}
+		return batch
+	}
+	texts := func(t *testing.T, batches []service.MessageBatch) (res []string) {
+		t.Helper()
+		require.Len(t, batches, 1)
+		for _, m := range batches[0] {
+			text, _ := m.MetaGet("text")
+			res = append(res, text)
+		}
+		return
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+server_address: `+srv.URL+`
+error_handling: flag
+`).(*ollamaEmbeddingProcessor)
+		batches, err := proc.ProcessBatch(t.Context(), input())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"hello world", "hello world!", "goodbye world", "Hello world.", "\xff"}, texts(t, batches))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+server_address: `+srv.URL+`
+error_handling: flag
+dedup_threshold: 0.99
+`).(*ollamaEmbeddingProcessor)
+		batches, err := proc.ProcessBatch(t.Context(), input())
+		require.NoError(t, err)
+
+		// Near-duplicates of the first message are dropped, while the flagged
+		// message without an embedding is kept.
+		assert.Equal(t, []string{"hello world", "goodbye world", "\xff"}, texts(t, batches))
+		_, flagged := batches[0][2].MetaGet("ollama_error")
+		assert.True(t, flagged)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+server_address: `+srv.URL+`
+dedup_threshold: 0.99
+`).(*ollamaEmbeddingProcessor)
+		batches, err := proc.ProcessBatch(t.Context(), input())
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		require.Len(t, batches[0], 3)
+		require.Error(t, batches[0][2].GetError())
+	})
+}
+
+func TestOllamaEmbeddingsDedupConfig(t *testing.T) {
+	for _, test := range []struct {
+		yaml        string
+		errContains string
+	}{
+		{yaml: "dedup_threshold: 0", errContains: "must be greater than 0"},
+		{yaml: "dedup_threshold: 1.5", errContains: "at most 1"},
+		{yaml: "dedup_threshold: 0.9\ntexts: root = this.chunks", errContains: "cannot both be set"},
+		{yaml: "dedup_threshold: 0.9\nchunk_size: 4\nchunk_output: array", errContains: "cannot be used when `chunk_output` is `array`"},
+	} {
+		conf, err := ollamaEmbeddingProcessorConfig().ParseYAML("model: nomic-embed-text\nmock: true\n"+test.yaml, nil)
+		require.NoError(t, err)
+
+		mgr := service.MockResources()
+		license.InjectTestService(mgr)
+
+		_, err = makeOllamaEmbeddingProcessor(conf, mgr)
+		require.Error(t, err, test.yaml)
+		assert.Contains(t, err.Error(), test.errContains)
+	}
+}
+
+func TestOllamaCosineSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, cosineSimilarity([]float64{1, 2}, []float64{2, 4}), 1e-9)
+	assert.InDelta(t, 0, cosineSimilarity([]float64{1, 0}, []float64{0, 1}), 1e-9)
+	assert.InDelta(t, -1, cosineSimilarity([]float64{1, 0}, []float64{-1, 0}), 1e-9)
+	assert.Zero(t, cosineSimilarity([]float64{0, 0}, []float64{1, 0}))
+	assert.Zero(t, cosineSimilarity([]float64{1}, []float64{1, 0}))
+}
+
+func TestOllamaEmbeddingsTruncateSide(t *testing.T) {
+	for _, test := range []struct {
+		side     string
+		expected string
+	}{
+		{side: "server", expected: "the start, the middle and the end"},
+		{side: "left", expected: "ddle and the end"},
+		{side: "right", expected: "the start, the m"},
+	} {
+		t.Run(test.side, func(t *testing.T) {
+			srv := newStubOllamaServer(t, "")
+			yaml := `
+model: nomic-embed-text
+server_address: ` + srv.URL + `
+text: "${! content().string() }"
+truncate_side: ` + test.side + "\n"
+			if test.side != "server" {
+				yaml += "truncate_tokens: 4\n"
+			}
+			proc := newEmbeddingsProcessorFromYAML(t, yaml)
+
+			// The text is longer than the 16 bytes estimated for 4 tokens.
+			_, err := proc.Process(t.Context(), service.NewMessage([]byte("the start, the middle and the end")))
+			require.NoError(t, err)
+
+			bodies := srv.requestsTo("/api/embeddings")
+			require.Len(t, bodies, 1)
+			var req api.EmbeddingRequest
+			require.NoError(t, json.Unmarshal(bodies[0], &req))
+			assert.Equal(t, test.expected, req.Prompt)
+		})
+	}
+}
+
+func TestOllamaEmbeddingsTruncateConfig(t *testing.T) {
+	for _, test := range []struct {
+		yaml        string
+		errContains string
+	}{
+		{yaml: "truncate_side: left", errContains: "must be set"},
+		{yaml: "truncate_tokens: 4", errContains: "requires `truncate_side`"},
+		{yaml: "truncate_side: right\ntruncate_tokens: 0", errContains: "must be at least 1"},
+		{yaml: "truncate_side: right\ntruncate_tokens: 4\ntexts: root = this.chunks", errContains: "cannot both be set"},
+	} {
+		conf, err := ollamaEmbeddingProcessorConfig().ParseYAML("model: nomic-embed-text\nmock: true\n"+test.yaml, nil)
+		require.NoError(t, err)
+
+		mgr := service.MockResources()
+		license.InjectTestService(mgr)
+
+		_, err = makeOllamaEmbeddingProcessor(conf, mgr)
+		require.Error(t, err, test.yaml)
+		assert.Contains(t, err.Error(), test.errContains)
+	}
+}
+
+func TestOllamaEmbeddingsTruncateText(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxBytes int
+		side     string
+		expected string
+	}{
+		{text: "abcdef", maxBytes: 4, side: "left", expected: "cdef"},
+		{text: "abcdef", maxBytes: 4, side: "right", expected: "abcd"},
+		{text: "abcdef", maxBytes: 4, side: "server", expected: "abcdef"},
+		{text: "abc", maxBytes: 4, side: "left", expected: "abc"},
+		// Characters that do not fit entirely are discarded.
+		{text: "héllo", maxBytes: 4, side: "left", expected: "llo"},
+		{text: "héllo", maxBytes: 2, side: "right", expected: "h"},
+		{text: "héllo", maxBytes: 3, side: "right", expected: "hé"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, truncateText(test.text, test.maxBytes, test.side), "%s %s", test.text, test.side)
+	}
+}
+
+func TestOllamaEmbeddingsErrorHandling(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("hello world"),
+		{0xff, 0xfe, 0xfd},
+		[]byte("goodbye world"),
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+`)
+		_, err := proc.Process(t.Context(), service.NewMessage(inputs[1]))
+		require.ErrorContains(t, err, "invalid UTF8")
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 8
+error_handling: flag
+`)
+		for i, input := range inputs {
+			batch, err := proc.Process(t.Context(), service.NewMessage(input))
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+
+			reason, flagged := batch[0].MetaGet("ollama_error")
+			if i != 1 {
+				assert.False(t, flagged, "message %d", i)
+				embd, err := batch[0].AsStructured()
+				require.NoError(t, err)
+				assert.Len(t, embd, 8)
+				continue
+			}
+			assert.True(t, flagged)
+			assert.Contains(t, reason, "invalid UTF8")
+			b, err := batch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, input, b)
+		}
+	})
+
+	t.Run("flag texts", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+texts: root = this.texts
+error_handling: flag
+`)
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte(`{"texts":"not an array"}`)))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		reason, _ := batch[0].MetaGet("ollama_error")
+		assert.Contains(t, reason, "expected `texts` to return an array")
+	})
+}
+
+func TestOllamaEmbeddingsValidateOutput(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	srv.embed = func(prompt string) []float64 {
+		if prompt == "broken" {
+			return make([]float64, 4)
+		}
+		return []float64{0.5, 0, 0.25, 0}
+	}
+
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+validate_output: true
+`)
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	embd, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, []any{0.5, 0.0, 0.25, 0.0}, embd)
+
+	_, err = proc.Process(t.Context(), service.NewMessage([]byte("broken")))
+	require.ErrorIs(t, err, errInvalidEmbedding)
+	require.ErrorContains(t, err, "all 4 elements are zero")
+
+	t.Run("flag texts", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+texts: root = this.texts
+validate_output: true
+error_handling: flag
+`)
+		input := []byte(`{"texts":["hello","broken"]}`)
+		batch, err := proc.Process(t.Context(), service.NewMessage(input))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		reason, flagged := batch[0].MetaGet("ollama_error")
+		assert.True(t, flagged)
+		assert.Contains(t, reason, "invalid embedding")
+		b, err := batch[0].AsBytes()
+		require.NoError(t, err)
+		assert.Equal(t, input, b)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+`)
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte("broken")))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		embd, err := batch[0].AsStructured()
+		require.NoError(t, err)
+		assert.Equal(t, []any{0.0, 0.0, 0.0, 0.0}, embd)
+	})
+}
+
+func TestOllamaValidateEmbedding(t *testing.T) {
+	require.NoError(t, validateEmbedding([]float64{0, -0.5, 0}))
+	for _, e := range [][]float64{
+		nil,
+		{0, 0, 0},
+		{0.5, math.NaN(), 0.25},
+		{math.Inf(1), 0.5},
+		{0.5, math.Inf(-1)},
+	} {
+		assert.ErrorIs(t, validateEmbedding(e), errInvalidEmbedding, "%v", e)
+	}
+}
+
+func TestOllamaEmbeddingsMapping(t *testing.T) {
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 16
+mapping: root = this.map_each(v -> [[v * 10, -1].max(), 1].min())
+`)
+
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	embd, err := batch[0].AsStructured()
+	require.NoError(t, err)
+
+	raw := mockEmbedding("hello world", 16)
+	require.Len(t, embd, len(raw))
+	for i, v := range embd.([]any) {
+		assert.InDelta(t, max(min(raw[i]*10, 1), -1), v, 1e-9, "element %d", i)
+	}
+
+	t.Run("with texts", func(t *testing.T) {
+		conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(`
+model: nomic-embed-text
+mock: true
+texts: root = this.texts
+mapping: root = this
+`, nil)
+		require.NoError(t, err)
+		mgr := service.MockResources()
+		license.InjectTestService(mgr)
+		_, err = makeOllamaEmbeddingProcessor(conf, mgr)
+		require.ErrorContains(t, err, "cannot both be set")
+	})
+}
+
+// decodePackedEmbedding reverses the packing of an embedding encoded with
+// base64_float32 or base64_float64.
+func decodePackedEmbedding(t *testing.T, s string, width int) []float64 {
+	t.Helper()
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	require.NoError(t, err)
+	require.Zero(t, len(b)%width)
+
+	e := make([]float64, 0, len(b)/width)
+	for ; len(b) > 0; b = b[width:] {
+		if width == 4 {
+			e = append(e, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		} else {
+			e = append(e, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+	}
+	return e
+}
+
+func TestOllamaEmbeddingsOutputEncoding(t *testing.T) {
+	raw := mockEmbedding("hello world", 16)
+
+	for _, test := range []struct {
+		encoding string
+		width    int
+		delta    float64
+	}{
+		{encoding: "base64_float32", width: 4, delta: 1e-7},
+		{encoding: "base64_float64", width: 8, delta: 0},
+	} {
+		t.Run(test.encoding, func(t *testing.T) {
+			proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 16
+output_encoding: `+test.encoding+`
+`)
+
+			batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+			b, err := batch[0].AsBytes()
+			require.NoError(t, err)
+
+			e := decodePackedEmbedding(t, string(b), test.width)
+			require.Len(t, e, len(raw))
+			assert.InDeltaSlice(t, raw, e, test.delta)
+		})
+	}
+
+	t.Run("with mapping", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 16
+mapping: root = this.map_each(v -> v * 2)
+output_encoding: base64_float64
+`)
+
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte("hello world")))
+		require.NoError(t, err)
+		b, err := batch[0].AsBytes()
+		require.NoError(t, err)
+
+		e := decodePackedEmbedding(t, string(b), 8)
+		require.Len(t, e, len(raw))
+		for i, v := range e {
+			assert.InDelta(t, raw[i]*2, v, 1e-12, "element %d", i)
+		}
+	})
+
+	t.Run("with texts", func(t *testing.T) {
+		proc := newEmbeddingsProcessorFromYAML(t, `
+model: nomic-embed-text
+mock: true
+dimensions: 16
+texts: root = this.texts
+window_size: 1
+output_encoding: base64_float32
+`)
+
+		batch, err := proc.Process(t.Context(), service.NewMessage([]byte(`{"texts":["hello world","goodbye world"]}`)))
+		require.NoError(t, err)
+		require.Len(t, batch, 1)
+		v, err := batch[0].AsStructured()
+		require.NoError(t, err)
+
+		arr, ok := v.([]any)
+		require.True(t, ok)
+		require.Len(t, arr, 2)
+		for i, text := range []string{"hello world", "goodbye world"} {
+			e := decodePackedEmbedding(t, arr[i].(string), 4)
+			assert.InDeltaSlice(t, mockEmbedding(text, 16), e, 1e-7, text)
+		}
+	})
+}
+
+func TestOllamaEmbeddingsOutputPath(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+text: '${! this.title }'
+output_path: vectors.title
+`)
+
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte(`{"title":"hello world","summary":"a greeting","vectors":{"body":[1,2]}}`)))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	doc, err := batch[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"title":   "hello world",
+		"summary": "a greeting",
+		"vectors": map[string]any{
+			"body":  []any{json.Number("1"), json.Number("2")},
+			"title": []any{0.5, 0.25},
+		},
+	}, doc)
+
+	bodies := srv.requestsTo("/api/embeddings")
+	require.Len(t, bodies, 1)
+	var req api.EmbeddingRequest
+	require.NoError(t, json.Unmarshal(bodies[0], &req))
+	assert.Equal(t, "hello world", req.Prompt)
+}
+
+func TestOllamaEmbeddingsOutputPathTexts(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+texts: root = this.chunks
+output_path: embeddings
+output_encoding: base64_float32
+`)
+
+	batch, err := proc.Process(t.Context(), service.NewMessage([]byte(`{"id":"doc-1","chunks":["foo","bar"]}`)))
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	doc, err := batch[0].AsStructured()
+	require.NoError(t, err)
+
+	obj := doc.(map[string]any)
+	assert.Equal(t, "doc-1", obj["id"])
+	assert.Equal(t, []any{"foo", "bar"}, obj["chunks"])
+	embeddings, ok := obj["embeddings"].([]any)
+	require.True(t, ok)
+	require.Len(t, embeddings, 2)
+	for _, e := range embeddings {
+		assert.Equal(t, []float64{0.5, 0.25}, decodePackedEmbedding(t, e.(string), 4))
+	}
+}
+
+func TestOllamaEmbeddingsOutputPathNotObject(t *testing.T) {
+	for _, payload := range []string{"hello world", `["hello world"]`} {
+		t.Run(payload, func(t *testing.T) {
+			srv := newStubOllamaServer(t, "")
+			proc := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+output_path: embedding
+`)
+			_, err := proc.Process(t.Context(), service.NewMessage([]byte(payload)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to set `output_path`")
+
+			flagged := newEmbeddingsProcessorFromYAML(t, `
+model: all-minilm
+server_address: `+srv.URL+`
+output_path: embedding
+error_handling: flag
+`)
+			batch, err := flagged.Process(t.Context(), service.NewMessage([]byte(payload)))
+			require.NoError(t, err)
+			require.Len(t, batch, 1)
+			b, err := batch[0].AsBytes()
+			require.NoError(t, err)
+			assert.Equal(t, payload, string(b))
+			reason, ok := batch[0].MetaGetMut("ollama_error")
+			require.True(t, ok)
+			assert.Contains(t, reason, "unable to set `output_path`")
+
+			// No embeddings are generated for payloads that cannot hold them.
+			assert.Empty(t, srv.requestsTo("/api/embeddings"))
+		})
+	}
+}
+
+type recordingTimer struct {
+	mu      sync.Mutex
+	timings map[string]int
+}
+
+func (r *recordingTimer) Timing(_ int64, labelValues ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings[strings.Join(labelValues, ",")]++
+}
+
+func TestOllamaEmbeddingsRequestLatency(t *testing.T) {
+	srv := newStubOllamaServer(t, "")
+	proc := newEmbeddingsProcessorFromYAML(t, `
+model: ${! @model }
+server_address: `+srv.URL+`
+`)
+	timer := &recordingTimer{timings: map[string]int{}}
+	proc.(*ollamaEmbeddingProcessor).requestLatency = timer
+
+	for _, model := range []string{"all-minilm", "nomic-embed-text", "all-minilm"} {
+		msg := service.NewMessage([]byte("hello world"))
+		msg.MetaSetMut("model", model)
+		_, err := proc.Process(t.Context(), msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, map[string]int{"all-minilm": 2, "nomic-embed-text": 1}, timer.timings)
+}
+
+func TestOllamaEmbeddingsSplitText(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxBytes int
+		expected []string
+	}{
+		{"hello", 10, []string{"hello"}},
+		{"", 10, []string{""}},
+		{"hello world", 8, []string{"hello", "world"}},
+		{"hello   world", 5, []string{"hello", "world"}},
+		{"helloworld", 4, []string{"hell", "owor", "ld"}},
+		{"héllo", 2, []string{"h", "é", "ll", "o"}},
+		{"€", 1, []string{"€"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, splitText(test.text, test.maxBytes), test.text)
+	}
+}
@@ -141,6 +141,18 @@ func (c *runOllamaConfig) downloadOllama(ctx context.Context, path string) error
 	return nil
 }
 
+// serverCommand returns the command that runs the ollama server at serverPath
+// with the environment and arguments of the config.
+func (c *runOllamaConfig) serverCommand(serverPath string) *exec.Cmd {
+	proc := exec.Command(serverPath, append([]string{"serve"}, c.args...)...)
+	// Later entries take precedence over earlier ones with the same key.
+	proc.Env = append(os.Environ(), "OLLAMA_FLASH_ATTENTION=1")
+	proc.Env = append(proc.Env, c.env...)
+	proc.Stdout = &commandOutput{logger: c.logger}
+	proc.Stderr = &commandOutput{logger: c.logger}
+	return proc
+}
+
 var ollamaProcess = singleton.New(singleton.Config[*exec.Cmd]{
 	Constructor: func(ctx context.Context) (*exec.Cmd, error) {
 		cfg, ok := ctx.Value(configKey).(runOllamaConfig)
@@ -167,10 +179,7 @@ var ollamaProcess = singleton.New(singleton.Config[*exec.Cmd]{
 			return nil, err
 		}
 		cfg.logger.Tracef("starting ollama subprocess at %s", serverPath)
-		proc := exec.Command(serverPath, "serve")
-		proc.Env = append(os.Environ() /*"OLLAMA_MODELS="+cfg.cacheDir,*/, "OLLAMA_FLASH_ATTENTION=1")
-		proc.Stdout = &commandOutput{logger: cfg.logger}
-		proc.Stderr = &commandOutput{logger: cfg.logger}
+		proc := cfg.serverCommand(serverPath)
 		if err = proc.Start(); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,121 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package ollama
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestOllamaServerProcessRunnerConfig(t *testing.T) {
+	modelsDir := t.TempDir()
+	conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(`
+model: all-minilm
+runner:
+  models_directory: `+modelsDir+`
+  num_parallel: 4
+  env:
+    OLLAMA_KEEP_ALIVE: 24h
+    OLLAMA_NUM_PARALLEL: "8"
+  args: [ --foo, bar ]
+`, nil)
+	require.NoError(t, err)
+
+	mgr := service.MockResources()
+	env, args, err := serverProcessFromConfig(conf, mgr.FS())
+	require.NoError(t, err)
+
+	// A stand-in for the ollama binary that records its environment and
+	// arguments.
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "out")
+	serverPath := filepath.Join(dir, "ollama")
+	require.NoError(t, os.WriteFile(serverPath, []byte(`#!/bin/sh
+printf '%s\n' "$OLLAMA_MODELS" "$OLLAMA_NUM_PARALLEL" "$OLLAMA_KEEP_ALIVE" "$OLLAMA_FLASH_ATTENTION" "$@" > `+outPath+`
+`), 0o755))
+
+	runConf := runOllamaConfig{
+		logger: mgr.Logger(),
+		env:    env,
+		args:   args,
+	}
+	require.NoError(t, runConf.serverCommand(serverPath).Run())
+
+	out, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+	// Variables set by env take precedence over the other fields.
+	assert.Equal(t, modelsDir+"\n8\n24h\n1\nserve\n--foo\nbar\n", string(out))
+}
+
+func TestOllamaServerProcessRunnerConfigErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	for _, test := range []struct {
+		name        string
+		yaml        string
+		errContains string
+	}{
+		{
+			name: "missing models directory",
+			yaml: `
+model: all-minilm
+runner:
+  models_directory: ` + filepath.Join(t.TempDir(), "missing"),
+			errContains: "runner.models_directory",
+		},
+		{
+			name: "models directory is a file",
+			yaml: `
+model: all-minilm
+runner:
+  models_directory: ` + file,
+			errContains: "is not a directory",
+		},
+		{
+			name: "no parallel requests",
+			yaml: `
+model: all-minilm
+runner:
+  num_parallel: 0
+`,
+			errContains: "runner.num_parallel",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(test.yaml, nil)
+			require.NoError(t, err)
+
+			_, _, err = serverProcessFromConfig(conf, service.MockResources().FS())
+			require.ErrorContains(t, err, test.errContains)
+		})
+	}
+
+	// Nothing is added without a runner config
+	conf, err := ollamaEmbeddingProcessorConfig().ParseYAML(`model: all-minilm`, nil)
+	require.NoError(t, err)
+	env, args, err := serverProcessFromConfig(conf, service.MockResources().FS())
+	require.NoError(t, err)
+	assert.Empty(t, env)
+	assert.Empty(t, args)
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import "errors"
+
+var (
+	errAvroDecimalEmpty    = errors.New("int128: empty avro decimal")
+	errAvroDecimalOverflow = errors.New("int128: avro decimal overflows a 128-bit integer")
+)
+
+// AvroDecimalBytes returns the unscaled value of a decimal as the minimal big
+// endian two's complement bytes expected by the Avro decimal logical type
+// with a bytes representation. Leading bytes that only extend the sign are
+// dropped, such that the result is between 1 and 16 bytes long and zero is
+// encoded as a single zero byte, matching BigInteger.toByteArray in Java.
+func (i Num) AvroDecimalBytes() []byte {
+	b := i.ToBigEndian()
+	n := 0
+	for n < len(b)-1 && (b[n] == 0x00 && b[n+1]&0x80 == 0 || b[n] == 0xff && b[n+1]&0x80 != 0) {
+		n++
+	}
+	return b[n:]
+}
+
+// FromAvroDecimalBytes converts the big endian two's complement bytes of the
+// unscaled value of an Avro decimal into an Int128. The bytes do not need to
+// be minimal, and so the sign extended bytes of a decimal with a fixed
+// representation are also accepted. An error is returned if b is empty or if
+// the value does not fit in an Int128.
+func FromAvroDecimalBytes(b []byte) (Num, error) {
+	if len(b) == 0 {
+		return Num{}, errAvroDecimalEmpty
+	}
+	var sign byte
+	if b[0]&0x80 != 0 {
+		sign = 0xff
+	}
+	if len(b) > 16 {
+		// The surplus bytes, and the top bit of the remaining ones, must only
+		// extend the sign.
+		for _, c := range b[:len(b)-16] {
+			if c != sign {
+				return Num{}, errAvroDecimalOverflow
+			}
+		}
+		b = b[len(b)-16:]
+		if b[0]&0x80 != sign&0x80 {
+			return Num{}, errAvroDecimalOverflow
+		}
+	}
+	var buf [16]byte
+	for j := range len(buf) - len(b) {
+		buf[j] = sign
+	}
+	copy(buf[16-len(b):], b)
+	return FromKey(buf), nil
+}
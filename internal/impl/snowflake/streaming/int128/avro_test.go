@@ -0,0 +1,129 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvroDecimalBytes(t *testing.T) {
+	tests := []struct {
+		n        Num
+		expected []byte
+	}{
+		{FromInt64(0), []byte{0x00}},
+		{FromInt64(1), []byte{0x01}},
+		{FromInt64(-1), []byte{0xff}},
+		{FromInt64(127), []byte{0x7f}},
+		{FromInt64(128), []byte{0x00, 0x80}},
+		{FromInt64(-128), []byte{0x80}},
+		{FromInt64(-129), []byte{0xff, 0x7f}},
+		{FromInt64(256), []byte{0x01, 0x00}},
+		{FromInt64(-256), []byte{0xff, 0x00}},
+		{MaxInt64, []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{MinInt64, []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{Add(MaxInt64, one), []byte{0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{MaxInt128, MaxInt128.ToBigEndian()},
+		{MinInt128, MinInt128.ToBigEndian()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.n.String(), func(t *testing.T) {
+			b := tc.n.AvroDecimalBytes()
+			require.Equal(t, tc.expected, b)
+			n, err := FromAvroDecimalBytes(b)
+			require.NoError(t, err)
+			require.Equal(t, tc.n, n)
+		})
+	}
+}
+
+func TestFromAvroDecimalBytes(t *testing.T) {
+	// Sign extended bytes, such as those of a fixed representation.
+	for _, tc := range []struct {
+		b        []byte
+		expected Num
+	}{
+		{[]byte{0x00, 0x00, 0x01}, FromInt64(1)},
+		{[]byte{0xff, 0xff, 0xff}, FromInt64(-1)},
+		{append(make([]byte, 8), MaxInt128.ToBigEndian()...), MaxInt128},
+		{append([]byte{0xff, 0xff, 0xff, 0xff}, MinInt128.ToBigEndian()...), MinInt128},
+	} {
+		n, err := FromAvroDecimalBytes(tc.b)
+		require.NoError(t, err, "%x", tc.b)
+		require.Equal(t, tc.expected, n, "%x", tc.b)
+	}
+
+	for _, b := range [][]byte{
+		// 2^127
+		append([]byte{0x00, 0x80}, make([]byte, 15)...),
+		// -2^127 - 1
+		append([]byte{0xff, 0x7f}, make([]byte, 15)...),
+		append([]byte{0x01}, make([]byte, 16)...),
+		append([]byte{0xfe}, make([]byte, 16)...),
+	} {
+		_, err := FromAvroDecimalBytes(b)
+		require.ErrorIs(t, err, errAvroDecimalOverflow, "%x", b)
+	}
+
+	_, err := FromAvroDecimalBytes(nil)
+	require.ErrorIs(t, err, errAvroDecimalEmpty)
+}
+
+func TestAvroDecimalBytesGoavro(t *testing.T) {
+	bytesCodec, err := goavro.NewCodec(`{"type":"bytes","logicalType":"decimal","precision":38,"scale":2}`)
+	require.NoError(t, err)
+	fixedCodec, err := goavro.NewCodec(`{"type":"fixed","name":"d","size":20,"logicalType":"decimal","precision":38,"scale":2}`)
+	require.NoError(t, err)
+
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 10_000 {
+		n := Rand(r)
+		if r.IntN(2) == 0 {
+			// Favour values of a smaller magnitude too.
+			n = Div(n, Shl(one, uint(r.IntN(127))))
+		}
+		rat := new(big.Rat).SetFrac(n.bigInt(), big.NewInt(100))
+
+		// Encoding matches that of goavro, where bytes are prefixed by their
+		// varint encoded length.
+		b := n.AvroDecimalBytes()
+		expected, err := bytesCodec.BinaryFromNative(nil, rat)
+		require.NoError(t, err)
+		require.Equal(t, expected, append(binary.AppendVarint(nil, int64(len(b))), b...), "%v", n)
+
+		// The encoding can be decoded by goavro.
+		native, _, err := bytesCodec.NativeFromBinary(expected)
+		require.NoError(t, err)
+		require.Zero(t, rat.Cmp(native.(*big.Rat)), "%v", n)
+
+		// Bytes sign extended to the size of a fixed representation are
+		// decoded by goavro and by FromAvroDecimalBytes to the same value.
+		fixed := make([]byte, 20-len(b), 20)
+		if n.IsNegative() {
+			for j := range fixed {
+				fixed[j] = 0xff
+			}
+		}
+		fixed = append(fixed, b...)
+		native, _, err = fixedCodec.NativeFromBinary(fixed)
+		require.NoError(t, err)
+		require.Zero(t, rat.Cmp(native.(*big.Rat)), "%v", n)
+		decoded, err := FromAvroDecimalBytes(fixed)
+		require.NoError(t, err)
+		require.Equal(t, n, decoded)
+	}
+}
@@ -26,6 +26,8 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strconv"
+	"strings"
 )
 
 // FitsInPrecision returns true or false if the value currently held by
@@ -33,7 +35,7 @@ import (
 func (i Num) FitsInPrecision(prec int32) bool {
 	if prec == 0 {
 		// Precision 0 is valid in snowflake, even if it seems useless
-		return i == Num{}
+		return i.IsZero()
 	}
 	// The abs call does nothing for this value, so we need to handle it properly
 	if i == MinInt128 {
@@ -42,6 +44,41 @@ func (i Num) FitsInPrecision(prec int32) bool {
 	return Less(i.Abs(), Pow10Table[prec])
 }
 
+// NumDigits returns the number of decimal digits in the magnitude of the
+// number, which is 1 for zero. Up to 38 digits this is the smallest precision
+// that the number fits in.
+func (i Num) NumDigits() int {
+	if i.IsZero() {
+		return 1
+	}
+	// The absolute value of MinInt128 overflows back to itself, which is
+	// still correct when interpreted as an unsigned number.
+	mag := i.Abs()
+	// Estimate the number of digits from the bit length using 1233/4096 as an
+	// approximation of log10(2), which is either exact or one too few.
+	d := ((fls128(mag) + 1) * 1233) >> 12
+	if d < len(Pow10Table) && CompareUnsigned(mag, Pow10Table[d]) < 0 {
+		return d
+	}
+	return d + 1
+}
+
+// ValidateDecimal returns an error if the unscaled value n does not fit within
+// a NUMBER(precision, scale) column, or if the precision and scale do not
+// describe a valid column.
+func ValidateDecimal(n Num, precision, scale int32) error {
+	if precision < 0 || precision > 38 {
+		return fmt.Errorf("invalid precision %d: must be between 0 and 38", precision)
+	}
+	if scale < 0 || scale > precision {
+		return fmt.Errorf("invalid scale %d for NUMBER(%d,%d): must be between 0 and the precision", scale, precision, scale)
+	}
+	if !n.FitsInPrecision(precision) {
+		return fmt.Errorf("value %s with scale %d does not fit in NUMBER(%d,%d): the unscaled value must have at most %d digits", n.String(), scale, precision, scale, precision)
+	}
+	return nil
+}
+
 func scalePositiveFloat64(v float64, prec, scale int32) (float64, error) {
 	var pscale float64
 	if scale >= -38 && scale <= 38 {
@@ -127,6 +164,50 @@ func FromFloat64(v float64, prec, scale int32) (Num, error) {
 	return fromPositiveFloat64(v, prec, scale)
 }
 
+// FromFloat64Scaled returns the unscaled value of f at the given scale, so 1.5
+// at a scale of 2 is 150, rounding any discarded digits using mode. The
+// returned bool is false if f is NaN or infinite, or if the result overflows
+// an int128.
+//
+// Unlike FromFloat64 the conversion is exact, however a float64 can only hold
+// binary fractions and so most decimal values are approximations, which can
+// affect how ties round. For example 2.675 is held as
+// 2.67499999999999982236431605997495353221893310546875, which rounds to 267
+// at a scale of 2 with any rounding mode.
+func FromFloat64Scaled(f float64, scale int32, mode RoundingMode) (Num, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Num{}, false
+	}
+	if f == 0 {
+		return Num{}, true
+	}
+	// The magnitude of a non-zero float64 is between roughly 4.9e-324 and
+	// 1.8e308, so beyond these scales the result either overflows or rounds
+	// to zero.
+	switch {
+	case scale > 38+324:
+		return Num{}, false
+	case scale < -309:
+		return Num{}, true
+	}
+	r := new(big.Rat).SetFloat64(f)
+	if scale >= 0 {
+		r = r.Mul(r, new(big.Rat).SetInt(pow10BigInt(int64(scale))))
+	} else {
+		r = r.Quo(r, new(big.Rat).SetInt(pow10BigInt(-int64(scale))))
+	}
+	var rem big.Int
+	q, _ := new(big.Int).QuoRem(r.Num(), r.Denom(), &rem)
+	if roundAwayFromZero(q, &rem, r.Denom(), mode) {
+		if rem.Sign() < 0 {
+			q = q.Sub(q, big.NewInt(1))
+		} else {
+			q = q.Add(q, big.NewInt(1))
+		}
+	}
+	return bigInt(q)
+}
+
 var pt5 = big.NewFloat(0.5)
 
 // FromString converts a string into an Int128 as long as it fits within the given precision and scale.
@@ -312,3 +393,343 @@ var float64PowersOfTen = [...]float64{
 	1e22, 1e23, 1e24, 1e25, 1e26, 1e27, 1e28, 1e29, 1e30, 1e31,
 	1e32, 1e33, 1e34, 1e35, 1e36, 1e37, 1e38,
 }
+
+// RoundingMode controls how digits are discarded when a value is rescaled to
+// a smaller scale.
+type RoundingMode int
+
+const (
+	// RoundHalfAwayFromZero rounds to the nearest value, with ties rounded
+	// away from zero. This matches how Snowflake rounds numbers.
+	RoundHalfAwayFromZero RoundingMode = iota
+	// RoundHalfEven rounds to the nearest value, with ties rounded to the
+	// nearest even value (also known as banker's rounding).
+	RoundHalfEven
+	// RoundTowardZero discards any extra digits.
+	RoundTowardZero
+)
+
+// MulScaled multiplies the decimal a (with scale aScale) by the decimal b
+// (with scale bScale) and returns the result at resultScale, rounding any
+// discarded digits using mode. The returned bool is false if the result
+// overflows an int128.
+func MulScaled(a Num, aScale int32, b Num, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	product := a.bigInt()
+	product = product.Mul(product, b.bigInt())
+	if product.Sign() == 0 {
+		return Num{}, true
+	}
+	// The product of two int128 values has at most 77 digits, so any shift
+	// larger than that either overflows or rounds to zero.
+	const maxShift = 2*38 + 1
+	shift := int64(resultScale) - int64(aScale) - int64(bScale)
+	return shiftBigInt(product, shift, maxShift, mode)
+}
+
+// AddScaled adds the decimal a (with scale aScale) to the decimal b (with
+// scale bScale) and returns the result at resultScale, rounding any discarded
+// digits using mode. The operands are aligned to a common scale before being
+// added, so the result is only rounded once. The returned bool is false if the
+// result overflows an int128.
+func AddScaled(a Num, aScale int32, b Num, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	return addScaledBigInt(a.bigInt(), aScale, b.bigInt(), bScale, resultScale, mode)
+}
+
+// SubScaled subtracts the decimal b (with scale bScale) from the decimal a
+// (with scale aScale) and returns the result at resultScale, in the same way
+// as AddScaled.
+func SubScaled(a Num, aScale int32, b Num, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	nb := b.bigInt()
+	return addScaledBigInt(a.bigInt(), aScale, nb.Neg(nb), bScale, resultScale, mode)
+}
+
+func addScaledBigInt(a *big.Int, aScale int32, b *big.Int, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	if aScale > bScale {
+		a, aScale, b, bScale = b, bScale, a, aScale
+	}
+	// An int128 has at most 39 digits, so when the scales are further apart
+	// than this b is smaller than a single unit of a by more than 40 orders of
+	// magnitude. Such a difference never reaches the digits that are kept
+	// without the result overflowing, and so only the sign of b can affect
+	// rounding, which avoids aligning the operands with huge powers of ten.
+	const maxScaleGap = 80
+	switch {
+	case b.Sign() == 0:
+		bScale = aScale
+	case a.Sign() == 0:
+		aScale = bScale
+	case int64(bScale)-int64(aScale) > maxScaleGap:
+		b = big.NewInt(int64(b.Sign()))
+		bScale = aScale + maxScaleGap
+	}
+	sum := a.Mul(a, pow10BigInt(int64(bScale)-int64(aScale)))
+	sum = sum.Add(sum, b)
+	if sum.Sign() == 0 {
+		return Num{}, true
+	}
+	// The aligned sum has at most 39+80+1 digits.
+	const maxShift = 39 + maxScaleGap + 1
+	return shiftBigInt(sum, int64(resultScale)-int64(bScale), maxShift, mode)
+}
+
+// shiftBigInt multiplies v by 10^shift, rounding any discarded digits using
+// mode. The magnitude of v must have fewer than maxShift digits.
+func shiftBigInt(v *big.Int, shift, maxShift int64, mode RoundingMode) (Num, bool) {
+	switch {
+	case shift > maxShift:
+		return Num{}, false
+	case shift > 0:
+		v = v.Mul(v, pow10BigInt(shift))
+	case shift < -maxShift:
+		return Num{}, true
+	case shift < 0:
+		divisor := pow10BigInt(-shift)
+		var rem big.Int
+		v.QuoRem(v, divisor, &rem)
+		if roundAwayFromZero(v, &rem, divisor, mode) {
+			if rem.Sign() < 0 {
+				v = v.Sub(v, big.NewInt(1))
+			} else {
+				v = v.Add(v, big.NewInt(1))
+			}
+		}
+	}
+	return bigInt(v)
+}
+
+func pow10BigInt(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+// roundAwayFromZero returns whether the truncated quotient q needs to be moved
+// away from zero given the remainder rem of a division by divisor.
+func roundAwayFromZero(q, rem, divisor *big.Int, mode RoundingMode) bool {
+	if rem.Sign() == 0 || mode == RoundTowardZero {
+		return false
+	}
+	twiceRem := new(big.Int).Abs(rem)
+	twiceRem = twiceRem.Lsh(twiceRem, 1)
+	switch twiceRem.Cmp(divisor) {
+	case 1:
+		return true
+	case 0:
+		return mode == RoundHalfAwayFromZero || q.Bit(0) == 1
+	}
+	return false
+}
+
+// ParseDecimal parses a plain decimal string such as "-12.345" and returns its
+// unscaled value at the given scale, so "12.34" with a scale of 2 is 1234.
+// Missing fractional digits are padded with zeros and any fractional digits
+// beyond scale are discarded according to mode. The returned bool is false if
+// the string is malformed or the result overflows an int128. Unlike
+// FromString, exponents are not accepted.
+func ParseDecimal(s string, scale int32, mode RoundingMode) (Num, bool) {
+	neg := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(whole)+len(frac) == 0 || !isDigits(whole) || !isDigits(frac) {
+		return Num{}, false
+	}
+	digits := strings.TrimLeft(whole+frac, "0")
+	if digits == "" {
+		return Num{}, true
+	}
+	v, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Num{}, false
+	}
+	if neg {
+		v = v.Neg(v)
+	}
+	shift := int64(scale) - int64(len(frac))
+	switch {
+	case shift > 38:
+		// Any non-zero value shifted this far overflows.
+		return Num{}, false
+	case shift > 0:
+		v = v.Mul(v, pow10BigInt(shift))
+	case shift < -int64(len(digits)):
+		// Every digit is discarded and the remainder is less than half the
+		// divisor, so the result rounds to zero in all modes.
+		return Num{}, true
+	case shift < 0:
+		divisor := pow10BigInt(-shift)
+		var rem big.Int
+		v.QuoRem(v, divisor, &rem)
+		if roundAwayFromZero(v, &rem, divisor, mode) {
+			if rem.Sign() < 0 {
+				v = v.Sub(v, big.NewInt(1))
+			} else {
+				v = v.Add(v, big.NewInt(1))
+			}
+		}
+	}
+	return bigInt(v)
+}
+
+// ParseScientific parses an integer that may be written in scientific notation
+// such as "1.5e20" or "-25E-1", where the mantissa may contain a fractional
+// part and the exponent is optional. The returned bool is false if the string
+// is malformed, the value is not a whole number such as "1.5e-1", or the
+// result overflows an int128. Fractional digits that are zeros, as in
+// "1.50e1" or "100e-2", do not make the value fractional.
+func ParseScientific(s string) (Num, bool) {
+	mantissa, exp, hasExp := strings.Cut(s, "e")
+	if !hasExp {
+		mantissa, exp, hasExp = strings.Cut(s, "E")
+	}
+	neg := false
+	if len(mantissa) > 0 && (mantissa[0] == '-' || mantissa[0] == '+') {
+		neg = mantissa[0] == '-'
+		mantissa = mantissa[1:]
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if len(whole)+len(frac) == 0 || !isDigits(whole) || !isDigits(frac) {
+		return Num{}, false
+	}
+	exponent := 0
+	if hasExp {
+		digits := exp
+		if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+			digits = digits[1:]
+		}
+		if digits == "" || !isDigits(digits) {
+			return Num{}, false
+		}
+		e, err := strconv.ParseInt(exp, 10, 32)
+		if err != nil {
+			// Only zero survives an exponent this large in either direction.
+			if strings.Trim(whole+frac, "0") == "" {
+				return Num{}, true
+			}
+			return Num{}, false
+		}
+		exponent = int(e)
+	}
+	// Trailing zeros are folded into the exponent so that they do not make the
+	// value appear fractional.
+	digits := strings.TrimLeft(whole+frac, "0")
+	trimmed := strings.TrimRight(digits, "0")
+	if trimmed == "" {
+		return Num{}, true
+	}
+	exponent += len(digits) - len(trimmed) - len(frac)
+	if exponent < 0 || len(trimmed)+exponent > 39 {
+		// Any remaining fractional digit is non-zero, and a 40 digit value
+		// always overflows.
+		return Num{}, false
+	}
+	v, ok := new(big.Int).SetString(trimmed, 10)
+	if !ok {
+		return Num{}, false
+	}
+	if neg {
+		v = v.Neg(v)
+	}
+	return bigInt(v.Mul(v, pow10BigInt(int64(exponent))))
+}
+
+func isDigits(s string) bool {
+	for _, ch := range []byte(s) {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// RoundSignificant rounds the decimal v (with the given scale) to at most
+// sigDigits significant digits, discarding digits according to mode, and
+// returns the rounded unscaled value along with its new scale. For example
+// 123456 at scale 3 (123.456) rounded to 4 significant digits is 1235 at
+// scale 1 (123.5). Values that already have no more than sigDigits digits are
+// returned unchanged. The returned bool is false if sigDigits is less than one
+// or the new scale overflows an int32.
+func RoundSignificant(v Num, scale, sigDigits int32, mode RoundingMode) (Num, int32, bool) {
+	if sigDigits < 1 {
+		return Num{}, 0, false
+	}
+	n := v.bigInt()
+	digits := int64(len(new(big.Int).Abs(n).String()))
+	if n.Sign() == 0 || digits <= int64(sigDigits) {
+		return v, scale, true
+	}
+	drop := digits - int64(sigDigits)
+	divisor := pow10BigInt(drop)
+	var rem big.Int
+	n.QuoRem(n, divisor, &rem)
+	if roundAwayFromZero(n, &rem, divisor, mode) {
+		if rem.Sign() < 0 {
+			n = n.Sub(n, big.NewInt(1))
+		} else {
+			n = n.Add(n, big.NewInt(1))
+		}
+		// Rounding up can carry into a new leading digit, such as 999 to
+		// 1000, in which case the trailing zero is also dropped.
+		if int64(len(new(big.Int).Abs(n).String())) > int64(sigDigits) {
+			n = n.Quo(n, big.NewInt(10))
+			drop++
+		}
+	}
+	newScale := int64(scale) - drop
+	if newScale < math.MinInt32 {
+		return Num{}, 0, false
+	}
+	// The rounded value has fewer digits than v and so always fits.
+	r, _ := bigInt(n)
+	return r, int32(newScale), true
+}
+
+// AsDecimalComponents returns the decimal i (with the given scale) as a
+// coefficient and exponent such that its value is coefficient * 10^exponent,
+// which is how decimal libraries such as shopspring/decimal represent values.
+// Trailing zeros are removed from the coefficient so that equal values always
+// have equal components, for example 1200 at scale 3 (1.2) is 12 with an
+// exponent of -1. The conversion is lossless and can be reversed with
+// FromDecimalComponents. Trailing zeros are kept once the exponent reaches
+// math.MaxInt32, and the scale must be greater than math.MinInt32.
+func (i Num) AsDecimalComponents(scale int32) (coefficient Num, exponent int32) {
+	coefficient, exponent = i, -scale
+	if i.IsZero() {
+		return coefficient, 0
+	}
+	for exponent < math.MaxInt32 {
+		q := Div(coefficient, ten)
+		if !Sub(coefficient, Mul(q, ten)).IsZero() {
+			return coefficient, exponent
+		}
+		coefficient = q
+		exponent++
+	}
+	return coefficient, exponent
+}
+
+// FromDecimalComponents returns coeff * 10^exponent, which is the unscaled
+// value of a decimal with a coefficient and exponent at a scale of zero. In
+// order to obtain the unscaled value at another scale add the scale to the
+// exponent. The returned bool is false if the result overflows an int128, or
+// if the exponent is negative and discarding digits would lose precision.
+func FromDecimalComponents(coeff Num, exponent int32) (Num, bool) {
+	if coeff.IsZero() || exponent == 0 {
+		return coeff, true
+	}
+	// A non-zero int128 has at most 39 digits, so any shift larger than that
+	// either overflows or discards non-zero digits.
+	if exponent > 39 || exponent < -39 {
+		return Num{}, false
+	}
+	v := coeff.bigInt()
+	if exponent > 0 {
+		return bigInt(v.Mul(v, pow10BigInt(int64(exponent))))
+	}
+	var rem big.Int
+	v.QuoRem(v, pow10BigInt(int64(-exponent)), &rem)
+	if rem.Sign() != 0 {
+		return Num{}, false
+	}
+	return bigInt(v)
+}
@@ -469,3 +469,725 @@ func computeDecimalParameters(str string) (digitCount, leadingDigits int32) {
 	}
 	return
 }
+
+// ratScale returns 10^-scale as a big.Rat.
+func ratScale(scale int32) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(scale, -scale))), nil)
+	if scale < 0 {
+		return new(big.Rat).SetInt(p)
+	}
+	return new(big.Rat).SetFrac(big.NewInt(1), p)
+}
+
+// ratRound rounds r to an integer using mode.
+func ratRound(r *big.Rat, mode RoundingMode) (Num, bool) {
+	whole := new(big.Int).Quo(r.Num(), r.Denom())
+	frac := new(big.Rat).Sub(r, new(big.Rat).SetInt(whole))
+	frac = frac.Abs(frac)
+	away := false
+	switch frac.Cmp(big.NewRat(1, 2)) {
+	case 1:
+		away = mode != RoundTowardZero
+	case 0:
+		away = mode == RoundHalfAwayFromZero || (mode == RoundHalfEven && whole.Bit(0) == 1)
+	}
+	if away {
+		if r.Sign() < 0 {
+			whole = whole.Sub(whole, big.NewInt(1))
+		} else {
+			whole = whole.Add(whole, big.NewInt(1))
+		}
+	}
+	return bigInt(whole)
+}
+
+// ratMulScaled computes MulScaled using big.Rat as a reference implementation.
+func ratMulScaled(a Num, aScale int32, b Num, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt(a.bigInt()), ratScale(aScale))
+	r = r.Mul(r, new(big.Rat).SetInt(b.bigInt()))
+	r = r.Mul(r, ratScale(bScale))
+	r = r.Quo(r, ratScale(resultScale))
+	return ratRound(r, mode)
+}
+
+// ratAddScaled computes AddScaled using big.Rat as a reference implementation.
+func ratAddScaled(a Num, aScale int32, b Num, bScale int32, resultScale int32, mode RoundingMode) (Num, bool) {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt(a.bigInt()), ratScale(aScale))
+	r = r.Add(r, new(big.Rat).Mul(new(big.Rat).SetInt(b.bigInt()), ratScale(bScale)))
+	r = r.Quo(r, ratScale(resultScale))
+	return ratRound(r, mode)
+}
+
+func TestMulScaled(t *testing.T) {
+	tests := []struct {
+		a           string
+		aScale      int32
+		b           string
+		bScale      int32
+		resultScale int32
+		mode        RoundingMode
+		expected    string
+		ok          bool
+	}{
+		// 19.99 * 0.0825 = 1.649175
+		{"1999", 2, "825", 4, 2, RoundHalfAwayFromZero, "165", true},
+		{"1999", 2, "825", 4, 2, RoundTowardZero, "164", true},
+		{"1999", 2, "825", 4, 6, RoundHalfEven, "1649175", true},
+		{"1999", 2, "825", 4, 8, RoundHalfEven, "164917500", true},
+		{"-1999", 2, "825", 4, 2, RoundHalfAwayFromZero, "-165", true},
+		{"-1999", 2, "825", 4, 2, RoundTowardZero, "-164", true},
+		// Ties
+		{"5", 1, "1", 0, 0, RoundHalfAwayFromZero, "1", true},
+		{"5", 1, "1", 0, 0, RoundHalfEven, "0", true},
+		{"5", 1, "1", 0, 0, RoundTowardZero, "0", true},
+		{"-5", 1, "1", 0, 0, RoundHalfAwayFromZero, "-1", true},
+		{"-5", 1, "1", 0, 0, RoundHalfEven, "0", true},
+		{"15", 1, "1", 0, 0, RoundHalfEven, "2", true},
+		{"-15", 1, "1", 0, 0, RoundHalfEven, "-2", true},
+		{"25", 1, "1", 0, 0, RoundHalfEven, "2", true},
+		// Negative scales
+		{"12", -2, "3", 0, 0, RoundHalfEven, "3600", true},
+		{"1234", 0, "1", 0, -2, RoundHalfAwayFromZero, "12", true},
+		// Overflow
+		{MaxInt128.String(), 0, "2", 0, 0, RoundHalfEven, "0", false},
+		{MaxInt128.String(), 0, "2", 0, -1, RoundHalfEven, "34028236692093846346337460743176821145", true},
+		{MaxInt128.String(), 0, MaxInt128.String(), 0, 38, RoundHalfEven, "0", false},
+		{"1", 0, "1", 0, 100, RoundHalfEven, "0", false},
+		{"0", 0, "1", 0, 100, RoundHalfEven, "0", true},
+		{"1", 0, "1", 0, -100, RoundHalfAwayFromZero, "0", true},
+		{MinInt128.String(), 0, "1", 0, 0, RoundHalfEven, MinInt128.String(), true},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			a := MustParse(tc.a)
+			b := MustParse(tc.b)
+			actual, ok := MulScaled(a, tc.aScale, b, tc.bScale, tc.resultScale, tc.mode)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+			expected, expectedOk := ratMulScaled(a, tc.aScale, b, tc.bScale, tc.resultScale, tc.mode)
+			require.Equal(t, expectedOk, ok)
+			if ok {
+				require.Equal(t, expected, actual)
+			}
+		})
+	}
+}
+
+func TestMulScaledRandomized(t *testing.T) {
+	randNum := func() Num {
+		n := New(rand.Int64N(1<<20), rand.Uint64())
+		switch rand.N(3) {
+		case 0:
+			n = FromInt64(rand.Int64())
+		case 1:
+			n = FromInt64(rand.Int64N(1_000_000))
+		}
+		if rand.N(2) == 0 {
+			n = Neg(n)
+		}
+		return n
+	}
+	for range 10000 {
+		a, b := randNum(), randNum()
+		aScale, bScale := int32(rand.N(20)), int32(rand.N(20))
+		resultScale := int32(rand.N(40))
+		mode := RoundingMode(rand.N(3))
+		actual, ok := MulScaled(a, aScale, b, bScale, resultScale, mode)
+		expected, expectedOk := ratMulScaled(a, aScale, b, bScale, resultScale, mode)
+		require.Equal(t, expectedOk, ok, "%s(%d) * %s(%d) at scale %d", a, aScale, b, bScale, resultScale)
+		if ok {
+			require.Equal(t, expected, actual, "%s(%d) * %s(%d) at scale %d", a, aScale, b, bScale, resultScale)
+		}
+	}
+}
+
+func TestAddScaled(t *testing.T) {
+	tests := []struct {
+		a           string
+		aScale      int32
+		b           string
+		bScale      int32
+		resultScale int32
+		mode        RoundingMode
+		expected    string
+		ok          bool
+	}{
+		// 1.5 + 0.25 = 1.75
+		{"15", 1, "25", 2, 2, RoundHalfEven, "175", true},
+		{"25", 2, "15", 1, 2, RoundHalfEven, "175", true},
+		{"15", 1, "25", 2, 4, RoundHalfEven, "17500", true},
+		// Aligning requires rounding: 1.5 + 0.25 at scale 1
+		{"15", 1, "25", 2, 1, RoundHalfAwayFromZero, "18", true},
+		{"15", 1, "25", 2, 1, RoundHalfEven, "18", true},
+		{"15", 1, "25", 2, 1, RoundTowardZero, "17", true},
+		{"15", 1, "-25", 2, 1, RoundHalfAwayFromZero, "13", true},
+		{"15", 1, "-25", 2, 1, RoundHalfEven, "12", true},
+		{"-15", 1, "-25", 2, 1, RoundHalfAwayFromZero, "-18", true},
+		{"-15", 1, "-25", 2, 1, RoundTowardZero, "-17", true},
+		// Cancellation
+		{"1", 0, "-10", 1, 5, RoundHalfEven, "0", true},
+		{"0", 0, "0", 0, 0, RoundHalfEven, "0", true},
+		// Negative scales: 1200 + 34 = 1234
+		{"12", -2, "34", 0, 0, RoundHalfEven, "1234", true},
+		{"12", -2, "34", 0, -1, RoundHalfEven, "123", true},
+		// Operands with very different scales only affect rounding by their
+		// sign: 1 - 10^-1000 at scale 0
+		{"1", 0, "-1", 1000, 0, RoundTowardZero, "0", true},
+		{"1", 0, "-1", 1000, 0, RoundHalfEven, "1", true},
+		{"1", 0, "1", 1000, 0, RoundTowardZero, "1", true},
+		{"5", 1, "1", 1000, 0, RoundHalfEven, "1", true},
+		{"5", 1, "-1", 1000, 0, RoundHalfAwayFromZero, "0", true},
+		{"1", 0, "1", 1000, 38, RoundHalfEven, "100000000000000000000000000000000000000", true},
+		{"1", 0, "1", 1000, 39, RoundHalfEven, "0", false},
+		{"0", 0, "7", 1000, 0, RoundHalfAwayFromZero, "0", true},
+		{"7", 1000, "0", 0, 1000, RoundHalfAwayFromZero, "7", true},
+		// Overflow
+		{MaxInt128.String(), 0, "1", 0, 0, RoundHalfEven, "0", false},
+		{MaxInt128.String(), 0, "1", 0, -1, RoundHalfEven, "17014118346046923173168730371588410573", true},
+		{MinInt128.String(), 0, "-1", 0, 0, RoundHalfEven, "0", false},
+		{MaxInt128.String(), 0, MinInt128.String(), 0, 0, RoundHalfEven, "-1", true},
+		{"1", 0, "1", 0, 100, RoundHalfEven, "0", false},
+		{"1", 0, "1", 0, -100, RoundHalfAwayFromZero, "0", true},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			a := MustParse(tc.a)
+			b := MustParse(tc.b)
+			actual, ok := AddScaled(a, tc.aScale, b, tc.bScale, tc.resultScale, tc.mode)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+			expected, expectedOk := ratAddScaled(a, tc.aScale, b, tc.bScale, tc.resultScale, tc.mode)
+			require.Equal(t, expectedOk, ok)
+			if ok {
+				require.Equal(t, expected, actual)
+			}
+		})
+	}
+}
+
+func TestSubScaled(t *testing.T) {
+	// 1.5 - 0.25 = 1.25
+	n, ok := SubScaled(FromInt64(15), 1, FromInt64(25), 2, 2, RoundHalfEven)
+	require.True(t, ok)
+	require.Equal(t, "125", n.String())
+	n, ok = SubScaled(FromInt64(15), 1, FromInt64(25), 2, 1, RoundHalfEven)
+	require.True(t, ok)
+	require.Equal(t, "12", n.String())
+
+	// Subtracting MinInt128 does not overflow when the result fits.
+	n, ok = SubScaled(FromInt64(-1), 0, MinInt128, 0, 0, RoundHalfEven)
+	require.True(t, ok)
+	require.Equal(t, MaxInt128, n)
+	_, ok = SubScaled(FromInt64(0), 0, MinInt128, 0, 0, RoundHalfEven)
+	require.False(t, ok)
+}
+
+func TestAddScaledRandomized(t *testing.T) {
+	randNum := func() Num {
+		n := New(rand.Int64N(1<<20), rand.Uint64())
+		switch rand.N(4) {
+		case 0:
+			n = FromInt64(rand.Int64())
+		case 1:
+			n = FromInt64(rand.Int64N(1_000_000))
+		case 2:
+			n = MaxInt128
+		}
+		if rand.N(2) == 0 {
+			n = Neg(n)
+		}
+		return n
+	}
+	randScale := func() int32 {
+		if rand.N(10) == 0 {
+			return int32(rand.N(300)) - 100
+		}
+		return int32(rand.N(40)) - 5
+	}
+	for range 10000 {
+		a, b := randNum(), randNum()
+		aScale, bScale, resultScale := randScale(), randScale(), randScale()
+		mode := RoundingMode(rand.N(3))
+		actual, ok := AddScaled(a, aScale, b, bScale, resultScale, mode)
+		expected, expectedOk := ratAddScaled(a, aScale, b, bScale, resultScale, mode)
+		require.Equal(t, expectedOk, ok, "%s(%d) + %s(%d) at scale %d", a, aScale, b, bScale, resultScale)
+		if ok {
+			require.Equal(t, expected, actual, "%s(%d) + %s(%d) at scale %d", a, aScale, b, bScale, resultScale)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input    string
+		scale    int32
+		mode     RoundingMode
+		expected string
+		ok       bool
+	}{
+		{"12.34", 2, RoundHalfEven, "1234", true},
+		{"-12.34", 2, RoundHalfEven, "-1234", true},
+		{"+12.34", 2, RoundHalfEven, "1234", true},
+		// Padding
+		{"12", 2, RoundHalfEven, "1200", true},
+		{"12.", 2, RoundHalfEven, "1200", true},
+		{".5", 2, RoundHalfEven, "50", true},
+		{"0012.300", 4, RoundHalfEven, "123000", true},
+		{"0", 10, RoundHalfEven, "0", true},
+		{"-0.00", 2, RoundHalfEven, "0", true},
+		// Truncation
+		{"12.349", 2, RoundTowardZero, "1234", true},
+		{"-12.349", 2, RoundTowardZero, "-1234", true},
+		{"0.009", 2, RoundTowardZero, "0", true},
+		// Rounding
+		{"12.345", 2, RoundHalfAwayFromZero, "1235", true},
+		{"12.345", 2, RoundHalfEven, "1234", true},
+		{"12.355", 2, RoundHalfEven, "1236", true},
+		{"-12.345", 2, RoundHalfAwayFromZero, "-1235", true},
+		{"-12.345", 2, RoundHalfEven, "-1234", true},
+		{"12.3451", 2, RoundHalfEven, "1235", true},
+		{"0.5", 0, RoundHalfAwayFromZero, "1", true},
+		{"0.05", 0, RoundHalfAwayFromZero, "0", true},
+		{"0.000000000000000000000000000000000000000000009", 1, RoundHalfAwayFromZero, "0", true},
+		// Negative scales
+		{"1250", -2, RoundHalfEven, "12", true},
+		{"1250", -2, RoundHalfAwayFromZero, "13", true},
+		// Overflow
+		{MaxInt128.String(), 0, RoundHalfEven, MaxInt128.String(), true},
+		{MinInt128.String(), 0, RoundHalfEven, MinInt128.String(), true},
+		{"170141183460469231731687303715884105728", 0, RoundHalfEven, "", false},
+		{"17014118346046923173168730371588410572.8", 1, RoundHalfEven, "", false},
+		{"17014118346046923173168730371588410572.75", 0, RoundHalfEven, "17014118346046923173168730371588410573", true},
+		{"1", 39, RoundHalfEven, "", false},
+		{"0", 1000, RoundHalfEven, "0", true},
+		{"1", -1000, RoundHalfAwayFromZero, "0", true},
+		// Malformed
+		{"", 2, RoundHalfEven, "", false},
+		{"-", 2, RoundHalfEven, "", false},
+		{".", 2, RoundHalfEven, "", false},
+		{"1.2.3", 2, RoundHalfEven, "", false},
+		{"1e3", 2, RoundHalfEven, "", false},
+		{"--1", 2, RoundHalfEven, "", false},
+		{" 1", 2, RoundHalfEven, "", false},
+		{"1,000", 2, RoundHalfEven, "", false},
+		{"NaN", 2, RoundHalfEven, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, ok := ParseDecimal(tc.input, tc.scale, tc.mode)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+		})
+	}
+}
+
+func TestParseScientific(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		ok       bool
+	}{
+		{"1.5e20", "150000000000000000000", true},
+		{"1.5E20", "150000000000000000000", true},
+		{"-1.5e+20", "-150000000000000000000", true},
+		{"+25e3", "25000", true},
+		{"123", "123", true},
+		{"-0", "0", true},
+		{".5e1", "5", true},
+		{"5.e1", "50", true},
+		{"1e0", "1", true},
+		{"007e2", "700", true},
+		// Negative exponents that resolve to whole numbers
+		{"1500e-2", "15", true},
+		{"1.50e1", "15", true},
+		{"120.000", "120", true},
+		{"100000000000000000000000000000000000000000000000000e-50", "1", true},
+		{"0e-99999999999", "0", true},
+		{"0.000e99999999999", "0", true},
+		// Fractional results
+		{"1.5e-1", "", false},
+		{"-1500e-3", "", false},
+		{"1.55e1", "", false},
+		{"1e-1", "", false},
+		{"12.5", "", false},
+		{"1e-99999999999", "", false},
+		// Overflow
+		{"1.70141183460469231731687303715884105727e38", MaxInt128.String(), true},
+		{"-1.70141183460469231731687303715884105728e38", MinInt128.String(), true},
+		{"1.70141183460469231731687303715884105728e38", "", false},
+		{"1e39", "", false},
+		{"1e2147483647", "", false},
+		{"1e99999999999", "", false},
+		// Malformed
+		{"", "", false},
+		{"e5", "", false},
+		{".e5", "", false},
+		{"1e", "", false},
+		{"1e+", "", false},
+		{"1e+-5", "", false},
+		{"1e5e3", "", false},
+		{"1e5E3", "", false},
+		{"1.2.3", "", false},
+		{"--1", "", false},
+		{"1e 5", "", false},
+		{"Inf", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, ok := ParseScientific(tc.input)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+		})
+	}
+}
+
+// bigRoundSignificant computes RoundSignificant using big.Rat as a reference
+// implementation.
+func bigRoundSignificant(v Num, scale, sigDigits int32, mode RoundingMode) (Num, int32) {
+	countDigits := func(n Num) int32 {
+		return int32(len(new(big.Int).Abs(n.bigInt()).String()))
+	}
+	digits := countDigits(v)
+	if digits <= sigDigits {
+		return v, scale
+	}
+	newScale := scale - (digits - sigDigits)
+	r, _ := ratMulScaled(v, scale, FromInt64(1), 0, newScale, mode)
+	if countDigits(r) > sigDigits {
+		newScale--
+		r, _ = ratMulScaled(v, scale, FromInt64(1), 0, newScale, mode)
+	}
+	return r, newScale
+}
+
+func TestRoundSignificant(t *testing.T) {
+	tests := []struct {
+		v             string
+		scale         int32
+		sigDigits     int32
+		mode          RoundingMode
+		expected      string
+		expectedScale int32
+		ok            bool
+	}{
+		// 123.456
+		{"123456", 3, 4, RoundHalfAwayFromZero, "1235", 1, true},
+		{"123456", 3, 4, RoundTowardZero, "1234", 1, true},
+		{"123456", 3, 2, RoundHalfEven, "12", -1, true},
+		{"-123456", 3, 4, RoundHalfAwayFromZero, "-1235", 1, true},
+		{"-123456", 3, 4, RoundTowardZero, "-1234", 1, true},
+		// Unchanged
+		{"123456", 3, 6, RoundHalfEven, "123456", 3, true},
+		{"123456", 3, 38, RoundHalfEven, "123456", 3, true},
+		{"0", 5, 1, RoundHalfEven, "0", 5, true},
+		// Ties
+		{"125", 2, 2, RoundHalfAwayFromZero, "13", 1, true},
+		{"125", 2, 2, RoundHalfEven, "12", 1, true},
+		{"135", 2, 2, RoundHalfEven, "14", 1, true},
+		{"-125", 2, 2, RoundHalfAwayFromZero, "-13", 1, true},
+		{"-125", 2, 2, RoundHalfEven, "-12", 1, true},
+		// Carries into a new digit
+		{"999", 0, 2, RoundHalfAwayFromZero, "10", -2, true},
+		{"-9999", 2, 1, RoundHalfEven, "-1", -2, true},
+		{"999", 0, 2, RoundTowardZero, "99", -1, true},
+		// Extremes
+		{MaxInt128.String(), 0, 38, RoundHalfEven, "17014118346046923173168730371588410573", -1, true},
+		{MinInt128.String(), 0, 38, RoundHalfEven, "-17014118346046923173168730371588410573", -1, true},
+		{MinInt128.String(), 10, 1, RoundHalfAwayFromZero, "-2", -28, true},
+		// Invalid
+		{"123", 0, 0, RoundHalfEven, "", 0, false},
+		{"123", 0, -1, RoundHalfEven, "", 0, false},
+		{"123", math.MinInt32, 1, RoundHalfEven, "", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			v := MustParse(tc.v)
+			actual, actualScale, ok := RoundSignificant(v, tc.scale, tc.sigDigits, tc.mode)
+			require.Equal(t, tc.ok, ok)
+			if !tc.ok {
+				return
+			}
+			require.Equal(t, tc.expected, actual.String())
+			require.Equal(t, tc.expectedScale, actualScale)
+			expected, expectedScale := bigRoundSignificant(v, tc.scale, tc.sigDigits, tc.mode)
+			require.Equal(t, expected, actual)
+			require.Equal(t, expectedScale, actualScale)
+		})
+	}
+}
+
+func TestRoundSignificantRandomized(t *testing.T) {
+	for range 10000 {
+		v := New(rand.Int64N(1<<20), rand.Uint64())
+		switch rand.N(3) {
+		case 0:
+			v = FromInt64(rand.Int64())
+		case 1:
+			v = FromInt64(rand.Int64N(1_000_000))
+		}
+		if rand.N(2) == 0 {
+			v = Neg(v)
+		}
+		scale := int32(rand.N(40)) - 10
+		sigDigits := int32(rand.N(40)) + 1
+		mode := RoundingMode(rand.N(3))
+		actual, actualScale, ok := RoundSignificant(v, scale, sigDigits, mode)
+		require.True(t, ok)
+		expected, expectedScale := bigRoundSignificant(v, scale, sigDigits, mode)
+		require.Equal(t, expected, actual, "%s(%d) to %d digits", v, scale, sigDigits)
+		require.Equal(t, expectedScale, actualScale, "%s(%d) to %d digits", v, scale, sigDigits)
+	}
+}
+
+func TestValidateDecimal(t *testing.T) {
+	tests := []struct {
+		v         string
+		precision int32
+		scale     int32
+		err       string
+	}{
+		{"99999", 5, 2, ""},
+		{"-99999", 5, 2, ""},
+		{"100000", 5, 2, "value 100000 with scale 2 does not fit in NUMBER(5,2): the unscaled value must have at most 5 digits"},
+		{"-100000", 5, 2, "value -100000 with scale 2 does not fit in NUMBER(5,2): the unscaled value must have at most 5 digits"},
+		{"0", 0, 0, ""},
+		{"1", 0, 0, "value 1 with scale 0 does not fit in NUMBER(0,0): the unscaled value must have at most 0 digits"},
+		{"9", 1, 1, ""},
+		{"-9", 1, 1, ""},
+		{"10", 1, 0, "value 10 with scale 0 does not fit in NUMBER(1,0): the unscaled value must have at most 1 digits"},
+		{"99999999999999999999999999999999999999", 38, 0, ""},
+		{"-99999999999999999999999999999999999999", 38, 37, ""},
+		{"100000000000000000000000000000000000000", 38, 0, "value 100000000000000000000000000000000000000 with scale 0 does not fit in NUMBER(38,0): the unscaled value must have at most 38 digits"},
+		{MinInt128.String(), 38, 0, "value -170141183460469231731687303715884105728 with scale 0 does not fit in NUMBER(38,0): the unscaled value must have at most 38 digits"},
+		{"1", 39, 0, "invalid precision 39: must be between 0 and 38"},
+		{"1", -1, 0, "invalid precision -1: must be between 0 and 38"},
+		{"1", 5, 6, "invalid scale 6 for NUMBER(5,6): must be between 0 and the precision"},
+		{"1", 5, -1, "invalid scale -1 for NUMBER(5,-1): must be between 0 and the precision"},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			err := ValidateDecimal(MustParse(tc.v), tc.precision, tc.scale)
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+// bigFromFloat64Scaled computes FromFloat64Scaled using big.Float as a
+// reference implementation.
+func bigFromFloat64Scaled(f float64, scale int32, mode RoundingMode) (Num, bool) {
+	const prec = 4096
+	x := new(big.Float).SetPrec(prec).SetFloat64(f)
+	p := new(big.Float).SetPrec(prec).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(scale, -scale))), nil))
+	if scale >= 0 {
+		x = x.Mul(x, p)
+	} else {
+		x = x.Quo(x, p)
+	}
+	whole, _ := x.Int(nil)
+	frac := new(big.Float).SetPrec(prec).Sub(x, new(big.Float).SetPrec(prec).SetInt(whole))
+	frac = frac.Abs(frac)
+	away := false
+	switch frac.Cmp(big.NewFloat(0.5)) {
+	case 1:
+		away = mode != RoundTowardZero
+	case 0:
+		away = mode == RoundHalfAwayFromZero || (mode == RoundHalfEven && whole.Bit(0) == 1)
+	}
+	if away {
+		if x.Sign() < 0 {
+			whole = whole.Sub(whole, big.NewInt(1))
+		} else {
+			whole = whole.Add(whole, big.NewInt(1))
+		}
+	}
+	return bigInt(whole)
+}
+
+func TestFromFloat64Scaled(t *testing.T) {
+	tests := []struct {
+		f        float64
+		scale    int32
+		mode     RoundingMode
+		expected string
+		ok       bool
+	}{
+		{1.5, 2, RoundHalfEven, "150", true},
+		{-1.5, 2, RoundHalfEven, "-150", true},
+		{0, 10, RoundHalfEven, "0", true},
+		{math.Copysign(0, -1), 10, RoundHalfEven, "0", true},
+		{123.456, 0, RoundTowardZero, "123", true},
+		{-123.456, 0, RoundTowardZero, "-123", true},
+		{123.456, 0, RoundHalfAwayFromZero, "123", true},
+		// Ties
+		{2.5, 0, RoundHalfAwayFromZero, "3", true},
+		{2.5, 0, RoundHalfEven, "2", true},
+		{3.5, 0, RoundHalfEven, "4", true},
+		{-2.5, 0, RoundHalfAwayFromZero, "-3", true},
+		{-2.5, 0, RoundHalfEven, "-2", true},
+		{0.125, 2, RoundHalfEven, "12", true},
+		{0.125, 2, RoundHalfAwayFromZero, "13", true},
+		// Binary approximations are honored exactly
+		{2.675, 2, RoundHalfAwayFromZero, "267", true},
+		{0.1, 20, RoundHalfEven, "10000000000000000555", true},
+		// Negative scales
+		{1250, -2, RoundHalfEven, "12", true},
+		{1250, -2, RoundHalfAwayFromZero, "13", true},
+		{1e308, -400, RoundHalfAwayFromZero, "0", true},
+		// Overflow
+		{1e38, 0, RoundHalfEven, "99999999999999997748809823456034029568", true},
+		{1e39, 0, RoundHalfEven, "", false},
+		{1, 39, RoundHalfEven, "", false},
+		{math.SmallestNonzeroFloat64, 400, RoundHalfEven, "", false},
+		{math.MaxFloat64, 0, RoundHalfEven, "", false},
+		{math.NaN(), 0, RoundHalfEven, "", false},
+		{math.Inf(1), 0, RoundHalfEven, "", false},
+		{math.Inf(-1), 0, RoundHalfEven, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(strconv.FormatFloat(tc.f, 'g', -1, 64), func(t *testing.T) {
+			actual, ok := FromFloat64Scaled(tc.f, tc.scale, tc.mode)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+			if !math.IsNaN(tc.f) && !math.IsInf(tc.f, 0) {
+				expected, expectedOk := bigFromFloat64Scaled(tc.f, tc.scale, tc.mode)
+				require.Equal(t, expectedOk, ok)
+				if ok {
+					require.Equal(t, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestFromFloat64ScaledRandomized(t *testing.T) {
+	for range 10000 {
+		f := math.Float64frombits(rand.Uint64())
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+		switch rand.N(3) {
+		case 0:
+			f = (rand.Float64() - 0.5) * math.Pow10(rand.N(40))
+		case 1:
+			f = float64(rand.Int64N(1_000_000)) / 8
+		}
+		scale := int32(rand.N(80)) - 40
+		mode := RoundingMode(rand.N(3))
+		actual, ok := FromFloat64Scaled(f, scale, mode)
+		expected, expectedOk := bigFromFloat64Scaled(f, scale, mode)
+		require.Equal(t, expectedOk, ok, "%v at scale %d", f, scale)
+		if ok {
+			require.Equal(t, expected, actual, "%v at scale %d", f, scale)
+		}
+	}
+}
+
+func TestDecimalComponents(t *testing.T) {
+	tests := []struct {
+		v           string
+		scale       int32
+		coefficient string
+		exponent    int32
+	}{
+		{"0", 0, "0", 0},
+		{"0", 5, "0", 0},
+		{"12345", 0, "12345", 0},
+		{"12345", 3, "12345", -3},
+		{"-12345", 3, "-12345", -3},
+		{"1200", 3, "12", -1},
+		{"-1200", 3, "-12", -1},
+		{"1200", 0, "12", 2},
+		{"1200", -2, "12", 4},
+		{"12345", -5, "12345", 5},
+		{"100000000000000000000000000000000000000", 38, "1", 0},
+		{MaxInt128.String(), 10, MaxInt128.String(), -10},
+		{MinInt128.String(), 37, MinInt128.String(), -37},
+		{MinInt128.String(), -7, MinInt128.String(), 7},
+		// Trailing zeros are kept once the exponent cannot be increased.
+		{"1200", -math.MaxInt32, "1200", math.MaxInt32},
+		{"1200", 2 - math.MaxInt32, "12", math.MaxInt32},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			v := MustParse(tc.v)
+			coefficient, exponent := v.AsDecimalComponents(tc.scale)
+			require.Equal(t, tc.coefficient, coefficient.String())
+			require.Equal(t, tc.exponent, exponent)
+
+			actual, ok := FromDecimalComponents(coefficient, exponent+tc.scale)
+			require.True(t, ok)
+			require.Equal(t, v, actual)
+		})
+	}
+}
+
+func TestFromDecimalComponents(t *testing.T) {
+	tests := []struct {
+		coeff    string
+		exponent int32
+		expected string
+		ok       bool
+	}{
+		{"0", 100, "0", true},
+		{"0", -100, "0", true},
+		{"12", 0, "12", true},
+		{"12", 3, "12000", true},
+		{"-12", 3, "-12000", true},
+		{"12000", -3, "12", true},
+		{"-12000", -3, "-12", true},
+		{"1", 38, "100000000000000000000000000000000000000", true},
+		{"-17", 37, "-170000000000000000000000000000000000000", true},
+		// Overflow
+		{"2", 38, "", false},
+		{"1", 39, "", false},
+		{"1", 40, "", false},
+		{MaxInt128.String(), 1, "", false},
+		{MinInt128.String(), 1, "", false},
+		// Discards non-zero digits
+		{"12345", -1, "", false},
+		{"-12345", -4, "", false},
+		{"1", -40, "", false},
+		{"1", math.MinInt32, "", false},
+		{MaxInt128.String(), -39, "", false},
+	}
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			actual, ok := FromDecimalComponents(MustParse(tc.coeff), tc.exponent)
+			require.Equal(t, tc.ok, ok)
+			if tc.ok {
+				require.Equal(t, tc.expected, actual.String())
+			}
+		})
+	}
+}
+
+func TestDecimalComponentsRandomized(t *testing.T) {
+	for range 10000 {
+		v := New(rand.Int64(), rand.Uint64())
+		switch rand.N(3) {
+		case 0:
+			v = FromInt64(rand.Int64())
+		case 1:
+			v = Mul(FromInt64(rand.Int64N(1_000_000)), Pow10Table[rand.N(20)])
+		}
+		scale := rand.N[int32](80) - 40
+		coefficient, exponent := v.AsDecimalComponents(scale)
+		actual, ok := FromDecimalComponents(coefficient, exponent+scale)
+		require.True(t, ok, "%s(%d)", v, scale)
+		require.Equal(t, v, actual, "%s(%d)", v, scale)
+	}
+}
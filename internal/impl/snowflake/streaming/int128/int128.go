@@ -17,10 +17,12 @@ package int128
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"math/big"
 	"math/bits"
+	"strconv"
 )
 
 // Common constant values for int128
@@ -141,6 +143,101 @@ func Mul(a, b Num) Num {
 	return Num{hi: int64(hi), lo: lo}
 }
 
+// AddSat computes a + b, clamping the result to MaxInt128 or MinInt128 instead
+// of wrapping on overflow.
+func AddSat(a, b Num) Num {
+	r := Add(a, b)
+	// Overflow is only possible when both operands have the same sign, in
+	// which case the sign of the result flips.
+	if a.IsNegative() == b.IsNegative() && r.IsNegative() != a.IsNegative() {
+		if a.IsNegative() {
+			return MinInt128
+		}
+		return MaxInt128
+	}
+	return r
+}
+
+// MulSat computes a * b, clamping the result to MaxInt128 or MinInt128 instead
+// of wrapping on overflow.
+func MulSat(a, b Num) Num {
+	neg := a.IsNegative() != b.IsNegative()
+	limit := MaxInt128
+	if neg {
+		limit = MinInt128
+	}
+	if a.IsZero() || b.IsZero() {
+		return Num{}
+	}
+	// Multiply the magnitudes as unsigned values, Neg(MinInt128) wraps back
+	// to MinInt128 which is the correct magnitude when treated as unsigned.
+	ua, ub := a, b
+	if ua.IsNegative() {
+		ua = Neg(ua)
+	}
+	if ub.IsNegative() {
+		ub = Neg(ub)
+	}
+	if ua.hi != 0 && ub.hi != 0 {
+		return limit
+	}
+	hi, lo := bits.Mul64(ua.lo, ub.lo)
+	c1hi, c1lo := bits.Mul64(uint64(ua.hi), ub.lo)
+	c2hi, c2lo := bits.Mul64(ua.lo, uint64(ub.hi))
+	if c1hi != 0 || c2hi != 0 {
+		return limit
+	}
+	var carry uint64
+	if hi, carry = bits.Add64(hi, c1lo, 0); carry != 0 {
+		return limit
+	}
+	if hi, carry = bits.Add64(hi, c2lo, 0); carry != 0 {
+		return limit
+	}
+	// The magnitude of MinInt128 is one greater than that of MaxInt128.
+	if hi > math.MaxInt64 && (!neg || hi != 1<<63 || lo != 0) {
+		return limit
+	}
+	r := Num{hi: int64(hi), lo: lo}
+	if neg {
+		r = Neg(r)
+	}
+	return r
+}
+
+// Sum computes the sum of vals, wrapping on overflow like Add. The sum of an
+// empty slice is zero.
+func Sum(vals []Num) Num {
+	var sum Num
+	for _, v := range vals {
+		sum = Add(sum, v)
+	}
+	return sum
+}
+
+// SumChecked computes the sum of vals, returning false if the sum does not fit
+// in an Int128, in which case the wrapped sum is returned. Intermediate sums
+// may overflow as long as the final sum fits, so the order of vals does not
+// affect the result. The sum of an empty slice is zero.
+func SumChecked(vals []Num) (Num, bool) {
+	var sum Num
+	// The number of times the running sum has wrapped, positive when wrapping
+	// past MaxInt128 and negative when wrapping past MinInt128.
+	var wraps int
+	for _, v := range vals {
+		r := Add(sum, v)
+		if sum.IsNegative() == v.IsNegative() && r.IsNegative() != v.IsNegative() {
+			if v.IsNegative() {
+				wraps--
+			} else {
+				wraps++
+			}
+		}
+		sum = r
+	}
+	return sum, wraps == 0
+}
+
 func fls128(n Num) int {
 	if n.hi != 0 {
 		return 127 - bits.LeadingZeros64(uint64(n.hi))
@@ -158,6 +255,13 @@ func Neg(n Num) Num {
 	return n
 }
 
+// NegChecked computes -n, returning false if the result overflows, which only
+// happens for MinInt128 as its negation does not fit in an Int128. In that case
+// MinInt128 is returned, the same as Neg.
+func NegChecked(n Num) (Num, bool) {
+	return Neg(n), n != MinInt128
+}
+
 // Abs computes v < 0 ? -v : v
 func (i Num) Abs() Num {
 	if i.IsNegative() {
@@ -171,6 +275,11 @@ func (i Num) IsNegative() bool {
 	return i.hi < 0
 }
 
+// IsZero returns true if `i` is zero
+func (i Num) IsZero() bool {
+	return i.hi == 0 && i.lo == 0
+}
+
 // Shl returns a << i
 func Shl(v Num, amt uint) Num {
 	n := amt - 64
@@ -207,6 +316,30 @@ func Greater(a, b Num) bool {
 	}
 }
 
+// LessInt64 returns i < v, without converting v into an Int128
+func (i Num) LessInt64(v int64) bool {
+	// v sign extended into the upper 64 bits
+	hi := v >> 63
+	if i.hi == hi {
+		return i.lo < uint64(v)
+	}
+	return i.hi < hi
+}
+
+// GreaterInt64 returns i > v, without converting v into an Int128
+func (i Num) GreaterInt64(v int64) bool {
+	hi := v >> 63
+	if i.hi == hi {
+		return i.lo > uint64(v)
+	}
+	return i.hi > hi
+}
+
+// EqualInt64 returns i == v, without converting v into an Int128
+func (i Num) EqualInt64(v int64) bool {
+	return i.hi == v>>63 && i.lo == uint64(v)
+}
+
 // FromBigEndian converts bi endian bytes to Int128
 func FromBigEndian(b []byte) Num {
 	hi := int64(binary.BigEndian.Uint64(b[0:8]))
@@ -231,6 +364,36 @@ func (i Num) AppendBigEndian(b []byte) []byte {
 	return binary.BigEndian.AppendUint64(b, i.lo)
 }
 
+// Key returns the big endian two's complement bytes of an Int128 as an array,
+// which can be used as a map key or compared for equality. Comparing keys
+// bytewise does not preserve the ordering of negative numbers.
+func (i Num) Key() [16]byte {
+	var k [16]byte
+	binary.BigEndian.PutUint64(k[0:8], uint64(i.hi))
+	binary.BigEndian.PutUint64(k[8:16], i.lo)
+	return k
+}
+
+// FromKey converts an array returned by Key back into an Int128
+func FromKey(k [16]byte) Num {
+	return FromBigEndian(k[:])
+}
+
+// SplitInt64 splits an Int128 into two int64 words, such as for storage in
+// two Int64 columns. hi is the upper 64 bits, which carries the sign of the
+// value. lo is the lower 64 bits reinterpreted as a two's complement int64,
+// so it is negative whenever its top bit is set and must be converted back to
+// a uint64 to recover its magnitude. The value is hi*2^64 + uint64(lo).
+func (i Num) SplitInt64() (hi, lo int64) {
+	return i.hi, int64(i.lo)
+}
+
+// JoinInt64 joins two int64 words returned by SplitInt64 back into an
+// Int128.
+func JoinInt64(hi, lo int64) Num {
+	return Num{hi: hi, lo: uint64(lo)}
+}
+
 // ToInt64 casts an Int128 to a int64 by truncating the bytes.
 func (i Num) ToInt64() int64 {
 	return int64(i.lo)
@@ -293,20 +456,199 @@ func Parse(str string) (n Num, ok bool) {
 	return bigInt(bi)
 }
 
+// ParseHex converts 1 to 32 hex digits, optionally prefixed with 0x, into an
+// Int128. The digits are the big endian two's complement representation of
+// the number, so inputs shorter than 32 digits are zero extended and are
+// never negative.
+func ParseHex(str string) (n Num, ok bool) {
+	if len(str) > 2 && str[0] == '0' && (str[1] == 'x' || str[1] == 'X') {
+		str = str[2:]
+	}
+	if len(str) == 0 || len(str) > 32 {
+		return
+	}
+	for _, c := range []byte(str) {
+		var d byte
+		switch {
+		case '0' <= c && c <= '9':
+			d = c - '0'
+		case 'a' <= c && c <= 'f':
+			d = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			d = c - 'A' + 10
+		default:
+			return Num{}, false
+		}
+		n.hi = n.hi<<4 | int64(n.lo>>60)
+		n.lo = n.lo<<4 | uint64(d)
+	}
+	return n, true
+}
+
+// HexString returns the big endian two's complement representation of the
+// number as exactly 32 lowercase hex digits, which unlike formatting with %032x
+// never has a sign. It is the inverse of ParseHex.
+func (i Num) HexString() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[0:8], uint64(i.hi))
+	binary.BigEndian.PutUint64(b[8:16], i.lo)
+	return hex.EncodeToString(b[:])
+}
+
 // String returns the number as base 10 formatted string.
-//
-// This is not fast but it isn't on a hot path.
 func (i Num) String() string {
-	return string(i.bigInt().Append(nil, 10))
+	return string(i.AppendTo(make([]byte, 0, 40)))
+}
+
+// AppendTo appends the base 10 representation of the Int128 to dst and
+// returns the extended buffer, it does not allocate if dst has enough
+// capacity (40 bytes is enough for any value).
+func (i Num) AppendTo(dst []byte) []byte {
+	hi, lo := uint64(i.hi), i.lo
+	if i.IsNegative() {
+		dst = append(dst, '-')
+		// Two's complement negation of the unsigned value, which handles
+		// MinInt128 correctly unlike Neg.
+		var borrow uint64
+		lo, borrow = bits.Sub64(0, lo, 0)
+		hi, _ = bits.Sub64(0, hi, borrow)
+	}
+	return appendUint128(dst, hi, lo)
+}
+
+// UnsignedString returns the bits of the number interpreted as an unsigned
+// 128 bit integer as a base 10 formatted string, so that MinInt128 is 2^127
+// and -1 is 2^128-1.
+func (i Num) UnsignedString() string {
+	return string(appendUint128(make([]byte, 0, 39), uint64(i.hi), i.lo))
+}
+
+// appendUint128 appends the base 10 representation of the unsigned 128 bit
+// integer made up of hi and lo to dst.
+func appendUint128(dst []byte, hi, lo uint64) []byte {
+	if hi == 0 {
+		return strconv.AppendUint(dst, lo, 10)
+	}
+	// Split the magnitude into base 10^19 chunks, which is the largest power
+	// of ten that fits within a uint64. A 128 bit value has at most three.
+	const chunk = 10_000_000_000_000_000_000
+	var chunks [3]uint64
+	n := 0
+	for hi != 0 {
+		var rem uint64
+		hi, rem = bits.Div64(0, hi, chunk)
+		lo, rem = bits.Div64(rem, lo, chunk)
+		chunks[n] = rem
+		n++
+	}
+	dst = strconv.AppendUint(dst, lo, 10)
+	for n > 0 {
+		n--
+		dst = appendPaddedUint(dst, chunks[n], 19)
+	}
+	return dst
+}
+
+// appendPaddedUint appends v to dst as exactly width base 10 digits.
+func appendPaddedUint(dst []byte, v uint64, width int) []byte {
+	var buf [20]byte
+	pos := len(buf)
+	for range width {
+		pos--
+		buf[pos] = byte('0' + v%10)
+		v /= 10
+	}
+	return append(dst, buf[pos:]...)
+}
+
+// Interface returns the value as a type that structured message serializers
+// understand. Values within the range of an int64 are returned as an int64,
+// otherwise the base 10 formatted string is returned so that large values are
+// not silently truncated.
+//
+// Callers should be aware that the returned type therefore depends on the
+// magnitude of the value.
+func (i Num) Interface() any {
+	if !Less(i, MinInt64) && !Greater(i, MaxInt64) {
+		return i.ToInt64()
+	}
+	return i.String()
+}
+
+// Format implements fmt.Formatter, supporting the same verbs and flags as
+// the builtin integer types (b, o, O, d, x, X, s and v along with width,
+// precision and the '+', '-', ' ', '#' and '0' flags).
+//
+// Base 10 verbs are formatted with AppendTo, other verbs are formatted by
+// converting to a big.Int, which is not fast but isn't on a hot path.
+func (i Num) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'd', 's', 'v':
+	default:
+		i.bigInt().Format(f, verb)
+		return
+	}
+	var digitsBuf [40]byte
+	digits := i.AppendTo(digitsBuf[:0])
+	var sign byte
+	if i.IsNegative() {
+		sign, digits = '-', digits[1:]
+	} else if f.Flag('+') {
+		sign = '+'
+	} else if f.Flag(' ') {
+		sign = ' '
+	}
+	zeros := 0
+	precision, hasPrecision := f.Precision()
+	if hasPrecision {
+		if precision == 0 && i.IsZero() {
+			digits = digits[:0]
+		}
+		zeros = max(precision-len(digits), 0)
+	}
+	n := len(digits) + zeros
+	if sign != 0 {
+		n++
+	}
+	padding := 0
+	if width, ok := f.Width(); ok && width > n {
+		padding = width - n
+	}
+	// Padding with zeros goes between the sign and the digits, and is
+	// ignored when a precision is given or the value is left aligned.
+	leftAlign := f.Flag('-')
+	if f.Flag('0') && !hasPrecision && !leftAlign {
+		zeros, padding = zeros+padding, 0
+	}
+
+	var buf [64]byte
+	out := buf[:0]
+	if !leftAlign {
+		out = appendRepeat(out, ' ', padding)
+	}
+	if sign != 0 {
+		out = append(out, sign)
+	}
+	out = appendRepeat(out, '0', zeros)
+	out = append(out, digits...)
+	if leftAlign {
+		out = appendRepeat(out, ' ', padding)
+	}
+	_, _ = f.Write(out)
+}
+
+func appendRepeat(dst []byte, c byte, n int) []byte {
+	for range n {
+		dst = append(dst, c)
+	}
+	return dst
 }
 
 // MarshalJSON implements JSON serialization of
 // an int128 like BigInteger in the Snowflake
 // Java SDK with Jackson.
-//
-// This is not fast but it isn't on a hot path.
 func (i Num) MarshalJSON() ([]byte, error) {
-	return i.bigInt().Append(nil, 10), nil
+	return i.AppendTo(nil), nil
 }
 
 func (i Num) bigInt() *big.Int {
@@ -13,7 +13,9 @@ package int128
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
 	"slices"
 	"testing"
 
@@ -120,6 +122,133 @@ func TestMul(t *testing.T) {
 	}
 }
 
+// clampBigInt converts a big integer into a Num, saturating at the limits.
+func clampBigInt(v *big.Int) Num {
+	if v.Cmp(maxBigInt128) > 0 {
+		return MaxInt128
+	}
+	if v.Cmp(minBigInt128) < 0 {
+		return MinInt128
+	}
+	n, _ := bigInt(v)
+	return n
+}
+
+func TestAddSat(t *testing.T) {
+	require.Equal(t, MaxInt128, AddSat(MaxInt128, FromInt64(1)))
+	require.Equal(t, MaxInt128, AddSat(MaxInt128, MaxInt128))
+	require.Equal(t, MinInt128, AddSat(MinInt128, FromInt64(-1)))
+	require.Equal(t, MinInt128, AddSat(MinInt128, MinInt128))
+	require.Equal(t, FromInt64(-1), AddSat(MaxInt128, MinInt128))
+	require.Equal(t, MaxInt128, AddSat(Sub(MaxInt128, FromInt64(1)), FromInt64(1)))
+	require.Equal(t, MinInt128, AddSat(Add(MinInt128, FromInt64(1)), FromInt64(-1)))
+	require.Equal(t, FromInt64(2), AddSat(FromInt64(1), FromInt64(1)))
+
+	nums := randomNums(t, 200)
+	for i, a := range nums {
+		b := nums[len(nums)-1-i]
+		expected := clampBigInt(new(big.Int).Add(a.bigInt(), b.bigInt()))
+		require.Equal(t, expected, AddSat(a, b), "%s + %s", a, b)
+	}
+}
+
+func TestMulSat(t *testing.T) {
+	require.Equal(t, MaxInt128, MulSat(MaxInt128, FromInt64(2)))
+	require.Equal(t, MinInt128, MulSat(MaxInt128, FromInt64(-2)))
+	require.Equal(t, MinInt128, MulSat(MinInt128, FromInt64(2)))
+	require.Equal(t, MaxInt128, MulSat(MinInt128, FromInt64(-1)))
+	require.Equal(t, MaxInt128, MulSat(MinInt128, MinInt128))
+	require.Equal(t, MinInt128, MulSat(MinInt128, FromInt64(1)))
+	require.Equal(t, MinInt128, MulSat(MaxInt128, MinInt128))
+	require.Equal(t, MaxInt128, MulSat(MaxInt128, FromInt64(1)))
+	require.Equal(t, Neg(MaxInt128), MulSat(MaxInt128, FromInt64(-1)))
+	require.Equal(t, FromInt64(0), MulSat(MinInt128, FromInt64(0)))
+	// 2^63 * 2^64 = 2^127 only fits when negative
+	require.Equal(t, MaxInt128, MulSat(FromUint64(1<<63), New(1, 0)))
+	require.Equal(t, MinInt128, MulSat(FromUint64(1<<63), New(-1, 0)))
+	require.Equal(t, MaxInt128, MulSat(FromUint64(math.MaxUint64), FromUint64(math.MaxUint64)))
+	require.Equal(t, FromInt64(-6), MulSat(FromInt64(2), FromInt64(-3)))
+
+	nums := randomNums(t, 200)
+	for i, a := range nums {
+		b := nums[len(nums)-1-i]
+		expected := clampBigInt(new(big.Int).Mul(a.bigInt(), b.bigInt()))
+		require.Equal(t, expected, MulSat(a, b), "%s * %s", a, b)
+		require.Equal(t, expected, MulSat(b, a), "%s * %s", b, a)
+	}
+}
+
+func TestSum(t *testing.T) {
+	sum, ok := SumChecked(nil)
+	require.True(t, ok)
+	require.Equal(t, Num{}, sum)
+	require.Equal(t, Num{}, Sum(nil))
+	require.Equal(t, Num{}, Sum([]Num{}))
+
+	sum, ok = SumChecked([]Num{FromInt64(1), FromInt64(-5), FromUint64(math.MaxUint64)})
+	require.True(t, ok)
+	require.Equal(t, Sub(FromUint64(math.MaxUint64), FromInt64(4)), sum)
+
+	// Overflowing in either direction is detected, and the wrapped sum
+	// matches Sum.
+	for _, vals := range [][]Num{
+		{MaxInt128, FromInt64(1)},
+		{MinInt128, FromInt64(-1)},
+		{MaxInt128, MaxInt128, MaxInt128, MaxInt128},
+		{FromInt64(5), MinInt128, MinInt128, FromInt64(3)},
+	} {
+		sum, ok := SumChecked(vals)
+		require.False(t, ok, "%v", vals)
+		require.Equal(t, Sum(vals), sum, "%v", vals)
+	}
+	require.Equal(t, MinInt128, Sum([]Num{MaxInt128, FromInt64(1)}))
+
+	// Intermediate sums may overflow as long as the final sum fits.
+	sum, ok = SumChecked([]Num{MaxInt128, FromInt64(1), FromInt64(-1)})
+	require.True(t, ok)
+	require.Equal(t, MaxInt128, sum)
+	sum, ok = SumChecked([]Num{MinInt128, FromInt64(-2), MaxInt128, FromInt64(3)})
+	require.True(t, ok)
+	require.Equal(t, FromInt64(0), sum)
+
+	nums := randomNums(t, 200)
+	for i := 0; i < len(nums); i += 5 {
+		vals := nums[i:min(i+5, len(nums))]
+		expected := new(big.Int)
+		for _, v := range vals {
+			expected.Add(expected, v.bigInt())
+		}
+		sum, ok := SumChecked(vals)
+		fits := expected.Cmp(maxBigInt128) <= 0 && expected.Cmp(minBigInt128) >= 0
+		require.Equal(t, fits, ok, "%v", vals)
+		if fits {
+			n, _ := bigInt(expected)
+			require.Equal(t, n, sum, "%v", vals)
+		}
+		require.Equal(t, Sum(vals), sum, "%v", vals)
+	}
+}
+
+func TestSign(t *testing.T) {
+	for _, tc := range []struct {
+		n        Num
+		zero     bool
+		negative bool
+	}{
+		{Num{}, true, false},
+		{FromInt64(0), true, false},
+		{FromInt64(1), false, false},
+		{FromUint64(math.MaxUint64), false, false},
+		{MaxInt128, false, false},
+		{FromInt64(-1), false, true},
+		{Neg(FromUint64(math.MaxUint64)), false, true},
+		{MinInt128, false, true},
+	} {
+		require.Equal(t, tc.zero, tc.n.IsZero(), "%s", tc.n)
+		require.Equal(t, tc.negative, tc.n.IsNegative(), "%s", tc.n)
+	}
+}
+
 func TestShl(t *testing.T) {
 	for i := uint(0); i < 64; i++ {
 		require.Equal(t, Num{lo: 1 << i}, Shl(FromInt64(1), i))
@@ -148,6 +277,43 @@ func TestNeg(t *testing.T) {
 	require.Equal(t, MinInt128, Neg(MinInt128))
 }
 
+func TestNegChecked(t *testing.T) {
+	n, ok := NegChecked(MinInt128)
+	require.False(t, ok)
+	require.Equal(t, MinInt128, n)
+
+	for _, v := range []Num{
+		FromInt64(0),
+		FromInt64(1),
+		FromInt64(-1),
+		MaxInt64,
+		MinInt64,
+		MaxInt128,
+		Add(MinInt128, FromInt64(1)),
+		FromUint64(math.MaxUint64),
+	} {
+		n, ok := NegChecked(v)
+		require.True(t, ok, v.String())
+		require.Equal(t, Neg(v), n, v.String())
+		back, ok := NegChecked(n)
+		require.True(t, ok, v.String())
+		require.Equal(t, v, back, v.String())
+	}
+
+	for range 100 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		v := FromBigEndian(input)
+		if v == MinInt128 {
+			continue
+		}
+		n, ok := NegChecked(v)
+		require.True(t, ok)
+		require.Equal(t, v, Neg(n))
+	}
+}
+
 func TestDiv(t *testing.T) {
 	type TestCase struct {
 		dividend, divisor, quotient Num
@@ -257,6 +423,9 @@ func TestDiv(t *testing.T) {
 			FromInt64(2),
 			MustParse("-126700887753561500000"),
 		},
+		{FromInt64(2), FromInt64(-2), FromInt64(-1)},
+		{FromInt64(-2), FromInt64(2), FromInt64(-1)},
+		{FromInt64(-2), FromInt64(-2), FromInt64(1)},
 	}
 	for _, c := range cases {
 		c := c
@@ -318,6 +487,208 @@ func TestCompare(t *testing.T) {
 	require.Equal(t, Shl(FromInt64(1), 64), Add(FromUint64(math.MaxUint64), FromInt64(1)))
 }
 
+func TestCompareInt64(t *testing.T) {
+	nums := []Num{
+		MinInt128,
+		Neg(FromUint64(math.MaxUint64)),
+		Sub(FromInt64(math.MinInt64), one),
+		FromInt64(math.MinInt64),
+		FromInt64(math.MinInt64 + 1),
+		FromInt64(-1),
+		FromInt64(0),
+		FromInt64(1),
+		FromInt64(math.MaxInt64 - 1),
+		FromInt64(math.MaxInt64),
+		Add(FromInt64(math.MaxInt64), one),
+		FromUint64(math.MaxUint64),
+		Shl(one, 64),
+		MaxInt128,
+	}
+	vals := []int64{math.MinInt64, math.MinInt64 + 1, -2, -1, 0, 1, 2, math.MaxInt64 - 1, math.MaxInt64}
+	for _, a := range nums {
+		for _, v := range vals {
+			c := Compare(a, FromInt64(v))
+			require.Equal(t, c < 0, a.LessInt64(v), "%v < %v", a, v)
+			require.Equal(t, c > 0, a.GreaterInt64(v), "%v > %v", a, v)
+			require.Equal(t, c == 0, a.EqualInt64(v), "%v == %v", a, v)
+		}
+	}
+}
+
+func TestCmpAbs(t *testing.T) {
+	tc := []struct {
+		a, b     Num
+		expected int
+	}{
+		{FromInt64(0), FromInt64(0), 0},
+		{FromInt64(1), FromInt64(-1), 0},
+		{FromInt64(-5), FromInt64(5), 0},
+		{FromInt64(1), FromInt64(2), -1},
+		{FromInt64(-1), FromInt64(2), -1},
+		{FromInt64(1), FromInt64(-2), -1},
+		{FromInt64(-1), FromInt64(-2), -1},
+		{FromInt64(-3), FromInt64(2), 1},
+		{FromInt64(3), FromInt64(-2), 1},
+		{FromInt64(math.MinInt64), FromInt64(math.MaxInt64), 1},
+		{FromUint64(math.MaxUint64), FromInt64(math.MinInt64), 1},
+		{Neg(FromUint64(math.MaxUint64)), FromUint64(math.MaxUint64), 0},
+		{MinInt128, MaxInt128, 1},
+		{MinInt128, Neg(MaxInt128), 1},
+		{MaxInt128, Neg(MaxInt128), 0},
+		{MinInt128, MinInt128, 0},
+		{FromInt64(-1), MinInt128, -1},
+	}
+	for _, c := range tc {
+		require.Equal(t, c.expected, CmpAbs(c.a, c.b), "CmpAbs(%s, %s)", c.a, c.b)
+		require.Equal(t, -c.expected, CmpAbs(c.b, c.a), "CmpAbs(%s, %s)", c.b, c.a)
+		// Cross check against the magnitudes
+		require.Equal(t, c.a.bigInt().CmpAbs(c.b.bigInt()), CmpAbs(c.a, c.b))
+	}
+}
+
+// randomNums returns random values with a spread of magnitudes, so that
+// small values are exercised as well as those using all 128 bits.
+func randomNums(t *testing.T, n int) []Num {
+	t.Helper()
+
+	nums := make([]Num, n)
+	for i := range nums {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		nums[i] = FromBigEndian(input)
+		nums[i] = Div(nums[i], Shl(FromInt64(1), uint(i%127)))
+	}
+	return nums
+}
+
+func TestMod(t *testing.T) {
+	nums := randomNums(t, 200)
+	for i, a := range nums {
+		b := nums[len(nums)-1-i]
+		if b == (Num{}) {
+			continue
+		}
+		expected := new(big.Int).Rem(a.bigInt(), b.bigInt())
+		require.Equal(t, expected.String(), Mod(a, b).String(), "%s %% %s", a, b)
+	}
+	require.Equal(t, FromInt64(0), Mod(MinInt128, FromInt64(-1)))
+	require.Equal(t, FromInt64(-1), Mod(FromInt64(-7), FromInt64(3)))
+	require.Equal(t, FromInt64(1), Mod(FromInt64(7), FromInt64(-3)))
+	// Operands of equal magnitude and opposite signs divide exactly.
+	require.Equal(t, FromInt64(0), Mod(FromInt64(2), FromInt64(-2)))
+	require.Equal(t, FromInt64(0), Mod(FromInt64(-5), FromInt64(5)))
+}
+
+func TestDivInt64(t *testing.T) {
+	divisors := []int64{1, -1, 2, -2, 3, 7, -10, 10, 1_000_000_007, math.MaxInt64, math.MinInt64, math.MinInt64 + 1}
+	for i := range 19 {
+		divisors = append(divisors, Pow10Table[i].ToInt64(), -Pow10Table[i].ToInt64())
+	}
+	nums := append(randomNums(t, 200), FromInt64(0), FromInt64(5), FromInt64(-5), MaxInt128, MinInt128, MinInt64, MaxInt64)
+	for _, a := range nums {
+		for _, b := range divisors {
+			q, r := DivInt64(a, b)
+			require.Equal(t, Div(a, FromInt64(b)), q, "%s / %d", a, b)
+			require.Equal(t, Mod(a, FromInt64(b)), FromInt64(r), "%s %% %d", a, b)
+		}
+	}
+
+	q, r := DivInt64(FromInt64(-7), 3)
+	require.Equal(t, FromInt64(-2), q)
+	require.Equal(t, int64(-1), r)
+	q, r = DivInt64(FromInt64(7), -3)
+	require.Equal(t, FromInt64(-2), q)
+	require.Equal(t, int64(1), r)
+	q, r = DivInt64(MinInt128, -1)
+	require.Equal(t, MinInt128, q)
+	require.Equal(t, int64(0), r)
+	require.Panics(t, func() { DivInt64(FromInt64(1), 0) })
+}
+
+func BenchmarkDiv(b *testing.B) {
+	for _, n := range []Num{MaxInt64, MaxInt128, MinInt128} {
+		divisor := Pow10Table[18]
+		b.Run("Div/"+n.String(), func(b *testing.B) {
+			for b.Loop() {
+				_ = Div(n, divisor)
+			}
+		})
+		b.Run("DivInt64/"+n.String(), func(b *testing.B) {
+			for b.Loop() {
+				_, _ = DivInt64(n, divisor.ToInt64())
+			}
+		})
+	}
+}
+
+func TestGCD(t *testing.T) {
+	tests := []struct {
+		a, b, expected Num
+	}{
+		{FromInt64(0), FromInt64(0), FromInt64(0)},
+		{FromInt64(12), FromInt64(0), FromInt64(12)},
+		{FromInt64(0), FromInt64(-12), FromInt64(12)},
+		{FromInt64(-12), FromInt64(18), FromInt64(6)},
+		{FromInt64(-12), FromInt64(-18), FromInt64(6)},
+		{MinInt128, FromInt64(6), FromInt64(2)},
+		{MaxInt128, MinInt128, FromInt64(1)},
+		{MinInt128, MinInt128, MinInt128},
+		{FromInt64(2), FromInt64(-2), FromInt64(2)},
+		{FromInt64(-5), FromInt64(5), FromInt64(5)},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, GCD(test.a, test.b), "gcd(%s, %s)", test.a, test.b)
+	}
+
+	nums := randomNums(t, 200)
+	for i, a := range nums {
+		b := nums[len(nums)-1-i]
+		expected := new(big.Int).GCD(nil, nil, a.bigInt(), b.bigInt())
+		require.Equal(t, expected.String(), GCD(a, b).String(), "gcd(%s, %s)", a, b)
+	}
+}
+
+func TestLCM(t *testing.T) {
+	tests := []struct {
+		a, b     Num
+		expected Num
+		ok       bool
+	}{
+		{FromInt64(0), FromInt64(5), FromInt64(0), true},
+		{FromInt64(-4), FromInt64(6), FromInt64(12), true},
+		{FromInt64(-4), FromInt64(-6), FromInt64(12), true},
+		{FromInt64(-5), FromInt64(5), FromInt64(5), true},
+		{MinInt128, FromInt64(1), Num{}, false},
+		{MaxInt128, FromInt64(1), MaxInt128, true},
+		{MaxInt128, FromInt64(2), Num{}, false},
+		{MaxInt64, Add(MaxInt64, FromInt64(1)), Mul(MaxInt64, Add(MaxInt64, FromInt64(1))), true},
+	}
+	for _, test := range tests {
+		actual, ok := LCM(test.a, test.b)
+		require.Equal(t, test.ok, ok, "lcm(%s, %s)", test.a, test.b)
+		require.Equal(t, test.expected, actual, "lcm(%s, %s)", test.a, test.b)
+	}
+
+	nums := randomNums(t, 200)
+	for i, a := range nums {
+		b := nums[len(nums)-1-i]
+		actual, ok := LCM(a, b)
+		expected := new(big.Int)
+		if a != (Num{}) && b != (Num{}) {
+			gcd := new(big.Int).GCD(nil, nil, a.bigInt(), b.bigInt())
+			expected.Mul(a.bigInt(), b.bigInt())
+			expected.Abs(expected)
+			expected.Quo(expected, gcd)
+		}
+		expectedNum, fits := bigInt(expected)
+		require.Equal(t, fits, ok, "lcm(%s, %s) = %s", a, b, expected)
+		if fits {
+			require.Equal(t, expectedNum, actual, "lcm(%s, %s)", a, b)
+		}
+	}
+}
+
 func TestParse(t *testing.T) {
 	for _, expected := range [...]Num{
 		MinInt128,
@@ -352,6 +723,217 @@ func TestString(t *testing.T) {
 	require.Equal(t, "170141183460469231731687303715884105727", MaxInt128.String())
 }
 
+func TestAppendTo(t *testing.T) {
+	chunk := FromUint64(10_000_000_000_000_000_000)
+	for _, n := range []Num{
+		MinInt128,
+		MaxInt128,
+		FromInt64(0),
+		FromInt64(-1),
+		FromInt64(1),
+		MinInt64,
+		MaxInt64,
+		FromUint64(math.MaxUint64),
+		Add(FromUint64(math.MaxUint64), FromInt64(1)),
+		chunk,
+		Sub(chunk, FromInt64(1)),
+		Mul(chunk, chunk),
+		Neg(Mul(chunk, chunk)),
+		Add(Mul(chunk, chunk), FromInt64(7)),
+	} {
+		require.Equal(t, n.bigInt().String(), string(n.AppendTo(nil)))
+		require.Equal(t, "x="+n.bigInt().String(), string(n.AppendTo([]byte("x="))))
+	}
+	for range 1000 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		n := FromBigEndian(input)
+		require.Equal(t, n.bigInt().String(), n.String())
+	}
+}
+
+func BenchmarkAppendTo(b *testing.B) {
+	for _, n := range []Num{FromInt64(42), MaxInt64, MinInt128} {
+		b.Run(n.String(), func(b *testing.B) {
+			buf := make([]byte, 0, 40)
+			b.ReportAllocs()
+			for b.Loop() {
+				buf = n.AppendTo(buf[:0])
+			}
+			if allocs := testing.AllocsPerRun(100, func() { buf = n.AppendTo(buf[:0]) }); allocs != 0 {
+				b.Fatalf("expected no allocations, got %v", allocs)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	formats := []string{
+		"%d", "%v", "%x", "%X", "%b", "%o", "%O",
+		"%+d", "% d", "%#x", "%#X", "%8d", "%-8d|", "%08d",
+		"%08x", "%.5d", "%8.5d", "%-8x|", "%+08d", "%.0d", "%5.0d",
+	}
+	values := []int64{0, 1, -1, 42, -42, 255, -255, math.MaxInt64, math.MinInt64}
+	for _, f := range formats {
+		for _, v := range values {
+			require.Equal(t, fmt.Sprintf(f, v), fmt.Sprintf(f, FromInt64(v)), "format %q of %d", f, v)
+		}
+	}
+	require.Equal(t, "-42", fmt.Sprintf("%s", FromInt64(-42)))
+	require.Equal(t, "010", fmt.Sprintf("%#o", FromInt64(8)))
+	require.Equal(t, "170141183460469231731687303715884105727", fmt.Sprintf("%v", MaxInt128))
+	require.Equal(t, "-170141183460469231731687303715884105728", fmt.Sprintf("%d", MinInt128))
+	require.Equal(t, "7fffffffffffffffffffffffffffffff", fmt.Sprintf("%x", MaxInt128))
+	require.Equal(t, "0X7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", fmt.Sprintf("%#X", MaxInt128))
+	require.Equal(t, "-80000000000000000000000000000000", fmt.Sprintf("%x", MinInt128))
+	require.Equal(t, "0000000000010000000000000000", fmt.Sprintf("%028x", Shl(FromInt64(1), 64)))
+	require.Equal(t, "[1 -2]", fmt.Sprintf("%v", []Num{FromInt64(1), FromInt64(-2)}))
+
+	// Base 10 verbs do not go through big.Int but must format the same.
+	decimalFormats := []string{
+		"%d", "%v", "%s", "%+d", "% d", "%+v", "%#v", "%8s", "%-8s|", "%08s",
+		"%.0d", "%.45d", "%+.3d", "%-+8d|", "% 08d", "%0-8d|", "%45d",
+		"%-45v|", "%045d", "%+045d", "%50.45d",
+	}
+	nums := []Num{
+		{}, FromInt64(1), FromInt64(-1), FromInt64(42), FromInt64(-42),
+		MaxInt64, MinInt64, MaxInt128, MinInt128, Shl(FromInt64(1), 64),
+	}
+	for _, f := range decimalFormats {
+		for _, n := range nums {
+			require.Equal(t, fmt.Sprintf(f, n.bigInt()), fmt.Sprintf(f, n), "format %q of %s", f, n)
+		}
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	for _, n := range []Num{FromInt64(42), MinInt128} {
+		b.Run(n.String(), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				_, _ = fmt.Fprintf(io.Discard, "%d", n)
+			}
+		})
+	}
+}
+
+func TestUnsignedString(t *testing.T) {
+	for _, tc := range []struct {
+		n        Num
+		expected string
+	}{
+		{FromInt64(0), "0"},
+		{FromInt64(1), "1"},
+		{FromUint64(math.MaxUint64), "18446744073709551615"},
+		{New(1, 0), "18446744073709551616"},
+		{MaxInt128, "170141183460469231731687303715884105727"},
+		{MinInt128, "170141183460469231731687303715884105728"},
+		{FromInt64(-1), "340282366920938463463374607431768211455"},
+		{FromInt64(-2), "340282366920938463463374607431768211454"},
+	} {
+		require.Equal(t, tc.expected, tc.n.UnsignedString(), "%s", tc.n)
+	}
+
+	two128 := new(big.Int).Lsh(big.NewInt(1), 128)
+	for _, n := range randomNums(t, 200) {
+		expected := n.bigInt()
+		if expected.Sign() < 0 {
+			expected.Add(expected, two128)
+		}
+		require.Equal(t, expected.String(), n.UnsignedString(), "%s", n)
+		if !n.IsNegative() {
+			require.Equal(t, n.String(), n.UnsignedString())
+		}
+	}
+}
+
+func TestHexString(t *testing.T) {
+	require.Equal(t, "00000000000000000000000000000000", FromInt64(0).HexString())
+	require.Equal(t, "00000000000000000000000000000001", FromInt64(1).HexString())
+	require.Equal(t, "ffffffffffffffffffffffffffffffff", FromInt64(-1).HexString())
+	require.Equal(t, "7fffffffffffffffffffffffffffffff", MaxInt128.HexString())
+	require.Equal(t, "80000000000000000000000000000000", MinInt128.HexString())
+	require.Equal(t, "0000000000000000ffffffffffffffff", FromUint64(math.MaxUint64).HexString())
+	require.Equal(t, "00000000000000010000000000000000", Shl(FromInt64(1), 64).HexString())
+	require.Equal(t, fmt.Sprintf("%032x", MaxInt64), MaxInt64.HexString())
+}
+
+func TestParseHex(t *testing.T) {
+	for _, expected := range [...]Num{
+		MinInt128,
+		MaxInt128,
+		FromInt64(0),
+		FromInt64(-1),
+		FromInt64(1),
+		MinInt64,
+		MaxInt64,
+		FromUint64(math.MaxUint64),
+		Shl(FromInt64(1), 64),
+	} {
+		actual, ok := ParseHex(expected.HexString())
+		require.True(t, ok, "%s", expected)
+		require.Equal(t, expected, actual)
+
+		actual, ok = ParseHex("0x" + expected.HexString())
+		require.True(t, ok, "%s", expected)
+		require.Equal(t, expected, actual)
+	}
+	for range 1000 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		n := FromBigEndian(input)
+		actual, ok := ParseHex(n.HexString())
+		require.True(t, ok, "%s", n)
+		require.Equal(t, n, actual)
+	}
+
+	tests := map[string]Num{
+		"0":                                  FromInt64(0),
+		"1":                                  FromInt64(1),
+		"0xA":                                FromInt64(10),
+		"0Xff":                               FromInt64(255),
+		"DeadBeef":                           FromInt64(0xdeadbeef),
+		"ffffffffffffffff":                   FromUint64(math.MaxUint64),
+		"10000000000000000":                  Shl(FromInt64(1), 64),
+		"ffffffffffffffffffffffffffffffff":   FromInt64(-1),
+		"0x80000000000000000000000000000000": MinInt128,
+	}
+	for input, expected := range tests {
+		actual, ok := ParseHex(input)
+		require.True(t, ok, input)
+		require.Equal(t, expected, actual, input)
+	}
+
+	for _, input := range []string{
+		"",
+		"0x",
+		"x1",
+		"-1",
+		"0x-1",
+		"0xg",
+		" 1",
+		"100000000000000000000000000000000",
+		"0x100000000000000000000000000000000",
+	} {
+		_, ok := ParseHex(input)
+		require.False(t, ok, input)
+	}
+}
+
+func TestInterface(t *testing.T) {
+	require.Equal(t, int64(0), FromInt64(0).Interface())
+	require.Equal(t, int64(-1), FromInt64(-1).Interface())
+	require.Equal(t, int64(math.MaxInt64), MaxInt64.Interface())
+	require.Equal(t, int64(math.MinInt64), MinInt64.Interface())
+	require.Equal(t, "9223372036854775808", Add(MaxInt64, FromInt64(1)).Interface())
+	require.Equal(t, "-9223372036854775809", Sub(MinInt64, FromInt64(1)).Interface())
+	require.Equal(t, "18446744073709551615", FromUint64(math.MaxUint64).Interface())
+	require.Equal(t, "170141183460469231731687303715884105727", MaxInt128.Interface())
+	require.Equal(t, "-170141183460469231731687303715884105728", MinInt128.Interface())
+}
+
 func TestByteWidth(t *testing.T) {
 	tests := [][2]int64{
 		{0, 1},
@@ -437,6 +1019,53 @@ func TestFitsInPrec(t *testing.T) {
 	require.True(t, n.FitsInPrecision(38), snowflakeNumberTiny)
 }
 
+func TestNumDigits(t *testing.T) {
+	require.Equal(t, 1, FromInt64(0).NumDigits())
+	require.Equal(t, 1, FromInt64(1).NumDigits())
+	require.Equal(t, 1, FromInt64(-9).NumDigits())
+	require.Equal(t, 2, FromInt64(10).NumDigits())
+	require.Equal(t, 19, MaxInt64.NumDigits())
+	require.Equal(t, 19, MinInt64.NumDigits())
+	require.Equal(t, 20, FromUint64(math.MaxUint64).NumDigits())
+	require.Equal(t, 38, MustParse("99999999999999999999999999999999999999").NumDigits())
+	require.Equal(t, 38, MustParse("-99999999999999999999999999999999999999").NumDigits())
+	require.Equal(t, 39, MaxInt128.NumDigits())
+	require.Equal(t, 39, MinInt128.NumDigits())
+
+	for d, p := range Pow10Table {
+		require.Equal(t, d+1, p.NumDigits(), "10^%d", d)
+		require.Equal(t, d+1, Neg(p).NumDigits(), "-10^%d", d)
+		if d > 0 {
+			require.Equal(t, d, Sub(p, FromInt64(1)).NumDigits(), "10^%d-1", d)
+			require.Equal(t, d, Neg(Sub(p, FromInt64(1))).NumDigits(), "-(10^%d-1)", d)
+		}
+		if d < 38 {
+			require.True(t, p.FitsInPrecision(int32(p.NumDigits())))
+			require.False(t, p.FitsInPrecision(int32(p.NumDigits()-1)))
+		}
+	}
+	for range 1000 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		n := FromBigEndian(input)
+		// Cover smaller magnitudes as well as those near the limits
+		n = uShr(n, uint(input[0]&0x7F))
+		expected := len(new(big.Int).Abs(n.bigInt()).String())
+		require.Equal(t, expected, n.NumDigits(), "%s", n)
+	}
+}
+
+func BenchmarkNumDigits(b *testing.B) {
+	for _, n := range []Num{FromInt64(42), MaxInt64, MinInt128} {
+		b.Run(n.String(), func(b *testing.B) {
+			for b.Loop() {
+				_ = n.NumDigits()
+			}
+		})
+	}
+}
+
 func TestToBytes(t *testing.T) {
 	for range 100 {
 		input := make([]byte, 16)
@@ -450,3 +1079,61 @@ func TestToBytes(t *testing.T) {
 		require.Equal(t, input, cloned) // Make sure cloned isn't mutated
 	}
 }
+
+func TestKey(t *testing.T) {
+	for range 100 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		n := FromBigEndian(input)
+		k := n.Key()
+		require.Equal(t, input, k[:])
+		require.Equal(t, n, FromKey(k))
+	}
+
+	set := map[[16]byte]struct{}{}
+	for _, n := range []Num{FromInt64(-1), FromInt64(1), MinInt128, MaxInt128, FromUint64(math.MaxUint64)} {
+		set[n.Key()] = struct{}{}
+	}
+	require.Len(t, set, 5)
+	for _, n := range []Num{FromInt64(-1), FromInt64(1), MinInt128, MaxInt128, FromUint64(math.MaxUint64)} {
+		require.Contains(t, set, n.Key())
+	}
+	// Values that only differ by their sign are distinct keys.
+	require.NotContains(t, set, Neg(FromUint64(math.MaxUint64)).Key())
+	require.NotContains(t, set, FromInt64(0).Key())
+	require.NotContains(t, set, Neg(MaxInt128).Key())
+	require.Contains(t, set, Sub(FromInt64(0), FromInt64(1)).Key())
+	require.Equal(t, [16]byte{15: 0x01}, FromInt64(1).Key())
+	require.Equal(t, [16]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, FromInt64(-1).Key())
+}
+
+func TestSplitInt64(t *testing.T) {
+	tests := []struct {
+		n      Num
+		hi, lo int64
+	}{
+		{FromInt64(0), 0, 0},
+		{FromInt64(1), 0, 1},
+		{FromInt64(-1), -1, -1},
+		{FromInt64(math.MinInt64), -1, math.MinInt64},
+		{FromUint64(math.MaxUint64), 0, -1},
+		{Neg(FromUint64(math.MaxUint64)), -1, 1},
+		{MaxInt128, math.MaxInt64, -1},
+		{MinInt128, math.MinInt64, 0},
+	}
+	for _, test := range tests {
+		hi, lo := test.n.SplitInt64()
+		require.Equal(t, test.hi, hi, test.n.String())
+		require.Equal(t, test.lo, lo, test.n.String())
+		require.Equal(t, test.n, JoinInt64(hi, lo), test.n.String())
+	}
+
+	for range 100 {
+		input := make([]byte, 16)
+		_, err := rand.Read(input)
+		require.NoError(t, err)
+		n := FromBigEndian(input)
+		require.Equal(t, n, JoinInt64(n.SplitInt64()))
+	}
+}
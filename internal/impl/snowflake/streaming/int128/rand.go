@@ -0,0 +1,46 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import "math/rand/v2"
+
+// Rand returns a value uniformly distributed over the full range of an
+// Int128 using the given source of randomness, which makes it suitable for
+// reproducible property tests when r is seeded.
+func Rand(r *rand.Rand) Num {
+	return New(int64(r.Uint64()), r.Uint64())
+}
+
+// RandN returns a value uniformly distributed in the half-open interval
+// [0, n) using the given source of randomness.
+//
+// RandN panics if n <= 0.
+func RandN(r *rand.Rand, n Num) Num {
+	if Compare(n, Num{}) <= 0 {
+		panic("invalid argument to RandN")
+	}
+	last := Sub(n, FromInt64(1))
+	if last.IsZero() {
+		return Num{}
+	}
+	// Mask random values to the bit length of n - 1 and reject any values
+	// that are out of range, which avoids the bias of taking a modulus. At
+	// least half of the masked values are in range.
+	bitLen := uint(fls128(last) + 1)
+	mask := Sub(Shl(FromInt64(1), bitLen), FromInt64(1))
+	for {
+		v := Rand(r)
+		v = Num{hi: v.hi & mask.hi, lo: v.lo & mask.lo}
+		if Compare(v, last) <= 0 {
+			return v
+		}
+	}
+}
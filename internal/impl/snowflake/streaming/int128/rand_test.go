@@ -0,0 +1,84 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRand(t *testing.T) {
+	const samples = 10_000
+	r := rand.New(rand.NewPCG(1, 2))
+
+	// Each bit should be set in roughly half of the samples, which would not
+	// be the case if values were biased towards zero.
+	var setBits [128]int
+	negative := 0
+	for range samples {
+		n := Rand(r)
+		if n.IsNegative() {
+			negative++
+		}
+		for i := range 64 {
+			setBits[i] += int(n.lo >> i & 1)
+			setBits[64+i] += int(uint64(n.hi) >> i & 1)
+		}
+	}
+	require.InDelta(t, samples/2, negative, samples/20)
+	for i, count := range setBits {
+		require.InDelta(t, samples/2, count, samples/20, "bit %d", i)
+	}
+
+	// The same seed produces the same values
+	a, b := rand.New(rand.NewPCG(3, 4)), rand.New(rand.NewPCG(3, 4))
+	for range 100 {
+		require.Equal(t, Rand(a), Rand(b))
+	}
+}
+
+func TestRandN(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+
+	require.Equal(t, Num{}, RandN(r, FromInt64(1)))
+	require.Panics(t, func() { RandN(r, Num{}) })
+	require.Panics(t, func() { RandN(r, FromInt64(-5)) })
+
+	for _, n := range []Num{
+		FromInt64(10),
+		MaxInt64,
+		FromUint64(1 << 63),
+		Add(MaxInt64, FromInt64(3)),
+		MaxInt128,
+	} {
+		half := Div(n, FromInt64(2))
+		upper := 0
+		for range 2_000 {
+			v := RandN(r, n)
+			require.False(t, v.IsNegative(), "%s from %s", v, n)
+			require.True(t, Less(v, n), "%s from %s", v, n)
+			if !Less(v, half) {
+				upper++
+			}
+		}
+		require.InDelta(t, 1_000, upper, 150, "%s", n)
+	}
+
+	var counts [10]int
+	for range 10_000 {
+		counts[RandN(r, FromInt64(10)).ToInt64()]++
+	}
+	for i, count := range counts {
+		require.InDelta(t, 1_000, count, 150, "value %d", i)
+	}
+}
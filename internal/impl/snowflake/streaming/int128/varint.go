@@ -0,0 +1,71 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import "errors"
+
+// MaxVarintLen is the maximum number of bytes of a varint encoded Int128.
+const MaxVarintLen = 19
+
+var (
+	errVarintTruncated = errors.New("int128: truncated varint")
+	errVarintOverflow  = errors.New("int128: varint overflows a 128-bit integer")
+)
+
+// AppendVarint appends the varint encoding of an Int128 to b. Values are
+// zigzag encoded so that those of a small magnitude, whether positive or
+// negative, are encoded in fewer bytes, and then written seven bits at a time
+// with the high bit of each byte set when more bytes follow, in the same way as
+// binary.AppendVarint. The encoding takes between 1 and MaxVarintLen bytes.
+func (i Num) AppendVarint(b []byte) []byte {
+	// zigzag: (i << 1) ^ (i >> 127)
+	sign := uint64(i.hi >> 63)
+	hi := (uint64(i.hi)<<1 | i.lo>>63) ^ sign
+	lo := i.lo<<1 ^ sign
+	for hi != 0 || lo >= 0x80 {
+		b = append(b, byte(lo)|0x80)
+		lo = lo>>7 | hi<<57
+		hi >>= 7
+	}
+	return append(b, byte(lo))
+}
+
+// Varint decodes an Int128 encoded by AppendVarint from the start of b,
+// returning the value and the number of bytes read. An error is returned if b
+// ends before the encoding does or if the encoding overflows an Int128.
+func Varint(b []byte) (Num, int, error) {
+	var hi, lo uint64
+	for n, c := range b {
+		if n == MaxVarintLen-1 && c > 3 {
+			// The final byte holds only the two highest bits.
+			return Num{}, 0, errVarintOverflow
+		}
+		v := uint64(c & 0x7f)
+		shift := uint(7 * n)
+		if shift < 64 {
+			lo |= v << shift
+			if shift > 57 {
+				hi |= v >> (64 - shift)
+			}
+		} else {
+			hi |= v << (shift - 64)
+		}
+		if c < 0x80 {
+			// unzigzag: (z >> 1) ^ -(z & 1)
+			sign := -(lo & 1)
+			return Num{
+				hi: int64(hi>>1 ^ sign),
+				lo: (lo>>1 | hi<<63) ^ sign,
+			}, n + 1, nil
+		}
+	}
+	return Num{}, 0, errVarintTruncated
+}
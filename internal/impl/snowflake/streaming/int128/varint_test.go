@@ -0,0 +1,149 @@
+/*
+ * Copyright 2024 Redpanda Data, Inc.
+ *
+ * Licensed as a Redpanda Enterprise file under the Redpanda Community
+ * License (the "License"); you may not use this file except in compliance with
+ * the License. You may obtain a copy of the License at
+ *
+ * https://github.com/redpanda-data/redpanda/blob/master/licenses/rcl.md
+ */
+
+package int128
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarint(t *testing.T) {
+	tests := []struct {
+		n    Num
+		size int
+	}{
+		{FromInt64(0), 1},
+		{FromInt64(-1), 1},
+		{FromInt64(1), 1},
+		{FromInt64(63), 1},
+		{FromInt64(-64), 1},
+		{FromInt64(64), 2},
+		{FromInt64(-65), 2},
+		{MaxInt64, 10},
+		{MinInt64, 10},
+		{Add(MaxInt64, one), 10},
+		{FromUint64(math.MaxUint64), 10},
+		{Neg(FromUint64(math.MaxUint64)), 10},
+		{MaxInt128, MaxVarintLen},
+		{MinInt128, MaxVarintLen},
+	}
+	for _, tc := range tests {
+		t.Run(tc.n.String(), func(t *testing.T) {
+			b := tc.n.AppendVarint([]byte{0xff})
+			require.Len(t, b, 1+tc.size)
+			n, read, err := Varint(b[1:])
+			require.NoError(t, err)
+			require.Equal(t, tc.size, read)
+			require.Equal(t, tc.n, n)
+		})
+	}
+
+	// Small values match the encoding of the standard library.
+	for _, v := range []int64{0, 1, -1, 300, -300, math.MaxInt64, math.MinInt64} {
+		require.Equal(t, binary.AppendVarint(nil, v), FromInt64(v).AppendVarint(nil), "%d", v)
+	}
+}
+
+func TestVarintRoundTrip(t *testing.T) {
+	var buf []byte
+	var nums []Num
+	for range 10000 {
+		n := New(rand.Int64(), rand.Uint64())
+		switch rand.N(4) {
+		case 0:
+			n = FromInt64(rand.Int64())
+		case 1:
+			n = FromInt64(rand.Int64N(1000) - 500)
+		case 2:
+			n = New(rand.Int64N(1<<10)-(1<<9), rand.Uint64())
+		}
+		nums = append(nums, n)
+		buf = n.AppendVarint(buf)
+	}
+	for _, expected := range nums {
+		n, read, err := Varint(buf)
+		require.NoError(t, err)
+		require.Equal(t, expected, n)
+		buf = buf[read:]
+	}
+	require.Empty(t, buf)
+}
+
+func TestVarintMalformed(t *testing.T) {
+	overflow := bytes.Repeat([]byte{0xff}, MaxVarintLen-1)
+	tests := []struct {
+		name  string
+		input []byte
+		err   string
+	}{
+		{"empty", nil, "int128: truncated varint"},
+		{"continuation", []byte{0x80}, "int128: truncated varint"},
+		{"truncated max", MaxInt128.AppendVarint(nil)[:MaxVarintLen-1], "int128: truncated varint"},
+		{"overflow", append(overflow, 0x04), "int128: varint overflows a 128-bit integer"},
+		{"too long", append(overflow, 0x80, 0x00), "int128: varint overflows a 128-bit integer"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Varint(tc.input)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+
+	// The largest final byte is accepted.
+	n, read, err := Varint(append(overflow, 0x03))
+	require.NoError(t, err)
+	require.Equal(t, MaxVarintLen, read)
+	require.Equal(t, MinInt128, n)
+}
+
+func BenchmarkVarint(b *testing.B) {
+	nums := make([]Num, 1024)
+	for i := range nums {
+		nums[i] = FromInt64(rand.Int64N(1 << 20))
+	}
+	b.Run("varint", func(b *testing.B) {
+		buf := make([]byte, 0, len(nums)*MaxVarintLen)
+		b.ReportAllocs()
+		for b.Loop() {
+			buf = buf[:0]
+			for _, n := range nums {
+				buf = n.AppendVarint(buf)
+			}
+			for rest := buf; len(rest) > 0; {
+				_, read, err := Varint(rest)
+				if err != nil {
+					b.Fatal(err)
+				}
+				rest = rest[read:]
+			}
+		}
+		b.ReportMetric(float64(len(buf))/float64(len(nums)), "bytes/value")
+	})
+	b.Run("big_endian", func(b *testing.B) {
+		buf := make([]byte, 0, len(nums)*16)
+		b.ReportAllocs()
+		for b.Loop() {
+			buf = buf[:0]
+			for _, n := range nums {
+				buf = n.AppendBigEndian(buf)
+			}
+			for rest := buf; len(rest) > 0; rest = rest[16:] {
+				_ = FromBigEndian(rest)
+			}
+		}
+		b.ReportMetric(float64(len(buf))/float64(len(nums)), "bytes/value")
+	})
+}